@@ -0,0 +1,144 @@
+// Package anomaly groups the traces of a run by their shape, and flags the
+// traces of rare shapes, see cmd/trace-anomalies, e.g. to find the few
+// broken executions among thousands of healthy ones.
+//
+// The shape of a trace is the sequences of the tags of the actions of each
+// of its tracers, in the order of their clocks, sorted, e.g.
+// "CreateTrace Put GenerateTokenTrace | ReceiveTokenTrace PutRecvd". It
+// does not depend on the identities of the tracers, unless
+// Options.Tracers is set, nor on the order of their concurrent actions.
+package anomaly
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Options are options of shapes.
+type Options struct {
+	// Tracers, if set, includes the identities of the tracers in the
+	// shapes, e.g. "client1: CreateTrace Put | server1: PutRecvd".
+	Tracers bool
+	// Collapse, if set, collapses the consecutive actions of a tracer with
+	// the same tag, e.g. retries, into one, marked +, e.g. "Put+".
+	Collapse bool
+}
+
+// Shape returns the shape of the trace of records.
+func Shape(records []tracing.TraceRecord, options Options) string {
+	byTracer := make(map[string][]tracing.TraceRecord)
+	for _, record := range records {
+		byTracer[record.TracerIdentity] = append(byTracer[record.TracerIdentity], record)
+	}
+	var sequences []string
+	for tracer, records := range byTracer {
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].VectorClock[tracer] < records[j].VectorClock[tracer]
+		})
+		var tags []string
+		for i, record := range records {
+			if options.Collapse && i > 0 && record.Tag == records[i-1].Tag {
+				if last := tags[len(tags)-1]; !strings.HasSuffix(last, "+") {
+					tags[len(tags)-1] = last + "+"
+				}
+				continue
+			}
+			tags = append(tags, record.Tag)
+		}
+		sequence := strings.Join(tags, " ")
+		if options.Tracers {
+			sequence = tracer + ": " + sequence
+		}
+		sequences = append(sequences, sequence)
+	}
+	sort.Strings(sequences)
+	return strings.Join(sequences, " | ")
+}
+
+// Cluster is the traces of a shape.
+type Cluster struct {
+	Shape  string
+	Traces []uint64 // in the order of their first record
+}
+
+// Clusters returns the clusters of the traces of records, the largest
+// first, then by shape.
+func Clusters(records []tracing.TraceRecord, options Options) []Cluster {
+	var ids []uint64
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			ids = append(ids, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+
+	var clusters []Cluster
+	index := make(map[string]int)
+	for _, id := range ids {
+		shape := Shape(byTrace[id], options)
+		i, ok := index[shape]
+		if !ok {
+			i = len(clusters)
+			index[shape] = i
+			clusters = append(clusters, Cluster{Shape: shape})
+		}
+		clusters[i].Traces = append(clusters[i].Traces, id)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Traces) != len(clusters[j].Traces) {
+			return len(clusters[i].Traces) > len(clusters[j].Traces)
+		}
+		return clusters[i].Shape < clusters[j].Shape
+	})
+	return clusters
+}
+
+// Outliers returns the clusters of clusters whose share of the traces is
+// below threshold, e.g. 0.01 for the shapes of less than 1% of the traces.
+func Outliers(clusters []Cluster, threshold float64) []Cluster {
+	traces := 0
+	for _, cluster := range clusters {
+		traces += len(cluster.Traces)
+	}
+	var outliers []Cluster
+	for _, cluster := range clusters {
+		if float64(len(cluster.Traces)) < threshold*float64(traces) {
+			outliers = append(outliers, cluster)
+		}
+	}
+	return outliers
+}
+
+// Write writes clusters to w, with their share of the traces, then lists
+// the traces of the outliers among them, for review, and a summary.
+func Write(w io.Writer, clusters, outliers []Cluster) error {
+	traces := 0
+	for _, cluster := range clusters {
+		traces += len(cluster.Traces)
+	}
+	for _, cluster := range clusters {
+		share := 100 * float64(len(cluster.Traces)) / float64(traces)
+		if _, err := fmt.Fprintf(w, "%d traces (%.1f%%): %s\n", len(cluster.Traces), share, cluster.Shape); err != nil {
+			return err
+		}
+	}
+	outlierTraces := 0
+	for _, cluster := range outliers {
+		outlierTraces += len(cluster.Traces)
+		if _, err := fmt.Fprintf(w, "\nrare shape: %s\n", cluster.Shape); err != nil {
+			return err
+		}
+		for _, id := range cluster.Traces {
+			if _, err := fmt.Fprintf(w, "\ttrace %d\n", id); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d traces in %d shapes, %d outliers in %d rare shapes\n", traces, len(clusters), outlierTraces, len(outliers))
+	return err
+}
@@ -0,0 +1,63 @@
+package anomaly
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestClusters(t *testing.T) {
+	var broken *tracing.Trace
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		// 9 healthy traces, passing a token from either client to the other,
+		// and a broken one, whose token is never received
+		for i := 0; i < 9; i++ {
+			from, to := client1, client2
+			if i%2 == 1 {
+				from, to = client2, client1
+			}
+			trace := from.CreateTrace()
+			trace.RecordAction(TestAction{Foo: "foo"})
+			to.ReceiveToken(trace.GenerateToken()).RecordAction(TestAction{Foo: "bar"})
+		}
+		broken = client1.CreateTrace()
+		broken.RecordAction(TestAction{Foo: "foo"})
+		broken.RecordAction(TestAction{Foo: "foo"})
+		broken.GenerateToken()
+	})
+
+	clusters := Clusters(records, Options{})
+	if len(clusters) != 2 || len(clusters[0].Traces) != 9 {
+		t.Fatalf("expected a cluster of the 9 healthy traces, got %v", clusters)
+	}
+	if shape := clusters[0].Shape; shape != "CreateTrace TestAction GenerateTokenTrace | ReceiveTokenTrace TestAction" {
+		t.Fatalf("unexpected healthy shape %q", shape)
+	}
+	outliers := Outliers(clusters, 0.2)
+	if len(outliers) != 1 || len(outliers[0].Traces) != 1 || outliers[0].Traces[0] != broken.ID {
+		t.Fatalf("expected the broken trace to be an outlier, got %v", outliers)
+	}
+
+	if clusters := Clusters(records, Options{Tracers: true}); len(clusters) != 3 {
+		t.Fatalf("expected the healthy traces to split by sender, got %v", clusters)
+	}
+	clusters = Clusters(records, Options{Collapse: true})
+	if shape := clusters[1].Shape; shape != "CreateTrace TestAction+ GenerateTokenTrace" {
+		t.Fatalf("unexpected collapsed shape %q", shape)
+	}
+
+	var buffer bytes.Buffer
+	if err := Write(&buffer, clusters, outliers); err != nil {
+		t.Fatal(err)
+	}
+	if summary := buffer.String(); !strings.HasSuffix(summary, "10 traces in 2 shapes, 1 outliers in 1 rare shapes\n") {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+}
@@ -0,0 +1,200 @@
+package tracing
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// Audit events, see AuditEntry.
+const (
+	AuditConnect    = "connect"
+	AuditDisconnect = "disconnect"
+	AuditRefuse     = "refuse"
+	AuditClose      = "close" // the server closed before the connection
+)
+
+// AuditEntry is an entry of the audit log of a server (see
+// TracingServerConfig.AuditLogFile), one JSON object per line, to
+// investigate suspected forgeries or misattributed records. Each
+// connection has an entry when it connects, or is refused, and when it
+// disconnects, or the server closes, with the requests its tracer made.
+type AuditEntry struct {
+	Event         string // AuditConnect, AuditDisconnect, AuditRefuse or AuditClose
+	Time          time.Time
+	RemoteAddress string
+	Connected     time.Time `json:",omitempty"` // when the connection was accepted, on disconnection or close
+
+	// Authentication is how the tracer authenticated: "none",
+	// "handshake" (see TracingServerConfig.Secret) or "certificate" (see
+	// ClientCAFile), as Identity.
+	Authentication string
+	Identity       string `json:",omitempty"`
+	Reason         string `json:",omitempty"` // why the connection was refused
+
+	// the number of records accepted and rejected, and of clock requests,
+	// by the identity they claimed, on disconnection or close
+	Records  map[string]uint64 `json:",omitempty"`
+	Rejected map[string]uint64 `json:",omitempty"`
+	LastVCs  map[string]uint64 `json:",omitempty"`
+}
+
+// auditLog writes the audit log of a server.
+type auditLog struct {
+	lock    sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	open    map[*connectionAudit]struct{} // the connections not closed yet
+}
+
+// openAuditLog opens the audit log fileName, which is only ever appended
+// to.
+func openAuditLog(fileName string) (*auditLog, error) {
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{
+		file:    file,
+		encoder: json.NewEncoder(file),
+		open:    make(map[*connectionAudit]struct{}),
+	}, nil
+}
+
+// writeLocked writes entry, unless the log is closed.
+func (l *auditLog) writeLocked(entry AuditEntry) {
+	if l.file == nil {
+		return
+	}
+	if err := l.encoder.Encode(entry); err != nil {
+		log.Print("error writing the audit log: ", err)
+	}
+}
+
+// refuse writes the entry of the connection of audit, refused for reason.
+func (l *auditLog) refuse(audit *connectionAudit, reason string) {
+	entry := audit.entry(AuditRefuse)
+	entry.Reason = reason
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.writeLocked(entry)
+}
+
+// connect writes the entry of the connection of audit, once accepted.
+func (l *auditLog) connect(audit *connectionAudit) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.open[audit] = struct{}{}
+	l.writeLocked(audit.entry(AuditConnect))
+}
+
+// disconnect writes the entry of the connection of audit, once closed,
+// unless the server closed first.
+func (l *auditLog) disconnect(audit *connectionAudit) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.open[audit]; ok {
+		delete(l.open, audit)
+		l.writeLocked(audit.summary(AuditDisconnect))
+	}
+}
+
+// Close writes the entries of the connections still open, and closes the
+// log.
+func (l *auditLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for audit := range l.open {
+		l.writeLocked(audit.summary(AuditClose))
+	}
+	l.open = nil
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// connectionAudit keeps track of the requests of a connection, for its
+// entry in the audit log.
+type connectionAudit struct {
+	conn           net.Conn
+	connected      time.Time
+	authentication string
+	identity       string
+
+	lock     sync.Mutex
+	records  map[string]uint64
+	rejected map[string]uint64
+	lastVCs  map[string]uint64
+}
+
+func newConnectionAudit(conn net.Conn) *connectionAudit {
+	return &connectionAudit{
+		conn:           conn,
+		connected:      time.Now(),
+		authentication: "none",
+		records:        make(map[string]uint64),
+		rejected:       make(map[string]uint64),
+		lastVCs:        make(map[string]uint64),
+	}
+}
+
+func (a *connectionAudit) entry(event string) AuditEntry {
+	return AuditEntry{
+		Event:          event,
+		Time:           time.Now(),
+		RemoteAddress:  a.conn.RemoteAddr().String(),
+		Authentication: a.authentication,
+		Identity:       a.identity,
+	}
+}
+
+// summary returns the entry of event, with the requests of the connection.
+func (a *connectionAudit) summary(event string) AuditEntry {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	entry := a.entry(event)
+	entry.Connected = a.connected
+	entry.Records = copyCounts(a.records)
+	entry.Rejected = copyCounts(a.rejected)
+	entry.LastVCs = copyCounts(a.lastVCs)
+	return entry
+}
+
+func copyCounts(counts map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(counts))
+	for key, count := range counts {
+		c[key] = count
+	}
+	return c
+}
+
+// auditHandler counts the requests of a connection.
+type auditHandler struct {
+	TransportHandler
+	audit *connectionAudit
+}
+
+func (h auditHandler) RecordAction(arg RecordActionArg) error {
+	err := h.TransportHandler.RecordAction(arg)
+	h.audit.lock.Lock()
+	if err == nil {
+		h.audit.records[arg.TracerIdentity]++
+	} else {
+		h.audit.rejected[arg.TracerIdentity]++
+	}
+	h.audit.lock.Unlock()
+	return err
+}
+
+func (h auditHandler) GetLastVC(arg string) (vclock.VClock, error) {
+	identity, _ := splitIdentity(arg)
+	h.audit.lock.Lock()
+	h.audit.lastVCs[identity]++
+	h.audit.lock.Unlock()
+	return h.TransportHandler.GetLastVC(arg)
+}
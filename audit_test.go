@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	auditLogFile := filepath.Join(dir, "audit.log")
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:   ":0",
+		Secret:       []byte("course secret"),
+		InMemory:     true,
+		AuditLogFile: auditLogFile,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	for _, config := range []TracerConfig{
+		{TracerIdentity: "client1", Secret: []byte("course secret")},
+		{TracerIdentity: "client2", Secret: []byte("other secret")},
+	} {
+		config.ServerAddress = server.Listener.Addr().String()
+		if tracer := NewTracerNonFatal(config); tracer != nil {
+			tracer.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+			tracer.Close()
+		}
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(auditLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []AuditEntry
+	for decoder := json.NewDecoder(file); decoder.More(); {
+		var entry AuditEntry
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	// client1 connects, and disconnects (unless the server closed first)
+	// with its records, and client2 is refused
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %v", entries)
+	}
+	if entry := entries[0]; entry.Event != AuditConnect || entry.Identity != "client1" || entry.Authentication != "handshake" {
+		t.Fatalf("unexpected connection entry %+v", entry)
+	}
+	var summary, refusal AuditEntry
+	for _, entry := range entries[1:] {
+		if entry.Event == AuditRefuse {
+			refusal = entry
+		} else {
+			summary = entry
+		}
+	}
+	if summary.Event != AuditDisconnect && summary.Event != AuditClose ||
+		summary.Identity != "client1" || summary.Records["client1"] != uint64(len(server.Records())) ||
+		summary.LastVCs["client1"] != 1 || len(summary.Rejected) != 0 {
+		t.Fatalf("unexpected disconnection entry %+v", summary)
+	}
+	if refusal.Identity != "client2" || refusal.Reason != errHandshake.Error() {
+		t.Fatalf("unexpected refusal entry %+v", refusal)
+	}
+}
@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+var errAuthentication = errors.New("authentication failed: missing or invalid MAC for the key of the tracer")
+
+// macWriter writes the fields of a message to a MAC, each length-prefixed
+// so that no two messages share an encoding.
+type macWriter struct {
+	hash.Hash
+}
+
+func (w macWriter) writeUint(n uint64) {
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], n)
+	w.Write(number[:])
+}
+
+func (w macWriter) writeBytes(data []byte) {
+	w.writeUint(uint64(len(data)))
+	w.Write(data)
+}
+
+func (w macWriter) writeVectorClock(vc map[string]uint64) {
+	ids := make([]string, 0, len(vc))
+	for id := range vc {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	w.writeUint(uint64(len(ids)))
+	for _, id := range ids {
+		w.writeBytes([]byte(id))
+		w.writeUint(vc[id])
+	}
+}
+
+// recordMAC returns the HMAC-SHA256, keyed with secret, of the fields of arg
+// the server records.
+func recordMAC(secret []byte, arg RecordActionArg) []byte {
+	mac := macWriter{hmac.New(sha256.New, secret)}
+	mac.writeBytes([]byte("RecordAction"))
+	mac.writeBytes([]byte(arg.TracerIdentity))
+	mac.writeUint(arg.TraceID)
+	mac.writeBytes([]byte(arg.RecordName))
+	mac.writeBytes(arg.Record)
+	mac.writeVectorClock(arg.VectorClock)
+	mac.writeBytes([]byte(arg.Compression))
+	mac.writeUint(arg.Session)
+	mac.writeUint(arg.Seq)
+	if arg.Striped {
+		// not written otherwise, for the MACs of tracers unaware of it
+		mac.writeBytes([]byte("Striped"))
+	}
+	return mac.Sum(nil)
+}
+
+// signRecord sets the MAC of arg for secret, if any.
+func signRecord(secret []byte, arg *RecordActionArg) {
+	arg.MAC = nil
+	if len(secret) > 0 {
+		arg.MAC = recordMAC(secret, *arg)
+	}
+}
+
+// identityMAC returns the HMAC-SHA256, keyed with secret, of a request of
+// the last vector clock of identity.
+func identityMAC(secret []byte, identity string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("GetLastVC\x00" + identity))
+	return mac.Sum(nil)
+}
+
+// signIdentity returns the argument of GetLastVC requesting the last vector
+// clock of identity: identity itself, followed, if secret is set, by a NUL
+// byte and the hex-encoded MAC of the request, which GetLastVC carries
+// over all transports.
+func signIdentity(secret []byte, identity string) string {
+	if len(secret) == 0 {
+		return identity
+	}
+	return identity + "\x00" + hex.EncodeToString(identityMAC(secret, identity))
+}
+
+// splitIdentity splits an argument of GetLastVC into the identity and the
+// MAC of the request, if any, see signIdentity.
+func splitIdentity(arg string) (string, []byte) {
+	i := strings.IndexByte(arg, 0)
+	if i < 0 {
+		return arg, nil
+	}
+	mac, err := hex.DecodeString(arg[i+1:])
+	if err != nil {
+		return arg[:i], nil
+	}
+	return arg[:i], mac
+}
+
+// credentials are the keys tracers sign their requests with: the key of
+// their identity in identities, if registered, and secret otherwise.
+type credentials struct {
+	secret     []byte
+	identities map[string][]byte
+}
+
+// key returns the key of identity, if any, and whether identity may send
+// requests: with a registry of identities and no secret, only registered
+// identities may.
+func (c credentials) key(identity string) ([]byte, bool) {
+	if key, ok := c.identities[identity]; ok {
+		return key, true
+	}
+	return c.secret, len(c.identities) == 0 || len(c.secret) > 0
+}
+
+// enabled returns whether tracers must authenticate.
+func (c credentials) enabled() bool {
+	return len(c.secret) > 0 || len(c.identities) > 0
+}
+
+func (tracingServer *TracingServer) credentials() credentials {
+	return credentials{secret: tracingServer.Config.Secret, identities: tracingServer.Config.Identities}
+}
+
+// authenticateRecord checks that arg is signed with the key of its tracer,
+// if any.
+func (tracingServer *TracingServer) authenticateRecord(arg RecordActionArg) error {
+	key, ok := tracingServer.credentials().key(arg.TracerIdentity)
+	if !ok {
+		return fmt.Errorf("unregistered tracer identity %q", arg.TracerIdentity)
+	}
+	if len(key) > 0 && !hmac.Equal(arg.MAC, recordMAC(key, arg)) {
+		return errAuthentication
+	}
+	return nil
+}
+
+// authenticateIdentity returns the identity a GetLastVC request with arg
+// is for, checking that it is signed with the key of the identity, if any,
+// so that tracers cannot fetch, and resume from, the clocks of others.
+func (tracingServer *TracingServer) authenticateIdentity(arg string) (string, error) {
+	identity, mac := splitIdentity(arg)
+	key, ok := tracingServer.credentials().key(identity)
+	if !ok {
+		return identity, fmt.Errorf("unregistered tracer identity %q", identity)
+	}
+	if len(key) > 0 && !hmac.Equal(mac, identityMAC(key, identity)) {
+		return identity, errAuthentication
+	}
+	return identity, nil
+}
@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSecret(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		Secret:     []byte("course secret"),
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	for _, config := range []TracerConfig{
+		{TracerIdentity: "client1", Secret: []byte("course secret")},
+		{TracerIdentity: "client2", Secret: []byte("other secret")},
+		{TracerIdentity: "client3"},
+	} {
+		config.ServerAddress = server.Listener.Addr().String()
+		tracer := NewTracerNonFatal(config)
+		if tracer == nil {
+			// refused by the handshake
+			if config.TracerIdentity != "client2" {
+				t.Fatalf("%s could not connect", config.TracerIdentity)
+			}
+			continue
+		}
+		if config.TracerIdentity == "client2" {
+			t.Fatal("the handshake accepted a tracer with another secret")
+		}
+		tracer.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+		tracer.Close()
+	}
+
+	// only a tracer with the secret resumes from its last clock
+	for identity, secret := range map[string][]byte{"client1": []byte("course secret"), "client2": nil} {
+		handler := transportHandler{server: server}
+		if _, err := handler.GetLastVC(signIdentity(secret, identity)); (err == nil) != (identity == "client1") {
+			t.Fatalf("unexpected error getting the last clock of %s: %v", identity, err)
+		}
+	}
+
+	// a relay with the secret signs the records it forwards, and its
+	// requests of clocks
+	relay := NewTracingServer(TracingServerConfig{
+		ServerBind:      ":0",
+		Secret:          []byte("course secret"),
+		UpstreamAddress: server.Listener.Addr().String(),
+	})
+	if err := relay.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go relay.Accept()
+	tracer := NewTracer(TracerConfig{
+		ServerAddress:  relay.Listener.Addr().String(),
+		TracerIdentity: "client1",
+		Secret:         []byte("course secret"),
+		Compression:    CompressionGzip,
+	})
+	if vc := tracer.logger.GetCurrentVC(); !cmp.Equal(vc, vclock.VClock{"client1": 2}) {
+		t.Fatalf("tracer clock %v does not resume from the last recorded one", vc)
+	}
+	tracer.CreateTrace().RecordAction(TestAction{Foo: strings.Repeat("x", 2*minCompressedRecordSize)})
+	tracer.Close()
+	if err := relay.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	records := server.Records()
+	if len(records) != 4 {
+		t.Fatalf("expected the 4 records of client1, got %v", records)
+	}
+	for _, record := range records {
+		if record.TracerIdentity != "client1" {
+			t.Fatalf("unexpected record from %s", record.TracerIdentity)
+		}
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		Identities: map[string][]byte{"server1": []byte("server1 key"), "client1": []byte("client1 key")},
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	tracer := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "server1",
+		Secret:         []byte("server1 key"),
+	})
+	tracer.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+	tracer.Close()
+
+	// client1 cannot pass as server1, nor can an unregistered tracer
+	// connect
+	for _, config := range []TracerConfig{
+		{TracerIdentity: "server1", Secret: []byte("client1 key")},
+		{TracerIdentity: "client2", Secret: []byte("client1 key")},
+	} {
+		config.ServerAddress = server.Listener.Addr().String()
+		if tracer := NewTracerNonFatal(config); tracer != nil {
+			tracer.Close()
+			t.Fatalf("the handshake accepted %s with the key %q", config.TracerIdentity, config.Secret)
+		}
+	}
+	handler := transportHandler{server: server}
+	arg := RecordActionArg{
+		TracerIdentity: "server1",
+		TraceID:        1,
+		RecordName:     "TestAction",
+		Record:         []byte(`{"Foo":"spoofed"}`),
+		VectorClock:    map[string]uint64{"server1": 10},
+	}
+	signRecord([]byte("client1 key"), &arg)
+	if err := handler.RecordAction(arg); err == nil {
+		t.Fatal("recorded the action of server1 signed with the key of client1")
+	}
+	if _, err := handler.GetLastVC(signIdentity([]byte("client1 key"), "server1")); err == nil {
+		t.Fatal("returned the clock of server1 to client1")
+	}
+	if vc, err := handler.GetLastVC(signIdentity([]byte("server1 key"), "server1")); err != nil || vc["server1"] != 2 {
+		t.Fatalf("unexpected clock %v of server1: %v", vc, err)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if records := server.Records(); len(records) != 2 {
+		t.Fatalf("expected the 2 records of server1, got %v", records)
+	}
+}
+
+func TestReplayedRecords(t *testing.T) {
+	secret := []byte("course secret")
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		Secret:     secret,
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	// captured requests replayed, or reordered, are dropped
+	handler := transportHandler{server: server}
+	for _, seq := range []uint64{1, 2, 2, 1, 3} {
+		arg := RecordActionArg{
+			TracerIdentity: "client1",
+			RecordName:     "TestAction",
+			Record:         []byte(`{"Foo":"foo"}`),
+			VectorClock:    vclock.VClock{"client1": seq},
+			Session:        42,
+			Seq:            seq,
+		}
+		signRecord(secret, &arg)
+		if err := handler.RecordAction(arg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	arg := RecordActionArg{TracerIdentity: "client1", RecordName: "TestAction", Record: []byte(`{}`)}
+	signRecord(secret, &arg)
+	if err := handler.RecordAction(arg); err != errUnnumberedRecord {
+		t.Fatalf("expected unnumbered records to be rejected, got %v", err)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var seqs []uint64
+	for _, record := range server.Records() {
+		seqs = append(seqs, record.TracerSeq)
+	}
+	if !cmp.Equal(seqs, []uint64{1, 2, 3}) {
+		t.Fatalf("expected the records 1, 2 and 3, got %v", seqs)
+	}
+	if replayed := server.ReplayedRecords(); replayed["client1"] != 2 {
+		t.Fatalf("expected 2 replayed records, got %v", replayed)
+	}
+}
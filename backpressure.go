@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSlowDown is returned by a server to the records it receives while the
+// records queued for writing exceed its MaxQueuedRecords. The records are
+// not recorded, and tracers send them again after pacing themselves, see
+// isSlowDown; relays send again the records of a batch after those
+// RecordActions recorded.
+var ErrSlowDown = errors.New("tracing server overloaded, slow down")
+
+// Bounds of the pacing of a tracer told to slow down.
+const (
+	minPace            = time.Millisecond
+	maxPace            = time.Second
+	maxSlowDownRetries = 20
+)
+
+// isSlowDown returns whether err is ErrSlowDown, as returned by the server
+// through any transport, which only carry the message of errors.
+func isSlowDown(err error) bool {
+	return err != nil && (err == ErrSlowDown || strings.Contains(err.Error(), ErrSlowDown.Error()))
+}
+
+// checkBackpressure returns ErrSlowDown if the writer is saturated. It is
+// checked before the rate limit and the sequence number of records, as the
+// records it rejects are sent again.
+func (tracingServer *TracingServer) checkBackpressure() error {
+	max := tracingServer.Config.MaxQueuedRecords
+	q := tracingServer.queue
+	if max <= 0 || q == nil {
+		return nil
+	}
+	if atomic.LoadInt32(&q.pending) <= int32(max) {
+		return nil
+	}
+	atomic.AddUint32(&tracingServer.slowDowns, 1)
+	return ErrSlowDown
+}
+
+// logSlowDowns reports the records rejected with ErrSlowDown at Close.
+func (tracingServer *TracingServer) logSlowDowns() {
+	if n := atomic.LoadUint32(&tracingServer.slowDowns); n > 0 {
+		log.Printf("told tracers to slow down %d times, with more than %d records queued", n, tracingServer.Config.MaxQueuedRecords)
+	}
+}
+
+// pacer paces the records a tracer sends to a server telling it to slow
+// down: the delay before each send doubles every time the server does, and
+// halves with every record accepted, until it vanishes.
+type pacer struct {
+	lock  sync.Mutex
+	delay time.Duration
+}
+
+// wait sleeps for the current delay.
+func (p *pacer) wait() {
+	p.lock.Lock()
+	delay := p.delay
+	p.lock.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+func (p *pacer) slowDown() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.delay *= 2
+	if p.delay < minPace {
+		p.delay = minPace
+	} else if p.delay > maxPace {
+		p.delay = maxPace
+	}
+}
+
+func (p *pacer) speedUp() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.delay /= 2
+	if p.delay < minPace {
+		p.delay = 0
+	}
+}
+
+// send sends arg over the i-th connection of the tracer, paced, and sends
+// it again whenever the server tells the tracer to slow down, up to
+// maxSlowDownRetries times.
+func (tracer *Tracer) send(i int, arg RecordActionArg) error {
+	for retries := 0; ; retries++ {
+		tracer.pacer.wait()
+		err := tracer.getConn(i).Send(arg)
+		if !isSlowDown(err) {
+			if err == nil {
+				tracer.pacer.speedUp()
+			}
+			return err
+		}
+		if retries == maxSlowDownRetries {
+			return err
+		}
+		tracer.pacer.slowDown()
+	}
+}
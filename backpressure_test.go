@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+func TestPacer(t *testing.T) {
+	var p pacer
+	p.slowDown()
+	if p.delay != minPace {
+		t.Fatalf("delay %v after slowing down once, want %v", p.delay, minPace)
+	}
+	for i := 0; i < 20; i++ {
+		p.slowDown()
+	}
+	if p.delay != maxPace {
+		t.Fatalf("delay %v after slowing down repeatedly, want %v", p.delay, maxPace)
+	}
+	for i := 0; i < 20; i++ {
+		p.speedUp()
+	}
+	if p.delay != 0 {
+		t.Fatalf("delay %v after speeding up repeatedly, want 0", p.delay)
+	}
+}
+
+func TestSlowDown(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		InMemory:         true,
+		MaxQueuedRecords: 10,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+
+	// saturate the writer for a while: the tracer is told to slow down,
+	// and sends its record again until it is accepted
+	atomic.AddInt32(&server.queue.pending, 100)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&server.queue.pending, -100)
+	}()
+	start := time.Now()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("record accepted after %v, while the writer was saturated", elapsed)
+	}
+	if client.pacer.delay == 0 {
+		t.Error("the tracer did not pace its records")
+	}
+	if atomic.LoadUint32(&server.slowDowns) == 0 {
+		t.Error("no record rejected with ErrSlowDown")
+	}
+	client.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := server.Records()
+	if len(records) != 2 || records[1].Tag != "TestAction" {
+		t.Fatalf("expected CreateTrace and the TestAction sent again, got %v", records)
+	}
+}
+
+// slowConn records the records it is sent, but tells the sender to slow
+// down at the second record, once.
+type slowConn struct {
+	lock       sync.Mutex
+	recorded   []RecordActionArg
+	slowedDown bool
+}
+
+func (c *slowConn) Send(arg RecordActionArg) error {
+	_, err := c.SendBatch([]RecordActionArg{arg})
+	return err
+}
+
+func (c *slowConn) SendBatch(args []RecordActionArg) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, arg := range args {
+		if len(c.recorded) == 1 && !c.slowedDown {
+			c.slowedDown = true
+			return i, ErrSlowDown
+		}
+		c.recorded = append(c.recorded, arg)
+	}
+	return len(args), nil
+}
+
+func (c *slowConn) GetLastVC(identity string) (vclock.VClock, error) {
+	return nil, ErrLastVCUnsupported
+}
+
+func (c *slowConn) Close() error {
+	return nil
+}
+
+func TestRelaySlowDown(t *testing.T) {
+	// the relay never dials the upstream server, it is connected already
+	conn := &slowConn{}
+	r := newRelay("127.0.0.1:0", 0, credentials{})
+	r.connLock.Lock()
+	r.conn = conn
+	r.connLock.Unlock()
+	for i := uint64(1); i <= 5; i++ {
+		r.enqueue(RecordActionArg{TracerIdentity: "client1", Seq: i})
+	}
+	if err := r.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the records recorded before the server told the relay to slow down
+	// are not sent again
+	if !conn.slowedDown || len(conn.recorded) != 5 {
+		t.Fatalf("expected the 5 records recorded once, after slowing down, got %v", conn.recorded)
+	}
+	for i, arg := range conn.recorded {
+		if arg.Seq != uint64(i+1) {
+			t.Fatalf("expected the records recorded once, in order, got %v", conn.recorded)
+		}
+	}
+}
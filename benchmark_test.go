@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// startBenchmarkServer starts a server keeping its records in memory, and
+// returns a tracer connected to it.
+func startBenchmarkServer(b *testing.B) (*TracingServer, *Tracer) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: "127.0.0.1:0",
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		b.Fatal(err)
+	}
+	go server.Accept()
+	tracer := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	tracer.SetShouldPrint(false)
+	return server, tracer
+}
+
+func BenchmarkRecordAction(b *testing.B) {
+	server, tracer := startBenchmarkServer(b)
+	trace := tracer.CreateTrace()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trace.RecordAction(TestAction{Foo: "foo"})
+	}
+	b.StopTimer()
+	tracer.Close()
+	server.Close()
+}
+
+func BenchmarkRecordActionParallel(b *testing.B) {
+	server, tracer := startBenchmarkServer(b)
+	tracer.Close()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		tracer := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+		})
+		tracer.SetShouldPrint(false)
+		trace := tracer.CreateTrace()
+		for pb.Next() {
+			trace.RecordAction(TestAction{Foo: "foo"})
+		}
+		tracer.Close()
+	})
+	b.StopTimer()
+	server.Close()
+}
+
+func BenchmarkGetLogString(b *testing.B) {
+	tracer := &Tracer{identity: "client1"}
+	trace := &Trace{ID: 42}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tracer.getLogString(trace, TestAction{Foo: "foo"})
+	}
+}
+
+func BenchmarkGenerateToken(b *testing.B) {
+	for _, bm := range []struct {
+		name   string
+		config TracerConfig
+	}{
+		{"JSON", TracerConfig{}},
+		{"Compact", TracerConfig{CompactTokens: true}},
+		{"Protobuf", TracerConfig{ProtobufTokens: true}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			server, tracer := startBenchmarkServer(b)
+			tracer.Close()
+			config := bm.config
+			config.ServerAddress = server.Listener.Addr().String()
+			config.TracerIdentity = "client1"
+			tracer = NewTracer(config)
+			tracer.SetShouldPrint(false)
+			trace := tracer.CreateTrace()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				trace.GenerateToken()
+			}
+			b.StopTimer()
+			tracer.Close()
+			server.Close()
+		})
+	}
+}
+
+func BenchmarkRecordFileSink(b *testing.B) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	record := TraceRecord{
+		TracerIdentity: "client1",
+		TraceID:        42,
+		Tag:            "TestAction",
+		Body:           []byte(`{"Foo":"foo"}`),
+		VectorClock:    map[string]uint64{"client1": 2, "client2": 3},
+		Seq:            1,
+	}
+	for _, bm := range []struct {
+		name       string
+		binary     bool
+		flushEvery int
+	}{
+		{"JSON", false, 0},
+		{"Binary", true, 0},
+		{"JSONBuffered", false, 1000},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			file, err := os.Create(filepath.Join(dir, bm.name+".log"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			sink, err := newRecordFileSink(file, bm.binary, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if bm.flushEvery > 0 {
+				sink.bufferWrites(bm.flushEvery)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := sink.Write(record); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			if err := sink.Close(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
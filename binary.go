@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing/tracingpb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// The formats of the output file, see TracingServerConfig.OutputFormat.
+const (
+	OutputFormatJSON   = "json"
+	OutputFormatBinary = "binary"
+)
+
+var errOutputFormat = errors.New("unknown output format")
+
+// binaryTracerSeqField is the field of the messages of the binary format
+// holding TraceRecord.TracerSeq, which RecordActionArg does not define.
+const binaryTracerSeqField = 15
+
+func (record TraceRecord) toProto() *tracingpb.RecordActionArg {
+	message := &tracingpb.RecordActionArg{
+		TracerIdentity: record.TracerIdentity,
+		TraceId:        record.TraceID,
+		RecordName:     record.Tag,
+		Record:         record.Body,
+		VectorClock:    record.VectorClock,
+	}
+	if record.TracerSeq != 0 {
+		field := protowire.AppendTag(nil, binaryTracerSeqField, protowire.VarintType)
+		message.ProtoReflect().SetUnknown(protowire.AppendVarint(field, record.TracerSeq))
+	}
+	return message
+}
+
+func traceRecordFromProto(message *tracingpb.RecordActionArg) TraceRecord {
+	record := TraceRecord{
+		TracerIdentity: message.TracerIdentity,
+		TraceID:        message.TraceId,
+		Tag:            message.RecordName,
+		Body:           message.Record,
+		VectorClock:    message.VectorClock,
+	}
+	for unknown := message.ProtoReflect().GetUnknown(); len(unknown) > 0; {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			break
+		}
+		unknown = unknown[n:]
+		if num == binaryTracerSeqField && typ == protowire.VarintType {
+			seq, n := protowire.ConsumeVarint(unknown)
+			if n < 0 {
+				break
+			}
+			record.TracerSeq = seq
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, unknown); n < 0 {
+			break
+		}
+		unknown = unknown[n:]
+	}
+	return record
+}
+
+// BinaryRecordReader reads an output file written in the binary format,
+// which is much cheaper for the server to encode than JSON: each record is
+// a tracingpb.RecordActionArg message (with the tag as record_name, the
+// JSON body as record, and TracerSeq as the extra field 15), prefixed with
+// its varint-encoded length. It reads every record, the header of the file
+// included (see OutputHeader), unlike NewRecordReader.
+type BinaryRecordReader struct {
+	reader *bufio.Reader
+	offset int64 // the offset of the end of the last record read
+}
+
+// NewBinaryRecordReader returns a reader of the records in r.
+func NewBinaryRecordReader(r io.Reader) *BinaryRecordReader {
+	return &BinaryRecordReader{reader: bufio.NewReader(r)}
+}
+
+// Read returns the next record. It returns io.EOF after the last one, and
+// io.ErrUnexpectedEOF if the input ends with a partial record, e.g. one a
+// server crashed while writing.
+func (r *BinaryRecordReader) Read() (TraceRecord, error) {
+	n, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return TraceRecord{}, err
+	}
+	if n > maxProtobufMessageSize {
+		return TraceRecord{}, errProtobufMessageSize
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r.reader, buf); err == io.EOF {
+		return TraceRecord{}, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return TraceRecord{}, err
+	}
+	var record tracingpb.RecordActionArg
+	if err := proto.Unmarshal(buf, &record); err != nil {
+		return TraceRecord{}, err
+	}
+	r.offset += int64(protowire.SizeVarint(n)) + int64(n)
+	return traceRecordFromProto(&record), nil
+}
+
+// resumeBinaryRecordFile is resumeRecordFile for an output file in the
+// binary format.
+func (tracingServer *TracingServer) resumeBinaryRecordFile(recordFile *os.File, lastVCs map[string]vclock.VClock, index *indexWriter) error {
+	reader := NewBinaryRecordReader(recordFile)
+	for {
+		start := reader.offset
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err == io.ErrUnexpectedEOF {
+			log.Printf("truncating the partial record at the end of %s", recordFile.Name())
+			return recordFile.Truncate(reader.offset)
+		} else if err != nil {
+			return err
+		}
+		if _, ok := outputHeaderOf(record); ok {
+			continue
+		}
+		if index != nil {
+			if err := index.write(record, start); err != nil {
+				return err
+			}
+		}
+		identity := record.TracerIdentity
+		if last, ok := lastVCs[identity]; !ok || record.VectorClock[identity] >= last[identity] {
+			lastVCs[identity] = record.VectorClock
+		}
+		tracingServer.recordSeq++
+	}
+}
@@ -0,0 +1,121 @@
+// Package bolt provides a storage sink for tracing servers in an
+// embedded bbolt key-value store, where the records of a trace are stored
+// contiguously, for fast per-trace retrieval and range scans:
+// 	store, err := bolt.Open("traces.db")
+// 	...
+// 	tracing.TracingServerConfig{
+// 		...
+// 		Sinks: []tracing.Sink{store},
+// 	}
+// The database can be read while the server runs by opening it read-only
+// with bbolt.Open.
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+
+	"github.com/DistributedClocks/tracing"
+	"go.etcd.io/bbolt"
+)
+
+// RecordsBucket is the bucket the records are stored in, keyed by the
+// big-endian trace ID followed by the big-endian sequence number of the
+// record, so that the records of a trace are sorted by sequence number.
+// Values are JSON-encoded tracing.TraceRecord instances.
+var RecordsBucket = []byte("records")
+
+// Store stores records in a bbolt database.
+type Store struct {
+	db *bbolt.DB
+}
+
+// Open opens the bbolt database file, creating it if needed.
+func Open(file string) (*Store, error) {
+	db, err := bbolt.Open(file, 0666, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(RecordsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// DB returns the database of the store.
+func (s *Store) DB() *bbolt.DB {
+	return s.db
+}
+
+// Key returns the key of the seq-th record, of the trace traceID.
+func Key(traceID, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, traceID)
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// Write stores record, in a transaction of its own.
+func (s *Store) Write(record tracing.TraceRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(RecordsBucket).Put(Key(record.TraceID, record.Seq), value)
+	})
+}
+
+// Flush syncs the database file. Transactions are synced as they commit,
+// unless the database's NoSync option is set.
+func (s *Store) Flush() error {
+	return s.db.Sync()
+}
+
+// Scan calls fn with the records of the trace traceID whose sequence
+// numbers are in [from, to], in order, until fn returns an error, which
+// Scan returns.
+func (s *Store) Scan(traceID, from, to uint64, fn func(seq uint64, record tracing.TraceRecord) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(RecordsBucket).Cursor()
+		for key, value := cursor.Seek(Key(traceID, from)); key != nil; key, value = cursor.Next() {
+			if binary.BigEndian.Uint64(key) != traceID {
+				break
+			}
+			seq := binary.BigEndian.Uint64(key[8:])
+			if seq > to {
+				break
+			}
+			record := tracing.TraceRecord{Seq: seq}
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if err := fn(seq, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TraceRecords returns the records of a trace, in the order they were
+// received, which is consistent with causality.
+func (s *Store) TraceRecords(traceID uint64) ([]tracing.TraceRecord, error) {
+	var records []tracing.TraceRecord
+	err := s.Scan(traceID, 0, math.MaxUint64, func(seq uint64, record tracing.TraceRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,96 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "traces.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		Sinks:            []tracing.Sink{store},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client1 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client2.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+	received := client2.ReceiveToken(trace.GenerateToken())
+	received.RecordAction(TestAction{Foo: "baz"})
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = Open(filepath.Join(dir, "traces.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	records, err := store.TraceRecords(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		tracer, tag string
+	}{
+		{"client1", "CreateTrace"},
+		{"client1", "TestAction"},
+		{"client1", "GenerateTokenTrace"},
+		{"client2", "ReceiveTokenTrace"},
+		{"client2", "TestAction"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %v", len(expected), records)
+	}
+	for i, record := range records {
+		if record.TracerIdentity != expected[i].tracer || record.Tag != expected[i].tag {
+			t.Fatalf("expected record %d to be %v, got %v", i, expected[i], record)
+		}
+	}
+
+	// the records of the other trace are numbered 3 and 4
+	var seqs []uint64
+	err = store.Scan(trace.ID, 3, 5, func(seq uint64, record tracing.TraceRecord) error {
+		seqs = append(seqs, seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != 1 || seqs[0] != 5 {
+		t.Fatalf("expected the 5th record only, got %v", seqs)
+	}
+}
@@ -0,0 +1,110 @@
+package check
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Causality checks that the vector clocks of records are consistent with
+// the happens-before relation they record. records must be in the order of
+// the output file of the server, which receives the records of each tracer
+// in order. The violations it reports are:
+//   - regressions: a record of a tracer whose clock is not after the one of
+//     its previous record;
+//   - skips: a record of a tracer whose own entry is more than one after
+//     the one of its previous record, which records each tick of its clock,
+//     so that records are missing in between;
+//   - receptions before sending: the reception of a token whose clock is
+//     not after the one of the generation of the token, or the one the
+//     token carries.
+func Causality(records []tracing.TraceRecord) []Violation {
+	var violations []Violation
+	last := make(map[string]tracing.TraceRecord) // the previous record of each tracer
+	generations := make(map[uint64]tracing.TraceRecord)
+	for _, record := range records {
+		identity := record.TracerIdentity
+		if previous, ok := last[identity]; ok {
+			if v, ok := checkSuccession(previous, record); ok {
+				violations = append(violations, v)
+			}
+		}
+		last[identity] = record
+		if record.Tag == "GenerateTokenTrace" {
+			generations[events.RecordID(record)] = record
+		}
+	}
+
+	for _, record := range records {
+		violations = append(violations, checkReceptions(record, generations)...)
+	}
+	return violations
+}
+
+// checkReceptions returns the violations of the receptions of tokens in
+// record, if any, by the generations of tokens by their ID.
+func checkReceptions(record tracing.TraceRecord, generations map[uint64]tracing.TraceRecord) []Violation {
+	var violations []Violation
+	for _, token := range events.ReceivedTokens(record) {
+		generation, ok := generations[events.ID(token.Tracer, token.VectorClock[token.Tracer])]
+		if ok && !after(record.VectorClock, generation.VectorClock) {
+			violations = append(violations, Violation{
+				Check:   "causality",
+				Message: fmt.Sprintf("%s received a token of %s before it was generated", record.TracerIdentity, token.Tracer),
+				Records: []tracing.TraceRecord{generation, record},
+			})
+		} else if !ok && !after(record.VectorClock, token.VectorClock) {
+			violations = append(violations, Violation{
+				Check:   "causality",
+				Message: fmt.Sprintf("%s received a token of %s before the clock it carries, %s", record.TracerIdentity, token.Tracer, token.VectorClock.ReturnVCString()),
+				Records: []tracing.TraceRecord{record},
+			})
+		}
+	}
+	return violations
+}
+
+// checkSuccession returns the violation of record succeeding previous, the
+// previous record of its tracer, if any.
+func checkSuccession(previous, record tracing.TraceRecord) (Violation, bool) {
+	identity := record.TracerIdentity
+	v := Violation{Check: "causality", Records: []tracing.TraceRecord{previous, record}}
+	own, previousOwn := record.VectorClock[identity], previous.VectorClock[identity]
+	if own <= previousOwn {
+		v.Message = fmt.Sprintf("the clock of %s regressed from %d to %d", identity, previousOwn, own)
+		return v, true
+	}
+
+	var regressed []string
+	for tracer, ticks := range previous.VectorClock {
+		if record.VectorClock[tracer] < ticks {
+			regressed = append(regressed, fmt.Sprintf("%s from %d to %d", tracer, ticks, record.VectorClock[tracer]))
+		}
+	}
+	if len(regressed) > 0 {
+		sort.Strings(regressed)
+		v.Message = fmt.Sprintf("the clock of %s regressed in the entries of %s", identity, strings.Join(regressed, ", "))
+		return v, true
+	}
+
+	if own > previousOwn+1 {
+		v.Message = fmt.Sprintf("the clock of %s skipped from %d to %d: %d records are missing", identity, previousOwn, own, own-previousOwn-1)
+		return v, true
+	}
+	return v, false
+}
+
+// after returns whether the event of clock a is after the one of clock b,
+// or the same: whether every entry of a is at least the one of b.
+func after(a, b vclock.VClock) bool {
+	for tracer, ticks := range b {
+		if a[tracer] < ticks {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,16 @@
+// Package check checks the records of a tracing server for anomalies, from
+// misuse of the library to lost or tampered records, see cmd/trace-check.
+// Each check returns the violations it finds, with the offending records.
+//
+// Importing the package also registers online versions of the checks with
+// tracing.RegisterChecker, for servers to run them as records arrive: the
+// "causality", "duplicates", "orphans" and "stalls" checkers, the
+// "statemachine:FILE" checker of the StateMachine in the JSON file FILE,
+// and the "rules:FILE" checker of the rules in FILE, see ParseRules and
+// tracing.TracingServerConfig.Checkers.
+package check
+
+import "github.com/DistributedClocks/tracing"
+
+// Violation is an anomaly found by a check.
+type Violation = tracing.Violation
@@ -0,0 +1,527 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+type Put struct {
+	Key string
+}
+
+type PutResult struct {
+	Key string
+}
+
+type TraceEnd struct{}
+
+type GetRequest struct{}
+
+type GetResponse struct{}
+
+// runRecords returns the records of a run where client1 creates a trace,
+// records an action and sends a token to client2, which records an action.
+func runRecords(t *testing.T) []tracing.TraceRecord {
+	return tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(TestAction{Foo: "foo"})
+		client2.ReceiveToken(trace.GenerateToken()).RecordAction(TestAction{Foo: "bar"})
+	})
+}
+
+// withClock returns record with its vector clock replaced by vc.
+func withClock(record tracing.TraceRecord, vc map[string]uint64) tracing.TraceRecord {
+	record.VectorClock = vclock.VClock(vc)
+	return record
+}
+
+func TestCausality(t *testing.T) {
+	records := runRecords(t)
+	if violations := Causality(records); len(violations) != 0 {
+		t.Fatalf("expected no violation, got %v", violations)
+	}
+
+	for _, test := range []struct {
+		name    string
+		records []tracing.TraceRecord
+		message string
+	}{
+		{
+			name:    "regression",
+			records: []tracing.TraceRecord{records[0], records[1], withClock(records[2], map[string]uint64{"client1": 2})},
+			message: "the clock of client1 regressed from 2 to 2",
+		},
+		{
+			name:    "entry regression",
+			records: []tracing.TraceRecord{records[3], withClock(records[4], map[string]uint64{"client1": 1, "client2": 2})},
+			message: "the clock of client2 regressed in the entries of client1 from 3 to 1",
+		},
+		{
+			name:    "skip",
+			records: []tracing.TraceRecord{records[0], records[2]},
+			message: "the clock of client1 skipped from 1 to 3: 1 records are missing",
+		},
+		{
+			name:    "reception before sending",
+			records: []tracing.TraceRecord{records[2], withClock(records[3], map[string]uint64{"client1": 2, "client2": 1})},
+			message: "client2 received a token of client1 before it was generated",
+		},
+		{
+			name:    "reception before the clock of the token",
+			records: []tracing.TraceRecord{withClock(records[3], map[string]uint64{"client2": 1})},
+			message: "client2 received a token of client1 before the clock it carries",
+		},
+	} {
+		violations := Causality(test.records)
+		if len(violations) != 1 || !strings.HasPrefix(violations[0].Message, test.message) {
+			t.Fatalf("%s: expected the violation %q, got %v", test.name, test.message, violations)
+		}
+	}
+}
+
+func TestOrphanTokens(t *testing.T) {
+	records := runRecords(t)
+	if violations := OrphanTokens(records); len(violations) != 0 {
+		t.Fatalf("expected no violation, got %v", violations)
+	}
+
+	for _, test := range []struct {
+		name    string
+		records []tracing.TraceRecord
+		message string
+	}{
+		{
+			name:    "never received",
+			records: records[:3],
+			message: "client1 generated a token in trace",
+		},
+		{
+			name:    "never generated",
+			records: records[3:],
+			message: "client2 received a token of client1 in trace",
+		},
+	} {
+		violations := OrphanTokens(test.records)
+		if len(violations) != 1 || !strings.HasPrefix(violations[0].Message, test.message) {
+			t.Fatalf("%s: expected the violation %q, got %v", test.name, test.message, violations)
+		}
+	}
+}
+
+func TestDuplicateTraceIDs(t *testing.T) {
+	records := runRecords(t)
+	if violations := DuplicateTraceIDs(records); len(violations) != 0 {
+		t.Fatalf("expected no violation, got %v", violations)
+	}
+
+	duplicate := records[0]
+	duplicate.TracerIdentity = "client2"
+	duplicate.VectorClock = map[string]uint64{"client2": 1}
+	violations := DuplicateTraceIDs(append(records, duplicate))
+	message := fmt.Sprintf("trace %d was created 2 times, by client1, client2", records[0].TraceID)
+	if len(violations) != 1 || violations[0].Message != message || len(violations[0].Records) != 2 {
+		t.Fatalf("expected the violation %q, got %v", message, violations)
+	}
+}
+
+func TestStalls(t *testing.T) {
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace = client2.ReceiveToken(trace.GenerateToken())
+		trace.RecordAction(Put{Key: "a"})
+
+		trace = client1.CreateTrace()
+		trace.RecordAction(Put{Key: "b"})
+		trace.GenerateToken()
+
+		trace = client1.CreateTrace()
+		trace.GenerateToken()
+		trace.RecordAction(Put{Key: "c"})
+	})
+
+	var stalled uint64
+	for _, record := range records {
+		if string(record.Body) == `{"Key":"b"}` {
+			stalled = record.TraceID
+		}
+	}
+	violations := Stalls(records)
+	if len(violations) != 1 || violations[0].Records[0].Tag != "GenerateTokenTrace" || violations[0].Records[0].TraceID != stalled {
+		t.Fatalf("expected a stall of the second trace, got %v", violations)
+	}
+
+	checker := &stallsChecker{newStalls()}
+	for _, record := range records {
+		if violations := checker.Check(record); len(violations) != 0 {
+			t.Fatalf("expected no violation before the end, got %v", violations)
+		}
+	}
+	if online := checker.Finish(); len(online) != 1 || online[0].Message != violations[0].Message {
+		t.Fatalf("expected the violations %v online, got %v", violations, online)
+	}
+}
+
+func TestRules(t *testing.T) {
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(Put{Key: "a"})
+		trace.RecordAction(Put{Key: "b"})
+		trace = client2.ReceiveToken(trace.GenerateToken())
+		trace.RecordAction(PutResult{Key: "a"})
+		trace.RecordAction(TraceEnd{})
+		trace.RecordAction(PutResult{Key: "b"})
+	})
+
+	rules, err := ParseRules(strings.NewReader(`
+# every put must complete
+Put followed by PutResult with same Key before TraceEnd
+PutResult preceded by Put with same Key
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Rule{
+		Every("Put").FollowedBy("PutResult").WithSame("Key").Before("TraceEnd"),
+		Every("PutResult").PrecededBy("Put").WithSame("Key"),
+	}
+	if len(rules) != 2 || rules[0].String() != expected[0].String() || rules[1].String() != expected[1].String() {
+		t.Fatalf("expected the rules %v, got %v", expected, rules)
+	}
+
+	violations := rules[0].Check(records)
+	if len(violations) != 1 || violations[0].Records[0].Tag != "Put" || string(violations[0].Records[0].Body) != `{"Key":"b"}` {
+		t.Fatalf("expected a violation for the put of b, got %v", violations)
+	}
+	if violations := rules[1].Check(records); len(violations) != 0 {
+		t.Fatalf("expected no violation, got %v", violations)
+	}
+
+	// causal edges between tracers
+	rule, err := ParseRule("Put on client* followed by PutResult on client2 with same Key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := Every("Put").On("client*").FollowedBy("PutResult").On("client2").WithSame("Key"); rule.String() != expected.String() {
+		t.Fatalf("expected the rule %v, got %v", expected, rule)
+	}
+	if violations := rule.Check(records); len(violations) != 0 {
+		t.Fatalf("expected no violation, got %v", violations)
+	}
+	if violations := Every("Put").FollowedBy("PutResult").On("client1").Check(records); len(violations) != 2 {
+		t.Fatalf("expected a violation for each put, got %v", violations)
+	}
+	if violations := Every("PutResult").On("client1").PrecededBy("Put").Check(records); len(violations) != 0 {
+		t.Fatalf("expected no violation, got %v", violations)
+	}
+
+	for _, rule := range []string{
+		"Put followed PutResult",
+		"Put followed by PutResult with same",
+		"Put preceded by PutResult before TraceEnd",
+		"Put[ followed by PutResult",
+		"Put on followed by PutResult",
+		"Put followed by PutResult on client[",
+	} {
+		if _, err := ParseRule(rule); err == nil {
+			t.Fatalf("expected an error parsing %q", rule)
+		}
+	}
+}
+
+func TestTemporal(t *testing.T) {
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(GetRequest{})
+		trace = client2.ReceiveToken(trace.GenerateToken())
+		trace.RecordAction(GetResponse{})
+		trace.RecordAction(GetRequest{})
+	})
+
+	formulas, err := ParseFormulas(strings.NewReader(`
+# every request is answered
+always (GetRequest -> eventually GetResponse)
+!GetResponse until GetRequest
+eventually (GetResponse && eventually GetRequest*) || always !Get*
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Formula{
+		Always(Implies(Tag("GetRequest"), Eventually(Tag("GetResponse")))),
+		Until(Not(Tag("GetResponse")), Tag("GetRequest")),
+		Or(Eventually(And(Tag("GetResponse"), Eventually(Tag("GetRequest*")))), Always(Not(Tag("Get*")))),
+	}
+	for i, f := range formulas {
+		if i >= len(expected) || f.String() != expected[i].String() {
+			t.Fatalf("expected the formulas %v, got %v", expected, formulas)
+		}
+	}
+
+	violations := CheckFormula(formulas[0], records)
+	if len(violations) != 1 || violations[0].Records[0].TracerIdentity != "client2" {
+		t.Fatalf("expected a violation for the request of client2, got %v", violations)
+	}
+	for _, f := range formulas[1:] {
+		if violations := CheckFormula(f, records); len(violations) != 0 {
+			t.Fatalf("expected no violation of %v, got %v", f, violations)
+		}
+	}
+	if violations := CheckFormula(Until(Not(Tag("GetRequest")), Tag("GetResponse")), records); len(violations) != 1 {
+		t.Fatalf("expected a violation, got %v", violations)
+	}
+
+	for _, f := range []string{"", "always", "(GetRequest", "GetRequest)", "GetRequest -> && GetResponse", "Get["} {
+		if _, err := ParseFormula(f); err == nil {
+			t.Fatalf("expected an error parsing %q", f)
+		}
+	}
+}
+
+func TestOnlineCheckers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reportFile := filepath.Join(dir, "report.log")
+
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind:      ":0",
+		Checkers:        []string{"causality", "duplicates", "orphans"},
+		CheckReportFile: reportFile,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client.CreateTrace().GenerateToken() // never received
+	client.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var violation Violation
+	if err := json.Unmarshal(data, &violation); err != nil {
+		t.Fatal(err)
+	}
+	if violation.Check != "orphans" || !strings.HasPrefix(violation.Message, "client1 generated a token") {
+		t.Fatalf("expected a single orphan token, got %s", data)
+	}
+
+	rulesFile := filepath.Join(dir, "rules.txt")
+	if err := ioutil.WriteFile(rulesFile, []byte("Put on client1 followed by PutResult on client2 with same Key\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	server = tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind:      ":0",
+		Checkers:        []string{"rules:" + rulesFile},
+		CheckReportFile: reportFile,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client1 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	trace.RecordAction(Put{Key: "a"})
+	trace.RecordAction(Put{Key: "b"})
+	client2.ReceiveToken(trace.GenerateToken()).RecordAction(PutResult{Key: "a"})
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = ioutil.ReadFile(reportFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &violation); err != nil {
+		t.Fatal(err)
+	}
+	if violation.Check != "rules" || len(violation.Records) != 1 || string(violation.Records[0].Body) != `{"Key":"b"}` {
+		t.Fatalf("expected a violation for the put of b, got %s", data)
+	}
+}
+
+const stateMachine = `{
+	"Initial": "Idle",
+	"Final": ["Idle"],
+	"Transitions": [
+		{"From": "Idle", "Action": "CreateTrace", "To": "Idle"},
+		{"From": "Idle", "Action": "Put", "To": "Putting"},
+		{"From": "Putting", "Action": "PutResult", "To": "Idle"}
+	],
+	"Ignore": ["GenerateTokenTrace", "ReceiveToken*"]
+}`
+
+func TestStateMachine(t *testing.T) {
+	m, err := ParseStateMachine(strings.NewReader(stateMachine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ok, broken, unfinished *tracing.Trace
+	run := func(client1, client2 *tracing.Tracer) {
+		ok = client1.CreateTrace()
+		ok.RecordAction(Put{Key: "a"})
+		client2.ReceiveToken(ok.GenerateToken()).RecordAction(PutResult{Key: "a"})
+		broken = client1.CreateTrace()
+		broken.RecordAction(Put{Key: "b"})
+		broken.RecordAction(Put{Key: "c"})
+		broken.RecordAction(PutResult{Key: "b"})
+		unfinished = client2.CreateTrace()
+		unfinished.RecordAction(Put{Key: "d"})
+	}
+	records := tracetest.Run(t, run)
+	violations := m.Check(records)
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", violations)
+	}
+	if expected := fmt.Sprintf("Put of client1 in trace %d in state Putting, expected one of PutResult", broken.ID); violations[0].Message != expected {
+		t.Fatalf("expected %q, got %v", expected, violations[0])
+	}
+	if expected := fmt.Sprintf("trace %d ended in state Putting, expected one of Idle", unfinished.ID); violations[1].Message != expected {
+		t.Fatalf("expected %q, got %v", expected, violations[1])
+	}
+
+	// online, with the violations recorded in their traces
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	machineFile := filepath.Join(dir, "machine.json")
+	if err := ioutil.WriteFile(machineFile, []byte(stateMachine), 0666); err != nil {
+		t.Fatal(err)
+	}
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind:       ":0",
+		InMemory:         true,
+		Checkers:         []string{"statemachine:" + machineFile},
+		ViolationRecords: true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client1 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	run(client1, client2)
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var recorded []tracing.TraceRecord
+	for _, record := range server.Records() {
+		if record.TracerIdentity == tracing.ViolationTracer {
+			recorded = append(recorded, record)
+		}
+	}
+	if len(recorded) != 1 || recorded[0].TraceID != broken.ID || !strings.Contains(string(recorded[0].Body), "Put of client1") {
+		t.Fatalf("expected a record of the violation in the broken trace, got %v", recorded)
+	}
+
+	for _, invalid := range []string{
+		`{"Transitions": []}`,
+		`{"Initial": "Idle", "Transitions": [{"From": "Idle", "Action": "Put"}]}`,
+		`{"Initial": "Idle", "Ignore": ["["]}`,
+		`{"Initial": "Idle", "States": []}`,
+	} {
+		if _, err := ParseStateMachine(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("expected an error parsing %s", invalid)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(Put{Key: "a"})
+		trace.RecordAction(Put{Key: "b"})
+		trace.RecordAction(Put{Key: "c"})
+		client2.ReceiveToken(trace.GenerateToken())
+		trace.GenerateToken()
+	})
+
+	config := Config{
+		Checks:        []string{"causality", "orphans"},
+		Rules:         []string{"Put followed by PutResult with same Key"},
+		MaxViolations: 2,
+	}
+	report, err := config.Run(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed || report.Records != len(records) || len(report.Results) != 3 {
+		t.Fatalf("expected a failed report of 3 checks on %d records, got %+v", len(records), report)
+	}
+	expected := []struct {
+		check      string
+		count      int
+		violations int
+	}{
+		{"causality", 0, 0},
+		{"orphans", 1, 1},
+		{"Put followed by PutResult with same Key", 3, 2},
+	}
+	for i, result := range report.Results {
+		if result.Check != expected[i].check || result.Passed != (expected[i].count == 0) || result.Count != expected[i].count || len(result.Violations) != expected[i].violations {
+			t.Fatalf("expected the result %d to be %+v, got %+v", i, expected[i], result)
+		}
+	}
+
+	var summary strings.Builder
+	if err := report.WriteSummary(&summary); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"PASS causality\n",
+		"FAIL orphans: 1 violations\n",
+		"\tand 1 more\n",
+		"1 of 3 checks passed",
+	} {
+		if !strings.Contains(summary.String(), line) {
+			t.Fatalf("expected %q in the summary, got:\n%s", line, summary.String())
+		}
+	}
+
+	if _, err := (Config{Checks: []string{"nonsense"}}).Run(records); err == nil {
+		t.Fatal("expected an error for an unknown check")
+	}
+	if _, err := (Config{Formulas: []string{"always ("}}).Run(records); err == nil {
+		t.Fatal("expected an error for an invalid formula")
+	}
+}
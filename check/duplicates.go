@@ -0,0 +1,43 @@
+package check
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// DuplicateTraceIDs reports the trace IDs created by several CreateTrace
+// records, rather than once and propagated by tokens: accidental collisions
+// that merge unrelated operations into a single trace, e.g. from tracers
+// seeding their IDs the same way.
+func DuplicateTraceIDs(records []tracing.TraceRecord) []Violation {
+	var ids []uint64 // in the order of their first creation
+	creations := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if record.Tag != "CreateTrace" {
+			continue
+		}
+		if _, ok := creations[record.TraceID]; !ok {
+			ids = append(ids, record.TraceID)
+		}
+		creations[record.TraceID] = append(creations[record.TraceID], record)
+	}
+
+	var violations []Violation
+	for _, id := range ids {
+		if len(creations[id]) < 2 {
+			continue
+		}
+		var creators []string
+		for _, record := range creations[id] {
+			creators = append(creators, record.TracerIdentity)
+		}
+		violations = append(violations, Violation{
+			Check:   "duplicates",
+			Message: fmt.Sprintf("trace %d was created %d times, by %s", id, len(creators), strings.Join(creators, ", ")),
+			Records: creations[id],
+		})
+	}
+	return violations
+}
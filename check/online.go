@@ -0,0 +1,152 @@
+package check
+
+import (
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+func init() {
+	tracing.RegisterChecker("causality", func() tracing.Checker {
+		return &causalityChecker{
+			last:        make(map[string]tracing.TraceRecord),
+			generations: make(map[uint64]tracing.TraceRecord),
+		}
+	})
+	tracing.RegisterChecker("duplicates", func() tracing.Checker {
+		return &duplicatesChecker{creations: make(map[uint64][]tracing.TraceRecord)}
+	})
+	tracing.RegisterChecker("orphans", func() tracing.Checker {
+		return &orphansChecker{}
+	})
+	tracing.RegisterChecker("stalls", func() tracing.Checker {
+		return &stallsChecker{newStalls()}
+	})
+	tracing.RegisterParameterizedChecker("statemachine", func(fileName string) (tracing.Checker, error) {
+		m, err := LoadStateMachine(fileName)
+		if err != nil {
+			return nil, err
+		}
+		return m.Checker(), nil
+	})
+	tracing.RegisterParameterizedChecker("rules", func(fileName string) (tracing.Checker, error) {
+		file, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		rules, err := ParseRules(file)
+		if err != nil {
+			return nil, err
+		}
+		return &rulesChecker{rules: rules}, nil
+	})
+}
+
+// causalityChecker is the online version of Causality. A reception is
+// checked against the generation of its token if the server wrote it
+// already, and against the clock of the token otherwise.
+type causalityChecker struct {
+	last        map[string]tracing.TraceRecord // the previous record of each tracer
+	generations map[uint64]tracing.TraceRecord
+}
+
+func (c *causalityChecker) Check(record tracing.TraceRecord) []Violation {
+	var violations []Violation
+	if previous, ok := c.last[record.TracerIdentity]; ok {
+		if v, ok := checkSuccession(previous, record); ok {
+			violations = append(violations, v)
+		}
+	}
+	c.last[record.TracerIdentity] = record
+	if record.Tag == "GenerateTokenTrace" {
+		c.generations[events.RecordID(record)] = record
+	}
+	return append(violations, checkReceptions(record, c.generations)...)
+}
+
+func (c *causalityChecker) Finish() []Violation {
+	return nil
+}
+
+// duplicatesChecker is the online version of DuplicateTraceIDs, which
+// reports each creation of a trace after the first.
+type duplicatesChecker struct {
+	creations map[uint64][]tracing.TraceRecord
+}
+
+func (c *duplicatesChecker) Check(record tracing.TraceRecord) []Violation {
+	if record.Tag != "CreateTrace" {
+		return nil
+	}
+	creations := append(c.creations[record.TraceID], record)
+	c.creations[record.TraceID] = creations
+	if len(creations) < 2 {
+		return nil
+	}
+	return DuplicateTraceIDs(creations)
+}
+
+func (c *duplicatesChecker) Finish() []Violation {
+	return nil
+}
+
+// orphansChecker is the online version of OrphanTokens, which only knows
+// which tokens are never received at the end of the run. It keeps the
+// records generating and receiving tokens until then.
+type orphansChecker struct {
+	records []tracing.TraceRecord
+}
+
+func (c *orphansChecker) Check(record tracing.TraceRecord) []Violation {
+	if record.Tag == "GenerateTokenTrace" || len(events.ReceivedTokens(record)) > 0 {
+		c.records = append(c.records, record)
+	}
+	return nil
+}
+
+func (c *orphansChecker) Finish() []Violation {
+	return OrphanTokens(c.records)
+}
+
+// stallsChecker is the online version of Stalls, which only knows which
+// traces stalled at the end of the run.
+type stallsChecker struct {
+	stalls *stalls
+}
+
+func (c *stallsChecker) Check(record tracing.TraceRecord) []Violation {
+	c.stalls.add(record)
+	return nil
+}
+
+func (c *stallsChecker) Finish() []Violation {
+	return c.stalls.violations()
+}
+
+// rulesChecker is the online version of Rule.Check, for rules, which only
+// knows which actions are not followed as required at the end of the run.
+// It keeps the records of the actions the rules refer to until then.
+type rulesChecker struct {
+	rules   []Rule
+	records []tracing.TraceRecord
+}
+
+func (c *rulesChecker) Check(record tracing.TraceRecord) []Violation {
+	for _, r := range c.rules {
+		if matchTag(r.action, record) || matchTag(r.other, record) || r.before != "" && matchTag(r.before, record) {
+			c.records = append(c.records, record)
+			break
+		}
+	}
+	return nil
+}
+
+func (c *rulesChecker) Finish() []Violation {
+	var violations []Violation
+	for _, r := range c.rules {
+		violations = append(violations, r.Check(c.records)...)
+	}
+	return violations
+}
@@ -0,0 +1,50 @@
+package check
+
+import (
+	"fmt"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// OrphanTokens matches the generations of tokens with their receptions, and
+// reports the tokens generated but never received, and the ones received
+// but never generated, which usually indicate dropped messages or missing
+// instrumentation, e.g. a node that forwards tokens without receiving them.
+func OrphanTokens(records []tracing.TraceRecord) []Violation {
+	received := make(map[uint64]bool) // the events that generated received tokens
+	for _, record := range records {
+		for _, token := range events.ReceivedTokens(record) {
+			received[events.ID(token.Tracer, token.VectorClock[token.Tracer])] = true
+		}
+	}
+
+	var violations []Violation
+	generated := make(map[uint64]bool)
+	for _, record := range records {
+		if record.Tag != "GenerateTokenTrace" {
+			continue
+		}
+		id := events.RecordID(record)
+		generated[id] = true
+		if !received[id] {
+			violations = append(violations, Violation{
+				Check:   "orphans",
+				Message: fmt.Sprintf("%s generated a token in trace %d that was never received", record.TracerIdentity, record.TraceID),
+				Records: []tracing.TraceRecord{record},
+			})
+		}
+	}
+	for _, record := range records {
+		for _, token := range events.ReceivedTokens(record) {
+			if !generated[events.ID(token.Tracer, token.VectorClock[token.Tracer])] {
+				violations = append(violations, Violation{
+					Check:   "orphans",
+					Message: fmt.Sprintf("%s received a token of %s in trace %d that was never generated", record.TracerIdentity, token.Tracer, token.TraceID),
+					Records: []tracing.TraceRecord{record},
+				})
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,142 @@
+package check
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Checks are the checks of the package, by name.
+var Checks = map[string]func(records []tracing.TraceRecord) []Violation{
+	"causality":  Causality,
+	"duplicates": DuplicateTraceIDs,
+	"orphans":    OrphanTokens,
+	"stalls":     Stalls,
+}
+
+// CheckNames returns the names of Checks, sorted.
+func CheckNames() []string {
+	var names []string
+	for name := range Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config is a set of checks to run on the records of a run, e.g. to grade
+// a submission, usually read from a JSON file, e.g.:
+//
+//	{
+//	  "Checks": ["causality", "orphans"],
+//	  "Rules": ["Put followed by PutResult with same Key"],
+//	  "Formulas": ["always (GetRequest -> eventually GetResponse)"],
+//	  "MaxViolations": 10
+//	}
+type Config struct {
+	Checks        []string      // the names of checks in Checks
+	Rules         []string      // ordering rules, see ParseRule
+	Formulas      []string      // temporal formulas, see ParseFormula
+	StateMachine  *StateMachine // the sequencing of the actions of each trace, if any
+	MaxViolations int           // the number of violations reported per check, if positive, the others are only counted
+}
+
+// Result is the result of a check.
+type Result struct {
+	Check      string // the name of the check, the rule or the formula
+	Passed     bool
+	Count      int         // the number of violations found
+	Violations []Violation // the violations, up to Config.MaxViolations
+}
+
+// Report is the result of the checks of a Config.
+type Report struct {
+	Passed  bool // whether every check passed
+	Records int  // the number of records checked
+	Results []Result
+}
+
+// Run runs the checks of config on records. It fails if config refers to
+// unknown checks or has invalid rules or formulas.
+func (config Config) Run(records []tracing.TraceRecord) (Report, error) {
+	type namedCheck struct {
+		name string
+		run  func(records []tracing.TraceRecord) []Violation
+	}
+	var checks []namedCheck
+	for _, name := range config.Checks {
+		run, ok := Checks[name]
+		if !ok {
+			return Report{}, fmt.Errorf("unknown check %q, expected one of %s", name, strings.Join(CheckNames(), ", "))
+		}
+		checks = append(checks, namedCheck{name, run})
+	}
+	for _, s := range config.Rules {
+		rule, err := ParseRule(s)
+		if err != nil {
+			return Report{}, err
+		}
+		checks = append(checks, namedCheck{rule.String(), rule.Check})
+	}
+	for _, s := range config.Formulas {
+		f, err := ParseFormula(s)
+		if err != nil {
+			return Report{}, err
+		}
+		checks = append(checks, namedCheck{f.String(), func(records []tracing.TraceRecord) []Violation {
+			return CheckFormula(f, records)
+		}})
+	}
+	if config.StateMachine != nil {
+		checks = append(checks, namedCheck{"statemachine", config.StateMachine.Check})
+	}
+
+	report := Report{Passed: true, Records: len(records)}
+	for _, check := range checks {
+		violations := check.run(records)
+		result := Result{Check: check.name, Passed: len(violations) == 0, Count: len(violations), Violations: violations}
+		if config.MaxViolations > 0 && len(violations) > config.MaxViolations {
+			result.Violations = violations[:config.MaxViolations]
+		}
+		if result.Violations == nil {
+			result.Violations = []Violation{}
+		}
+		report.Passed = report.Passed && result.Passed
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// WriteSummary writes a human-readable summary of the report to w: a line
+// per check, followed by the messages of its violations, and a line with
+// the totals.
+func (report Report) WriteSummary(w io.Writer) error {
+	passed := 0
+	for _, result := range report.Results {
+		if result.Passed {
+			passed++
+			if _, err := fmt.Fprintf(w, "PASS %s\n", result.Check); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "FAIL %s: %d violations\n", result.Check, result.Count); err != nil {
+			return err
+		}
+		for _, violation := range result.Violations {
+			if _, err := fmt.Fprintf(w, "\t%s\n", violation.Message); err != nil {
+				return err
+			}
+		}
+		if hidden := result.Count - len(result.Violations); hidden > 0 {
+			if _, err := fmt.Fprintf(w, "\tand %d more\n", hidden); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d of %d checks passed, on %d records\n", passed, len(report.Results), report.Records)
+	return err
+}
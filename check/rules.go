@@ -0,0 +1,291 @@
+package check
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Rule is an ordering rule over the actions of each trace, e.g. that every
+// Put is followed by a PutResult with the same key before the TraceEnd of
+// the trace:
+//
+//	check.Every("Put").FollowedBy("PutResult").WithSame("Key").Before("TraceEnd")
+//
+// or, in the language of ParseRules:
+//
+//	Put followed by PutResult with same Key before TraceEnd
+//
+// Actions are designated by patterns of their tags (the names of their
+// types), in the syntax of path.Match, e.g. "Put*". Orders are causal: an
+// action follows another if its vector clock is after the other's, so that
+// rules hold across tracers whatever the order their records reach the
+// server.
+//
+// Both actions can be restricted to tracers, by patterns of their
+// identities, to require causal edges between tracers, e.g. that every Put
+// of a client happens before a PutRecvd of a server, in the same trace:
+//
+//	Put on client* followed by PutRecvd on server*
+type Rule struct {
+	action       string   // the pattern of the actions the rule applies to
+	actionTracer string   // the pattern of the tracers of the actions, if any
+	other        string   // the pattern of the actions that must follow or precede them
+	otherTracer  string   // the pattern of the tracers of the other actions, if any
+	preceded     bool     // whether the other actions must precede rather than follow
+	same         []string // the fields of the bodies both actions must have equal
+	before       string   // the pattern of the actions the other action must not follow, if any
+}
+
+// Every starts a rule applying to every action whose tag matches pattern.
+func Every(pattern string) Rule {
+	return Rule{action: pattern}
+}
+
+// FollowedBy requires the actions of the rule to be causally followed, in
+// their trace, by an action whose tag matches pattern.
+func (r Rule) FollowedBy(pattern string) Rule {
+	r.other, r.preceded = pattern, false
+	return r
+}
+
+// PrecededBy requires the actions of the rule to be causally preceded, in
+// their trace, by an action whose tag matches pattern.
+func (r Rule) PrecededBy(pattern string) Rule {
+	r.other, r.preceded = pattern, true
+	return r
+}
+
+// On restricts the actions of the rule, or, once FollowedBy or PrecededBy
+// is given, the following or preceding actions, to the tracers whose
+// identity matches pattern.
+func (r Rule) On(pattern string) Rule {
+	if r.other == "" {
+		r.actionTracer = pattern
+	} else {
+		r.otherTracer = pattern
+	}
+	return r
+}
+
+// WithSame requires the following or preceding action to have the same
+// value as the action of the rule in the given fields of their bodies.
+func (r Rule) WithSame(fields ...string) Rule {
+	r.same = append(append([]string(nil), r.same...), fields...)
+	return r
+}
+
+// Before requires the following action not to be causally after an action
+// whose tag matches pattern and which follows the action of the rule, e.g.
+// the end of the trace.
+func (r Rule) Before(pattern string) Rule {
+	r.before = pattern
+	return r
+}
+
+// String returns the rule in the language of ParseRules.
+func (r Rule) String() string {
+	s := r.action
+	if r.actionTracer != "" {
+		s += " on " + r.actionTracer
+	}
+	if r.preceded {
+		s += " preceded by " + r.other
+	} else {
+		s += " followed by " + r.other
+	}
+	if r.otherTracer != "" {
+		s += " on " + r.otherTracer
+	}
+	if len(r.same) > 0 {
+		s += " with same " + strings.Join(r.same, ", ")
+	}
+	if r.before != "" {
+		s += " before " + r.before
+	}
+	return s
+}
+
+// ParseRule parses a rule of the form
+//
+//	ACTION followed by ACTION [with same FIELD[, FIELD...]] [before ACTION]
+//	ACTION preceded by ACTION [with same FIELD[, FIELD...]]
+//
+// where each ACTION is a pattern of tags, optionally followed by on TRACER,
+// a pattern of tracer identities, see Rule.
+func ParseRule(s string) (Rule, error) {
+	words := strings.Fields(strings.Replace(s, ",", " ", -1))
+	// action consumes ACTION [on TRACER] from words
+	action := func() (string, string) {
+		var pattern, tracer string
+		if len(words) > 0 {
+			pattern, words = words[0], words[1:]
+		}
+		if len(words) >= 2 && words[0] == "on" {
+			tracer, words = words[1], words[2:]
+		}
+		return pattern, tracer
+	}
+	pattern, tracer := action()
+	if len(words) < 3 || words[1] != "by" || (words[0] != "followed" && words[0] != "preceded") {
+		return Rule{}, fmt.Errorf("invalid rule %q: expected ACTION followed by ACTION or ACTION preceded by ACTION", s)
+	}
+	preceded := words[0] == "preceded"
+	words = words[2:]
+	r := Every(pattern).On(tracer)
+	pattern, tracer = action()
+	if preceded {
+		r = r.PrecededBy(pattern).On(tracer)
+	} else {
+		r = r.FollowedBy(pattern).On(tracer)
+	}
+	for _, pattern := range []string{r.action, r.actionTracer, r.other, r.otherTracer} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid rule %q: %v", s, err)
+		}
+	}
+
+	if len(words) >= 2 && words[0] == "with" && words[1] == "same" {
+		words = words[2:]
+		for len(words) > 0 && words[0] != "before" {
+			r.same = append(r.same, words[0])
+			words = words[1:]
+		}
+		if len(r.same) == 0 {
+			return Rule{}, fmt.Errorf("invalid rule %q: expected fields after with same", s)
+		}
+	}
+	if len(words) == 2 && words[0] == "before" && !r.preceded {
+		if _, err := path.Match(words[1], ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid rule %q: %v", s, err)
+		}
+		r.before = words[1]
+		words = nil
+	}
+	if len(words) > 0 {
+		return Rule{}, fmt.Errorf("invalid rule %q: unexpected %q", s, strings.Join(words, " "))
+	}
+	return r, nil
+}
+
+// ParseRules parses the rules in r, one per line, see ParseRule. Empty lines
+// and lines starting with # are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := ParseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// Check checks the rule in each trace of records, and returns a violation
+// for each action of the rule which is not followed or preceded as
+// required.
+func (r Rule) Check(records []tracing.TraceRecord) []Violation {
+	var traces []uint64 // in the order of their first record
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			traces = append(traces, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+
+	var violations []Violation
+	for _, id := range traces {
+		trace := byTrace[id]
+		for i, record := range trace {
+			if !matchTag(r.action, record) || !matchTracer(r.actionTracer, record) || r.satisfied(trace, i) {
+				continue
+			}
+			violations = append(violations, Violation{
+				Check:   "rules",
+				Message: fmt.Sprintf("%s of %s in trace %d breaks the rule %q", record.Tag, record.TracerIdentity, id, r),
+				Records: []tracing.TraceRecord{record},
+			})
+		}
+	}
+	return violations
+}
+
+// satisfied returns whether the rule holds for the action of trace[i].
+func (r Rule) satisfied(trace []tracing.TraceRecord, i int) bool {
+	record := trace[i]
+	for j, other := range trace {
+		if j == i || !matchTag(r.other, other) || !matchTracer(r.otherTracer, other) || !r.sameFields(record, other) {
+			continue
+		}
+		if r.preceded && after(record.VectorClock, other.VectorClock) {
+			return true
+		}
+		if !r.preceded && after(other.VectorClock, record.VectorClock) && !r.ended(trace, record, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// ended returns whether an action matching the before pattern of the rule
+// happened between record and its follower in trace.
+func (r Rule) ended(trace []tracing.TraceRecord, record, follower tracing.TraceRecord) bool {
+	if r.before == "" {
+		return false
+	}
+	for _, end := range trace {
+		if matchTag(r.before, end) && after(end.VectorClock, record.VectorClock) && after(follower.VectorClock, end.VectorClock) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameFields returns whether a and b have equal values in the fields of
+// the rule, which both must have.
+func (r Rule) sameFields(a, b tracing.TraceRecord) bool {
+	if len(r.same) == 0 {
+		return true
+	}
+	var aFields, bFields map[string]interface{}
+	if json.Unmarshal(a.Body, &aFields) != nil || json.Unmarshal(b.Body, &bFields) != nil {
+		return false
+	}
+	for _, field := range r.same {
+		aValue, ok := aFields[field]
+		bValue, bOK := bFields[field]
+		if !ok || !bOK || !reflect.DeepEqual(aValue, bValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTag returns whether the tag of record matches pattern.
+func matchTag(pattern string, record tracing.TraceRecord) bool {
+	ok, _ := path.Match(pattern, record.Tag)
+	return ok
+}
+
+// matchTracer returns whether the tracer of record matches pattern, if
+// any.
+func matchTracer(pattern string, record tracing.TraceRecord) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, record.TracerIdentity)
+	return ok
+}
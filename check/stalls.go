@@ -0,0 +1,93 @@
+package check
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Stalls reports the traces that stalled: a tracer generated a token, and
+// nothing happened after it in the trace, on any tracer, neither the
+// reception of the token nor any other action. It is a heuristic for
+// deadlocks and lost messages, e.g. a request forwarded to a node that
+// never handled it.
+func Stalls(records []tracing.TraceRecord) []Violation {
+	s := newStalls()
+	for _, record := range records {
+		s.add(record)
+	}
+	return s.violations()
+}
+
+// progress is the latest event of a tracer in a trace, as far as the
+// records of the trace know.
+type progress struct {
+	ticks      uint64               // the entry of the tracer in the clocks of the records
+	count      int                  // the number of records with ticks
+	generation *tracing.TraceRecord // the record generating a token at ticks, if any
+}
+
+// stalls tracks the progress of traces, to find those stalled.
+type stalls struct {
+	traces   []uint64 // in the order of their first record
+	progress map[uint64]map[string]*progress
+}
+
+func newStalls() *stalls {
+	return &stalls{progress: make(map[uint64]map[string]*progress)}
+}
+
+func (s *stalls) add(record tracing.TraceRecord) {
+	trace := s.progress[record.TraceID]
+	if trace == nil {
+		trace = make(map[string]*progress)
+		s.progress[record.TraceID] = trace
+		s.traces = append(s.traces, record.TraceID)
+	}
+	for tracer, ticks := range record.VectorClock {
+		p := trace[tracer]
+		if p == nil {
+			p = &progress{}
+			trace[tracer] = p
+		}
+		switch {
+		case ticks > p.ticks:
+			*p = progress{ticks: ticks, count: 1}
+		case ticks == p.ticks:
+			p.count++
+		default:
+			continue
+		}
+		if tracer == record.TracerIdentity && record.Tag == "GenerateTokenTrace" && p.count == 1 {
+			generation := record
+			p.generation = &generation
+		}
+	}
+}
+
+// violations returns the stalls, in the order of the traces, then of the
+// tracers.
+func (s *stalls) violations() []Violation {
+	var violations []Violation
+	for _, id := range s.traces {
+		trace := s.progress[id]
+		var tracers []string
+		for tracer := range trace {
+			tracers = append(tracers, tracer)
+		}
+		sort.Strings(tracers)
+		for _, tracer := range tracers {
+			p := trace[tracer]
+			if p.generation == nil || p.count > 1 {
+				continue
+			}
+			violations = append(violations, Violation{
+				Check:   "stalls",
+				Message: fmt.Sprintf("trace %d stalled after %s generated a token: nothing happened after it", id, tracer),
+				Records: []tracing.TraceRecord{*p.generation},
+			})
+		}
+	}
+	return violations
+}
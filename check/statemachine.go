@@ -0,0 +1,208 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// StateMachine specifies the sequencing of the actions of each trace, e.g.
+// of an assignment, as a state machine whose transitions are actions:
+// each trace starts in the Initial state, and each of its actions must be
+// allowed by a transition from its current state, e.g.:
+//
+//	{
+//	  "Initial": "Idle",
+//	  "Final": ["Idle"],
+//	  "Transitions": [
+//	    {"From": "Idle", "Action": "CreateTrace", "To": "Idle"},
+//	    {"From": "Idle", "Action": "Put", "To": "Putting"},
+//	    {"From": "Putting", "Action": "PutResult", "To": "Idle"}
+//	  ],
+//	  "Ignore": ["GenerateTokenTrace", "ReceiveToken*"]
+//	}
+//
+// Actions are designated by patterns of their tags, in the syntax of
+// path.Match, as in rules. A trace breaking the machine is only reported
+// once, at its first unexpected action.
+type StateMachine struct {
+	Initial     string
+	Final       []string // the states traces may end in, any if empty
+	Transitions []Transition
+	Ignore      []string // the patterns of the actions that do not change the state
+}
+
+// Transition is a transition of a StateMachine, from the state From to the
+// state To, by an action whose tag matches the pattern Action.
+type Transition struct {
+	From, Action, To string
+}
+
+// ParseStateMachine parses the state machine of the JSON object in r.
+func ParseStateMachine(r io.Reader) (StateMachine, error) {
+	var m StateMachine
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&m); err != nil {
+		return m, fmt.Errorf("invalid state machine: %v", err)
+	}
+	if m.Initial == "" {
+		return m, fmt.Errorf("invalid state machine: no Initial state")
+	}
+	for _, t := range m.Transitions {
+		if t.From == "" || t.Action == "" || t.To == "" {
+			return m, fmt.Errorf("invalid state machine: transition %+v misses From, Action or To", t)
+		}
+		if _, err := path.Match(t.Action, ""); err != nil {
+			return m, fmt.Errorf("invalid state machine: invalid pattern %q", t.Action)
+		}
+	}
+	for _, pattern := range m.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return m, fmt.Errorf("invalid state machine: invalid pattern %q", pattern)
+		}
+	}
+	return m, nil
+}
+
+// LoadStateMachine parses the state machine of the file fileName, see
+// ParseStateMachine.
+func LoadStateMachine(fileName string) (StateMachine, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return StateMachine{}, err
+	}
+	defer file.Close()
+	return ParseStateMachine(file)
+}
+
+// machineTrace is the state of a trace in a StateMachine.
+type machineTrace struct {
+	state  string
+	broken bool // whether an action broke the machine
+	last   tracing.TraceRecord
+}
+
+// step moves trace by the action of record, and returns the violation if
+// the machine does not allow it.
+func (m StateMachine) step(trace *machineTrace, record tracing.TraceRecord) (Violation, bool) {
+	if trace.broken {
+		return Violation{}, false
+	}
+	trace.last = record
+	for _, pattern := range m.Ignore {
+		if matchTag(pattern, record) {
+			return Violation{}, false
+		}
+	}
+	var expected []string
+	for _, t := range m.Transitions {
+		if t.From != trace.state {
+			continue
+		}
+		if matchTag(t.Action, record) {
+			trace.state = t.To
+			return Violation{}, false
+		}
+		expected = append(expected, t.Action)
+	}
+	trace.broken = true
+	message := fmt.Sprintf("%s of %s in trace %d in state %s, expected ", record.Tag, record.TracerIdentity, record.TraceID, trace.state)
+	if len(expected) == 0 {
+		message += "no action"
+	} else {
+		message += "one of " + strings.Join(expected, ", ")
+	}
+	return Violation{Check: "statemachine", Message: message, Records: []tracing.TraceRecord{record}}, true
+}
+
+// finish returns the violation if trace, of id, ended in a state that is
+// not final.
+func (m StateMachine) finish(id uint64, trace *machineTrace) (Violation, bool) {
+	if trace.broken || len(m.Final) == 0 {
+		return Violation{}, false
+	}
+	for _, state := range m.Final {
+		if trace.state == state {
+			return Violation{}, false
+		}
+	}
+	return Violation{
+		Check:   "statemachine",
+		Message: fmt.Sprintf("trace %d ended in state %s, expected one of %s", id, trace.state, strings.Join(m.Final, ", ")),
+		Records: []tracing.TraceRecord{trace.last},
+	}, true
+}
+
+// Check runs each trace of records through the machine, its actions in a
+// deterministic causal order, and returns a violation for its first
+// action that the machine does not allow, or its state at the end if it is
+// not final.
+func (m StateMachine) Check(records []tracing.TraceRecord) []Violation {
+	var traces []uint64 // in the order of their first record
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			traces = append(traces, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+
+	var violations []Violation
+	for _, id := range traces {
+		trace := &machineTrace{state: m.Initial}
+		for _, record := range events.Order(byTrace[id]) {
+			if v, ok := m.step(trace, record); ok {
+				violations = append(violations, v)
+			}
+		}
+		if v, ok := m.finish(id, trace); ok {
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// Checker returns the online version of Check, which runs the actions of
+// each trace through the machine in the order the server writes them,
+// for servers to report violations as they happen. The order of
+// concurrent actions of different tracers may differ from that of Check.
+func (m StateMachine) Checker() tracing.Checker {
+	return &stateMachineChecker{machine: m, traces: make(map[uint64]*machineTrace)}
+}
+
+// stateMachineChecker is the online version of StateMachine.Check.
+type stateMachineChecker struct {
+	machine StateMachine
+	order   []uint64 // the traces in the order of their first record
+	traces  map[uint64]*machineTrace
+}
+
+func (c *stateMachineChecker) Check(record tracing.TraceRecord) []Violation {
+	trace, ok := c.traces[record.TraceID]
+	if !ok {
+		trace = &machineTrace{state: c.machine.Initial}
+		c.traces[record.TraceID] = trace
+		c.order = append(c.order, record.TraceID)
+	}
+	if v, ok := c.machine.step(trace, record); ok {
+		return []Violation{v}
+	}
+	return nil
+}
+
+func (c *stateMachineChecker) Finish() []Violation {
+	var violations []Violation
+	for _, id := range c.order {
+		if v, ok := c.machine.finish(id, c.traces[id]); ok {
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,375 @@
+package check
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Formula is a temporal property of the causal order of a trace, in the
+// style of LTL, e.g. that every GetRequest is eventually causally followed
+// by a GetResponse:
+//
+//	check.Always(check.Implies(check.Tag("GetRequest"), check.Eventually(check.Tag("GetResponse"))))
+//
+// or, in the syntax of ParseFormula:
+//
+//	always (GetRequest -> eventually GetResponse)
+//
+// Formulas hold at events of the trace, or at its start, before all of
+// them. The future of an event is itself and the events causally after it,
+// from any tracer; the future of the start is the whole trace. Since the
+// order is partial, there is no next event.
+type Formula interface {
+	// String returns the formula in the syntax of ParseFormula.
+	String() string
+	// holds returns whether the formula holds at the event i of t, or its
+	// start if i is -1.
+	holds(t *causalTrace, i int) bool
+}
+
+// causalTrace is a trace to evaluate formulas over.
+type causalTrace struct {
+	records []tracing.TraceRecord
+	future  [][]int // the indexes of the events in the future of each event
+	all     []int   // the indexes of all the events, the future of the start
+}
+
+func newCausalTrace(records []tracing.TraceRecord) *causalTrace {
+	t := &causalTrace{records: records, future: make([][]int, len(records))}
+	for i, record := range records {
+		t.all = append(t.all, i)
+		for j, other := range records {
+			if after(other.VectorClock, record.VectorClock) {
+				t.future[i] = append(t.future[i], j)
+			}
+		}
+	}
+	return t
+}
+
+// futureOf returns the future of the event i, or the whole trace if i is -1.
+func (t *causalTrace) futureOf(i int) []int {
+	if i < 0 {
+		return t.all
+	}
+	return t.future[i]
+}
+
+// before returns whether the event i is strictly causally before j.
+func (t *causalTrace) before(i, j int) bool {
+	return i != j && after(t.records[j].VectorClock, t.records[i].VectorClock)
+}
+
+type tagFormula string
+
+// Tag holds at the events whose tag matches pattern, in the syntax of
+// path.Match.
+func Tag(pattern string) Formula { return tagFormula(pattern) }
+
+func (f tagFormula) String() string { return string(f) }
+
+func (f tagFormula) holds(t *causalTrace, i int) bool {
+	return i >= 0 && matchTag(string(f), t.records[i])
+}
+
+type matchFormula struct {
+	name  string
+	match func(record tracing.TraceRecord) bool
+}
+
+// Match holds at the events whose record satisfies match, e.g. to inspect
+// their bodies. name stands for it when printing the formula.
+func Match(name string, match func(record tracing.TraceRecord) bool) Formula {
+	return matchFormula{name: name, match: match}
+}
+
+func (f matchFormula) String() string { return f.name }
+
+func (f matchFormula) holds(t *causalTrace, i int) bool {
+	return i >= 0 && f.match(t.records[i])
+}
+
+type notFormula struct{ f Formula }
+
+// Not holds where f does not.
+func Not(f Formula) Formula { return notFormula{f} }
+
+func (f notFormula) String() string { return "!" + operand(f.f) }
+
+func (f notFormula) holds(t *causalTrace, i int) bool { return !f.f.holds(t, i) }
+
+type binaryFormula struct {
+	op   string
+	a, b Formula
+}
+
+// And holds where both a and b hold.
+func And(a, b Formula) Formula { return binaryFormula{"&&", a, b} }
+
+// Or holds where a or b holds.
+func Or(a, b Formula) Formula { return binaryFormula{"||", a, b} }
+
+// Implies holds where b holds, or a does not.
+func Implies(a, b Formula) Formula { return binaryFormula{"->", a, b} }
+
+// Until holds at an event if b holds in its future, and a holds at every
+// event of its future causally before that one.
+func Until(a, b Formula) Formula { return binaryFormula{"until", a, b} }
+
+func (f binaryFormula) String() string {
+	return operand(f.a) + " " + f.op + " " + operand(f.b)
+}
+
+func (f binaryFormula) holds(t *causalTrace, i int) bool {
+	switch f.op {
+	case "&&":
+		return f.a.holds(t, i) && f.b.holds(t, i)
+	case "||":
+		return f.a.holds(t, i) || f.b.holds(t, i)
+	case "->":
+		return !f.a.holds(t, i) || f.b.holds(t, i)
+	}
+	future := t.futureOf(i)
+	for _, j := range future {
+		if !f.b.holds(t, j) {
+			continue
+		}
+		held := true
+		for _, k := range future {
+			if t.before(k, j) && !f.a.holds(t, k) {
+				held = false
+				break
+			}
+		}
+		if held {
+			return true
+		}
+	}
+	return false
+}
+
+type unaryFormula struct {
+	op string
+	f  Formula
+}
+
+// Always holds at an event if f holds at every event of its future.
+func Always(f Formula) Formula { return unaryFormula{"always", f} }
+
+// Eventually holds at an event if f holds at an event of its future.
+func Eventually(f Formula) Formula { return unaryFormula{"eventually", f} }
+
+func (f unaryFormula) String() string { return f.op + " " + operand(f.f) }
+
+func (f unaryFormula) holds(t *causalTrace, i int) bool {
+	always := f.op == "always"
+	for _, j := range t.futureOf(i) {
+		if f.f.holds(t, j) != always {
+			return !always
+		}
+	}
+	return always
+}
+
+// operand returns f as the operand of an operator, in parentheses unless it
+// is atomic.
+func operand(f Formula) string {
+	switch f.(type) {
+	case tagFormula, matchFormula:
+		return f.String()
+	}
+	return "(" + f.String() + ")"
+}
+
+// CheckFormula checks that f holds at the start of each trace of records,
+// and returns a violation for each trace where it does not. If f is always
+// g, it instead returns a violation for each event where g does not hold,
+// e.g. each GetRequest never followed by a GetResponse.
+func CheckFormula(f Formula, records []tracing.TraceRecord) []Violation {
+	var traces []uint64 // in the order of their first record
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			traces = append(traces, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+
+	var violations []Violation
+	for _, id := range traces {
+		t := newCausalTrace(byTrace[id])
+		if always, ok := f.(unaryFormula); ok && always.op == "always" {
+			for i, record := range t.records {
+				if !always.f.holds(t, i) {
+					violations = append(violations, Violation{
+						Check:   "temporal",
+						Message: fmt.Sprintf("%s of %s in trace %d violates %q", record.Tag, record.TracerIdentity, id, f),
+						Records: []tracing.TraceRecord{record},
+					})
+				}
+			}
+		} else if !f.holds(t, -1) {
+			violations = append(violations, Violation{
+				Check:   "temporal",
+				Message: fmt.Sprintf("trace %d violates %q", id, f),
+				Records: t.records[:1],
+			})
+		}
+	}
+	return violations
+}
+
+// ParseFormula parses a formula made of patterns of tags (see Tag), and in
+// decreasing order of precedence:
+//
+//	!F, always F, eventually F
+//	F until F
+//	F && F
+//	F || F
+//	F -> F
+//
+// with parentheses for grouping. until and -> are right associative.
+func ParseFormula(s string) (Formula, error) {
+	p := &formulaParser{tokens: tokenizeFormula(s)}
+	f, err := p.implication()
+	if err == nil && len(p.tokens) > 0 {
+		err = fmt.Errorf("unexpected %q", p.tokens[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid formula %q: %v", s, err)
+	}
+	return f, nil
+}
+
+// ParseFormulas parses the formulas in r, one per line, see ParseFormula.
+// Empty lines and lines starting with # are ignored.
+func ParseFormulas(r io.Reader) ([]Formula, error) {
+	var formulas []Formula
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		f, err := ParseFormula(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		formulas = append(formulas, f)
+	}
+	return formulas, scanner.Err()
+}
+
+// tokenizeFormula splits s into parentheses, operators and words.
+func tokenizeFormula(s string) []string {
+	var tokens []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		n := 1
+		switch {
+		case strings.HasPrefix(s, "->"), strings.HasPrefix(s, "&&"), strings.HasPrefix(s, "||"):
+			n = 2
+		case s[0] == '(' || s[0] == ')' || s[0] == '!':
+		default:
+			for n < len(s) && !unicode.IsSpace(rune(s[n])) && !strings.ContainsRune("()!&|", rune(s[n])) && !strings.HasPrefix(s[n:], "->") {
+				n++
+			}
+		}
+		tokens = append(tokens, s[:n])
+		s = s[n:]
+	}
+	return tokens
+}
+
+// formulaParser is a recursive descent parser of formulas.
+type formulaParser struct {
+	tokens []string
+}
+
+// accept consumes the next token if it is token.
+func (p *formulaParser) accept(token string) bool {
+	if len(p.tokens) > 0 && p.tokens[0] == token {
+		p.tokens = p.tokens[1:]
+		return true
+	}
+	return false
+}
+
+func (p *formulaParser) implication() (Formula, error) {
+	return p.binary("->", Implies, p.disjunction, true)
+}
+
+func (p *formulaParser) disjunction() (Formula, error) {
+	return p.binary("||", Or, p.conjunction, false)
+}
+
+func (p *formulaParser) conjunction() (Formula, error) {
+	return p.binary("&&", And, p.until, false)
+}
+
+func (p *formulaParser) until() (Formula, error) {
+	return p.binary("until", Until, p.unary, true)
+}
+
+// binary parses operands separated by op.
+func (p *formulaParser) binary(op string, combine func(a, b Formula) Formula, next func() (Formula, error), right bool) (Formula, error) {
+	f, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(op) {
+		var g Formula
+		if right {
+			g, err = p.binary(op, combine, next, right)
+		} else {
+			g, err = next()
+		}
+		if err != nil {
+			return nil, err
+		}
+		f = combine(f, g)
+	}
+	return f, nil
+}
+
+func (p *formulaParser) unary() (Formula, error) {
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	token := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	switch token {
+	case "!", "always", "eventually":
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case "!":
+			return Not(f), nil
+		case "always":
+			return Always(f), nil
+		}
+		return Eventually(f), nil
+	case "(":
+		f, err := p.implication()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("expected )")
+		}
+		return f, nil
+	case ")", "->", "&&", "||", "until":
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	if _, err := path.Match(token, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", token, err)
+	}
+	return Tag(token), nil
+}
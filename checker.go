@@ -0,0 +1,229 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// ViolationTracer is the tracer identity of the records of violations, see
+// TracingServerConfig.ViolationRecords. Checkers do not check its records.
+const ViolationTracer = "tracing-checker"
+
+// violationBody is the body of the records of violations, tagged Violation.
+type violationBody struct {
+	Check   string
+	Message string
+}
+
+// Violation is an anomaly found in records, by a Checker, or by the checks
+// of github.com/DistributedClocks/tracing/check.
+type Violation struct {
+	Check   string        // the name of the check, e.g. "causality"
+	Message string        // what is wrong
+	Records []TraceRecord // the offending records, e.g. a pair whose clocks contradict each other
+}
+
+// String returns the violation on a line, followed by its records, one per
+// line, in JSON, indented.
+func (v Violation) String() string {
+	var b strings.Builder
+	b.WriteString(v.Check + ": " + v.Message)
+	for _, record := range v.Records {
+		data, _ := json.Marshal(record)
+		b.WriteString("\n\t" + string(data))
+	}
+	return b.String()
+}
+
+// Checker checks the records a tracing server writes as they arrive, to
+// catch anomalies during a run rather than after it, see
+// TracingServerConfig.Checkers. Its methods are called from one goroutine
+// at a time.
+type Checker interface {
+	// Check checks record, the next one written, and returns the
+	// violations it reveals.
+	Check(record TraceRecord) []Violation
+	// Finish returns the violations only revealed by the end of the run,
+	// when the server is closed, e.g. tokens never received.
+	Finish() []Violation
+}
+
+var (
+	registeredCheckersLock          sync.RWMutex
+	registeredCheckers              = make(map[string]func() Checker)
+	registeredParameterizedCheckers = make(map[string]func(argument string) (Checker, error))
+)
+
+// RegisterChecker makes the checkers created by newChecker available to
+// servers under name, in TracingServerConfig.Checkers. It is meant to be
+// called from the init function of packages providing checkers, such as
+// github.com/DistributedClocks/tracing/check, which servers then import for
+// its side effects.
+func RegisterChecker(name string, newChecker func() Checker) {
+	registeredCheckersLock.Lock()
+	defer registeredCheckersLock.Unlock()
+	registeredCheckers[name] = newChecker
+}
+
+// RegisterParameterizedChecker is RegisterChecker for checkers that take an
+// argument, e.g. the file of a specification, which
+// TracingServerConfig.Checkers gives after their name and a colon, e.g.
+// "statemachine:assignment2.json". newChecker fails if the argument is
+// invalid, failing to open the server.
+func RegisterParameterizedChecker(name string, newChecker func(argument string) (Checker, error)) {
+	registeredCheckersLock.Lock()
+	defer registeredCheckersLock.Unlock()
+	registeredParameterizedCheckers[name] = newChecker
+}
+
+// newRegisteredChecker creates the checker registered under name, given as
+// NAME, or NAME:ARGUMENT for parameterized checkers.
+func newRegisteredChecker(name string) (Checker, error) {
+	registeredCheckersLock.RLock()
+	defer registeredCheckersLock.RUnlock()
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		if newChecker, ok := registeredParameterizedCheckers[name[:i]]; ok {
+			checker, err := newChecker(name[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("checker %s: %v", name[:i], err)
+			}
+			return checker, nil
+		}
+	} else if newChecker, ok := registeredCheckers[name]; ok {
+		return newChecker(), nil
+	}
+	var registered []string
+	for name := range registeredCheckers {
+		registered = append(registered, name)
+	}
+	for name := range registeredParameterizedCheckers {
+		registered = append(registered, name+":ARGUMENT")
+	}
+	sort.Strings(registered)
+	return nil, fmt.Errorf("unknown checker %q, registered: %s", name, strings.Join(registered, ", "))
+}
+
+// checkerSink feeds the records a server writes to its checkers, and logs
+// the violations they find, also writing them to the report file, if any,
+// one JSON object per line. If records is set, it also makes records of
+// them, for the server to write.
+type checkerSink struct {
+	checkers []Checker
+	report   *os.File
+	encoder  *json.Encoder
+
+	records bool
+	clock   vclock.VClock // of ViolationTracer, after all the records of violations
+	pending []TraceRecord // the records of violations not taken yet
+}
+
+// newCheckerSink creates the checkers named names, writing their
+// violations to the report file reportFileName, if set.
+func newCheckerSink(names []string, reportFileName string, flag int) (*checkerSink, error) {
+	s := &checkerSink{clock: vclock.New()}
+	for _, name := range names {
+		checker, err := newRegisteredChecker(name)
+		if err != nil {
+			return nil, err
+		}
+		s.checkers = append(s.checkers, checker)
+	}
+
+	if reportFileName != "" {
+		report, err := os.OpenFile(reportFileName, flag, 0666)
+		if err != nil {
+			return nil, err
+		}
+		s.report, s.encoder = report, json.NewEncoder(report)
+	}
+	return s, nil
+}
+
+func (s *checkerSink) Write(record TraceRecord) error {
+	if record.TracerIdentity == ViolationTracer {
+		return nil
+	}
+	for _, checker := range s.checkers {
+		violations := checker.Check(record)
+		if err := s.writeViolations(violations); err != nil {
+			return err
+		}
+		if s.records {
+			s.recordViolations(record, violations)
+		}
+	}
+	return nil
+}
+
+// recordViolations makes a record of each of violations, revealed by
+// record, in its trace, and after it: its clock is that of
+// ViolationTracer, which merges the clocks of the records revealing
+// violations.
+func (s *checkerSink) recordViolations(record TraceRecord, violations []Violation) {
+	for _, violation := range violations {
+		body, err := json.Marshal(violationBody{Check: violation.Check, Message: violation.Message})
+		if err != nil {
+			continue
+		}
+		s.clock.Merge(record.VectorClock)
+		s.clock.Tick(ViolationTracer)
+		s.pending = append(s.pending, TraceRecord{
+			TracerIdentity: ViolationTracer,
+			TraceID:        record.TraceID,
+			Tag:            "Violation",
+			Body:           body,
+			VectorClock:    s.clock.Copy(),
+		})
+	}
+}
+
+// takeRecords returns the records of the violations found since the last
+// call.
+func (s *checkerSink) takeRecords() []TraceRecord {
+	records := s.pending
+	s.pending = nil
+	return records
+}
+
+// writeViolations logs violations, and writes them to the report file.
+func (s *checkerSink) writeViolations(violations []Violation) error {
+	for _, violation := range violations {
+		log.Printf("violation of %s: %s", violation.Check, violation.Message)
+		if s.encoder != nil {
+			if err := s.encoder.Encode(violation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *checkerSink) Flush() error {
+	if s.report == nil {
+		return nil
+	}
+	return s.report.Sync()
+}
+
+// Close writes the violations the checkers find at the end of the run.
+func (s *checkerSink) Close() error {
+	var err error
+	for _, checker := range s.checkers {
+		if writeErr := s.writeViolations(checker.Finish()); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	if s.report != nil {
+		if closeErr := s.report.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
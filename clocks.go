@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// clockShards is the number of shards of a clockMap.
+const clockShards = 32
+
+// clockMap maps tracer identities to their last vector clock. It is
+// sharded by identity, so that the records of different tracers do not
+// contend for a single lock. The clocks are stored as received, and must
+// not be modified afterwards.
+type clockMap struct {
+	shards [clockShards]clockShard
+	dirty  int32 // whether a clock was stored since the last takeDirty, atomically
+}
+
+type clockShard struct {
+	lock   sync.RWMutex
+	clocks map[string]vclock.VClock
+}
+
+func newClockMap() *clockMap {
+	m := &clockMap{}
+	for i := range m.shards {
+		m.shards[i].clocks = make(map[string]vclock.VClock)
+	}
+	return m
+}
+
+func (m *clockMap) shard(identity string) *clockShard {
+	h := fnv.New32a()
+	h.Write([]byte(identity))
+	return &m.shards[h.Sum32()%clockShards]
+}
+
+// load returns the clock of identity, if any.
+func (m *clockMap) load(identity string) (vclock.VClock, bool) {
+	shard := m.shard(identity)
+	shard.lock.RLock()
+	defer shard.lock.RUnlock()
+	vc, ok := shard.clocks[identity]
+	return vc, ok
+}
+
+// store sets the clock of identity to vc, if it is later than its clock
+// (has more ticks of identity), as records missing their turn may be
+// recorded late, see awaitTurn, and marks the map dirty.
+func (m *clockMap) store(identity string, vc vclock.VClock) {
+	shard := m.shard(identity)
+	shard.lock.Lock()
+	if last, ok := shard.clocks[identity]; !ok || vc[identity] > last[identity] {
+		shard.clocks[identity] = vc
+	}
+	shard.lock.Unlock()
+	m.markDirty()
+}
+
+// storeMissing sets the clock of identity to vc, unless it has one
+// already.
+func (m *clockMap) storeMissing(identity string, vc vclock.VClock) {
+	shard := m.shard(identity)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+	if _, ok := shard.clocks[identity]; !ok {
+		shard.clocks[identity] = vc
+	}
+}
+
+// snapshot returns a copy of the map. The clocks themselves are shared.
+func (m *clockMap) snapshot() map[string]vclock.VClock {
+	clocks := make(map[string]vclock.VClock)
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.lock.RLock()
+		for identity, vc := range shard.clocks {
+			clocks[identity] = vc
+		}
+		shard.lock.RUnlock()
+	}
+	return clocks
+}
+
+func (m *clockMap) markDirty() {
+	atomic.StoreInt32(&m.dirty, 1)
+}
+
+// takeDirty returns whether a clock was stored since the last call, and
+// marks the map clean.
+func (m *clockMap) takeDirty() bool {
+	return atomic.SwapInt32(&m.dirty, 0) == 1
+}
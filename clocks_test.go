@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+func TestClockMap(t *testing.T) {
+	m := newClockMap()
+	m.storeMissing("client0", vclock.VClock{"client0": 1})
+	if m.takeDirty() {
+		t.Fatal("clocks loaded from the StateFile marked dirty")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			identity := fmt.Sprintf("client%d", i)
+			for tick := uint64(1); tick <= 10; tick++ {
+				m.store(identity, vclock.VClock{identity: tick})
+			}
+		}(i)
+	}
+	wg.Wait()
+	m.storeMissing("client0", vclock.VClock{"client0": 1})
+	// records recorded late do not set the clocks back
+	m.store("client1", vclock.VClock{"client1": 3})
+	m.store("client1", vclock.VClock{"client1": 10, "client2": 1})
+	if last, _ := m.load("client1"); len(last) != 1 {
+		t.Fatalf("expected the clock of client1 to be kept, got %v", last)
+	}
+
+	if !m.takeDirty() || m.takeDirty() {
+		t.Fatal("expected the map to be dirty once")
+	}
+	clocks := m.snapshot()
+	if len(clocks) != 50 {
+		t.Fatalf("expected the clocks of 50 tracers, got %d", len(clocks))
+	}
+	for identity, vc := range clocks {
+		if last, _ := m.load(identity); vc[identity] != 10 || last[identity] != 10 {
+			t.Fatalf("expected the last clock of %s, got %v", identity, vc)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Command convert converts output files written in the binary format (see
+// tracing.BinaryRecordReader) to JSON, one object per line, as the server
+// writes them by default:
+//
+//	convert trace_output.bin > trace_output.log
+//
+// With no file given, it converts its standard input.
+//
+// With -key, the file holding the raw bytes of the EncryptionKey of the
+// server, it decrypts encrypted output files, in the format given with
+// -format, binary by default:
+//
+//	convert -key course.key -format json trace_output.log > decrypted.log
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	keyFile := flag.String("key", "", "the file holding the raw bytes of the encryption key of the server, to decrypt encrypted output files")
+	format := flag.String("format", tracing.OutputFormatBinary, "the format of the output files: json or binary")
+	flag.Parse()
+
+	var key []byte
+	if *keyFile != "" {
+		var err error
+		if key, err = ioutil.ReadFile(*keyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	output := bufio.NewWriter(os.Stdout)
+	err := tracereader.EachFile(flag.Args(), func(r io.Reader) error {
+		return convert(r, *format, key, output)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := output.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// convert writes the records of r, an output file in format, encrypted
+// with key if set, to output as JSON, the header of the file included.
+func convert(r io.Reader, format string, key []byte, output io.Writer) error {
+	if key != nil {
+		var err error
+		if r, err = tracing.NewDecryptingReader(r, key); err != nil {
+			return err
+		}
+	}
+	switch format {
+	case tracing.OutputFormatJSON:
+		// already JSON once decrypted
+		_, err := io.Copy(output, r)
+		return err
+	case tracing.OutputFormatBinary:
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	reader := tracing.NewBinaryRecordReader(r)
+	encoder := json.NewEncoder(output)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Command critical-path reports the critical path of each trace in the
+// output files of a tracing server (see package criticalpath): the longest
+// chain of happens-before edges through its events, and the shares of the
+// tracers and actions in it, which dominate its end-to-end latency:
+//
+//	critical-path -trace 6234715893 trace_output.log
+//
+// Without -trace, it reports every trace. The records of all the files
+// given (e.g. partitions, or rotated files) are analyzed together. With no
+// file given, it reads its standard input. Files in the binary format
+// require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/criticalpath"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	traceID := flag.Uint64("trace", 0, "the ID of the trace to report, or 0 for every trace")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *traceID != 0 {
+		var traceRecords []tracing.TraceRecord
+		for _, record := range records {
+			if record.TraceID == *traceID {
+				traceRecords = append(traceRecords, record)
+			}
+		}
+		if len(traceRecords) == 0 {
+			log.Fatalf("no record of trace %d", *traceID)
+		}
+		records = traceRecords
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	if err := criticalpath.Write(output, criticalpath.Paths(records)); err != nil {
+		log.Fatal(err)
+	}
+	if err := output.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
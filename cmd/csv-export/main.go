@@ -0,0 +1,52 @@
+// Command csv-export flattens the output files of a tracing server to CSV,
+// one row per record, with a column per field of their bodies (see package
+// csv), for spreadsheets and data frames:
+//
+//	csv-export -o records.csv trace_output.log
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are written together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/csv"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the CSV file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	if err := csv.Write(writer, records); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Command html-report renders the output files of a tracing server as a
+// single, self-contained HTML page (see package htmlreport), to view a run
+// in any browser without ShiViz:
+//
+//	html-report -o report.html trace_output.log
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are rendered together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/htmlreport"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the HTML file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	title := flag.String("title", "", "the title of the report, the names of the files by default")
+	flag.Parse()
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *title == "" {
+		*title = "Tracing report"
+		if flag.NArg() > 0 {
+			*title += ": " + strings.Join(flag.Args(), ", ")
+		}
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	if err := htmlreport.Write(writer, *title, records); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Command jaeger-export converts the output files of a tracing server to a
+// JSON file of traces the Jaeger UI can load (with "Upload JSON"):
+//
+//	jaeger-export -o traces.json trace_output.log
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are converted together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary. Spans are placed on a
+// logical time axis (see package jaeger) starting at -start, by default the
+// modification time of the first file, or the current time.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/jaeger"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the JSON file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	startFlag := flag.String("start", "", "the start of the time axis, in RFC 3339 format")
+	flag.Parse()
+
+	start := time.Now()
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if info, err := os.Stat(flag.Arg(0)); err == nil {
+		start = info.ModTime()
+	}
+	if *startFlag != "" {
+		var err error
+		if start, err = time.Parse(time.RFC3339, *startFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	if err := json.NewEncoder(writer).Encode(jaeger.Convert(records, start)); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
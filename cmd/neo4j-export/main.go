@@ -0,0 +1,82 @@
+// Command neo4j-export converts the output files of a tracing server to a
+// happens-before graph for Neo4j (see package neo4j), as Cypher statements:
+//
+//	neo4j-export trace_output.log | cypher-shell -u neo4j -p password
+//
+// or, with -csv, as the CSV files of its nodes and relationships in a
+// directory, for neo4j-admin database import:
+//
+//	neo4j-export -csv import trace_output.log
+//	neo4j-admin database import full --nodes=import/nodes.csv --relationships=import/relationships.csv
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are converted together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/neo4j"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the file of Cypher statements to write, or - for the standard output")
+	csvDir := flag.String("csv", "", "the directory to write nodes.csv and relationships.csv to, instead of Cypher statements")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *csvDir != "" {
+		if err := os.MkdirAll(*csvDir, 0777); err != nil {
+			log.Fatal(err)
+		}
+		nodes, err := os.Create(filepath.Join(*csvDir, "nodes.csv"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		relationships, err := os.Create(filepath.Join(*csvDir, "relationships.csv"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := neo4j.WriteCSV(nodes, relationships, records); err != nil {
+			log.Fatal(err)
+		}
+		for _, file := range []*os.File{nodes, relationships} {
+			if err := file.Close(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	if err := neo4j.WriteCypher(writer, records); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Command parquet-export converts the output files of a tracing server to
+// an Apache Parquet file (see package parquet), to query them with DuckDB or
+// Spark:
+//
+//	parquet-export -o records.parquet trace_output*.log
+//
+// The records of all the files given (e.g. partitions, rotated files, or
+// the files of several servers) are written together, without holding them
+// all in memory. With no file given, it reads its standard input. Files in
+// the binary format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/parquet"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+// convert writes the records read from r to w.
+func convert(w *parquet.Writer, r io.Reader, format string) error {
+	reader, err := tracing.NewRecordReader(r, format)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+}
+
+func main() {
+	outputFile := flag.String("o", "-", "the Parquet file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	buffer := bufio.NewWriter(output)
+	writer := parquet.NewWriter(buffer)
+
+	err := tracereader.EachFile(flag.Args(), func(r io.Reader) error {
+		return convert(writer, r, *format)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := buffer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Command plantuml-export renders the output files of a tracing server as
+// PlantUML sequence diagrams, one per trace (see package plantuml):
+//
+//	plantuml-export -o traces.puml trace_output.log
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are rendered together, or only those of the trace given with -trace.
+// With no file given, it reads its standard input. Files in the binary
+// format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/plantuml"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the PlantUML file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	traceID := flag.Uint64("trace", 0, "the ID of the trace to render, if not all")
+	flag.Parse()
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *traceID != 0 {
+		var traceRecords []tracing.TraceRecord
+		for _, record := range records {
+			if record.TraceID == *traceID {
+				traceRecords = append(traceRecords, record)
+			}
+		}
+		records = traceRecords
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	if err := plantuml.Write(writer, records); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
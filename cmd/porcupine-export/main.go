@@ -0,0 +1,69 @@
+// Command porcupine-export converts the output files of a tracing server to
+// a JSON history of operations for the Porcupine linearizability checker
+// (see package history), pairing the records whose tags match -calls with
+// the ones matching -returns:
+//
+//	porcupine-export -calls '*Request' -returns '*Response' -o history.json trace_output.log
+//
+// The inputs and outputs of the operations are the tags and bodies of their
+// records, {"Tag": ..., "Body": ...}. To map actions to the inputs and
+// outputs of a model otherwise, use package history directly.
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are converted together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/history"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the JSON file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	calls := flag.String("calls", "*Request", "the pattern of the tags of the calls of operations")
+	returns := flag.String("returns", "*Response", "the pattern of the tags of the returns of operations")
+	flag.Parse()
+
+	var records []tracereader.Record
+	err := tracereader.EachFile(flag.Args(), func(r io.Reader) error {
+		reader, err := tracereader.NewReader(r, *format)
+		if err != nil {
+			return err
+		}
+		fileRecords, err := reader.ReadAll()
+		records = append(records, fileRecords...)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	if err := history.Write(writer, records, history.TagMapping(*calls, *returns)); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
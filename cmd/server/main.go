@@ -2,8 +2,12 @@ package main
 
 import (
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/DistributedClocks/tracing"
+	_ "github.com/DistributedClocks/tracing/check" // registers the checkers of TracingServerConfig.Checkers
 )
 
 func main() {
@@ -14,5 +18,13 @@ func main() {
 		log.Fatal(err)
 	}
 
-	tracingServer.Accept() // serve requests forever
+	go tracingServer.Accept() // serve requests until signaled
+
+	// closing the server writes the records it buffered, see FlushEvery
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	if err := tracingServer.Close(); err != nil {
+		log.Fatal(err)
+	}
 }
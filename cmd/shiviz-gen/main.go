@@ -0,0 +1,99 @@
+// Command shiviz-gen regenerates the ShiViz-compatible log of a tracing
+// server from its output files, e.g. when the ShiViz output file was lost,
+// or the run did not write one:
+//
+//	shiviz-gen -o shiviz_output.log trace_output.log
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are written to the same log, in order. With no file given, it reads its
+// standard input. Files in the binary format require -format binary.
+//
+// With -trace, only the records of the traces given are written, for ShiViz
+// cannot open the logs of whole runs:
+//
+//	shiviz-gen -trace 5672529976708428194,812 -o shiviz_trace.log trace_output.log
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the ShiViz log to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	traceIDs := flag.String("trace", "", "the IDs of the traces to write, separated by commas, all by default")
+	flag.Parse()
+
+	var traces map[uint64]bool
+	if *traceIDs != "" {
+		traces = make(map[uint64]bool)
+		for _, traceID := range strings.Split(*traceIDs, ",") {
+			id, err := strconv.ParseUint(traceID, 10, 64)
+			if err != nil {
+				log.Fatalf("invalid trace ID %q", traceID)
+			}
+			traces[id] = true
+		}
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	shiviz, err := tracing.NewShivizWriter(writer)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = tracereader.EachFile(flag.Args(), func(r io.Reader) error {
+		return convert(r, *format, traces, shiviz)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// convert writes the records of the output file r, in format, to shiviz,
+// those of traces only if not nil.
+func convert(r io.Reader, format string, traces map[uint64]bool, shiviz *tracing.ShivizWriter) error {
+	reader, err := tracing.NewRecordReader(r, format)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if traces != nil && !traces[record.TraceID] {
+			continue
+		}
+		if err := shiviz.Write(record); err != nil {
+			return err
+		}
+	}
+}
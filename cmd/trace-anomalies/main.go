@@ -0,0 +1,67 @@
+// Command trace-anomalies groups the traces of the output files of a
+// tracing server by their shape, the sequences of the tags of the actions
+// of their tracers (see package anomaly), and lists the traces of rare
+// shapes for review, e.g. to find the few broken executions of a run:
+//
+//	trace-anomalies -threshold 0.01 trace_output.log
+//
+// The shapes of less than -threshold of the traces are rare. With -tracers,
+// the identities of the tracers are part of the shapes. With -collapse, the
+// repeated actions of a tracer, e.g. retries, do not make shapes differ. It
+// exits with status 1 if it finds any rare shape.
+//
+// The records of all the files given are analyzed together. With no file
+// given, it reads its standard input. Files in the binary format require
+// -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/anomaly"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the file to write, or - for the standard output")
+	threshold := flag.Float64("threshold", 0.01, "the share of the traces below which a shape is rare")
+	var options anomaly.Options
+	flag.BoolVar(&options.Tracers, "tracers", false, "include the identities of the tracers in the shapes")
+	flag.BoolVar(&options.Collapse, "collapse", false, "collapse the consecutive actions of a tracer with the same tag")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	clusters := anomaly.Clusters(records, options)
+	outliers := anomaly.Outliers(clusters, *threshold)
+	if err := anomaly.Write(writer, clusters, outliers); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(outliers) > 0 {
+		os.Exit(1)
+	}
+}
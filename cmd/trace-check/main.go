@@ -0,0 +1,129 @@
+// Command trace-check checks the output files of a tracing server for
+// anomalies (see package check), and reports the violations it finds with
+// the offending records:
+//
+//	trace-check trace_output.log
+//
+// It exits with status 1 if it finds any, e.g. to fail a grading script.
+// The checks to run are selected with -checks, all of them by default:
+//   - causality: vector clock regressions and skips, and receptions of
+//     tokens before their generation.
+//   - orphans: tokens generated but never received, or received but never
+//     generated.
+//   - duplicates: trace IDs created several times, rather than propagated
+//     by tokens.
+//   - stalls: traces where a token was generated and nothing happened
+//     after it, on any tracer, e.g. because of a deadlock or a lost
+//     message.
+//
+// With -rules, it also checks the ordering rules in the given file, one per
+// line, e.g. "Put followed by PutResult with same Key before TraceEnd", or
+// "Put on client* followed by PutRecvd on server*" for causal edges between
+// tracers (see check.ParseRule). With -formulas, it checks the temporal formulas in the
+// given file, one per line, e.g. "always (GetRequest -> eventually
+// GetResponse)" (see check.ParseFormula). With -statemachine, it checks
+// that the actions of each trace follow the state machine in the given JSON
+// file (see check.StateMachine).
+//
+// The records of all the files given are checked together, in order. With
+// no file given, it reads its standard input. Files in the binary format
+// require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/check"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	checksFlag := flag.String("checks", strings.Join(check.CheckNames(), ","), "the checks to run, separated by commas")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	rulesFile := flag.String("rules", "", "a file of ordering rules to check, one per line")
+	formulasFile := flag.String("formulas", "", "a file of temporal formulas to check, one per line")
+	stateMachineFile := flag.String("statemachine", "", "a JSON file of a state machine the actions of each trace must follow")
+	flag.Parse()
+
+	var rules []check.Rule
+	if *rulesFile != "" {
+		file, err := os.Open(*rulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rules, err = check.ParseRules(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("reading %s: %v", *rulesFile, err)
+		}
+	}
+	var formulas []check.Formula
+	if *formulasFile != "" {
+		file, err := os.Open(*formulasFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		formulas, err = check.ParseFormulas(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("reading %s: %v", *formulasFile, err)
+		}
+	}
+	var machine *check.StateMachine
+	if *stateMachineFile != "" {
+		m, err := check.LoadStateMachine(*stateMachineFile)
+		if err != nil {
+			log.Fatalf("reading %s: %v", *stateMachineFile, err)
+		}
+		machine = &m
+	}
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	violations := 0
+	for _, name := range strings.Split(*checksFlag, ",") {
+		run, ok := check.Checks[name]
+		if !ok {
+			log.Fatalf("unknown check %q, expected one of %s", name, strings.Join(check.CheckNames(), ", "))
+		}
+		for _, violation := range run(records) {
+			fmt.Fprintln(output, violation)
+			violations++
+		}
+	}
+	for _, rule := range rules {
+		for _, violation := range rule.Check(records) {
+			fmt.Fprintln(output, violation)
+			violations++
+		}
+	}
+	for _, formula := range formulas {
+		for _, violation := range check.CheckFormula(formula, records) {
+			fmt.Fprintln(output, violation)
+			violations++
+		}
+	}
+	if machine != nil {
+		for _, violation := range machine.Check(records) {
+			fmt.Fprintln(output, violation)
+			violations++
+		}
+	}
+	if err := output.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if violations > 0 {
+		log.Printf("%d violations in %d records", violations, len(records))
+		os.Exit(1)
+	}
+}
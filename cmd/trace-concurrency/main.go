@@ -0,0 +1,70 @@
+// Command trace-concurrency lists, for each trace of the output files of a
+// tracing server, the pairs of actions that are concurrent according to
+// their vector clocks, neither happening before the other (see package
+// concurrency), e.g. to verify that operations which must be serialized
+// were:
+//
+//	trace-concurrency -tags Put:Put,Put:Get trace_output.log
+//
+// With -tags, only the pairs of actions with the given tags are listed. It
+// exits with status 1 if it finds any pair.
+//
+// The records of all the files given are analyzed together. With no file
+// given, it reads its standard input. Files in the binary format require
+// -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/concurrency"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the file to write, or - for the standard output")
+	tags := flag.String("tags", "", "the pairs of tags of the actions to list, e.g. Put:Put,Put:Get, all by default")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	var tagPairs []concurrency.TagPair
+	if *tags != "" {
+		var err error
+		if tagPairs, err = concurrency.ParseTagPairs(*tags); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	pairs := concurrency.Pairs(records, tagPairs)
+	if err := concurrency.Write(writer, pairs); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(pairs) > 0 {
+		os.Exit(1)
+	}
+}
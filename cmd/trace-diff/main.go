@@ -0,0 +1,60 @@
+// Command trace-diff compares the traces of two output files of tracing
+// servers by their shape (see package diff): the actions of their events,
+// and the causal edges between them, ignoring IDs and clocks, e.g. to
+// compare a student run against the run of a reference solution:
+//
+//	trace-diff reference.log run.log
+//
+// It prints the events of each trace of the reference missing from the run
+// (-), added to it (+) or reordered (~), and exits with status 1 if any
+// trace differs, like diff. Files in the binary format require -format
+// binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/diff"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+// readFile returns the records of the output file fileName.
+func readFile(fileName, format string) []tracing.TraceRecord {
+	records, err := tracereader.ReadFiles([]string{fileName}, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return records
+}
+
+func main() {
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] REFERENCE RUN\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	diffs := diff.Compare(readFile(flag.Arg(0), *format), readFile(flag.Arg(1), *format))
+	output := bufio.NewWriter(os.Stdout)
+	if err := diff.Write(output, diffs); err != nil {
+		log.Fatal(err)
+	}
+	if err := output.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range diffs {
+		if !d.Identical() {
+			os.Exit(1)
+		}
+	}
+}
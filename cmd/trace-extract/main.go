@@ -0,0 +1,114 @@
+// Command trace-extract extracts the records of a trace from the output
+// files of a tracing server to an output file of their own, e.g. to share
+// or analyze a single trace of a large run:
+//
+//	trace-extract -trace 5672529976708428194 -o trace.log trace_output.log
+//
+// With -follow, the traces linked to it by tokens are extracted too: the
+// traces of the tokens its records receive (see Tracer.ReceiveTokens), and
+// those receiving its tokens, transitively.
+//
+// The records are written in causal order, by the sum of the entries of
+// their vector clocks, and in their order in the files otherwise. The
+// records of all the files given (e.g. partitions, or rotated files) are
+// extracted together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary, and are extracted to
+// the binary format.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+// linkedTraces returns the traces linked to traceID by tokens in records,
+// traceID included.
+func linkedTraces(records []tracing.TraceRecord, traceID uint64) map[uint64]bool {
+	links := make(map[uint64][]uint64)
+	for _, record := range records {
+		for _, token := range events.ReceivedTokens(record) {
+			if token.TraceID != record.TraceID {
+				links[token.TraceID] = append(links[token.TraceID], record.TraceID)
+				links[record.TraceID] = append(links[record.TraceID], token.TraceID)
+			}
+		}
+	}
+	traces := map[uint64]bool{traceID: true}
+	for queue := []uint64{traceID}; len(queue) > 0; queue = queue[1:] {
+		for _, linked := range links[queue[0]] {
+			if !traces[linked] {
+				traces[linked] = true
+				queue = append(queue, linked)
+			}
+		}
+	}
+	return traces
+}
+
+func main() {
+	traceID := flag.Uint64("trace", 0, "the ID of the trace to extract")
+	follow := flag.Bool("follow", false, "extract the traces linked to the trace by tokens too")
+	outputFile := flag.String("o", "-", "the output file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+	if *traceID == 0 {
+		log.Fatal("no trace given, see -trace")
+	}
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	traces := map[uint64]bool{*traceID: true}
+	if *follow {
+		traces = linkedTraces(records, *traceID)
+	}
+	var extracted []tracing.TraceRecord
+	for _, record := range records {
+		if traces[record.TraceID] {
+			extracted = append(extracted, record)
+		}
+	}
+	if len(extracted) == 0 {
+		log.Fatalf("no record of trace %d", *traceID)
+	}
+	// the sum of the entries of the clocks grows along the happens-before
+	// relation, unlike the order of files written concurrently
+	sort.SliceStable(extracted, func(i, j int) bool {
+		return events.LogicalTime(extracted[i].VectorClock) < events.LogicalTime(extracted[j].VectorClock)
+	})
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	buffer := bufio.NewWriter(output)
+	writer, err := tracing.NewRecordWriter(buffer, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, record := range extracted {
+		if err := writer.Write(record); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := buffer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Command trace-grade grades a submission by the output files of a tracing
+// server: it runs the checks of a configuration (see check.Config) on their
+// records, writes a report in JSON with, for each check, whether it passed,
+// the number of its violations and their details, and prints a summary
+// suitable for the feedback of an autograder:
+//
+//	trace-grade -config grading.json -report report.json trace_output.log
+//
+// e.g. with grading.json:
+//
+//	{
+//	  "Checks": ["causality", "orphans"],
+//	  "Rules": ["Put followed by PutResult with same Key"],
+//	  "Formulas": ["always (GetRequest -> eventually GetResponse)"],
+//	  "MaxViolations": 10
+//	}
+//
+// Without -config, it runs all the checks of package check. The report is
+// written to the standard output, after the summary, unless -report is set.
+// It exits with status 1 if any check fails.
+//
+// With -key, the file of the SigningKey of the server that wrote the output
+// files, it first verifies their signatures (see tracing.VerifySignatures),
+// and fails if any file was edited after the fact.
+//
+// The records of all the files given are checked together, in order. With
+// no file given, it reads its standard input. Files in the binary format
+// require -format binary.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/check"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	configFile := flag.String("config", "", "the JSON file of the checks to run, all the checks of package check by default")
+	reportFile := flag.String("report", "-", "the file to write the report to, - for the standard output")
+	keyFile := flag.String("key", "", "the file holding the raw bytes of the signing key of the server, to verify the signatures of the output files")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	if *keyFile != "" {
+		key, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if flag.NArg() == 0 {
+			log.Fatal("the signatures of the standard input cannot be verified")
+		}
+		for _, fileName := range flag.Args() {
+			if err := tracing.VerifySignatures(fileName, *format, key); err != nil {
+				log.Fatalf("verifying %s: %v", fileName, err)
+			}
+		}
+	}
+
+	config := check.Config{Checks: check.CheckNames()}
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config = check.Config{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			log.Fatalf("reading %s: %v", *configFile, err)
+		}
+	}
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := config.Run(records)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	if err := report.WriteSummary(output); err != nil {
+		log.Fatal(err)
+	}
+	file := os.Stdout
+	if *reportFile != "-" {
+		if err := output.Flush(); err != nil {
+			log.Fatal(err)
+		}
+		if file, err = os.Create(*reportFile); err != nil {
+			log.Fatal(err)
+		}
+		output = bufio.NewWriter(file)
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "\t")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if err := output.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if file != os.Stdout {
+		if err := file.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,127 @@
+// Command trace-loadgen simulates tracers recording actions against a
+// running tracing server, and reports the throughput and latencies it
+// measured, e.g. to size a server for a class, or to compare releases:
+//
+//	trace-loadgen -server 127.0.0.1:42124 -tracers 100 -rate 50 -duration 30s
+//
+// Each of the -tracers tracers, named loadgen0, loadgen1, ..., records
+// actions with bodies of -size bytes in a trace of its own, -rate times
+// per second (as fast as it can if 0), for -duration. With -token-every,
+// every so many actions, a tracer also generates a token that the next
+// tracer receives, as nodes exchanging messages do.
+//
+// With -config, the tracers are configured by a tracer configuration file
+// instead (see tracing.NewTracerFromFile), e.g. for TLS, a Secret or
+// compression; its ServerAddress is overridden by -server if set, and its
+// TracerIdentity by the names of the tracers.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// LoadAction is the action the tracers record.
+type LoadAction struct {
+	N       int
+	Payload string
+}
+
+func main() {
+	server := flag.String("server", "", "the address of the tracing server to load")
+	configFile := flag.String("config", "", "a tracer configuration file configuring the tracers")
+	tracers := flag.Int("tracers", 10, "the number of tracers")
+	rate := flag.Float64("rate", 10, "the actions each tracer records per second, as many as it can if 0")
+	duration := flag.Duration("duration", 10*time.Second, "how long the tracers record actions")
+	size := flag.Int("size", 64, "the size of the payload of the actions, in bytes")
+	tokenEvery := flag.Int("token-every", 0, "pass a token to the next tracer every so many actions, never if 0")
+	flag.Parse()
+
+	var config tracing.TracerConfig
+	if *configFile != "" {
+		configData, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			log.Fatal("reading config file: ", err)
+		}
+		if err := json.Unmarshal(configData, &config); err != nil {
+			log.Fatal("parsing config data: ", err)
+		}
+	}
+	if *server != "" {
+		config.ServerAddress = *server
+	}
+	if config.ServerAddress == "" {
+		log.Fatal("no server to load, see -server")
+	}
+	if *tracers <= 0 {
+		log.Fatal("-tracers must be positive")
+	}
+
+	clients := make([]*tracing.Tracer, *tracers)
+	for i := range clients {
+		config.TracerIdentity = fmt.Sprintf("loadgen%d", i)
+		if clients[i] = tracing.NewTracerNonFatal(config); clients[i] == nil {
+			log.Fatalf("%s could not connect to %s", config.TracerIdentity, config.ServerAddress)
+		}
+		clients[i].SetShouldPrint(false)
+	}
+
+	payload := strings.Repeat("x", *size)
+	latencies := make([][]time.Duration, len(clients))
+	deadline := time.Now().Add(*duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *tracing.Tracer) {
+			defer wg.Done()
+			var ticker *time.Ticker
+			if *rate > 0 {
+				ticker = time.NewTicker(time.Duration(float64(time.Second) / *rate))
+				defer ticker.Stop()
+			}
+			trace := client.CreateTrace()
+			for n := 1; time.Now().Before(deadline); n++ {
+				if ticker != nil {
+					<-ticker.C
+				}
+				recordStart := time.Now()
+				trace.RecordAction(LoadAction{N: n, Payload: payload})
+				latencies[i] = append(latencies[i], time.Since(recordStart))
+				if *tokenEvery > 0 && n%*tokenEvery == 0 {
+					clients[(i+1)%len(clients)].ReceiveToken(trace.GenerateToken())
+				}
+			}
+		}(i, client)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	for _, client := range clients {
+		client.Close()
+	}
+
+	var all []time.Duration
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+	if len(all) == 0 {
+		log.Fatal("no action recorded")
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	percentile := func(p float64) time.Duration {
+		return all[int(p*float64(len(all)-1))]
+	}
+	fmt.Printf("%d actions recorded by %d tracers in %v: %.0f actions/s\n",
+		len(all), len(clients), elapsed.Round(time.Millisecond), float64(len(all))/elapsed.Seconds())
+	fmt.Printf("RecordAction latency: p50 %v, p90 %v, p99 %v, max %v\n",
+		percentile(0.5), percentile(0.9), percentile(0.99), all[len(all)-1])
+}
@@ -0,0 +1,75 @@
+// Command trace-merge merges the output files of several tracing servers,
+// e.g. one per region, into a single output file:
+//
+//	trace-merge -o trace_output.log region1.log region2.log
+//
+// The records are written in an order consistent with their vector clocks,
+// and those found in several files, e.g. when servers relay to each other,
+// are written once (see package merge). Records with the same identity but
+// different contents are reported, and only the first is kept.
+//
+// Files in the binary format require -format binary, and are merged to the
+// binary format.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/merge"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the output file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	var inputs [][]tracing.TraceRecord
+	for _, fileName := range flag.Args() {
+		records, err := tracereader.ReadFiles([]string{fileName}, *format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		inputs = append(inputs, records)
+	}
+	merged, duplicates := merge.Merge(inputs)
+	for _, duplicate := range duplicates {
+		if duplicate.Conflicting() {
+			record := duplicate.Record
+			log.Printf("%s: conflicting records of %s at %d, keeping the first: %s", flag.Arg(duplicate.Input), record.TracerIdentity, record.VectorClock[record.TracerIdentity], record.Body)
+		}
+	}
+	if len(duplicates) > 0 {
+		log.Printf("merged %d records, skipping %d duplicates", len(merged), len(duplicates))
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	buffer := bufio.NewWriter(output)
+	writer, err := tracing.NewRecordWriter(buffer, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, record := range merged {
+		if err := writer.Write(record); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := buffer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
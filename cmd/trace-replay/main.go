@@ -0,0 +1,59 @@
+// Command trace-replay re-submits the records of output files of a tracing
+// server to a running tracing server (see tracing.Replay), preserving their
+// identities, clocks and order, e.g. to exercise the features of a server
+// with a captured workload:
+//
+//	trace-replay -server 127.0.0.1:42124 trace_output.log
+//
+// With -config, the connection is configured by a tracer configuration
+// file instead (see tracing.NewTracerFromFile), e.g. for TLS; its
+// ServerAddress is overridden by -server if set.
+//
+// The records of all the files given are replayed in order. With no file
+// given, it reads its standard input. Files in the binary format require
+// -format binary.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	server := flag.String("server", "", "the address of the tracing server to replay the records to")
+	configFile := flag.String("config", "", "a tracer configuration file configuring the connection")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	var config tracing.TracerConfig
+	if *configFile != "" {
+		configData, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			log.Fatal("reading config file: ", err)
+		}
+		if err := json.Unmarshal(configData, &config); err != nil {
+			log.Fatal("parsing config data: ", err)
+		}
+	}
+	if *server != "" {
+		config.ServerAddress = *server
+	}
+	if config.ServerAddress == "" {
+		log.Fatal("no server to replay the records to, see -server")
+	}
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := tracing.Replay(config, records); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("replayed %d records to %s", len(records), config.ServerAddress)
+}
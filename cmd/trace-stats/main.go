@@ -0,0 +1,124 @@
+// Command trace-stats computes per-trace metrics from the output files of a
+// tracing server (see package stats): the number of actions, of tracers and
+// of tokens of each trace, its causal depth and its logical duration, as
+// JSON, or CSV with -csv:
+//
+//	trace-stats -csv -o stats.csv trace_output.log
+//
+// With -summary, it prints a summary of the run instead, the first thing to
+// look at in a submission: the records of each tracer and of each tag, the
+// traces started and completed, the tokens generated and received, and the
+// health of the files, the records missing from the gaps in the sequence
+// numbers of tracers and the lines that are not records (see
+// stats.Summarize).
+//
+// With -coverage, it reports instead which of the action types declared in
+// the given file each tracer recorded, and which are missing (see
+// stats.ParseDeclarations), to catch incomplete instrumentation before
+// checking the traces, and exits with status 1 if any is missing:
+//
+//	trace-stats -coverage types.txt trace_output.log
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are analyzed together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/stats"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the file to write, or - for the standard output")
+	csv := flag.Bool("csv", false, "write CSV instead of JSON")
+	summary := flag.Bool("summary", false, "write a summary of the run instead")
+	coverageFile := flag.String("coverage", "", "a file of the action types each tracer must record, to report their coverage instead")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	flag.Parse()
+
+	var declarations []stats.Declaration
+	if *coverageFile != "" {
+		file, err := os.Open(*coverageFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		declarations, err = stats.ParseDeclarations(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("reading %s: %v", *coverageFile, err)
+		}
+	}
+
+	// With -summary, the lines that are not records are counted rather
+	// than fatal.
+	read := func(r io.Reader) ([]tracing.TraceRecord, int, error) {
+		records, err := tracing.ReadRecords(r, *format)
+		return records, 0, err
+	}
+	if *summary {
+		read = func(r io.Reader) ([]tracing.TraceRecord, int, error) {
+			return stats.Read(r, *format)
+		}
+	}
+	var records []tracing.TraceRecord
+	parseErrors := 0
+	err := tracereader.EachFile(flag.Args(), func(r io.Reader) error {
+		fileRecords, fileParseErrors, err := read(r)
+		records = append(records, fileRecords...)
+		parseErrors += fileParseErrors
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	write := stats.WriteJSON
+	if *csv {
+		write = stats.WriteCSV
+	}
+	if *summary {
+		write = func(w io.Writer, records []tracing.TraceRecord) error {
+			s := stats.Summarize(records)
+			s.ParseErrors = parseErrors
+			return s.Write(w)
+		}
+	}
+	complete := true
+	if *coverageFile != "" {
+		write = func(w io.Writer, records []tracing.TraceRecord) error {
+			coverage := stats.ComputeCoverage(records, declarations)
+			complete = coverage.Complete()
+			return coverage.Write(w)
+		}
+	}
+	if err := write(writer, records); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !complete {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,86 @@
+// Command trace-tail prints the records a tracing server writes as they
+// arrive, one JSON object per line as in its output files, e.g. to watch a
+// class-wide run in real time:
+//
+//	trace-tail -server localhost:8080 -tracer client1,client2 -tag Put
+//
+// The server must stream its records, on the address of its TailBind
+// configuration field. With -tracer, -tag and -trace, only the records of
+// the given tracers, tags and trace IDs are printed, each separated by
+// commas. Records the server dropped because trace-tail did not keep up
+// are reported on the standard error. It exits once the server closes.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func main() {
+	server := flag.String("server", "localhost:8080", "the address the server streams its records on, its TailBind")
+	tracers := flag.String("tracer", "", "the tracers of the records to print, separated by commas, all by default")
+	tags := flag.String("tag", "", "the tags of the records to print, separated by commas, all by default")
+	traces := flag.String("trace", "", "the IDs of the traces of the records to print, separated by commas, all by default")
+	flag.Parse()
+
+	address := *server
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	query := u.Query()
+	for name, value := range map[string]string{"tracer": *tracers, "tag": *tags, "trace": *traces} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var message strings.Builder
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			message.WriteString(scanner.Text())
+		}
+		log.Fatalf("%s: %s", resp.Status, message.String())
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 64*1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := strings.TrimPrefix(line, "data: ")
+			if event == "dropped" {
+				log.Printf("the server dropped %s records", data)
+				continue
+			}
+			// unbuffered, to print the records as they arrive
+			if _, err := os.Stdout.WriteString(data + "\n"); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
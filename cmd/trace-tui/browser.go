@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// trace is a trace of the file, its records in causal order.
+type trace struct {
+	id      uint64
+	records []tracing.TraceRecord
+	tracers []string
+}
+
+// browser is the state of the UI: the list of traces, or a trace with a
+// cursor on one of its events.
+type browser struct {
+	traces  []*trace
+	height  int // the number of lines of lists
+	current *trace
+	cursor  int    // the index of the selected trace in the list, or event in the current trace
+	status  string // a message for the user, e.g. the result of the last command
+}
+
+func newBrowser(records []tracing.TraceRecord, height int) *browser {
+	var ids []uint64
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			ids = append(ids, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+	b := &browser{height: height}
+	for _, id := range ids {
+		t := &trace{id: id, records: events.Order(byTrace[id])}
+		tracers := make(map[string]bool)
+		for _, record := range t.records {
+			if !tracers[record.TracerIdentity] {
+				tracers[record.TracerIdentity] = true
+				t.tracers = append(t.tracers, record.TracerIdentity)
+			}
+		}
+		sort.Strings(t.tracers)
+		b.traces = append(b.traces, t)
+	}
+	return b
+}
+
+// help lists the commands.
+const help = `commands, followed by Enter:
+  n, p        next, previous trace or event (Enter alone is n)
+  g N         go to trace or event N
+  o [N]       open the selected trace, or trace N of the list
+  t ID        open the trace of ID
+  j           jump between a token generation and its receptions
+  /TEXT       search TEXT in the bodies, from the selection on
+  b           back to the list of traces
+  q           quit`
+
+// execute executes command, and returns false to quit.
+func (b *browser) execute(command string) bool {
+	b.status = ""
+	command = strings.TrimSpace(command)
+	if strings.HasPrefix(command, "/") {
+		b.search(command[1:])
+		return true
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		fields = []string{"n"}
+	}
+	arg := -1
+	if len(fields) > 1 {
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			b.status = fmt.Sprintf("invalid number %q", fields[1])
+			return true
+		}
+		arg = n
+	}
+	switch fields[0] {
+	case "q":
+		return false
+	case "n":
+		b.move(b.cursor + 1)
+	case "p":
+		b.move(b.cursor - 1)
+	case "g":
+		b.move(arg)
+	case "o":
+		if b.current != nil {
+			b.status = "already in a trace, b goes back to the list"
+			break
+		}
+		if arg >= 0 {
+			b.move(arg)
+		}
+		if len(b.traces) > 0 {
+			b.open(b.traces[b.cursor])
+		}
+	case "t":
+		if len(fields) < 2 {
+			b.status = "t expects a trace ID"
+			break
+		}
+		id, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			b.status = fmt.Sprintf("invalid trace ID %q", fields[1])
+			break
+		}
+		for i, t := range b.traces {
+			if t.id == id {
+				b.current, b.cursor = nil, i
+				b.open(t)
+				return true
+			}
+		}
+		b.status = fmt.Sprintf("no trace %d", id)
+	case "j":
+		b.jump()
+	case "b":
+		if b.current != nil {
+			for i, t := range b.traces {
+				if t == b.current {
+					b.cursor = i
+				}
+			}
+			b.current = nil
+		}
+	case "?", "h":
+		b.status = help
+	default:
+		b.status = fmt.Sprintf("unknown command %q, ? lists the commands", fields[0])
+	}
+	return true
+}
+
+// length returns the number of lines of the current list.
+func (b *browser) length() int {
+	if b.current != nil {
+		return len(b.current.records)
+	}
+	return len(b.traces)
+}
+
+func (b *browser) move(cursor int) {
+	if cursor < 0 || cursor >= b.length() {
+		b.status = "no such line"
+		return
+	}
+	b.cursor = cursor
+}
+
+func (b *browser) open(t *trace) {
+	b.current, b.cursor = t, 0
+}
+
+// jump moves the cursor from a token generation to its next reception, or
+// from a reception to the generation of its (first) token.
+func (b *browser) jump() {
+	if b.current == nil {
+		b.status = "j only works in a trace"
+		return
+	}
+	record := b.current.records[b.cursor]
+	var targets []uint64 // the events to jump to
+	var isTarget func(tracing.TraceRecord) bool
+	if record.Tag == "GenerateTokenTrace" {
+		id := events.RecordID(record)
+		isTarget = func(other tracing.TraceRecord) bool {
+			for _, token := range events.ReceivedTokens(other) {
+				if events.ID(token.Tracer, token.VectorClock[token.Tracer]) == id {
+					return true
+				}
+			}
+			return false
+		}
+	} else {
+		for _, token := range events.ReceivedTokens(record) {
+			targets = append(targets, events.ID(token.Tracer, token.VectorClock[token.Tracer]))
+		}
+		if len(targets) == 0 {
+			b.status = "not a token generation or reception"
+			return
+		}
+		isTarget = func(other tracing.TraceRecord) bool {
+			return events.RecordID(other) == targets[0]
+		}
+	}
+	// the next target after the cursor, wrapping around
+	n := len(b.current.records)
+	for k := 1; k < n; k++ {
+		i := (b.cursor + k) % n
+		if isTarget(b.current.records[i]) {
+			b.cursor = i
+			return
+		}
+	}
+	b.status = "the other end of the token is not in the trace"
+}
+
+// search moves the cursor to the next event whose body contains text, or to
+// the next trace with one.
+func (b *browser) search(text string) {
+	if text == "" {
+		b.status = "/ expects a text to search"
+		return
+	}
+	contains := func(record tracing.TraceRecord) bool {
+		return bytes.Contains(record.Body, []byte(text))
+	}
+	n := b.length()
+	for k := 1; k <= n; k++ {
+		i := (b.cursor + k) % n
+		if b.current != nil && contains(b.current.records[i]) {
+			b.cursor = i
+			return
+		}
+		if b.current == nil {
+			for _, record := range b.traces[i].records {
+				if contains(record) {
+					b.cursor = i
+					return
+				}
+			}
+		}
+	}
+	b.status = fmt.Sprintf("%q not found", text)
+}
+
+// draw writes the screen to w.
+func (b *browser) draw(w io.Writer) {
+	if b.current == nil {
+		fmt.Fprintf(w, "%d traces\n\n", len(b.traces))
+		b.page(w, func(i int) string {
+			t := b.traces[i]
+			return fmt.Sprintf("trace %d: %d events, tracers %s", t.id, len(t.records), strings.Join(t.tracers, ", "))
+		})
+	} else {
+		fmt.Fprintf(w, "trace %d: %d events, in causal order\n\n", b.current.id, len(b.current.records))
+		b.page(w, func(i int) string {
+			record := b.current.records[i]
+			line := record.TracerIdentity + " " + record.Tag
+			if record.Tag == "GenerateTokenTrace" {
+				line += " ->"
+			} else if len(events.ReceivedTokens(record)) > 0 {
+				line += " <-"
+			}
+			return line
+		})
+		record := b.current.records[b.cursor]
+		fmt.Fprintf(w, "\n%s %s, clock %v\n", record.TracerIdentity, record.Tag, record.VectorClock)
+		var body bytes.Buffer
+		if json.Indent(&body, record.Body, "", "  ") == nil {
+			fmt.Fprintln(w, body.String())
+		} else {
+			fmt.Fprintln(w, string(record.Body))
+		}
+	}
+	if b.status != "" {
+		fmt.Fprintf(w, "\n%s\n", b.status)
+	}
+	fmt.Fprint(w, "\n(? for help) > ")
+}
+
+// page writes the lines of the current list around the cursor, marked.
+func (b *browser) page(w io.Writer, line func(i int) string) {
+	n := b.length()
+	start := b.cursor - b.height/2
+	if start > n-b.height {
+		start = n - b.height
+	}
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < n && i < start+b.height; i++ {
+		marker := " "
+		if i == b.cursor {
+			marker = ">"
+		}
+		fmt.Fprintf(w, "%s %4d  %s\n", marker, i, line(i))
+	}
+}
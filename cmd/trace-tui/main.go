@@ -0,0 +1,61 @@
+// Command trace-tui browses the output files of a tracing server in a
+// terminal, e.g. over SSH, where ShiViz is out of reach:
+//
+//	trace-tui trace_output.log
+//
+// It lists the traces, and opens them to show their events in causal order,
+// with the body and vector clock of the selected one. It jumps between the
+// generations of tokens (marked ->) and their receptions (marked <-), and
+// searches the bodies of the records. Commands are typed followed by Enter,
+// ? lists them.
+//
+// The records of all the files given are browsed together. Files in the
+// binary format require -format binary.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+func main() {
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	lines := flag.Int("lines", 20, "the number of traces or events shown at once")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("usage: trace-tui [-format binary] [-lines N] FILE...")
+	}
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// clear the screen before each redraw, unless the output is not a
+	// terminal, e.g. piped to a file
+	clear := ""
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		clear = "\x1b[H\x1b[2J"
+	}
+	b := newBrowser(records, *lines)
+	input := bufio.NewScanner(os.Stdin)
+	output := bufio.NewWriter(os.Stdout)
+	for {
+		output.WriteString(clear)
+		b.draw(output)
+		if err := output.Flush(); err != nil {
+			log.Fatal(err)
+		}
+		if !input.Scan() || !b.execute(input.Text()) {
+			break
+		}
+	}
+	if err := input.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+// Command zipkin-export converts the output files of a tracing server to a
+// JSON array of Zipkin v2 spans, which Zipkin's UI can load, or its API
+// ingest (POST /api/v2/spans):
+//
+//	zipkin-export -o spans.json trace_output.log
+//
+// The records of all the files given (e.g. partitions, or rotated files)
+// are converted together. With no file given, it reads its standard input.
+// Files in the binary format require -format binary. Spans are inferred
+// from pairs of actions (see package zipkin), by default those tagged
+// with the suffixes Start and End, Begin and End, or Request and Response,
+// or those given with -pairs, e.g. -pairs Call:Result,Start:Done. They are
+// placed on a logical time axis starting at -start, by default the
+// modification time of the first file, or the current time.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/tracereader"
+	"github.com/DistributedClocks/tracing/zipkin"
+)
+
+func main() {
+	outputFile := flag.String("o", "-", "the JSON file to write, or - for the standard output")
+	format := flag.String("format", tracing.OutputFormatJSON, "the format of the output files: json or binary")
+	startFlag := flag.String("start", "", "the start of the time axis, in RFC 3339 format")
+	pairsFlag := flag.String("pairs", "", "the comma-separated start:end suffixes of the pairs of actions delimiting spans")
+	flag.Parse()
+
+	options := zipkin.Options{Start: time.Now()}
+	if *pairsFlag != "" {
+		options.Pairs = []zipkin.Pair{}
+		for _, pair := range strings.Split(*pairsFlag, ",") {
+			suffixes := strings.Split(pair, ":")
+			if len(suffixes) != 2 || suffixes[0] == "" || suffixes[1] == "" {
+				log.Fatalf("malformed pair %q, expected start:end", pair)
+			}
+			options.Pairs = append(options.Pairs, zipkin.Pair{StartSuffix: suffixes[0], EndSuffix: suffixes[1]})
+		}
+	}
+
+	records, err := tracereader.ReadFiles(flag.Args(), *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if info, err := os.Stat(flag.Arg(0)); err == nil {
+		options.Start = info.ModTime()
+	}
+	if *startFlag != "" {
+		var err error
+		if options.Start, err = time.Parse(time.RFC3339, *startFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	output := os.Stdout
+	if *outputFile != "-" {
+		var err error
+		if output, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writer := bufio.NewWriter(output)
+	if err := json.NewEncoder(writer).Encode(zipkin.Convert(records, options)); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/golang/snappy"
+)
+
+// Record compression algorithms, see TracerConfig.Compression.
+const (
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+)
+
+// minCompressedRecordSize is the size, in bytes, below which records are
+// sent uncompressed, as compressing them would hardly save anything.
+const minCompressedRecordSize = 512
+
+type compressor struct {
+	compress func(data []byte) ([]byte, error)
+	// decompress returns the decompressed data, and its size, or only its
+	// size if it exceeds maxSize (when positive)
+	decompress func(data []byte, maxSize int) ([]byte, int, error)
+}
+
+var compressors = map[string]compressor{
+	CompressionGzip: {
+		compress: func(data []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			if _, err := w.Write(data); err != nil {
+				return nil, err
+			}
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		decompress: func(data []byte, maxSize int) ([]byte, int, error) {
+			r, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, 0, err
+			}
+			defer r.Close()
+			if maxSize <= 0 {
+				data, err := ioutil.ReadAll(r)
+				return data, len(data), err
+			}
+			data, err = ioutil.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+			if err != nil || len(data) <= maxSize {
+				return data, len(data), err
+			}
+			// the rest is only counted, not to hold it in memory
+			n, err := io.Copy(ioutil.Discard, r)
+			return nil, len(data) + int(n), err
+		},
+	},
+	CompressionSnappy: {
+		compress: func(data []byte) ([]byte, error) {
+			return snappy.Encode(nil, data), nil
+		},
+		decompress: func(data []byte, maxSize int) ([]byte, int, error) {
+			size, err := snappy.DecodedLen(data)
+			if err != nil || maxSize > 0 && size > maxSize {
+				return nil, size, err
+			}
+			data, err = snappy.Decode(nil, data)
+			return data, len(data), err
+		},
+	},
+}
+
+// supportedCompressions returns the names of the compression algorithms
+// this release supports, in order.
+func supportedCompressions() []string {
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CompressionNegotiator is implemented by TransportConn instances whose
+// transport can carry compressed records, to support
+// TracerConfig.Compression.
+type CompressionNegotiator interface {
+	// Compressions returns the compression algorithms the server supports.
+	Compressions() ([]string, error)
+}
+
+// negotiateCompression returns compression if the server at the other end
+// of conn supports it, and "" (no compression) otherwise.
+func negotiateCompression(conn TransportConn, compression string) (string, error) {
+	if compression == "" {
+		return "", nil
+	}
+	if _, ok := compressors[compression]; !ok {
+		return "", fmt.Errorf("unsupported record compression %q", compression)
+	}
+	negotiator, ok := conn.(CompressionNegotiator)
+	if !ok {
+		return "", nil
+	}
+	// servers predating compression fail the call
+	supported, err := negotiator.Compressions()
+	if err != nil {
+		return "", nil
+	}
+	for _, name := range supported {
+		if name == compression {
+			return compression, nil
+		}
+	}
+	return "", nil
+}
+
+// compressRecord compresses the record of arg with compression, if any, and
+// if the record is large enough to be worth it.
+func compressRecord(arg *RecordActionArg, compression string) error {
+	if compression == "" || len(arg.Record) < minCompressedRecordSize {
+		return nil
+	}
+	compressed, err := compressors[compression].compress(arg.Record)
+	if err != nil {
+		return err
+	}
+	arg.Record = compressed
+	arg.Compression = compression
+	return nil
+}
+
+// decompressRecord restores the record of arg, if it is compressed, and
+// returns its size. Past maxSize (when positive), the record is not
+// decompressed, and dropped from arg, so that it cannot exhaust memory.
+func decompressRecord(arg *RecordActionArg, maxSize int) (int, error) {
+	if arg.Compression == "" {
+		return len(arg.Record), nil
+	}
+	c, ok := compressors[arg.Compression]
+	if !ok {
+		return 0, fmt.Errorf("unsupported record compression %q", arg.Compression)
+	}
+	record, size, err := c.decompress(arg.Record, maxSize)
+	if err != nil {
+		return 0, err
+	}
+	arg.Record = record
+	arg.Compression = ""
+	return size, nil
+}
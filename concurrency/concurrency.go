@@ -0,0 +1,123 @@
+// Package concurrency finds the concurrent actions of traces, see
+// cmd/trace-concurrency, e.g. to verify that operations which must be
+// serialized were.
+//
+// Two records of a trace are concurrent if neither happened before the
+// other, according to their vector clocks: none knows of the event of the
+// other, at its tracer's clock. The records of a tracer are never
+// concurrent.
+package concurrency
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// TagPair restricts the pairs reported to those of an action tagged A and
+// one tagged B, in any order.
+type TagPair struct {
+	A, B string
+}
+
+// ParseTagPairs parses tag pairs separated by commas, each of two tags
+// separated by a colon, e.g. "Put:Put,Put:Get".
+func ParseTagPairs(s string) ([]TagPair, error) {
+	var pairs []TagPair
+	for _, pair := range strings.Split(s, ",") {
+		tags := strings.Split(strings.TrimSpace(pair), ":")
+		if len(tags) != 2 || tags[0] == "" || tags[1] == "" {
+			return nil, fmt.Errorf("invalid tag pair %q, expected TAG:TAG", pair)
+		}
+		pairs = append(pairs, TagPair{A: tags[0], B: tags[1]})
+	}
+	return pairs, nil
+}
+
+func (p TagPair) match(a, b tracing.TraceRecord) bool {
+	return p.A == a.Tag && p.B == b.Tag || p.A == b.Tag && p.B == a.Tag
+}
+
+// Pair is a pair of concurrent records of a trace.
+type Pair struct {
+	TraceID uint64
+	A, B    tracing.TraceRecord // A first, in a deterministic causal order
+}
+
+// happenedBefore returns whether the event of a happened before that of b,
+// another event: whether b knows of it.
+func happenedBefore(a, b tracing.TraceRecord) bool {
+	return b.VectorClock[a.TracerIdentity] >= a.VectorClock[a.TracerIdentity]
+}
+
+// Concurrent returns whether the events of a and b are concurrent.
+func Concurrent(a, b tracing.TraceRecord) bool {
+	return !happenedBefore(a, b) && !happenedBefore(b, a)
+}
+
+// Pairs returns the pairs of concurrent records of each trace of records,
+// in the order of their first record, restricted to those matching one of
+// tagPairs, if any. The pairs of a trace are in a deterministic causal
+// order.
+func Pairs(records []tracing.TraceRecord, tagPairs []TagPair) []Pair {
+	var ids []uint64
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			ids = append(ids, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+
+	var pairs []Pair
+	for _, id := range ids {
+		trace := events.Order(byTrace[id])
+		for i, a := range trace {
+			for _, b := range trace[i+1:] {
+				if !Concurrent(a, b) || !matchAny(tagPairs, a, b) {
+					continue
+				}
+				pairs = append(pairs, Pair{TraceID: id, A: a, B: b})
+			}
+		}
+	}
+	return pairs
+}
+
+func matchAny(tagPairs []TagPair, a, b tracing.TraceRecord) bool {
+	if len(tagPairs) == 0 {
+		return true
+	}
+	for _, p := range tagPairs {
+		if p.match(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes pairs to w, one per line, then a summary.
+func Write(w io.Writer, pairs []Pair) error {
+	traces := make(map[uint64]bool)
+	for _, p := range pairs {
+		traces[p.TraceID] = true
+		_, err := fmt.Fprintf(w, "trace %d: %s || %s\n", p.TraceID, describe(p.A), describe(p.B))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d concurrent pairs in %d traces\n", len(pairs), len(traces))
+	return err
+}
+
+// describe returns the description of the event of record in a pair.
+func describe(record tracing.TraceRecord) string {
+	description := fmt.Sprintf("%s %s", record.TracerIdentity, record.Tag)
+	if body := string(record.Body); body != "" && body != "{}" && body != "null" {
+		description += " " + body
+	}
+	return description
+}
@@ -0,0 +1,60 @@
+package concurrency
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestPairs(t *testing.T) {
+	var trace1 *tracing.Trace
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace1 = client1.CreateTrace()
+		client2.ReceiveToken(trace1.GenerateToken()).RecordAction(TestAction{Foo: "foo"})
+		trace1.RecordAction(TestAction{Foo: "bar"})
+		trace2 := client1.CreateTrace()
+		trace2.RecordAction(TestAction{Foo: "baz"})
+	})
+
+	// The last action of client1 in trace1 is concurrent with the
+	// reception of the token by client2, and its action.
+	pairs := Pairs(records, nil)
+	if len(pairs) != 2 {
+		t.Fatalf("expected 2 concurrent pairs, got %v", pairs)
+	}
+	for _, p := range pairs {
+		if p.TraceID != trace1.ID || p.A.TracerIdentity == p.B.TracerIdentity {
+			t.Fatalf("unexpected pair %v", p)
+		}
+	}
+
+	tagPairs, err := ParseTagPairs("TestAction:TestAction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs = Pairs(records, tagPairs)
+	if len(pairs) != 1 {
+		t.Fatalf("expected a concurrent pair of actions, got %v", pairs)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, pairs); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("trace %d: client1 TestAction {\"Foo\":\"bar\"} || client2 TestAction {\"Foo\":\"foo\"}\n1 concurrent pairs in 1 traces\n", trace1.ID)
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+
+	for _, s := range []string{"TestAction", "A:B:C", ":B", "A:B,"} {
+		if _, err := ParseTagPairs(s); err == nil {
+			t.Fatalf("expected an error parsing %q", s)
+		}
+	}
+}
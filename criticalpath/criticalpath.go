@@ -0,0 +1,165 @@
+// Package criticalpath computes the critical paths of the traces of a
+// tracing server, see cmd/critical-path: the longest chains of events
+// ordered by happens-before edges, which bound their end-to-end latency, and
+// the tracers and actions that dominate them.
+//
+// The edges are those of the program order of each tracer, and those from
+// the generation of tokens to their receptions. Records carry no time, so
+// every event weighs the same: the critical path is the one with the most
+// events.
+package criticalpath
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Path is the critical path of a trace.
+type Path struct {
+	TraceID uint64
+	Records []tracing.TraceRecord // the events of the path, in causal order
+	Total   int                   // the number of events of the trace
+}
+
+// Share is the share of a tracer or action in a critical path.
+type Share struct {
+	Name   string
+	Events int
+}
+
+// Paths returns the critical path of each trace of records, in the order of
+// their first record.
+func Paths(records []tracing.TraceRecord) []Path {
+	var traces []uint64
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			traces = append(traces, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+	paths := make([]Path, 0, len(traces))
+	for _, id := range traces {
+		paths = append(paths, Path{
+			TraceID: id,
+			Records: criticalPath(byTrace[id]),
+			Total:   len(byTrace[id]),
+		})
+	}
+	return paths
+}
+
+// criticalPath returns the longest chain of events of trace.
+func criticalPath(trace []tracing.TraceRecord) []tracing.TraceRecord {
+	// The logical time grows along edges, which makes it a topological order.
+	trace = append([]tracing.TraceRecord(nil), trace...)
+	sort.SliceStable(trace, func(i, j int) bool {
+		return events.LogicalTime(trace[i].VectorClock) < events.LogicalTime(trace[j].VectorClock)
+	})
+
+	index := make(map[uint64]int) // the index of each event by ID
+	for i, record := range trace {
+		index[events.RecordID(record)] = i
+	}
+	length := make([]int, len(trace))   // the length of the longest chain ending at each event
+	previous := make([]int, len(trace)) // the previous event of that chain, or -1
+	last := make(map[string]int)        // the last event of each tracer so far
+	end := -1
+	for i, record := range trace {
+		length[i], previous[i] = 1, -1
+		var predecessors []int
+		if j, ok := last[record.TracerIdentity]; ok {
+			predecessors = append(predecessors, j)
+		}
+		last[record.TracerIdentity] = i
+		for _, token := range events.ReceivedTokens(record) {
+			if j, ok := index[events.ID(token.Tracer, token.VectorClock[token.Tracer])]; ok && j < i {
+				predecessors = append(predecessors, j)
+			}
+		}
+		for _, j := range predecessors {
+			if length[j]+1 > length[i] {
+				length[i], previous[i] = length[j]+1, j
+			}
+		}
+		if end < 0 || length[i] > length[end] {
+			end = i
+		}
+	}
+
+	var path []tracing.TraceRecord
+	for i := end; i >= 0; i = previous[i] {
+		path = append(path, trace[i])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// ByTracer returns the number of events of the path of each tracer, from
+// the largest.
+func (p Path) ByTracer() []Share {
+	return shares(p.Records, func(record tracing.TraceRecord) string { return record.TracerIdentity })
+}
+
+// ByAction returns the number of events of the path of each action tag,
+// from the largest.
+func (p Path) ByAction() []Share {
+	return shares(p.Records, func(record tracing.TraceRecord) string { return record.Tag })
+}
+
+// shares counts records by key, from the largest count, then by name.
+func shares(records []tracing.TraceRecord, key func(record tracing.TraceRecord) string) []Share {
+	counts := make(map[string]int)
+	for _, record := range records {
+		counts[key(record)]++
+	}
+	result := make([]Share, 0, len(counts))
+	for name, n := range counts {
+		result = append(result, Share{Name: name, Events: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Events != result[j].Events {
+			return result[i].Events > result[j].Events
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Write writes a report of paths to w: for each, its events, and the shares
+// of tracers and actions in it.
+func Write(w io.Writer, paths []Path) error {
+	for _, path := range paths {
+		if _, err := fmt.Fprintf(w, "trace %d: critical path of %d of %d events\n", path.TraceID, len(path.Records), path.Total); err != nil {
+			return err
+		}
+		for _, record := range path.Records {
+			if _, err := fmt.Fprintf(w, "\t%s\t%s\t%s\n", record.TracerIdentity, record.Tag, record.VectorClock.ReturnVCString()); err != nil {
+				return err
+			}
+		}
+		for _, group := range []struct {
+			name   string
+			shares []Share
+		}{{"tracers", path.ByTracer()}, {"actions", path.ByAction()}} {
+			if _, err := fmt.Fprintf(w, "\t%s:", group.name); err != nil {
+				return err
+			}
+			for _, share := range group.shares {
+				if _, err := fmt.Fprintf(w, " %s %d%%", share.Name, 100*share.Events/len(path.Records)); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package criticalpath
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestPaths(t *testing.T) {
+	var trace *tracing.Trace
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace = client1.CreateTrace()
+		token := trace.GenerateToken()
+		trace.RecordAction(TestAction{Foo: "concurrent"})
+		received := client2.ReceiveToken(token)
+		for i := 0; i < 3; i++ {
+			received.RecordAction(TestAction{Foo: "critical"})
+		}
+	})
+
+	paths := Paths(records)
+	if len(paths) != 1 || paths[0].TraceID != trace.ID || paths[0].Total != 7 {
+		t.Fatalf("expected a path of trace %d of 7 events, got %v", trace.ID, paths)
+	}
+	var tags []string
+	for _, record := range paths[0].Records {
+		tags = append(tags, record.TracerIdentity+":"+record.Tag)
+	}
+	expected := "client1:CreateTrace client1:GenerateTokenTrace client2:ReceiveTokenTrace client2:TestAction client2:TestAction client2:TestAction"
+	if strings.Join(tags, " ") != expected {
+		t.Fatalf("expected the path %s, got %s", expected, strings.Join(tags, " "))
+	}
+	if shares := paths[0].ByTracer(); len(shares) != 2 || shares[0] != (Share{Name: "client2", Events: 4}) {
+		t.Fatalf("expected client2 to dominate the path, got %v", shares)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, paths); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "critical path of 6 of 7 events") || !strings.Contains(buf.String(), "tracers: client2 66% client1 33%") {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,115 @@
+// Package csv flattens the records of a tracing server to CSV, for
+// spreadsheets and data frames, see cmd/csv-export.
+//
+// Each record is a row, with the columns TracerIdentity, TraceID, Tag,
+// TracerSeq and VectorClock (as JSON), then a column per field of the
+// bodies, named after its path (Body.Foo, or Body.Foo.Bar for the field Bar
+// of the object in Foo). Arrays are kept as JSON, and the columns of the
+// fields a record does not have are empty.
+package csv
+
+import (
+	"bytes"
+	stdcsv "encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// columns are the columns of the records, before those of the bodies.
+var columns = []string{"TracerIdentity", "TraceID", "Tag", "TracerSeq", "VectorClock"}
+
+// Write writes records to w, as CSV with a header row.
+func Write(w io.Writer, records []tracing.TraceRecord) error {
+	bodies := make([]map[string]string, len(records))
+	bodyColumns := make(map[string]bool)
+	for i, record := range records {
+		bodies[i] = make(map[string]string)
+		if err := flatten(record.Body, "Body", bodies[i]); err != nil {
+			return err
+		}
+		for column := range bodies[i] {
+			bodyColumns[column] = true
+		}
+	}
+	header := append([]string(nil), columns...)
+	var sorted []string
+	for column := range bodyColumns {
+		sorted = append(sorted, column)
+	}
+	sort.Strings(sorted)
+	header = append(header, sorted...)
+
+	writer := stdcsv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i, record := range records {
+		clock, err := json.Marshal(record.VectorClock)
+		if err != nil {
+			return err
+		}
+		row := []string{
+			record.TracerIdentity,
+			strconv.FormatUint(record.TraceID, 10),
+			record.Tag,
+			"",
+			string(clock),
+		}
+		if record.TracerSeq != 0 {
+			row[3] = strconv.FormatUint(record.TracerSeq, 10)
+		}
+		for _, column := range sorted {
+			row = append(row, bodies[i][column])
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// flatten adds the fields of the JSON value data to fields, under their
+// path from prefix.
+func flatten(data json.RawMessage, prefix string, fields map[string]string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	return flattenValue(value, prefix, fields)
+}
+
+func flattenValue(value interface{}, prefix string, fields map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if err := flattenValue(field, prefix+"."+key, fields); err != nil {
+				return err
+			}
+		}
+	case nil:
+		fields[prefix] = ""
+	case string:
+		fields[prefix] = v
+	case json.Number:
+		fields[prefix] = v.String()
+	case bool:
+		fields[prefix] = strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fields[prefix] = string(encoded)
+	}
+	return nil
+}
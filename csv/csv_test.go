@@ -0,0 +1,40 @@
+package csv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+func TestWrite(t *testing.T) {
+	records := []tracing.TraceRecord{
+		{
+			TracerIdentity: "client1",
+			TraceID:        42,
+			Tag:            "CreateTrace",
+			Body:           json.RawMessage(`{}`),
+			VectorClock:    map[string]uint64{"client1": 1},
+			TracerSeq:      1,
+		},
+		{
+			TracerIdentity: "client1",
+			TraceID:        42,
+			Tag:            "Put",
+			Body:           json.RawMessage(`{"Key":"k","Value":{"Size":3,"Data":[1,2,3]},"Sync":true,"Prev":null}`),
+			VectorClock:    map[string]uint64{"client1": 2},
+		},
+	}
+	var b strings.Builder
+	if err := Write(&b, records); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TracerIdentity,TraceID,Tag,TracerSeq,VectorClock,Body.Key,Body.Prev,Body.Sync,Body.Value.Data,Body.Value.Size
+client1,42,CreateTrace,1,"{""client1"":1}",,,,,
+client1,42,Put,,"{""client1"":2}",k,,true,"[1,2,3]",3
+`
+	if b.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+}
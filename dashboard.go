@@ -0,0 +1,297 @@
+package tracing
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// dashboardRecentTraces is the number of traces the dashboard keeps the
+	// records of, the most recently active ones.
+	dashboardRecentTraces = 100
+	// dashboardTraceRecords is the number of records the dashboard keeps of
+	// each trace, the first ones.
+	dashboardTraceRecords = 1000
+	// dashboardActivity is how long a tracer is shown active after its last
+	// record.
+	dashboardActivity = time.Minute
+)
+
+// dashboardSink tracks the activity of the server, for its dashboard, see
+// TracingServerConfig.DashboardBind.
+type dashboardSink struct {
+	lock    sync.Mutex
+	now     func() time.Time
+	started time.Time
+	records uint64
+	tracers map[string]*tracerActivity
+	// perSecond counts the records of each of the last seconds, by the
+	// second modulo its length, as of second
+	perSecond [60]uint64
+	second    [60]int64
+	recent    []*recentTrace // by activity, the most recent last
+	traces    map[uint64]*recentTrace
+}
+
+// tracerActivity is the activity of a tracer.
+type tracerActivity struct {
+	records  uint64
+	lastSeen time.Time
+}
+
+// recentTrace is a recently active trace.
+type recentTrace struct {
+	id       uint64
+	records  []TraceRecord
+	dropped  int // the records past dashboardTraceRecords
+	tracers  map[string]bool
+	lastSeen time.Time
+}
+
+func newDashboardSink() *dashboardSink {
+	s := &dashboardSink{
+		now:     time.Now,
+		tracers: make(map[string]*tracerActivity),
+		traces:  make(map[uint64]*recentTrace),
+	}
+	s.started = s.now()
+	return s
+}
+
+func (s *dashboardSink) Write(record TraceRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	s.records++
+
+	tracer := s.tracers[record.TracerIdentity]
+	if tracer == nil {
+		tracer = &tracerActivity{}
+		s.tracers[record.TracerIdentity] = tracer
+	}
+	tracer.records++
+	tracer.lastSeen = now
+
+	second := now.Unix()
+	slot := second % int64(len(s.perSecond))
+	if s.second[slot] != second {
+		s.second[slot], s.perSecond[slot] = second, 0
+	}
+	s.perSecond[slot]++
+
+	trace := s.traces[record.TraceID]
+	if trace == nil {
+		trace = &recentTrace{id: record.TraceID, tracers: make(map[string]bool)}
+		s.traces[record.TraceID] = trace
+		if len(s.recent) == dashboardRecentTraces {
+			delete(s.traces, s.recent[0].id)
+			s.recent = s.recent[1:]
+		}
+	} else {
+		for i, other := range s.recent {
+			if other == trace {
+				s.recent = append(s.recent[:i], s.recent[i+1:]...)
+				break
+			}
+		}
+	}
+	s.recent = append(s.recent, trace)
+	if len(trace.records) < dashboardTraceRecords {
+		trace.records = append(trace.records, record)
+	} else {
+		trace.dropped++
+	}
+	trace.tracers[record.TracerIdentity] = true
+	trace.lastSeen = now
+	return nil
+}
+
+func (s *dashboardSink) Flush() error {
+	return nil
+}
+
+func (s *dashboardSink) Close() error {
+	return nil
+}
+
+// rate returns the number of records per second over the last seconds,
+// the current one excluded, as it is not over.
+func (s *dashboardSink) rate(now time.Time, seconds int) float64 {
+	var records uint64
+	for second := now.Unix() - int64(seconds); second < now.Unix(); second++ {
+		slot := second % int64(len(s.perSecond))
+		if s.second[slot] == second {
+			records += s.perSecond[slot]
+		}
+	}
+	return float64(records) / float64(seconds)
+}
+
+// dashboardStatus is what the dashboard shows.
+type dashboardStatus struct {
+	Uptime        time.Duration
+	Records       uint64
+	RatePer10s    float64 // records per second over the last 10 seconds
+	RatePerMinute float64 // over the last minute
+	Tracers       []dashboardTracer
+	Traces        []dashboardTrace // the most recent first
+}
+
+type dashboardTracer struct {
+	Identity string
+	Records  uint64
+	LastSeen time.Duration // ago
+	Active   bool
+}
+
+type dashboardTrace struct {
+	ID       uint64
+	Records  int
+	Tracers  []string
+	LastSeen time.Duration // ago
+}
+
+func (s *dashboardSink) status() dashboardStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	status := dashboardStatus{
+		Uptime:        now.Sub(s.started).Truncate(time.Second),
+		Records:       s.records,
+		RatePer10s:    s.rate(now, 10),
+		RatePerMinute: s.rate(now, 60),
+	}
+	for identity, tracer := range s.tracers {
+		ago := now.Sub(tracer.lastSeen)
+		status.Tracers = append(status.Tracers, dashboardTracer{
+			Identity: identity,
+			Records:  tracer.records,
+			LastSeen: ago.Truncate(time.Second),
+			Active:   ago < dashboardActivity,
+		})
+	}
+	sort.Slice(status.Tracers, func(i, j int) bool {
+		return status.Tracers[i].Identity < status.Tracers[j].Identity
+	})
+	for i := len(s.recent) - 1; i >= 0; i-- {
+		trace := s.recent[i]
+		t := dashboardTrace{ID: trace.id, Records: len(trace.records) + trace.dropped, LastSeen: now.Sub(trace.lastSeen).Truncate(time.Second)}
+		for tracer := range trace.tracers {
+			t.Tracers = append(t.Tracers, tracer)
+		}
+		sort.Strings(t.Tracers)
+		status.Traces = append(status.Traces, t)
+	}
+	return status
+}
+
+// traceRecords returns the records kept of the trace id, and the number of
+// those dropped, or false if the trace is not recent.
+func (s *dashboardSink) traceRecords(id uint64) ([]TraceRecord, int, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	trace, ok := s.traces[id]
+	if !ok {
+		return nil, 0, false
+	}
+	return append([]TraceRecord(nil), trace.records...), trace.dropped, true
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="2">
+<title>Tracing server</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 8px; text-align: left; }
+.inactive { color: gray; }
+</style>
+</head>
+<body>
+<h1>Tracing server</h1>
+<p>Up {{.Uptime}}, {{.Records}} records, {{printf "%.1f" .RatePer10s}} records/s over the last 10s, {{printf "%.1f" .RatePerMinute}} over the last minute.</p>
+<h2>Tracers</h2>
+<table>
+<tr><th>Tracer</th><th>Records</th><th>Last record</th></tr>
+{{range .Tracers}}<tr{{if not .Active}} class="inactive"{{end}}><td>{{.Identity}}</td><td>{{.Records}}</td><td>{{.LastSeen}} ago</td></tr>
+{{end}}</table>
+<h2>Recent traces</h2>
+<table>
+<tr><th>Trace</th><th>Records</th><th>Tracers</th><th>Last record</th></tr>
+{{range .Traces}}<tr><td><a href="trace?id={{.ID}}">{{.ID}}</a></td><td>{{.Records}}</td><td>{{range $i, $t := .Tracers}}{{if $i}}, {{end}}{{$t}}{{end}}</td><td>{{.LastSeen}} ago</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var dashboardTraceTemplate = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Trace {{.ID}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 8px; text-align: left; vertical-align: top; }
+td.body { font-family: monospace; }
+</style>
+</head>
+<body>
+<p><a href=".">Tracing server</a></p>
+<h1>Trace {{.ID}}</h1>
+<p>Records in the order the server wrote them.{{if .Dropped}} The last {{.Dropped}} are not shown.{{end}}</p>
+<table>
+<tr><th>Tracer</th><th>Tag</th><th>Vector clock</th><th>Body</th></tr>
+{{range .Records}}<tr><td>{{.Tracer}}</td><td>{{.Tag}}</td><td>{{.Clock}}</td><td class="body">{{.Body}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// ServeHTTP serves the dashboard: its page at /, the records of a recent
+// trace at /trace?id=ID, and what the page shows as JSON at /status.
+func (s *dashboardSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		dashboardTemplate.Execute(w, s.status())
+	case "/status":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.status())
+	case "/trace":
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid trace ID", http.StatusBadRequest)
+			return
+		}
+		records, dropped, ok := s.traceRecords(id)
+		if !ok {
+			http.Error(w, "no recent trace "+strconv.FormatUint(id, 10), http.StatusNotFound)
+			return
+		}
+		type row struct {
+			Tracer, Tag, Clock, Body string
+		}
+		page := struct {
+			ID      uint64
+			Dropped int
+			Records []row
+		}{ID: id, Dropped: dropped}
+		for _, record := range records {
+			clock, _ := json.Marshal(record.VectorClock)
+			page.Records = append(page.Records, row{record.TracerIdentity, record.Tag, string(clock), string(record.Body)})
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		dashboardTraceTemplate.Execute(w, page)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardStatus(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := newDashboardSink()
+	s.now = func() time.Time { return now }
+	s.started = now
+
+	// 20 records a second for 10 seconds, of client1 then client2
+	for second := 0; second < 10; second++ {
+		for i := 0; i < 20; i++ {
+			identity := "client1"
+			if second >= 5 {
+				identity = "client2"
+			}
+			s.Write(TraceRecord{TracerIdentity: identity, TraceID: uint64(second%3 + 1), Tag: "TestAction"})
+		}
+		now = now.Add(time.Second)
+	}
+	now = now.Add(time.Minute - 5*time.Second)
+
+	status := s.status()
+	if status.Records != 200 || status.Uptime != 65*time.Second {
+		t.Fatalf("expected 200 records in 65s, got %d in %v", status.Records, status.Uptime)
+	}
+	if status.RatePer10s != 0 || status.RatePerMinute != 100.0/60 {
+		t.Fatalf("expected the records of the last 5s of the minute, got %v and %v records/s", status.RatePer10s, status.RatePerMinute)
+	}
+	if len(status.Tracers) != 2 || status.Tracers[0].Identity != "client1" || status.Tracers[0].Active || !status.Tracers[1].Active || status.Tracers[1].Records != 100 {
+		t.Fatalf("expected client1 inactive and client2 active, got %+v", status.Tracers)
+	}
+	// the traces by last activity: 1 at 9s, 3 at 8s, 2 at 7s
+	var ids []uint64
+	for _, trace := range status.Traces {
+		ids = append(ids, trace.ID)
+	}
+	if fmt.Sprint(ids) != "[1 3 2]" || status.Traces[0].Records != 80 {
+		t.Fatalf("expected the traces 1, 3 and 2, 1 with 80 records, got %+v", status.Traces)
+	}
+
+	for id := uint64(10); id < 10+dashboardRecentTraces; id++ {
+		s.Write(TraceRecord{TracerIdentity: "client1", TraceID: id})
+	}
+	if _, _, ok := s.traceRecords(1); ok {
+		t.Fatal("expected the least recent trace to be dropped")
+	}
+	if records, _, ok := s.traceRecords(10); !ok || len(records) != 1 {
+		t.Fatalf("expected the records of trace 10, got %v", records)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboardBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:    ":0",
+		DashboardBind: dashboardBind,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	defer server.Close()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get("http://" + dashboardBind + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %s for %s: %s", resp.Status, path, body)
+		}
+		return string(body)
+	}
+	if page := get("/"); !strings.Contains(page, fmt.Sprintf(`<a href="trace?id=%d">`, trace.ID)) || !strings.Contains(page, "<td>client1</td><td>2</td>") {
+		t.Fatalf("expected the trace and the tracer in the dashboard, got:\n%s", page)
+	}
+	var status dashboardStatus
+	if err := json.Unmarshal([]byte(get("/status")), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Records != 2 || len(status.Traces) != 1 || status.Traces[0].ID != trace.ID {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if page := get(fmt.Sprintf("/trace?id=%d", trace.ID)); !strings.Contains(page, "{&#34;Foo&#34;:&#34;foo&#34;}") {
+		t.Fatalf("expected the escaped body of the action, got:\n%s", page)
+	}
+}
@@ -0,0 +1,261 @@
+// Package diff compares the traces of two runs by their shape, see
+// cmd/trace-diff, e.g. a student run against the run of a reference
+// solution.
+//
+// The shape of a trace is the sequence of its events in a deterministic
+// topological order of the happens-before relation, each described by its
+// tracer and tag, and, for receptions of tokens, the tracers that generated
+// them. Trace IDs, clocks and bodies are ignored, and so is the
+// interleaving of concurrent events.
+//
+// The traces of the runs are paired first by equal shapes, then in the
+// order of their first records. The events of paired traces are then
+// aligned, to find those missing from the run, those added to it, and those
+// reordered, present in both but at different positions.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Kinds of lines of a diff.
+const (
+	Same      = '='
+	Missing   = '-' // in the reference only
+	Added     = '+' // in the run only
+	Reordered = '~' // in both, at a different position, shown at the position in the run
+)
+
+// Line is an event of a diff.
+type Line struct {
+	Kind  byte
+	Event string
+}
+
+// TraceDiff is the diff of a trace of the reference and one of the run.
+type TraceDiff struct {
+	Reference uint64 // the ID of the trace of the reference, or 0 if the run added it
+	Run       uint64 // the ID of the trace of the run, or 0 if it is missing from it
+	Lines     []Line
+}
+
+// Identical returns whether the traces have the same shape.
+func (d TraceDiff) Identical() bool {
+	for _, line := range d.Lines {
+		if line.Kind != Same {
+			return false
+		}
+	}
+	return d.Reference != 0 && d.Run != 0
+}
+
+// shape is the shape of a trace.
+type shape struct {
+	id     uint64
+	events []string
+}
+
+func (s shape) key() string {
+	return strings.Join(s.events, "\n")
+}
+
+// shapes returns the shapes of the traces of records, in the order of their
+// first record.
+func shapes(records []tracing.TraceRecord) []shape {
+	var ids []uint64
+	byTrace := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := byTrace[record.TraceID]; !ok {
+			ids = append(ids, record.TraceID)
+		}
+		byTrace[record.TraceID] = append(byTrace[record.TraceID], record)
+	}
+	result := make([]shape, 0, len(ids))
+	for _, id := range ids {
+		s := shape{id: id}
+		for _, record := range events.Order(byTrace[id]) {
+			s.events = append(s.events, describe(record))
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// describe returns the description of the event of record in a shape.
+func describe(record tracing.TraceRecord) string {
+	description := record.TracerIdentity + " " + record.Tag
+	var sources []string
+	for _, token := range events.ReceivedTokens(record) {
+		sources = append(sources, token.Tracer)
+	}
+	if len(sources) > 0 {
+		sort.Strings(sources)
+		description += " from " + strings.Join(sources, ", ")
+	}
+	return description
+}
+
+// Compare returns the diffs of the traces of the reference run and those
+// of run, in the order of the traces of the reference, then of those added.
+func Compare(reference, run []tracing.TraceRecord) []TraceDiff {
+	referenceShapes, runShapes := shapes(reference), shapes(run)
+
+	// Pair equal shapes first.
+	paired := make([]int, len(referenceShapes)) // the index of the shape of the run paired with each, or -1
+	used := make([]bool, len(runShapes))
+	byKey := make(map[string][]int)
+	for j, s := range runShapes {
+		byKey[s.key()] = append(byKey[s.key()], j)
+	}
+	for i, s := range referenceShapes {
+		paired[i] = -1
+		if candidates := byKey[s.key()]; len(candidates) > 0 {
+			paired[i], used[candidates[0]] = candidates[0], true
+			byKey[s.key()] = candidates[1:]
+		}
+	}
+	// Then the others, in order.
+	j := 0
+	for i := range referenceShapes {
+		if paired[i] >= 0 {
+			continue
+		}
+		for j < len(runShapes) && used[j] {
+			j++
+		}
+		if j < len(runShapes) {
+			paired[i], used[j] = j, true
+		}
+	}
+
+	var diffs []TraceDiff
+	for i, s := range referenceShapes {
+		if paired[i] < 0 {
+			diffs = append(diffs, TraceDiff{Reference: s.id, Lines: lines(Missing, s.events)})
+			continue
+		}
+		other := runShapes[paired[i]]
+		diffs = append(diffs, TraceDiff{Reference: s.id, Run: other.id, Lines: align(s.events, other.events)})
+	}
+	for j, s := range runShapes {
+		if !used[j] {
+			diffs = append(diffs, TraceDiff{Run: s.id, Lines: lines(Added, s.events)})
+		}
+	}
+	return diffs
+}
+
+func lines(kind byte, events []string) []Line {
+	result := make([]Line, 0, len(events))
+	for _, event := range events {
+		result = append(result, Line{Kind: kind, Event: event})
+	}
+	return result
+}
+
+// align returns the diff of the events a of the reference and b of the
+// run, from their longest common subsequence.
+func align(a, b []string) []Line {
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+	var result []Line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			result = append(result, Line{Kind: Same, Event: a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && common[i+1][j] >= common[i][j+1]:
+			result = append(result, Line{Kind: Missing, Event: a[i]})
+			i++
+		default:
+			result = append(result, Line{Kind: Added, Event: b[j]})
+			j++
+		}
+	}
+
+	// An event both missing and added was reordered: keep it where the run
+	// has it.
+	missing := make(map[string]int)
+	for _, line := range result {
+		if line.Kind == Missing {
+			missing[line.Event]++
+		}
+	}
+	moved := make(map[string]int)
+	for k, line := range result {
+		if line.Kind == Added && missing[line.Event] > 0 {
+			missing[line.Event]--
+			moved[line.Event]++
+			result[k].Kind = Reordered
+		}
+	}
+	kept := result[:0]
+	for _, line := range result {
+		if line.Kind == Missing && moved[line.Event] > 0 {
+			moved[line.Event]--
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// Write writes diffs to w: the lines of those that are not identical, then
+// a summary.
+func Write(w io.Writer, diffs []TraceDiff) error {
+	var identical, differing, missing, added int
+	for _, d := range diffs {
+		switch {
+		case d.Identical():
+			identical++
+			continue
+		case d.Run == 0:
+			missing++
+			_, err := fmt.Fprintf(w, "trace %d of the reference is missing from the run\n", d.Reference)
+			if err != nil {
+				return err
+			}
+		case d.Reference == 0:
+			added++
+			_, err := fmt.Fprintf(w, "trace %d of the run is not in the reference\n", d.Run)
+			if err != nil {
+				return err
+			}
+		default:
+			differing++
+			_, err := fmt.Fprintf(w, "trace %d of the reference differs from trace %d of the run\n", d.Reference, d.Run)
+			if err != nil {
+				return err
+			}
+		}
+		for _, line := range d.Lines {
+			if _, err := fmt.Fprintf(w, "%c %s\n", line.Kind, line.Event); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d traces identical, %d differing, %d missing, %d added\n", identical, differing, missing, added)
+	return err
+}
@@ -0,0 +1,80 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+	"github.com/google/go-cmp/cmp"
+)
+
+type Put struct{}
+
+type Get struct{}
+
+type Done struct{}
+
+func TestCompare(t *testing.T) {
+	var referenceIDs, runIDs []uint64
+	reference := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(Put{})
+		client2.ReceiveToken(trace.GenerateToken()).RecordAction(Get{})
+		trace.RecordAction(Done{})
+		other := client1.CreateTrace()
+		other.RecordAction(Put{})
+		other.RecordAction(Get{})
+		referenceIDs = []uint64{trace.ID, other.ID}
+	})
+	run := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		// the second trace first, with the concurrent events in another order
+		trace := client1.CreateTrace()
+		token := trace.GenerateToken()
+		trace.RecordAction(Put{})
+		trace.RecordAction(Done{})
+		client2.ReceiveToken(token).RecordAction(Get{})
+		other := client1.CreateTrace()
+		other.RecordAction(Get{})
+		other.RecordAction(Put{})
+		other.RecordAction(Done{})
+		runIDs = []uint64{trace.ID, other.ID}
+	})
+
+	diffs := Compare(reference, run)
+	expected := []TraceDiff{
+		{Reference: referenceIDs[0], Run: runIDs[0], Lines: []Line{
+			{Same, "client1 CreateTrace"},
+			{Same, "client1 GenerateTokenTrace"},
+			{Reordered, "client1 Put"},
+			{Same, "client1 Done"},
+			{Same, "client2 ReceiveTokenTrace from client1"},
+			{Same, "client2 Get"},
+		}},
+		{Reference: referenceIDs[1], Run: runIDs[1], Lines: []Line{
+			{Same, "client1 CreateTrace"},
+			{Same, "client1 Get"},
+			{Reordered, "client1 Put"},
+			{Added, "client1 Done"},
+		}},
+	}
+	if !cmp.Equal(diffs, expected) {
+		t.Fatalf("unexpected diffs: %s", cmp.Diff(expected, diffs))
+	}
+
+	if diffs := Compare(reference, reference); len(diffs) != 2 || !diffs[0].Identical() || !diffs[1].Identical() {
+		t.Fatalf("expected identical traces, got %v", diffs)
+	}
+	diffs = Compare(reference, reference[:len(reference)-3])
+	var buf bytes.Buffer
+	if err := Write(&buf, diffs); err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := fmt.Sprintf("trace %d of the reference is missing from the run\n"+
+		"- client1 CreateTrace\n- client1 Put\n- client1 Get\n"+
+		"1 traces identical, 0 differing, 1 missing, 0 added\n", referenceIDs[1])
+	if buf.String() != expectedOutput {
+		t.Fatalf("expected\n%s\ngot\n%s", expectedOutput, buf.String())
+	}
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"log"
+	"time"
+)
+
+// syncer periodically flushes the server's sinks, every SyncInterval, or
+// the buffers of its output files, every FlushInterval.
+type syncer struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (tracingServer *TracingServer) startSyncer(interval time.Duration) {
+	tracingServer.syncer = &syncer{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go tracingServer.runSyncer(tracingServer.syncer, interval)
+}
+
+func (tracingServer *TracingServer) runSyncer(s *syncer, interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		tracingServer.recordLock.Lock()
+		err := tracingServer.flushSinks()
+		tracingServer.recordLock.Unlock()
+		if err != nil {
+			log.Print("error syncing records: ", err)
+		}
+	}
+}
+
+func (tracingServer *TracingServer) stopSyncer() {
+	if tracingServer.syncer == nil {
+		return
+	}
+	close(tracingServer.syncer.stop)
+	<-tracingServer.syncer.done
+	tracingServer.syncer = nil
+}
+
+// flushSinks flushes the sinks, if records were written since they were
+// last flushed. Like writes, flushes only fail if every sink fails, but the
+// write-ahead log is only checkpointed once every sink flushed. The caller
+// must hold recordLock.
+func (tracingServer *TracingServer) flushSinks() error {
+	if tracingServer.unsynced == 0 {
+		return nil
+	}
+	var err error
+	flushed := 0
+	for _, sink := range tracingServer.sinks {
+		if flushErr := sink.Flush(); flushErr != nil {
+			sink.fail("flushing records", flushErr)
+			err = flushErr
+			continue
+		}
+		sink.succeed()
+		flushed++
+	}
+	if flushed == 0 && err != nil {
+		return err
+	}
+	tracingServer.unsynced = 0
+	if tracingServer.walFile != nil && err == nil {
+		return tracingServer.checkpointWAL()
+	}
+	return nil
+}
+
+func (tracingServer *TracingServer) startFlusher(interval time.Duration) {
+	tracingServer.flusher = &syncer{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go tracingServer.runFlusher(tracingServer.flusher, interval)
+}
+
+func (tracingServer *TracingServer) runFlusher(s *syncer, interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := tracingServer.Flush(); err != nil {
+			log.Print("error flushing records: ", err)
+		}
+	}
+}
+
+func (tracingServer *TracingServer) stopFlusher() {
+	if tracingServer.flusher == nil {
+		return
+	}
+	close(tracingServer.flusher.stop)
+	<-tracingServer.flusher.done
+	tracingServer.flusher = nil
+}
+
+// Flush writes the records buffered (see FlushEvery) to the output files,
+// without syncing them, e.g. when the process is signaled. The records are
+// flushed on Close anyway.
+func (tracingServer *TracingServer) Flush() error {
+	tracingServer.recordLock.Lock()
+	defer tracingServer.recordLock.Unlock()
+	return tracingServer.recordSinks.flushBuffers()
+}
@@ -0,0 +1,127 @@
+package tracing
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedOutputMagic starts the output files encrypted with an
+// EncryptionKey.
+const encryptedOutputMagic = "TRACING-AESGCM2\n"
+
+// maxEncryptedFrameSize bounds the size of the frames of encrypted output
+// files, so that a corrupt length prefix cannot exhaust memory.
+const maxEncryptedFrameSize = maxProtobufMessageSize + 64
+
+var errEncryptedOutput = errors.New("EncryptionKey precludes AppendOutput, IndexOutput and WALFile, which read or locate the records of the output files")
+
+var errNotEncrypted = errors.New("not an encrypted output file")
+
+// newOutputCipher returns the AES-GCM cipher of the EncryptionKey key.
+func newOutputCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EncryptionKey: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// frameAdditionalData returns the data authenticated along with the frame
+// numbered index of an encrypted output file, last if it ends the file, so
+// that frames cannot be dropped, moved or replaced by the last one.
+func frameAdditionalData(index uint64, last bool) []byte {
+	var data [9]byte
+	binary.BigEndian.PutUint64(data[:], index)
+	if last {
+		data[8] = 1
+	}
+	return data[:]
+}
+
+// sealFrame returns the frame numbered index of an encrypted output file
+// holding data, e.g. a record: the 4-byte length of the rest of the frame,
+// a random nonce, and data sealed with aead. The last frame of the file
+// holds no data.
+func sealFrame(aead cipher.AEAD, index uint64, data []byte) ([]byte, error) {
+	frame := make([]byte, 4+aead.NonceSize(), 4+aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(frame[4:]); err != nil {
+		return nil, err
+	}
+	frame = aead.Seal(frame, frame[4:], data, frameAdditionalData(index, len(data) == 0))
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
+	return frame, nil
+}
+
+// decryptingReader reads the plaintext of an encrypted output file.
+type decryptingReader struct {
+	reader *bufio.Reader
+	aead   cipher.AEAD
+	frame  []byte // the plaintext of the current frame not read yet
+	frames uint64 // the number of frames read
+	ended  bool   // whether the last frame was read
+}
+
+// NewDecryptingReader returns a reader of the plaintext of r, an output file
+// written by a server with EncryptionKey set to key, e.g. to read its
+// records with NewRecordReader:
+//
+//	plaintext, err := tracing.NewDecryptingReader(file, key)
+//	...
+//	records, err := tracing.ReadRecords(plaintext, tracing.OutputFormatJSON)
+//
+// Reading fails with io.ErrUnexpectedEOF if the file ends before the server
+// closed it, e.g. after crashing, or if it was truncated, and with an error
+// if the file was tampered with, its frames dropped or reordered.
+func NewDecryptingReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newOutputCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(encryptedOutputMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != encryptedOutputMagic {
+		return nil, errNotEncrypted
+	}
+	return &decryptingReader{reader: reader, aead: aead}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.frame) == 0 {
+		var length [4]byte
+		if _, err := io.ReadFull(r.reader, length[:]); err == io.EOF && !r.ended {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+		if r.ended {
+			return 0, errors.New("encrypted output file tampered with, data follows its last frame")
+		}
+		n := binary.BigEndian.Uint32(length[:])
+		if n > maxEncryptedFrameSize || int(n) < r.aead.NonceSize() {
+			return 0, errors.New("corrupt encrypted output file")
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r.reader, frame); err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+		nonce, sealed := frame[:r.aead.NonceSize()], frame[r.aead.NonceSize():]
+		last := len(sealed) == r.aead.Overhead()
+		var err error
+		if r.frame, err = r.aead.Open(sealed[:0], nonce, sealed, frameAdditionalData(r.frames, last)); err != nil {
+			return 0, errors.New("encrypted output file tampered with, or decrypted with the wrong key")
+		}
+		r.frames++
+		r.ended = last
+	}
+	n := copy(p, r.frame)
+	r.frame = r.frame[n:]
+	return n, nil
+}
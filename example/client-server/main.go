@@ -14,19 +14,21 @@ type Person struct {
 	tracer *tracing.Tracer
 }
 
+// Args embeds tracing.TracedArgs, so that GetName has access to the trace
+// of the call. The trace is propagated by the tracing RPC codecs.
 type Args struct {
-	Token tracing.TracingToken
+	tracing.TracedArgs
 }
 
 type Reply struct {
-	Name  string
-	Token tracing.TracingToken
+	Name string
 }
 
+type NameRequested struct{}
+
 func (p *Person) GetName(args Args, reply *Reply) error {
-	trace := p.tracer.ReceiveToken(args.Token)
+	args.Trace().RecordAction(NameRequested{})
 	reply.Name = p.name
-	reply.Token = trace.GenerateToken()
 	return nil
 }
 
@@ -57,7 +59,13 @@ func server(done chan int) {
 	trace.RecordAction(ServerStart{Port: serverPort})
 	done <- 1
 
-	rpc.Accept(listener)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go tracing.ServeConn(tracer, rpc.DefaultServer, conn)
+	}
 }
 
 type ClientStart struct {
@@ -74,20 +82,19 @@ func client(done chan int) {
 
 	trace := tracer.CreateTrace()
 
-	client, err := rpc.Dial("tcp", serverPort)
+	client, err := tracing.DialRPC(tracer, "tcp", serverPort)
 	if err != nil {
 		log.Fatal("dialing:", err)
 	}
+	defer client.Close()
 	trace.RecordAction(ClientStart{ServerPort: serverPort})
 
-	args := Args{Token: trace.GenerateToken()}
 	var reply *Reply
-	err = client.Call("Person.GetName", args, &reply)
+	err = client.Call(trace, "Person.GetName", Args{}, &reply)
 	if err != nil {
 		log.Fatal("person error:", err)
 	}
 	fmt.Printf("GetName: %s\n", reply.Name)
-	tracer.ReceiveToken(reply.Token)
 
 	trace.RecordAction(ClientFinish{ServerPort: serverPort})
 	done <- 1
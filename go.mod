@@ -4,5 +4,12 @@ go 1.14
 
 require (
 	github.com/DistributedClocks/GoVector v0.0.0-20210402100930-db949c81a0af
-	github.com/google/go-cmp v0.5.4
+	github.com/golang/snappy v0.0.4
+	github.com/google/go-cmp v0.5.5
+	github.com/gorilla/websocket v1.4.2
+	github.com/segmentio/kafka-go v0.4.16
+	github.com/vmihailenco/msgpack/v5 v5.1.4
+	go.etcd.io/bbolt v1.3.5
+	google.golang.org/grpc v1.38.0
+	google.golang.org/protobuf v1.26.0
 )
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// goVectorSink writes a GoVector log per tracer, see
+// TracingServerConfig.GoVectorLogDir.
+type goVectorSink struct {
+	dir   string
+	flag  int                 // to open the logs with
+	files map[string]*os.File // the logs, by tracer identity
+}
+
+func newGoVectorSink(dir string, flag int) (*goVectorSink, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &goVectorSink{dir: dir, flag: flag, files: make(map[string]*os.File)}, nil
+}
+
+// goVectorLogName returns the name of the GoVector log of the tracer
+// identity in dir, the name InitGoVector gives it when passed the identity
+// as its log file name, with path separators replaced.
+func goVectorLogName(dir, identity string) string {
+	return filepath.Join(dir, strings.NewReplacer("/", "_", `\`, "_").Replace(identity)+"-Log.txt")
+}
+
+// Write appends record to the log of its tracer, as the event GoVector logs
+// for a local event: the identity and clock of the tracer on a line, and
+// the message, with the priority prefix of the tracer's, on the next.
+func (s *goVectorSink) Write(record TraceRecord) error {
+	file, ok := s.files[record.TracerIdentity]
+	if !ok {
+		var err error
+		file, err = os.OpenFile(goVectorLogName(s.dir, record.TracerIdentity), s.flag, 0666)
+		if err != nil {
+			return err
+		}
+		s.files[record.TracerIdentity] = file
+	}
+	_, err := fmt.Fprintf(file, "%s %s\nINFO [%s] TraceID=%d %s %s\n",
+		record.TracerIdentity, record.VectorClock.ReturnVCString(),
+		record.TracerIdentity, record.TraceID, record.Tag, record.Body)
+	return err
+}
+
+func (s *goVectorSink) Flush() error {
+	for _, file := range s.files {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *goVectorSink) Close() error {
+	var err error
+	for _, file := range s.files {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
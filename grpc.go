@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing/tracingpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcTransport calls the gRPC service of a tracing server, see
+// tracingpb.TracingServer.
+type grpcTransport struct{}
+
+func (grpcTransport) Dial(address string) (TransportConn, error) {
+	return dialGRPC(address)
+}
+
+func (grpcTransport) Listen(address string, handler TransportHandler) (io.Closer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer()
+	tracingpb.RegisterTracingServer(server, &grpcProvider{handler: handler})
+	l := &grpcListener{server: server, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		server.Serve(listener)
+	}()
+	return l, nil
+}
+
+// grpcListener stops a gRPC server, once its in-flight calls complete.
+type grpcListener struct {
+	server *grpc.Server
+	done   chan struct{}
+}
+
+func (l *grpcListener) Close() error {
+	l.server.GracefulStop()
+	<-l.done
+	return nil
+}
+
+// grpcConn calls the tracing server's gRPC service.
+type grpcConn struct {
+	conn   *grpc.ClientConn
+	client tracingpb.TracingClient
+}
+
+func dialGRPC(address string) (*grpcConn, error) {
+	// block until connected, as dialing the other transports does
+	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true))
+	if err != nil {
+		return nil, err
+	}
+	return &grpcConn{conn: conn, client: tracingpb.NewTracingClient(conn)}, nil
+}
+
+func (c *grpcConn) Send(arg RecordActionArg) error {
+	_, err := c.client.RecordAction(context.Background(), arg.toProto())
+	return err
+}
+
+func (c *grpcConn) GetLastVC(identity string) (vclock.VClock, error) {
+	result, err := c.client.GetLastVC(context.Background(), &tracingpb.GetLastVCArg{TracerIdentity: identity})
+	if err != nil {
+		return nil, err
+	}
+	return result.VectorClock, nil
+}
+
+func (c *grpcConn) Ping() error {
+	_, err := c.client.Ping(context.Background(), &tracingpb.PingArg{})
+	return err
+}
+
+func (c *grpcConn) Close() error {
+	return c.conn.Close()
+}
+
+// grpcProvider implements the gRPC service of the tracing server, with the
+// same semantics as the methods of RPCProvider.
+type grpcProvider struct {
+	tracingpb.UnimplementedTracingServer
+	handler TransportHandler
+}
+
+func (p *grpcProvider) RecordAction(ctx context.Context, arg *tracingpb.RecordActionArg) (*tracingpb.RecordActionResult, error) {
+	err := p.handler.RecordAction(recordActionArgFromProto(arg))
+	if isSlowDown(err) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &tracingpb.RecordActionResult{}, nil
+}
+
+func (p *grpcProvider) GetLastVC(ctx context.Context, arg *tracingpb.GetLastVCArg) (*tracingpb.GetLastVCResult, error) {
+	vc, err := p.handler.GetLastVC(arg.TracerIdentity)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &tracingpb.GetLastVCResult{VectorClock: vc}, nil
+}
+
+func (p *grpcProvider) Ping(ctx context.Context, arg *tracingpb.PingArg) (*tracingpb.PingResult, error) {
+	return &tracingpb.PingResult{}, nil
+}
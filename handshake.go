@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// handshakeTimeout bounds the handshake of a connection, so that a peer not
+// taking part in it, e.g. a server without a Secret, cannot stall the other
+// one.
+const handshakeTimeout = 10 * time.Second
+
+const handshakeNonceSize = 32
+
+// maxHandshakeIdentitySize bounds the identity a tracer authenticates as.
+const maxHandshakeIdentitySize = 1 << 16
+
+var errHandshake = errors.New("handshake failed: the server rejected the key of the tracer")
+
+// handshakeMAC returns the proof of knowledge of key, by identity, for
+// nonce.
+func handshakeMAC(key, nonce []byte, identity string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("Handshake\x00"))
+	mac.Write(nonce)
+	mac.Write([]byte(identity))
+	return mac.Sum(nil)
+}
+
+// serverHandshake authenticates the tracer at the other end of conn, before
+// any of its requests is served, if the server has a Secret or Identities,
+// on the connections to its Listener (or TLSListener) only: the server
+// sends a random nonce, which the tracer must reply to with the identity it
+// authenticates as, prefixed with its 2-byte length, and the MAC of the
+// nonce and identity keyed with the key of the identity (see credentials),
+// and the server acknowledges the MAC with a single byte. It returns the
+// identity the tracer claimed, if it sent one. Relays and replays, which
+// send the requests of several tracers, authenticate as the empty identity,
+// with the secret.
+func serverHandshake(conn net.Conn, credentials credentials) (string, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, handshakeNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write(nonce); err != nil {
+		return "", err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return "", err
+	}
+	identity := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, identity); err != nil {
+		return "", err
+	}
+	mac := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(conn, mac); err != nil {
+		return "", err
+	}
+	key, ok := credentials.key(string(identity))
+	if !ok || len(key) == 0 || !hmac.Equal(mac, handshakeMAC(key, nonce, string(identity))) {
+		return string(identity), errHandshake
+	}
+	_, err := conn.Write([]byte{1})
+	return string(identity), err
+}
+
+// clientHandshake proves to the server at the other end of conn that the
+// tracer, of identity, knows key, see serverHandshake.
+func clientHandshake(conn net.Conn, identity string, key []byte) error {
+	if len(identity) >= maxHandshakeIdentitySize {
+		return fmt.Errorf("tracer identity of %d bytes too long for the handshake", len(identity))
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, handshakeNonceSize)
+	if _, err := io.ReadFull(conn, nonce); err != nil {
+		return err
+	}
+	message := make([]byte, 2, 2+len(identity)+sha256.Size)
+	binary.BigEndian.PutUint16(message, uint16(len(identity)))
+	message = append(message, identity...)
+	message = append(message, handshakeMAC(key, nonce, identity)...)
+	if _, err := conn.Write(message); err != nil {
+		return err
+	}
+	ack := make([]byte, 1)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		// the server closes the connection when it rejects the key
+		return errHandshake
+	}
+	return nil
+}
+
+// withSecret returns transport, set up to perform the handshake as
+// identity with key when it connects, if it is the default transport.
+func withSecret(transport Transport, identity string, key []byte) Transport {
+	if t, ok := transport.(rpcTransport); ok && len(key) > 0 {
+		t.identity, t.secret = identity, key
+		return t
+	}
+	return transport
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+)
+
+// Output schema versions, the versions of the format of the records in the
+// output files.
+const (
+	// outputSchemaLegacy identifies the output files written by releases
+	// that predate the header, which have none.
+	outputSchemaLegacy = 0
+	// OutputSchemaVersion is the version of the records this release
+	// writes: the fields of TraceRecord.
+	OutputSchemaVersion = 1
+)
+
+// schemaUpgrades holds, for every output schema version this release can
+// read, how its records are brought to the current version, nil if they
+// need nothing. Files written by older releases must stay readable, like
+// older tokens (see tokenDecoders).
+var schemaUpgrades = map[int]func(record *TraceRecord){
+	// legacy records are current ones without TracerSeq, which is zero
+	outputSchemaLegacy:  nil,
+	OutputSchemaVersion: nil,
+}
+
+// outputHeaderTag is the tag of the record of the header of output files.
+const outputHeaderTag = "TracingOutputHeader"
+
+// modulePath is the path of the module of the library.
+const modulePath = "github.com/DistributedClocks/tracing"
+
+// OutputHeader identifies the writer of an output file, and the format of
+// its records. It is the body of the first record of the file, tagged
+// TracingOutputHeader and of no tracer, which RecordReader does not return
+// as a record, see RecordReader.Header. Files written by releases that
+// predate the header have none: their header is the zero OutputHeader.
+type OutputHeader struct {
+	LibraryVersion string // the version of the library, as in go.mod, "(devel)" if built from its own module
+	SchemaVersion  int    // the version of the records, see OutputSchemaVersion
+}
+
+// libraryVersion returns the version of the module of the library the
+// running binary was built with.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, module := range info.Deps {
+		if module.Path != modulePath {
+			continue
+		}
+		if module.Replace != nil && module.Replace.Version != "" {
+			return module.Replace.Version
+		}
+		return module.Version
+	}
+	return "unknown"
+}
+
+// newOutputHeaderRecord returns the record of the header of the output
+// files the server writes.
+func newOutputHeaderRecord() TraceRecord {
+	body, _ := json.Marshal(OutputHeader{LibraryVersion: libraryVersion(), SchemaVersion: OutputSchemaVersion})
+	return TraceRecord{Tag: outputHeaderTag, Body: body}
+}
+
+// outputHeaderOf returns the header record is the record of, if it is one.
+func outputHeaderOf(record TraceRecord) (OutputHeader, bool) {
+	var header OutputHeader
+	if record.Tag != outputHeaderTag || record.TracerIdentity != "" {
+		return header, false
+	}
+	if err := json.Unmarshal(record.Body, &header); err != nil {
+		return header, false
+	}
+	return header, true
+}
+
+// headerRecordReader reads the records of an output file after its header,
+// upgrading them from the schema version of the file.
+type headerRecordReader struct {
+	reader  recordDecoder // of every record, header included
+	header  OutputHeader
+	upgrade func(record *TraceRecord)
+	next    *TraceRecord // the first record, if not the header
+	err     error        // reading the first record
+}
+
+// newHeaderRecordReader reads the header of the records of reader, if any.
+// It fails if the file is of a schema version this release cannot read.
+func newHeaderRecordReader(reader recordDecoder) (*headerRecordReader, error) {
+	r := &headerRecordReader{reader: reader}
+	record, err := reader.Read()
+	if err != nil {
+		r.err = err
+	} else if header, ok := outputHeaderOf(record); ok {
+		r.header = header
+	} else {
+		r.next = &record
+	}
+	upgrade, ok := schemaUpgrades[r.header.SchemaVersion]
+	if !ok {
+		return nil, fmt.Errorf("output schema version %d is not supported by this release of the library, which writes version %d: the file was written by %s", r.header.SchemaVersion, OutputSchemaVersion, r.header.LibraryVersion)
+	}
+	r.upgrade = upgrade
+	return r, nil
+}
+
+func (r *headerRecordReader) Read() (TraceRecord, error) {
+	var record TraceRecord
+	if r.err != nil {
+		err := r.err
+		r.err = nil
+		return record, err
+	}
+	if r.next != nil {
+		record = *r.next
+		r.next = nil
+	} else {
+		var err error
+		if record, err = r.reader.Read(); err != nil {
+			return record, err
+		}
+	}
+	if r.upgrade != nil {
+		r.upgrade(&record)
+	}
+	return record, nil
+}
+
+func (r *headerRecordReader) Header() OutputHeader {
+	return r.header
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+var errHeartbeatTimeout = errors.New("heartbeat timed out")
+
+// heartbeat periodically pings the server over a tracer's connection, and
+// replaces the connection when a ping fails or times out. This detects
+// half-open connections (e.g. after a laptop slept, or a NAT mapping timed
+// out), over which records would otherwise silently be lost.
+type heartbeat struct {
+	interval time.Duration
+	timeout  time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (tracer *Tracer) startHeartbeat(interval, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = interval
+	}
+	tracer.heartbeat = &heartbeat{
+		interval: interval,
+		timeout:  timeout,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go tracer.runHeartbeat(tracer.heartbeat)
+}
+
+func (tracer *Tracer) runHeartbeat(hb *heartbeat) {
+	defer close(hb.done)
+
+	ticker := time.NewTicker(hb.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hb.stop:
+			return
+		case <-ticker.C:
+		}
+		for i := range tracer.conns {
+			conn := tracer.getConn(i)
+			if err := ping(conn, hb.timeout); err != nil {
+				log.Print("lost connection to the tracing server: ", err)
+				tracer.reconnect(i, conn)
+			}
+		}
+	}
+}
+
+// ping pings the server over conn, failing if no reply arrives within
+// timeout.
+func ping(conn TransportConn, timeout time.Duration) error {
+	pinger, ok := conn.(TransportPinger)
+	if !ok {
+		return nil
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- pinger.Ping()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return errHeartbeatTimeout
+	}
+}
+
+// reconnect replaces the dead connection conn, the i-th of the tracer, with
+// a new one, then closes conn, which makes any call blocked on it fail
+// rather than hang forever. If the server cannot be reached, the next
+// heartbeat tries again.
+func (tracer *Tracer) reconnect(i int, conn TransportConn) {
+	newConn, err := tracer.transport.Dial(tracer.address)
+	if err != nil {
+		log.Print("error reconnecting to the tracing server: ", err)
+		conn.Close()
+		return
+	}
+	tracer.connLock.Lock()
+	tracer.conns[i] = newConn
+	tracer.connLock.Unlock()
+	conn.Close()
+}
+
+// stopHeartbeat stops the heartbeat, if any, and waits for it to return.
+func (tracer *Tracer) stopHeartbeat() {
+	if tracer.heartbeat == nil {
+		return
+	}
+	close(tracer.heartbeat.stop)
+	<-tracer.heartbeat.done
+	tracer.heartbeat = nil
+}
+
+// getConn returns the i-th connection of the tracer.
+func (tracer *Tracer) getConn(i int) TransportConn {
+	tracer.connLock.Lock()
+	defer tracer.connLock.Unlock()
+	return tracer.conns[i]
+}
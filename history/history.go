@@ -0,0 +1,145 @@
+// Package history converts the records of a tracing server to histories of
+// operations for the Porcupine linearizability checker
+// (github.com/anishathalye/porcupine), see cmd/porcupine-export, e.g. to
+// check that the key-value store of an assignment is linearizable from its
+// traces alone:
+//
+//	records, err := tracereader.ReadFile("trace_output.log", PutRequest{}, PutResponse{}, ...)
+//	...
+//	operations := history.Convert(records, history.Mapping{
+//		Input: func(record tracereader.Record) (interface{}, bool) {
+//			switch action := record.Action.(type) {
+//			case PutRequest:
+//				return kvInput{Op: "put", Key: action.Key, Value: action.Value}, true
+//			...
+//		},
+//		Output: ...,
+//	})
+//
+// Each operation pairs a call with the first return causally after it in
+// its trace. Records carry no time, so operations are placed on a logical
+// time axis instead: the sum of the entries of the vector clocks of their
+// call and return, which orders causally related operations. Operations
+// which are concurrent may be ordered arbitrarily, which constrains
+// linearizations more than real time would: a rejected history may come
+// from the arbitrary order of its concurrent operations.
+package history
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+// Operation is an operation of a history, which unmarshals from JSON into a
+// porcupine.Operation.
+type Operation struct {
+	ClientId int         // the client of the operation, a number for the tracer of its call
+	Input    interface{} // the input of the call
+	Call     int64       // the logical time of the call
+	Output   interface{} // the output of the return
+	Return   int64       // the logical time of the return
+}
+
+// Mapping maps the calls and returns of operations to their inputs and
+// outputs, which must match the Porcupine model they are checked against.
+type Mapping struct {
+	// Input returns the input of the operation record calls, if it calls one.
+	Input func(record tracereader.Record) (interface{}, bool)
+	// Output returns the output of the operation record returns, if it
+	// returns one.
+	Output func(record tracereader.Record) (interface{}, bool)
+}
+
+// Action is the input or output of an operation mapped by TagMapping.
+type Action struct {
+	Tag  string          // the tag of the record
+	Body json.RawMessage // the body of the record
+}
+
+// TagMapping returns a mapping of the records whose tags match the patterns
+// calls and returns, in the syntax of path.Match, to Actions, e.g. "*Request"
+// and "*Response", for when the actions are not registered.
+func TagMapping(calls, returns string) Mapping {
+	action := func(pattern string) func(record tracereader.Record) (interface{}, bool) {
+		return func(record tracereader.Record) (interface{}, bool) {
+			if ok, _ := path.Match(pattern, record.Tag); !ok {
+				return nil, false
+			}
+			return Action{Tag: record.Tag, Body: record.Body}, true
+		}
+	}
+	return Mapping{Input: action(calls), Output: action(returns)}
+}
+
+// Convert returns the operations of records mapped by mapping, in the order
+// of their calls. Calls which never return are left out.
+func Convert(records []tracereader.Record, mapping Mapping) []Operation {
+	clients := make(map[string]int)
+	var operations []Operation
+	for _, trace := range tracereader.GroupByTrace(records) {
+		type call struct {
+			record tracereader.Record
+			input  interface{}
+		}
+		var pending []call
+		for _, record := range trace.Records {
+			if input, ok := mapping.Input(record); ok {
+				pending = append(pending, call{record: record, input: input})
+				continue
+			}
+			output, ok := mapping.Output(record)
+			if !ok {
+				continue
+			}
+			for i, c := range pending {
+				if !returnsAfter(record.TraceRecord, c.record.TraceRecord) {
+					continue
+				}
+				client, ok := clients[c.record.TracerIdentity]
+				if !ok {
+					client = len(clients)
+					clients[c.record.TracerIdentity] = client
+				}
+				operations = append(operations, Operation{
+					ClientId: client,
+					Input:    c.input,
+					Call:     int64(events.LogicalTime(c.record.VectorClock)),
+					Output:   output,
+					Return:   int64(events.LogicalTime(record.VectorClock)),
+				})
+				pending = append(pending[:i], pending[i+1:]...)
+				break
+			}
+		}
+	}
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].Call < operations[j].Call
+	})
+	return operations
+}
+
+// returnsAfter returns whether ret is causally after call.
+func returnsAfter(ret, call tracing.TraceRecord) bool {
+	for tracer, ticks := range call.VectorClock {
+		if ret.VectorClock[tracer] < ticks {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes the operations of records mapped by mapping to w, as a JSON
+// array.
+func Write(w io.Writer, records []tracereader.Record, mapping Mapping) error {
+	operations := Convert(records, mapping)
+	if operations == nil {
+		operations = []Operation{}
+	}
+	return json.NewEncoder(w).Encode(operations)
+}
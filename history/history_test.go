@@ -0,0 +1,53 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+	"github.com/DistributedClocks/tracing/tracereader"
+)
+
+type GetRequest struct {
+	Key string
+}
+
+type GetResponse struct {
+	Value string
+}
+
+func TestConvert(t *testing.T) {
+	traceRecords := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(GetRequest{Key: "a"})
+		client1.ReceiveToken(client2.ReceiveToken(trace.GenerateToken()).GenerateToken()).RecordAction(GetResponse{Value: "1"})
+		client1.CreateTrace().RecordAction(GetRequest{Key: "b"}) // never returns
+	})
+
+	var records []tracereader.Record
+	for _, record := range traceRecords {
+		records = append(records, tracereader.Record{TraceRecord: record})
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, records, TagMapping("*Request", "*Response")); err != nil {
+		t.Fatal(err)
+	}
+	var operations []struct {
+		ClientId      int
+		Input, Output Action
+		Call, Return  int64
+	}
+	if err := json.Unmarshal(buf.Bytes(), &operations); err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 1 {
+		t.Fatalf("expected 1 operation, got %s", buf.String())
+	}
+	op := operations[0]
+	if op.ClientId != 0 || op.Input.Tag != "GetRequest" || string(op.Input.Body) != `{"Key":"a"}` ||
+		op.Output.Tag != "GetResponse" || string(op.Output.Body) != `{"Value":"1"}` || op.Call >= op.Return {
+		t.Fatalf("expected the operation of the get of a, got %s", buf.String())
+	}
+}
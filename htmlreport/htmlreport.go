@@ -0,0 +1,200 @@
+// Package htmlreport renders the records of a tracing server as a single,
+// self-contained HTML page, to view a run in a browser without ShiViz, see
+// cmd/html-report.
+//
+// The page has a timeline per trace, with a lane per tracer where its
+// actions are points, in an order consistent with the happens-before
+// relation, and arrows for the tokens received from other lanes; and the
+// list of the actions of each tracer, with their vector clocks and bodies.
+// It needs no script, and links to nothing.
+package htmlreport
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// The geometry of the timelines, in pixels.
+const (
+	laneHeight  = 40
+	laneLabel   = 160 // the width of the names of the lanes
+	eventWidth  = 40  // between two successive points in time
+	eventRadius = 6
+)
+
+type report struct {
+	Title   string
+	Traces  []timeline
+	Tracers []tracerRecords
+}
+
+// timeline is the timeline of a trace.
+type timeline struct {
+	TraceID uint64
+	Width   int
+	Height  int
+	Lanes   []lane
+	Events  []event
+	Tokens  []token
+}
+
+type lane struct {
+	Tracer string
+	Y      int
+}
+
+type event struct {
+	X, Y  int
+	Label string
+}
+
+// token is the edge from the generation of a token to its reception.
+type token struct {
+	X1, Y1, X2, Y2 int
+	From, To       string
+}
+
+// tracerRecords is the list of the actions of a tracer.
+type tracerRecords struct {
+	Tracer  string
+	Records []record
+}
+
+type record struct {
+	TraceID     uint64
+	Tag         string
+	VectorClock string
+	Body        string
+}
+
+// Write writes the report of records to w, titled title. The traces and
+// tracers are in the order of their first record.
+func Write(w io.Writer, title string, records []tracing.TraceRecord) error {
+	r := report{Title: title}
+
+	var traceIDs []uint64
+	traces := make(map[uint64][]tracing.TraceRecord)
+	tracers := make(map[string]int)
+	for _, rec := range records {
+		if _, ok := traces[rec.TraceID]; !ok {
+			traceIDs = append(traceIDs, rec.TraceID)
+		}
+		traces[rec.TraceID] = append(traces[rec.TraceID], rec)
+
+		i, ok := tracers[rec.TracerIdentity]
+		if !ok {
+			i = len(r.Tracers)
+			tracers[rec.TracerIdentity] = i
+			r.Tracers = append(r.Tracers, tracerRecords{Tracer: rec.TracerIdentity})
+		}
+		clock, _ := json.Marshal(rec.VectorClock)
+		r.Tracers[i].Records = append(r.Tracers[i].Records, record{
+			TraceID:     rec.TraceID,
+			Tag:         rec.Tag,
+			VectorClock: string(clock),
+			Body:        string(rec.Body),
+		})
+	}
+	for _, traceID := range traceIDs {
+		r.Traces = append(r.Traces, newTimeline(traceID, traces[traceID]))
+	}
+	return page.Execute(w, r)
+}
+
+// newTimeline returns the timeline of the trace traceID, whose records are
+// records.
+func newTimeline(traceID uint64, records []tracing.TraceRecord) timeline {
+	// the sum of the entries of the clocks grows along happens-before, and
+	// the records of a tracer are already in order
+	sorted := append([]tracing.TraceRecord(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return events.LogicalTime(sorted[i].VectorClock) < events.LogicalTime(sorted[j].VectorClock)
+	})
+
+	t := timeline{TraceID: traceID}
+	lanes := make(map[string]int) // the Y of the lanes of the tracers
+	points := make(map[uint64]event)
+	for i, rec := range sorted {
+		y, ok := lanes[rec.TracerIdentity]
+		if !ok {
+			y = len(lanes)*laneHeight + laneHeight/2
+			lanes[rec.TracerIdentity] = y
+			t.Lanes = append(t.Lanes, lane{Tracer: rec.TracerIdentity, Y: y})
+		}
+		e := event{X: laneLabel + i*eventWidth + eventWidth/2, Y: y, Label: rec.Tag}
+		if body := string(rec.Body); body != "" && body != "{}" {
+			e.Label += " " + body
+		}
+		t.Events = append(t.Events, e)
+		points[events.RecordID(rec)] = e
+
+		for _, info := range events.ReceivedTokens(rec) {
+			if source, ok := points[events.ID(info.Tracer, info.VectorClock[info.Tracer])]; ok {
+				t.Tokens = append(t.Tokens, token{X1: source.X, Y1: source.Y, X2: e.X, Y2: e.Y, From: info.Tracer, To: rec.TracerIdentity})
+			}
+		}
+	}
+	t.Width = laneLabel + len(sorted)*eventWidth
+	t.Height = len(lanes) * laneHeight
+	return t
+}
+
+var page = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.timeline { overflow-x: auto; border: 1px solid #ccc; margin-bottom: 2em; }
+.lane { stroke: #ddd; }
+.event { fill: #1f77b4; }
+.event:hover { fill: #d62728; }
+.token { stroke: #ff7f0e; stroke-width: 1.5; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+td.json { font-family: monospace; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>Traces</h2>
+{{range .Traces}}
+<h3 id="trace-{{.TraceID}}">Trace {{.TraceID}}</h3>
+<div class="timeline">
+<svg width="{{.Width}}" height="{{.Height}}" xmlns="http://www.w3.org/2000/svg">
+<defs><marker id="arrow-{{.TraceID}}" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M 0 0 L 10 5 L 0 10 z" fill="#ff7f0e"/></marker></defs>
+{{- $width := .Width}}
+{{- $traceID := .TraceID}}
+{{- range .Lanes}}
+<line class="lane" x1="0" y1="{{.Y}}" x2="{{$width}}" y2="{{.Y}}"/>
+<text x="4" y="{{.Y}}" dy="0.35em">{{.Tracer}}</text>
+{{- end}}
+{{- range .Tokens}}
+<line class="token" x1="{{.X1}}" y1="{{.Y1}}" x2="{{.X2}}" y2="{{.Y2}}" marker-end="url(#arrow-{{$traceID}})"><title>token from {{.From}} to {{.To}}</title></line>
+{{- end}}
+{{- range .Events}}
+<circle class="event" cx="{{.X}}" cy="{{.Y}}" r="` + strconv.Itoa(eventRadius) + `"><title>{{.Label}}</title></circle>
+{{- end}}
+</svg>
+</div>
+{{end}}
+<h2>Tracers</h2>
+{{range .Tracers}}
+<h3>{{.Tracer}}</h3>
+<table>
+<tr><th>Trace</th><th>Action</th><th>Vector clock</th><th>Body</th></tr>
+{{- range .Records}}
+<tr><td><a href="#trace-{{.TraceID}}">{{.TraceID}}</a></td><td>{{.Tag}}</td><td class="json">{{.VectorClock}}</td><td class="json">{{.Body}}</td></tr>
+{{- end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
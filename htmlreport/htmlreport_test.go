@@ -0,0 +1,41 @@
+package htmlreport
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestWrite(t *testing.T) {
+	var trace *tracing.Trace
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace = client1.CreateTrace()
+		client2.ReceiveToken(trace.GenerateToken()).RecordAction(TestAction{Foo: "foo"})
+	})
+
+	var report strings.Builder
+	if err := Write(&report, "Run <1>", records); err != nil {
+		t.Fatal(err)
+	}
+	page := report.String()
+	for _, expected := range []string{
+		"<title>Run &lt;1&gt;</title>",
+		fmt.Sprintf(`<h3 id="trace-%d">`, trace.ID),
+		"token from client1 to client2",
+		"<h3>client2</h3>",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Fatalf("expected the report to contain %q, got:\n%s", expected, page)
+		}
+	}
+	if strings.Count(page, `<circle class="event"`) != 4 {
+		t.Fatalf("expected 4 events, got:\n%s", page)
+	}
+}
@@ -0,0 +1,195 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// httpRecordArg is the body of a request to the HTTP ingestion endpoint. It
+// mirrors RecordActionArg, except that Record may be given as the record's
+// JSON object itself, rather than base64-encoded as encoding/json would
+// encode RecordActionArg.Record. The MAC, if any, is computed over the
+// record as decoded, with an empty record replaced by {}.
+type httpRecordArg struct {
+	TracerIdentity string
+	TraceID        uint64
+	RecordName     string
+	Record         json.RawMessage
+	VectorClock    vclock.VClock
+	Session        uint64
+	Seq            uint64
+	MAC            []byte
+}
+
+// httpTransport calls the net/rpc methods of RPCProvider over HTTP, as
+// rpc.DialHTTP does, see httpHandler.
+type httpTransport struct{}
+
+// Dial connects to address, an http:// URL whose path, if any, is the
+// prefix the server's HTTP endpoints are mounted at.
+func (httpTransport) Dial(address string) (TransportConn, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	client, err := rpc.DialHTTPPath("tcp", u.Host, strings.TrimSuffix(u.Path, "/")+rpc.DefaultRPCPath)
+	if err != nil {
+		return nil, err
+	}
+	return rpcConn{client: client}, nil
+}
+
+func (httpTransport) Listen(address string, handler TransportHandler) (io.Closer, error) {
+	h, err := httpHandler(handler)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	// let in-flight requests finish recording their actions on close; as
+	// with TracingServer.Listener, RPC connections, which are hijacked, are
+	// closed by their clients
+	return serveHTTP(listener, h, true), nil
+}
+
+// httpServer is an http.Server serving connections from a listener.
+type httpServer struct {
+	server   *http.Server
+	done     chan struct{}
+	graceful bool
+}
+
+// serveHTTP serves handler on the connections accepted by listener, until
+// the returned server is closed. Closing a graceful server waits for the
+// in-flight requests to complete.
+func serveHTTP(listener net.Listener, handler http.Handler, graceful bool) *httpServer {
+	s := &httpServer{
+		server:   &http.Server{Handler: handler},
+		done:     make(chan struct{}),
+		graceful: graceful,
+	}
+	go func() {
+		defer close(s.done)
+		s.server.Serve(listener)
+	}()
+	return s
+}
+
+func (s *httpServer) Close() error {
+	var err error
+	if s.graceful {
+		err = s.server.Shutdown(context.Background())
+	} else {
+		err = s.server.Close()
+	}
+	<-s.done
+	return err
+}
+
+// closeWithin closes the server gracefully, unless its in-flight requests
+// take longer than timeout to complete, then abruptly.
+func (s *httpServer) closeWithin(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := s.server.Shutdown(ctx)
+	if err == context.DeadlineExceeded {
+		err = s.server.Close()
+	}
+	<-s.done
+	return err
+}
+
+// HTTPHandler returns a handler serving the HTTP endpoints of the server
+// (see httpHandler), which can be mounted on an existing mux, e.g.:
+// 	mux.Handle("/tracing/", http.StripPrefix("/tracing", server.HTTPHandler()))
+// for tracers to connect to with a ServerAddress of the form
+// "http://ip:port/tracing". Records are written to the output files opened
+// by Open, so the handler must not be served before the server is opened,
+// nor after it is closed.
+func (tracingServer *TracingServer) HTTPHandler() http.Handler {
+	handler, err := httpHandler(transportHandler{server: tracingServer})
+	if err != nil {
+		// RPCProvider is a valid receiver, registering it cannot fail
+		panic(err)
+	}
+	return handler
+}
+
+// httpHandler serves the HTTP endpoints of a tracing server:
+// 	- the net/rpc methods of RPCProvider at rpc.DefaultRPCPath, as with
+// 	  rpc.HandleHTTP, for tracers with a ServerAddress of the form http://ip:port
+// 	- the ingestion endpoint at /record, which records the action POSTed as
+// 	  JSON, and replies with 204 No Content once the action is recorded, or
+// 	  503 Service Unavailable if the server is overloaded (see ErrSlowDown), e.g.:
+// 	curl -d '{"TracerIdentity": "script", "TraceID": 1, "RecordName": "Started",
+// 		"Record": {"Step": 1}, "VectorClock": {"script": 1}}' http://ip:port/record
+func httpHandler(handler TransportHandler) (http.Handler, error) {
+	rpcServer, err := newRPCServer(handler)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, rpcServer)
+	mux.HandleFunc("/record", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		arg, err := decodeHTTPRecordArg(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = handler.RecordAction(arg)
+		if isSlowDown(err) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux, nil
+}
+
+func decodeHTTPRecordArg(r *http.Request) (RecordActionArg, error) {
+	var body httpRecordArg
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return RecordActionArg{}, err
+	}
+	arg := RecordActionArg{
+		TracerIdentity: body.TracerIdentity,
+		TraceID:        body.TraceID,
+		RecordName:     body.RecordName,
+		Record:         []byte(body.Record),
+		VectorClock:    body.VectorClock,
+		Session:        body.Session,
+		Seq:            body.Seq,
+		MAC:            body.MAC,
+	}
+	if len(body.Record) > 0 && body.Record[0] == '"' {
+		// the base64 encoding of RecordActionArg.Record
+		if err := json.Unmarshal(body.Record, &arg.Record); err != nil {
+			return RecordActionArg{}, err
+		}
+	}
+	if len(arg.Record) == 0 {
+		arg.Record = []byte("{}")
+	}
+	return arg, nil
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+)
+
+// IndexEntry locates a record in an output file, in its index: each line
+// of the index is an IndexEntry, as a JSON object.
+type IndexEntry struct {
+	TraceID        uint64
+	TracerIdentity string
+	Offset         int64 // the offset of the record in the output file
+}
+
+// indexFileName returns the name of the index of the output file fileName.
+func indexFileName(fileName string) string {
+	return fileName + ".idx"
+}
+
+// indexWriter writes the index of an output file.
+type indexWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func createIndex(fileName string) (*indexWriter, error) {
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &indexWriter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// write indexes record, written at offset in the output file.
+func (w *indexWriter) write(record TraceRecord, offset int64) error {
+	return w.encoder.Encode(IndexEntry{
+		TraceID:        record.TraceID,
+		TracerIdentity: record.TracerIdentity,
+		Offset:         offset,
+	})
+}
+
+func (w *indexWriter) Close() error {
+	return w.file.Close()
+}
+
+// Index locates the records of each trace and tracer in an output file.
+type Index struct {
+	traces  map[uint64][]int64
+	tracers map[string][]int64
+}
+
+// ReadIndex reads the index of outputFile, written by a server with
+// IndexOutput set.
+func ReadIndex(outputFile string) (*Index, error) {
+	file, err := os.Open(indexFileName(outputFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	index := &Index{
+		traces:  make(map[uint64][]int64),
+		tracers: make(map[string][]int64),
+	}
+	for decoder := json.NewDecoder(bufio.NewReader(file)); decoder.More(); {
+		var entry IndexEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+		index.traces[entry.TraceID] = append(index.traces[entry.TraceID], entry.Offset)
+		index.tracers[entry.TracerIdentity] = append(index.tracers[entry.TracerIdentity], entry.Offset)
+	}
+	return index, nil
+}
+
+// TraceOffsets returns the offsets of the records of the trace traceID, in
+// order.
+func (index *Index) TraceOffsets(traceID uint64) []int64 {
+	return index.traces[traceID]
+}
+
+// TracerOffsets returns the offsets of the records of the tracer identity,
+// in order.
+func (index *Index) TracerOffsets(identity string) []int64 {
+	return index.tracers[identity]
+}
+
+// ReadRecordAt reads the record at offset in an output file of the given
+// format (see TracingServerConfig.OutputFormat).
+func ReadRecordAt(file io.ReaderAt, offset int64, format string) (TraceRecord, error) {
+	r := io.NewSectionReader(file, offset, math.MaxInt64-offset)
+	switch format {
+	case "", OutputFormatJSON:
+		var record TraceRecord
+		err := json.NewDecoder(r).Decode(&record)
+		return record, err
+	case OutputFormatBinary:
+		return NewBinaryRecordReader(r).Read()
+	}
+	return TraceRecord{}, errOutputFormat
+}
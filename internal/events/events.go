@@ -0,0 +1,183 @@
+// Package events derives the events of traces, and the causal edges
+// between them, from the records of a tracing server, for the exporters.
+package events
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing"
+)
+
+// ID returns the ID of the event of tracer at its own clock, which is
+// unique in a run (a rejoining tracer resumes from its last clock), and
+// never 0.
+func ID(tracer string, clock uint64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(tracer))
+	var b [9]byte // with a separator from the identity
+	binary.BigEndian.PutUint64(b[1:], clock)
+	h.Write(b[:])
+	if id := h.Sum64(); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// RecordID returns the ID of the event of record.
+func RecordID(record tracing.TraceRecord) uint64 {
+	return ID(record.TracerIdentity, record.VectorClock[record.TracerIdentity])
+}
+
+// TokenSource returns the ID of the event that generated the token received
+// in record, if record is a ReceiveTokenTrace: the event of its generating
+// tracer at the clock the token carries.
+func TokenSource(record tracing.TraceRecord) (uint64, bool) {
+	if record.Tag != "ReceiveTokenTrace" {
+		return 0, false
+	}
+	var body struct{ Token tracing.TracingToken }
+	if err := json.Unmarshal(record.Body, &body); err != nil {
+		return 0, false
+	}
+	info, err := tracing.InspectToken(body.Token)
+	if err != nil {
+		return 0, false
+	}
+	return ID(info.Tracer, info.VectorClock[info.Tracer]), true
+}
+
+// ReceivedTokens returns the tokens received in record, if it is a
+// ReceiveTokenTrace or ReceiveTokensTrace, skipping those it cannot decode.
+func ReceivedTokens(record tracing.TraceRecord) []tracing.TokenInfo {
+	var body struct {
+		Token  tracing.TracingToken
+		Tokens []tracing.TracingToken
+	}
+	switch record.Tag {
+	case "ReceiveTokenTrace", "ReceiveTokensTrace":
+		if err := json.Unmarshal(record.Body, &body); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if body.Token != nil {
+		body.Tokens = append(body.Tokens, body.Token)
+	}
+	var infos []tracing.TokenInfo
+	for _, token := range body.Tokens {
+		if info, err := tracing.InspectToken(token); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// LogicalTime returns the sum of the entries of vc, which grows along the
+// happens-before relation, to place events on a time axis when records
+// carry no time.
+func LogicalTime(vc vclock.VClock) uint64 {
+	var sum uint64
+	for _, clock := range vc {
+		sum += clock
+	}
+	return sum
+}
+
+// Order returns records in a topological order of the happens-before
+// relation their vector clocks record, which is deterministic: of the
+// events whose predecessors are all ordered, the one of the smallest tracer
+// identity comes first. It does not depend on the order of records, nor on
+// the values of the clocks beyond the relation. Records in cycles, which
+// consistent clocks cannot have, come last, in their order.
+func Order(records []tracing.TraceRecord) []tracing.TraceRecord {
+	// The records of each tracer, by their own clock.
+	byTracer := make(map[string][]int)
+	for i, record := range records {
+		byTracer[record.TracerIdentity] = append(byTracer[record.TracerIdentity], i)
+	}
+	for tracer, indexes := range byTracer {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return records[indexes[i]].VectorClock[tracer] < records[indexes[j]].VectorClock[tracer]
+		})
+	}
+
+	// An event of tracer T happens before an event whose clock has the
+	// entry n for T if it is at most n, so the immediate predecessors of an
+	// event are, for each tracer, its last event within that entry.
+	successors := make([][]int, len(records))
+	pending := make([]int, len(records)) // the number of unordered predecessors of each record
+	for i, record := range records {
+		for tracer, ticks := range record.VectorClock {
+			if tracer == record.TracerIdentity {
+				ticks--
+			}
+			indexes := byTracer[tracer]
+			n := sort.Search(len(indexes), func(k int) bool {
+				return records[indexes[k]].VectorClock[tracer] > ticks
+			})
+			if n > 0 && indexes[n-1] != i {
+				successors[indexes[n-1]] = append(successors[indexes[n-1]], i)
+				pending[i]++
+			}
+		}
+	}
+
+	ready := &readyQueue{records: records}
+	for i := range records {
+		if pending[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+	ordered := make([]tracing.TraceRecord, 0, len(records))
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		ordered = append(ordered, records[i])
+		for _, j := range successors[i] {
+			if pending[j]--; pending[j] == 0 {
+				heap.Push(ready, j)
+			}
+		}
+	}
+	for i, record := range records {
+		if pending[i] > 0 {
+			ordered = append(ordered, record)
+		}
+	}
+	return ordered
+}
+
+// readyQueue is a heap of the indexes of records, by tracer identity, then
+// own clock, then index for inconsistent records sharing a clock.
+type readyQueue struct {
+	records []tracing.TraceRecord
+	indexes []int
+}
+
+func (q *readyQueue) Len() int { return len(q.indexes) }
+
+func (q *readyQueue) Less(i, j int) bool {
+	a, b := q.records[q.indexes[i]], q.records[q.indexes[j]]
+	if a.TracerIdentity != b.TracerIdentity {
+		return a.TracerIdentity < b.TracerIdentity
+	}
+	if a.VectorClock[a.TracerIdentity] != b.VectorClock[b.TracerIdentity] {
+		return a.VectorClock[a.TracerIdentity] < b.VectorClock[b.TracerIdentity]
+	}
+	return q.indexes[i] < q.indexes[j]
+}
+
+func (q *readyQueue) Swap(i, j int) { q.indexes[i], q.indexes[j] = q.indexes[j], q.indexes[i] }
+
+func (q *readyQueue) Push(x interface{}) { q.indexes = append(q.indexes, x.(int)) }
+
+func (q *readyQueue) Pop() interface{} {
+	i := q.indexes[len(q.indexes)-1]
+	q.indexes = q.indexes[:len(q.indexes)-1]
+	return i
+}
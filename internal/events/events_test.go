@@ -0,0 +1,42 @@
+package events
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing"
+)
+
+func TestOrder(t *testing.T) {
+	records := []tracing.TraceRecord{
+		{TracerIdentity: "b", Tag: "b2", VectorClock: vclock.VClock{"a": 1, "b": 2}},
+		{TracerIdentity: "a", Tag: "a2", VectorClock: vclock.VClock{"a": 2}},
+		{TracerIdentity: "c", Tag: "c1", VectorClock: vclock.VClock{"c": 1}},
+		{TracerIdentity: "b", Tag: "b1", VectorClock: vclock.VClock{"b": 1}},
+		{TracerIdentity: "a", Tag: "a3", VectorClock: vclock.VClock{"a": 3, "b": 2}},
+		{TracerIdentity: "a", Tag: "a1", VectorClock: vclock.VClock{"a": 1}},
+	}
+	expected := "a1 a2 b1 b2 a3 c1"
+	for i := 0; i < len(records); i++ {
+		// rotate the records, which must not change the order
+		rotated := append(append([]tracing.TraceRecord(nil), records[i:]...), records[:i]...)
+		var tags []string
+		for _, record := range Order(rotated) {
+			tags = append(tags, record.Tag)
+		}
+		if strings.Join(tags, " ") != expected {
+			t.Fatalf("expected the order %s, got %s", expected, strings.Join(tags, " "))
+		}
+	}
+
+	// a cycle, which consistent clocks cannot have
+	cycle := []tracing.TraceRecord{
+		{TracerIdentity: "a", Tag: "a1", VectorClock: vclock.VClock{"a": 1, "b": 1}},
+		{TracerIdentity: "b", Tag: "b1", VectorClock: vclock.VClock{"a": 1, "b": 1}},
+		{TracerIdentity: "c", Tag: "c1", VectorClock: vclock.VClock{"c": 1}},
+	}
+	if ordered := Order(cycle); len(ordered) != 3 || ordered[0].Tag != "c1" || ordered[1].Tag != "a1" {
+		t.Fatalf("expected c1 then the cycle, got %v", ordered)
+	}
+}
@@ -0,0 +1,37 @@
+// Package tracetest runs tracers against an in-memory tracing server, for
+// the tests of the packages reading its records.
+package tracetest
+
+import (
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Run returns the records of run, given the tracers client1 and client2 of
+// an in-memory tracing server, once they and the server are closed.
+func Run(t testing.TB, run func(client1, client2 *tracing.Tracer)) []tracing.TraceRecord {
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind: ":0",
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client1 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	run(client1, client2)
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return server.Records()
+}
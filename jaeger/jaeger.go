@@ -0,0 +1,141 @@
+// Package jaeger converts the records of a tracing server to the JSON
+// format the Jaeger UI loads traces from (with "Upload JSON"), see
+// cmd/jaeger-export.
+//
+// Each record becomes a span of its trace, named after its tag, from the
+// process named after its tracer identity. The reception of a token
+// follows from the span of its generation. Records carry no time, so spans
+// are placed on a logical time axis instead: the sum of the entries of
+// their vector clock, in microseconds after the start of the conversion,
+// which preserves the happens-before order.
+package jaeger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// File is the JSON document of traces the Jaeger UI loads.
+type File struct {
+	Data []Trace `json:"data"`
+}
+
+// Trace is a trace of a File.
+type Trace struct {
+	TraceID   string             `json:"traceID"`
+	Spans     []Span             `json:"spans"`
+	Processes map[string]Process `json:"processes"`
+}
+
+// Span is a span of a Trace.
+type Span struct {
+	TraceID       string      `json:"traceID"`
+	SpanID        string      `json:"spanID"`
+	Flags         int         `json:"flags"`
+	OperationName string      `json:"operationName"`
+	References    []Reference `json:"references"`
+	StartTime     int64       `json:"startTime"` // in microseconds since the Unix epoch
+	Duration      int64       `json:"duration"`  // in microseconds
+	Tags          []Tag       `json:"tags"`
+	Logs          []Log       `json:"logs"`
+	ProcessID     string      `json:"processID"`
+	Warnings      []string    `json:"warnings"`
+}
+
+// Reference is a causal edge from a span to another.
+type Reference struct {
+	RefType string `json:"refType"` // CHILD_OF or FOLLOWS_FROM
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+// Tag is a key-value annotation of a span or process.
+type Tag struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Log is a timestamped event of a span, which the conversion does not
+// produce.
+type Log struct {
+	Timestamp int64 `json:"timestamp"`
+	Fields    []Tag `json:"fields"`
+}
+
+// Process is the process a span is from.
+type Process struct {
+	ServiceName string `json:"serviceName"`
+	Tags        []Tag  `json:"tags"`
+}
+
+// Convert returns the traces of records, on a logical time axis from start.
+// The traces are ordered by trace ID, and their spans as in records.
+func Convert(records []tracing.TraceRecord, start time.Time) File {
+	traces := make(map[uint64]*Trace)
+	processIDs := make(map[uint64]map[string]string) // by trace, by tracer
+	for _, record := range records {
+		trace, ok := traces[record.TraceID]
+		if !ok {
+			trace = &Trace{TraceID: traceID(record.TraceID), Processes: make(map[string]Process)}
+			traces[record.TraceID] = trace
+			processIDs[record.TraceID] = make(map[string]string)
+		}
+		processID, ok := processIDs[record.TraceID][record.TracerIdentity]
+		if !ok {
+			processID = "p" + strconv.Itoa(len(trace.Processes)+1)
+			processIDs[record.TraceID][record.TracerIdentity] = processID
+			trace.Processes[processID] = Process{ServiceName: record.TracerIdentity, Tags: []Tag{}}
+		}
+		trace.Spans = append(trace.Spans, newSpan(record, trace.TraceID, processID, start))
+	}
+
+	file := File{Data: []Trace{}}
+	for _, trace := range traces {
+		file.Data = append(file.Data, *trace)
+	}
+	sort.Slice(file.Data, func(i, j int) bool {
+		return file.Data[i].TraceID < file.Data[j].TraceID
+	})
+	return file
+}
+
+func newSpan(record tracing.TraceRecord, traceID string, processID string, start time.Time) Span {
+	clock, _ := json.Marshal(record.VectorClock)
+	span := Span{
+		TraceID:       traceID,
+		SpanID:        spanID(events.RecordID(record)),
+		Flags:         1,
+		OperationName: record.Tag,
+		References:    []Reference{},
+		StartTime:     start.UnixNano()/int64(time.Microsecond) + int64(events.LogicalTime(record.VectorClock)),
+		Tags: []Tag{
+			{Key: "tracing.tracer_identity", Type: "string", Value: record.TracerIdentity},
+			{Key: "tracing.vector_clock", Type: "string", Value: string(clock)},
+			{Key: "tracing.body", Type: "string", Value: string(record.Body)},
+		},
+		Logs:      []Log{},
+		ProcessID: processID,
+	}
+	if source, ok := events.TokenSource(record); ok {
+		span.References = append(span.References, Reference{RefType: "FOLLOWS_FROM", TraceID: traceID, SpanID: spanID(source)})
+	}
+	return span
+}
+
+// traceID returns the Jaeger trace ID of the trace id, in hexadecimal.
+func traceID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// spanID returns the span ID of the event id (see events.ID), in
+// hexadecimal.
+func spanID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
@@ -0,0 +1,53 @@
+package jaeger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestConvert(t *testing.T) {
+	var trace *tracing.Trace
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace = client1.CreateTrace()
+		trace.RecordAction(TestAction{Foo: "foo"})
+		client2.ReceiveToken(trace.GenerateToken())
+		client1.CreateTrace()
+	})
+
+	start := time.Unix(1000, 0)
+	file := Convert(records, start)
+	if len(file.Data) != 2 {
+		t.Fatalf("expected 2 traces, got %v", file.Data)
+	}
+	var converted Trace
+	for _, converted = range file.Data {
+		if converted.TraceID == traceID(trace.ID) {
+			break
+		}
+	}
+	if len(converted.Spans) != 4 || len(converted.Processes) != 2 {
+		t.Fatalf("expected 4 spans from 2 processes, got %v", converted)
+	}
+	generate, receive := converted.Spans[2], converted.Spans[3]
+	if generate.OperationName != "GenerateTokenTrace" || receive.OperationName != "ReceiveTokenTrace" {
+		t.Fatalf("unexpected spans %v", converted.Spans)
+	}
+	if converted.Processes[receive.ProcessID].ServiceName != "client2" {
+		t.Fatalf("expected the reception to be from client2, got %v", converted.Processes[receive.ProcessID])
+	}
+	if len(receive.References) != 1 || receive.References[0].SpanID != generate.SpanID {
+		t.Fatalf("expected the reception to follow from the generation %s, got %v", generate.SpanID, receive.References)
+	}
+	// the generation is at {client1: 3}, the reception at {client1: 3,
+	// client2: 1}
+	if generate.StartTime != 1000000000+3 || receive.StartTime != 1000000000+4 {
+		t.Fatalf("unexpected start times %d and %d", generate.StartTime, receive.StartTime)
+	}
+}
@@ -0,0 +1,94 @@
+// Package kafka provides a sink for tracing servers that publishes each
+// record to a Kafka topic, for deployments feeding traces into streaming
+// pipelines:
+// 	tracing.TracingServerConfig{
+// 		...
+// 		Sinks: []tracing.Sink{kafka.NewSink([]string{"broker:9092"}, "traces")},
+// 	}
+// Records are published asynchronously, and may be lost if the brokers are
+// unreachable: the server's output file remains the source of truth.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/DistributedClocks/tracing"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// messageWriter is the part of kafkago.Writer the sink uses.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Sink publishes records to a Kafka topic, as JSON-encoded
+// tracing.TraceRecord messages keyed by the decimal trace ID, so that the
+// records of a trace land in the same partition, in order. Messages carry
+// the sequence number of the record in their "seq" header.
+type Sink struct {
+	writer messageWriter
+
+	lock sync.Mutex
+	err  error // of the last failed delivery
+}
+
+// NewSink returns a sink publishing records to topic, on the cluster of
+// brokers.
+func NewSink(brokers []string, topic string) *Sink {
+	s := &Sink{}
+	s.writer = &kafkago.Writer{
+		Addr:       kafkago.TCP(brokers...),
+		Topic:      topic,
+		Balancer:   &kafkago.Hash{},
+		Async:      true,
+		Completion: s.completed,
+	}
+	return s
+}
+
+func (s *Sink) completed(messages []kafkago.Message, err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("error publishing %d records to Kafka: %v", len(messages), err)
+	s.lock.Lock()
+	s.err = err
+	s.lock.Unlock()
+}
+
+// Write publishes record, asynchronously.
+func (s *Sink) Write(record tracing.TraceRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(context.Background(), kafkago.Message{
+		Key:     []byte(strconv.FormatUint(record.TraceID, 10)),
+		Value:   value,
+		Headers: []kafkago.Header{{Key: "seq", Value: []byte(strconv.FormatUint(record.Seq, 10))}},
+	})
+}
+
+// Flush returns the last error publishing records since the previous call,
+// if any.
+func (s *Sink) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// Close publishes the pending records, and closes the connections to the
+// brokers.
+func (s *Sink) Close() error {
+	if err := s.writer.Close(); err != nil {
+		return err
+	}
+	return s.Flush()
+}
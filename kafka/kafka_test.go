@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// fakeWriter keeps the messages written to it.
+type fakeWriter struct {
+	messages []kafkago.Message
+	closed   bool
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	writer := &fakeWriter{}
+	sink := &Sink{writer: writer}
+	record := tracing.TraceRecord{
+		TracerIdentity: "client1",
+		TraceID:        42,
+		Tag:            "TestAction",
+		Body:           json.RawMessage(`{"Foo":"foo"}`),
+		VectorClock:    map[string]uint64{"client1": 2},
+		Seq:            7,
+	}
+	if err := sink.Write(record); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed asynchronous delivery is reported by the next flush
+	sink.completed(writer.messages, errors.New("broker unreachable"))
+	if err := sink.Flush(); err == nil {
+		t.Fatal("expected the delivery error to be reported")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(writer.messages) != 1 || !writer.closed {
+		t.Fatalf("expected 1 message and the writer closed, got %v", writer.messages)
+	}
+	message := writer.messages[0]
+	if string(message.Key) != "42" || string(message.Headers[0].Value) != "7" {
+		t.Fatalf("unexpected message key %s or headers %v", message.Key, message.Headers)
+	}
+	var published tracing.TraceRecord
+	if err := json.Unmarshal(message.Value, &published); err != nil {
+		t.Fatal(err)
+	}
+	if published.TracerIdentity != "client1" || string(published.Body) != `{"Foo":"foo"}` {
+		t.Fatalf("unexpected published record %v", published)
+	}
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TruncatedRecordTag is the tag of the records standing for those whose
+// body exceeded the limits of the server, see TruncateRecords.
+const TruncatedRecordTag = "TruncatedRecord"
+
+// ErrRecordRejected is wrapped by the errors of the records a server
+// rejects for good, e.g. for exceeding its MaxRecordSize, or the rate limit
+// of their tracer: unlike ErrSlowDown, sending them again does not get them
+// recorded, see isRejected.
+var ErrRecordRejected = errors.New("record rejected")
+
+// isRejected returns whether err wraps ErrRecordRejected, as returned by
+// the server through any transport, which only carry the message of
+// errors.
+func isRejected(err error) bool {
+	return err != nil && (errors.Is(err, ErrRecordRejected) || strings.Contains(err.Error(), ErrRecordRejected.Error()))
+}
+
+// TruncatedRecord is the body of the records tagged TruncatedRecordTag,
+// which replace the records whose body exceeded the MaxRecordSize or
+// MaxRecordFields of a server with TruncateRecords set, in their trace and
+// with their vector clock.
+type TruncatedRecord struct {
+	Tag    string // the tag of the record replaced
+	Size   int    // the size of its body, in bytes
+	Fields int    `json:",omitempty"` // the number of fields of its body, if counted
+}
+
+// decodeRecord decompresses the record of arg, and enforces the
+// MaxRecordSize and MaxRecordFields of the server, rejecting the record, or
+// replacing it with a TruncatedRecord, if it exceeds them.
+func (tracingServer *TracingServer) decodeRecord(arg *RecordActionArg) error {
+	maxSize, maxFields := tracingServer.Config.MaxRecordSize, tracingServer.Config.MaxRecordFields
+	size, err := decompressRecord(arg, maxSize)
+	if err != nil {
+		return err
+	}
+	var exceeded error
+	fields := 0
+	if maxSize > 0 && size > maxSize {
+		exceeded = fmt.Errorf("%w: %s of %d bytes exceeds the limit of %d bytes", ErrRecordRejected, arg.RecordName, size, maxSize)
+	} else if maxFields > 0 {
+		if fields, err = countFields(arg.Record); err != nil {
+			return fmt.Errorf("%w: invalid %s: %v", ErrRecordRejected, arg.RecordName, err)
+		}
+		if fields > maxFields {
+			exceeded = fmt.Errorf("%w: %s of %d fields exceeds the limit of %d fields", ErrRecordRejected, arg.RecordName, fields, maxFields)
+		}
+	}
+	if exceeded == nil || !tracingServer.Config.TruncateRecords {
+		return exceeded
+	}
+	body, err := json.Marshal(TruncatedRecord{Tag: arg.RecordName, Size: size, Fields: fields})
+	if err != nil {
+		return err
+	}
+	arg.RecordName, arg.Record = TruncatedRecordTag, body
+	return nil
+}
+
+// countFields returns the number of fields of the objects of the JSON
+// value data, nested ones included.
+func countFields(data []byte) (int, error) {
+	// the containers the decoder is in, and whether the next token of each
+	// object is a key
+	type container struct {
+		object, key bool
+	}
+	var stack []container
+	value := func() {
+		if n := len(stack); n > 0 && stack[n-1].object {
+			stack[n-1].key = true
+		}
+	}
+	fields := 0
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF && len(stack) == 0 {
+			return fields, nil
+		} else if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+		if n := len(stack); n > 0 && stack[n-1].key && token != json.Delim('}') {
+			fields++
+			stack[n-1].key = false
+			continue
+		}
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, container{object: true, key: true})
+		case json.Delim('['):
+			stack = append(stack, container{})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			value()
+		default:
+			value()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCountFields(t *testing.T) {
+	for data, expected := range map[string]int{
+		`"foo"`:                              0,
+		`{}`:                                 0,
+		`{"Foo":"foo","Bar":[1,{"Baz":{}}]}`: 3,
+		`[{"Foo":1},{"Foo":2,"Bar":[]}]`:     3,
+	} {
+		if fields, err := countFields([]byte(data)); err != nil || fields != expected {
+			t.Errorf("expected %d fields in %s, got %d (%v)", expected, data, fields, err)
+		}
+	}
+	if _, err := countFields([]byte(`{"Foo":`)); err == nil {
+		t.Error("expected an error counting the fields of invalid JSON")
+	}
+}
+
+func TestMaxRecordSize(t *testing.T) {
+	for _, truncate := range []bool{false, true} {
+		server := NewTracingServer(TracingServerConfig{
+			ServerBind:      ":0",
+			InMemory:        true,
+			MaxRecordSize:   1024,
+			MaxRecordFields: 2,
+			TruncateRecords: truncate,
+		})
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+
+		// the compressed record is only decompressed up to the limit
+		tracer := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+			Compression:    CompressionGzip,
+		})
+		trace := tracer.CreateTrace()
+		trace.RecordAction(TestAction{Foo: strings.Repeat("x", 4096)})
+		trace.RecordAction(struct{ Foo, Bar, Baz string }{})
+		trace.RecordAction(TestAction{Foo: "foo"})
+		tracer.Close()
+		err := server.decodeRecord(&RecordActionArg{RecordName: "TestAction", Record: make([]byte, 2048)})
+		if truncate && err != nil || !truncate && !isRejected(err) {
+			t.Fatalf("unexpected error decoding a record past the limits: %v", err)
+		}
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var truncated []TruncatedRecord
+		tags := 0
+		for _, record := range server.Records() {
+			if record.Tag == TruncatedRecordTag {
+				var body TruncatedRecord
+				if err := json.Unmarshal(record.Body, &body); err != nil {
+					t.Fatal(err)
+				}
+				truncated = append(truncated, body)
+			} else if record.Tag == "TestAction" {
+				tags++
+			}
+		}
+		if tags != 1 {
+			t.Fatalf("expected a single TestAction record within the limits, got %d", tags)
+		}
+		var expected []TruncatedRecord
+		if truncate {
+			expected = []TruncatedRecord{
+				{Tag: "TestAction", Size: len(`{"Foo":""}`) + 4096},
+				{Tag: "", Size: len(`{"Foo":"","Bar":"","Baz":""}`), Fields: 3},
+			}
+		}
+		if !cmp.Equal(truncated, expected) {
+			t.Fatalf("expected the truncated records %v, got %v", expected, truncated)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// actionType is the reflect metadata of a type of action, computed once
+// per type, as reflection dominates the cost of recording at high rates.
+type actionType struct {
+	name   string
+	fields []string // the names of the fields, each prefixed with its separator
+}
+
+// actionTypes caches the actionType of each type of action recorded,
+// by reflect.Type.
+var actionTypes sync.Map
+
+// getActionType returns the metadata of the type of record, which must be
+// a struct.
+func getActionType(recType reflect.Type) *actionType {
+	if cached, ok := actionTypes.Load(recType); ok {
+		return cached.(*actionType)
+	}
+	numFields := recType.NumField()
+	t := &actionType{
+		name:   recType.Name(),
+		fields: make([]string, numFields),
+	}
+	for i := 0; i < numFields; i++ {
+		sep := ", "
+		if i == 0 {
+			sep = " "
+		}
+		t.fields[i] = sep + recType.Field(i).Name + "="
+	}
+	cached, _ := actionTypes.LoadOrStore(recType, t)
+	return cached.(*actionType)
+}
+
+// buffers pools the buffers the log strings are built in.
+var buffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	// do not keep the buffers of outsized records around
+	if buf.Cap() <= 64<<10 {
+		buffers.Put(buf)
+	}
+}
+
+// writeLogString writes the log string of record, in trace if not nil, to
+// buf; see getLogString.
+func (tracer *Tracer) writeLogString(buf *bytes.Buffer, trace *Trace, record interface{}) {
+	recVal := reflect.ValueOf(record)
+	t := getActionType(recVal.Type())
+
+	buf.WriteByte('[')
+	buf.WriteString(tracer.identity)
+	buf.WriteString("] ")
+	if trace != nil {
+		buf.WriteString("TraceID=")
+		buf.WriteString(strconv.FormatUint(trace.ID, 10))
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(t.name)
+	for i, field := range t.fields {
+		buf.WriteString(field)
+		// strip all pointer types (when not nil), so we log the pointed-to value
+		valueToLog := recVal.Field(i)
+		for valueToLog.Kind() == reflect.Ptr && !valueToLog.IsNil() {
+			valueToLog = reflect.Indirect(valueToLog)
+		}
+		fmt.Fprint(buf, valueToLog.Interface())
+	}
+}
@@ -0,0 +1,32 @@
+package tracing
+
+import "testing"
+
+type marshalAction struct {
+	Name  string
+	Count *int
+	Next  *marshalAction
+	HTML  string `json:",omitempty"`
+}
+
+func TestGetLogString(t *testing.T) {
+	tracer := &Tracer{identity: "client1"}
+	count := 3
+	tests := []struct {
+		trace  *Trace
+		record interface{}
+		want   string
+	}{
+		{nil, marshalAction{Name: "a"}, "[client1] marshalAction Name=a, Count=<nil>, Next=<nil>, HTML="},
+		{&Trace{ID: 42}, marshalAction{Name: "b", Count: &count}, "[client1] TraceID=42 marshalAction Name=b, Count=3, Next=<nil>, HTML="},
+		{&Trace{ID: 7}, CreateTrace{}, "[client1] TraceID=7 CreateTrace"},
+	}
+	for _, test := range tests {
+		// twice, as the metadata of the type is cached the first time
+		for i := 0; i < 2; i++ {
+			if got := tracer.getLogString(test.trace, test.record); got != test.want {
+				t.Errorf("getLogString(%#v) = %q, want %q", test.record, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import "sync"
+
+// memoryStore keeps the records the server writes in memory, for
+// TracingServer.Records and RecordsForTrace.
+type memoryStore struct {
+	lock    sync.RWMutex
+	records []TraceRecord
+	traces  map[uint64][]int // the indices in records of the records of each trace
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{traces: make(map[uint64][]int)}
+}
+
+func (s *memoryStore) Write(record TraceRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.traces[record.TraceID] = append(s.traces[record.TraceID], len(s.records))
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memoryStore) Flush() error {
+	return nil
+}
+
+// Close keeps the records, which can still be queried once the server is
+// closed.
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// Records returns the records written by the server, in order, if
+// InMemory is set.
+func (tracingServer *TracingServer) Records() []TraceRecord {
+	if tracingServer.memoryStore == nil {
+		return nil
+	}
+	s := tracingServer.memoryStore
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]TraceRecord(nil), s.records...)
+}
+
+// RecordsForTrace returns the records of the trace traceID written by the
+// server, in order, if InMemory is set.
+func (tracingServer *TracingServer) RecordsForTrace(traceID uint64) []TraceRecord {
+	if tracingServer.memoryStore == nil {
+		return nil
+	}
+	s := tracingServer.memoryStore
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var records []TraceRecord
+	for _, i := range s.traces[traceID] {
+		records = append(records, s.records[i])
+	}
+	return records
+}
@@ -0,0 +1,74 @@
+// Package merge merges the records of the output files of several tracing
+// servers, e.g. one per region, see cmd/trace-merge.
+//
+// The records are merged in an order consistent with their vector clocks:
+// by the sum of their entries, which grows along the happens-before
+// relation, and in the order of the inputs otherwise. Records found in
+// several inputs, e.g. when servers relay to each other, are kept once: a
+// record is identified by its tracer and the entry of its tracer in its
+// vector clock, which ticks once per record.
+package merge
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Duplicate is a record skipped by Merge, as the same record was found
+// before it, in an earlier input or earlier in its own.
+type Duplicate struct {
+	Input  int                 // the index of the input of Record
+	Record tracing.TraceRecord // the record skipped
+	First  tracing.TraceRecord // the record kept
+}
+
+// Conflicting returns whether the record skipped records another action
+// than the record kept, though both have the same identity, e.g. when the
+// output file of a server was edited.
+func (d Duplicate) Conflicting() bool {
+	return !sameRecord(d.First, d.Record)
+}
+
+// Merge returns the records of inputs, in causal order, once each, and the
+// duplicates it skipped, in the order it found them.
+func Merge(inputs [][]tracing.TraceRecord) ([]tracing.TraceRecord, []Duplicate) {
+	var merged []tracing.TraceRecord
+	var duplicates []Duplicate
+	seen := make(map[uint64]tracing.TraceRecord)
+	for i, records := range inputs {
+		for _, record := range records {
+			id := events.RecordID(record)
+			if first, ok := seen[id]; ok {
+				duplicates = append(duplicates, Duplicate{Input: i, Record: record, First: first})
+				continue
+			}
+			seen[id] = record
+			merged = append(merged, record)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return events.LogicalTime(merged[i].VectorClock) < events.LogicalTime(merged[j].VectorClock)
+	})
+	return merged, duplicates
+}
+
+// sameRecord returns whether a and b record the same action.
+func sameRecord(a, b tracing.TraceRecord) bool {
+	if a.TraceID != b.TraceID || a.Tag != b.Tag || len(a.VectorClock) != len(b.VectorClock) {
+		return false
+	}
+	for tracer, ticks := range a.VectorClock {
+		if b.VectorClock[tracer] != ticks {
+			return false
+		}
+	}
+	var aBody, bBody bytes.Buffer
+	if json.Compact(&aBody, a.Body) != nil || json.Compact(&bBody, b.Body) != nil {
+		return bytes.Equal(a.Body, b.Body)
+	}
+	return bytes.Equal(aBody.Bytes(), bBody.Bytes())
+}
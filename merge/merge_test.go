@@ -0,0 +1,74 @@
+package merge
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing"
+	"github.com/google/go-cmp/cmp"
+)
+
+// record returns the record of tracer with tag and body, at vc.
+func record(tracer, tag, body string, vc map[string]uint64) tracing.TraceRecord {
+	return tracing.TraceRecord{
+		TracerIdentity: tracer,
+		TraceID:        1,
+		Tag:            tag,
+		Body:           json.RawMessage(body),
+		VectorClock:    vclock.VClock(vc),
+	}
+}
+
+func TestMerge(t *testing.T) {
+	create := record("client1", "CreateTrace", "{}", map[string]uint64{"client1": 1})
+	put := record("client1", "Put", `{"Key":"k"}`, map[string]uint64{"client1": 2})
+	generate := record("client1", "GenerateTokenTrace", `{}`, map[string]uint64{"client1": 3})
+	receive := record("server1", "ReceiveTokenTrace", `{}`, map[string]uint64{"client1": 3, "server1": 1})
+	get := record("server1", "Get", `{"Key":"k"}`, map[string]uint64{"client1": 3, "server1": 2})
+	other := record("client2", "CreateTrace", "{}", map[string]uint64{"client2": 1})
+
+	// region1 relayed the records of client1 to region2, which has those
+	// of server1, out of causal order, and region3 those of client2
+	region1 := []tracing.TraceRecord{create, put, generate}
+	region2 := []tracing.TraceRecord{get, put, receive, create, generate}
+	region3 := []tracing.TraceRecord{other}
+	merged, duplicates := Merge([][]tracing.TraceRecord{region1, region2, region3})
+
+	// in causal order, and in the order of the inputs otherwise
+	expected := []tracing.TraceRecord{create, other, put, generate, receive, get}
+	if !cmp.Equal(merged, expected) {
+		t.Fatalf("unexpected records: %s", cmp.Diff(expected, merged))
+	}
+	if len(duplicates) != 3 {
+		t.Fatalf("expected the 3 records of client1 in region2 to be duplicates, got %v", duplicates)
+	}
+	for _, duplicate := range duplicates {
+		if duplicate.Input != 1 || duplicate.Record.TracerIdentity != "client1" || duplicate.Conflicting() {
+			t.Fatalf("unexpected duplicate %v", duplicate)
+		}
+	}
+}
+
+func TestConflicting(t *testing.T) {
+	put := record("client1", "Put", `{"Key":"k"}`, map[string]uint64{"client1": 2})
+	for _, test := range []struct {
+		name        string
+		record      tracing.TraceRecord
+		conflicting bool
+	}{
+		{"same", put, false},
+		{"reformatted body", record("client1", "Put", `{ "Key": "k" }`, map[string]uint64{"client1": 2}), false},
+		{"other body", record("client1", "Put", `{"Key":"j"}`, map[string]uint64{"client1": 2}), true},
+		{"other tag", record("client1", "Get", `{"Key":"k"}`, map[string]uint64{"client1": 2}), true},
+		{"other clock", record("client1", "Put", `{"Key":"k"}`, map[string]uint64{"client1": 2, "server1": 1}), true},
+	} {
+		_, duplicates := Merge([][]tracing.TraceRecord{{put}, {test.record}})
+		if len(duplicates) != 1 {
+			t.Fatalf("%s: expected a duplicate, got %v", test.name, duplicates)
+		}
+		if conflicting := duplicates[0].Conflicting(); conflicting != test.conflicting {
+			t.Fatalf("%s: expected conflicting to be %t, got %t", test.name, test.conflicting, conflicting)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package neo4j converts the records of a tracing server to a
+// happens-before graph for Neo4j, to run causality queries in Cypher, see
+// cmd/neo4j-export. E.g. the actions that happened before a Commit:
+//
+//	MATCH (a:Action)-[:HAPPENS_BEFORE*]->(c:Action {tag: "Commit"}) RETURN a
+//
+// Each record is an Action node, with the properties id (see events.ID),
+// tracer, trace (the trace ID, as a string), tag, clock and body (as JSON).
+// HAPPENS_BEFORE relationships link each action to the next one of its
+// tracer (of kind "program"), and the generation of a token to its
+// receptions (of kind "token"): happens-before is their transitive closure.
+//
+// The graph is written either as Cypher statements, for cypher-shell or the
+// Neo4j browser, or as the CSV files of its nodes and relationships, for
+// neo4j-admin database import.
+package neo4j
+
+import (
+	stdcsv "encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Relationship kinds.
+const (
+	kindProgram = "program"
+	kindToken   = "token"
+)
+
+type node struct {
+	id     string
+	tracer string
+	trace  string
+	tag    string
+	clock  string
+	body   string
+}
+
+type edge struct {
+	from, to string
+	kind     string
+}
+
+// graph returns the nodes and relationships of the happens-before graph of
+// records.
+func graph(records []tracing.TraceRecord) ([]node, []edge) {
+	var nodes []node
+	var edges []edge
+	ids := make(map[uint64]bool)
+	tracers := make(map[string][]tracing.TraceRecord)
+	for _, record := range records {
+		id := events.RecordID(record)
+		ids[id] = true
+		clock, _ := json.Marshal(record.VectorClock)
+		nodes = append(nodes, node{
+			id:     nodeID(id),
+			tracer: record.TracerIdentity,
+			trace:  strconv.FormatUint(record.TraceID, 10),
+			tag:    record.Tag,
+			clock:  string(clock),
+			body:   string(record.Body),
+		})
+		tracers[record.TracerIdentity] = append(tracers[record.TracerIdentity], record)
+	}
+
+	var identities []string
+	for identity := range tracers {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+	for _, identity := range identities {
+		program := tracers[identity]
+		sort.SliceStable(program, func(i, j int) bool {
+			return program[i].VectorClock[identity] < program[j].VectorClock[identity]
+		})
+		for i := 1; i < len(program); i++ {
+			edges = append(edges, edge{from: nodeID(events.RecordID(program[i-1])), to: nodeID(events.RecordID(program[i])), kind: kindProgram})
+		}
+	}
+	for _, record := range records {
+		for _, token := range events.ReceivedTokens(record) {
+			source := events.ID(token.Tracer, token.VectorClock[token.Tracer])
+			if ids[source] {
+				edges = append(edges, edge{from: nodeID(source), to: nodeID(events.RecordID(record)), kind: kindToken})
+			}
+		}
+	}
+	return nodes, edges
+}
+
+// nodeID returns the id property of the node of the event id.
+func nodeID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// WriteCypher writes the graph of records to w, as Cypher statements
+// creating its nodes, indexed by id, and then its relationships.
+func WriteCypher(w io.Writer, records []tracing.TraceRecord) error {
+	nodes, edges := graph(records)
+	var b strings.Builder
+	b.WriteString("CREATE INDEX action_id IF NOT EXISTS FOR (a:Action) ON (a.id);\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "CREATE (:Action {id: %s, tracer: %s, trace: %s, tag: %s, clock: %s, body: %s});\n",
+			quote(n.id), quote(n.tracer), quote(n.trace), quote(n.tag), quote(n.clock), quote(n.body))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "MATCH (a:Action {id: %s}), (b:Action {id: %s}) CREATE (a)-[:HAPPENS_BEFORE {kind: %s}]->(b);\n",
+			quote(e.from), quote(e.to), quote(e.kind))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote returns s as a Cypher string literal.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
+
+// WriteCSV writes the graph of records as the CSV files of its nodes, to
+// nodes, and of its relationships, to relationships, with the headers
+// neo4j-admin database import expects:
+//
+//	neo4j-admin database import full --nodes=nodes.csv --relationships=relationships.csv
+func WriteCSV(nodes, relationships io.Writer, records []tracing.TraceRecord) error {
+	graphNodes, graphEdges := graph(records)
+
+	w := stdcsv.NewWriter(nodes)
+	w.Write([]string{"id:ID", "tracer", "trace", "tag", "clock", "body", ":LABEL"})
+	for _, n := range graphNodes {
+		w.Write([]string{n.id, n.tracer, n.trace, n.tag, n.clock, n.body, "Action"})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	w = stdcsv.NewWriter(relationships)
+	w.Write([]string{":START_ID", ":END_ID", ":TYPE", "kind"})
+	for _, e := range graphEdges {
+		w.Write([]string{e.from, e.to, "HAPPENS_BEFORE", e.kind})
+	}
+	w.Flush()
+	return w.Error()
+}
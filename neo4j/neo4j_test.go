@@ -0,0 +1,62 @@
+package neo4j
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func records(t *testing.T) []tracing.TraceRecord {
+	return tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		client2.ReceiveToken(trace.GenerateToken()).RecordAction(TestAction{Foo: `"foo"`})
+	})
+}
+
+func TestWriteCypher(t *testing.T) {
+	records := records(t)
+	var b strings.Builder
+	if err := WriteCypher(&b, records); err != nil {
+		t.Fatal(err)
+	}
+	cypher := b.String()
+
+	ids := make(map[string]string) // by tag
+	for _, record := range records {
+		ids[record.Tag] = nodeID(events.RecordID(record))
+	}
+	for _, expected := range []string{
+		fmt.Sprintf(`CREATE (:Action {id: "%s", tracer: "client2", trace: "%d", tag: "TestAction", clock: "{\"client1\":2,\"client2\":2}", body: "{\"Foo\":\"\\\"foo\\\"\"}"});`, ids["TestAction"], records[0].TraceID),
+		fmt.Sprintf(`MATCH (a:Action {id: "%s"}), (b:Action {id: "%s"}) CREATE (a)-[:HAPPENS_BEFORE {kind: "program"}]->(b);`, ids["CreateTrace"], ids["GenerateTokenTrace"]),
+		fmt.Sprintf(`MATCH (a:Action {id: "%s"}), (b:Action {id: "%s"}) CREATE (a)-[:HAPPENS_BEFORE {kind: "program"}]->(b);`, ids["ReceiveTokenTrace"], ids["TestAction"]),
+		fmt.Sprintf(`MATCH (a:Action {id: "%s"}), (b:Action {id: "%s"}) CREATE (a)-[:HAPPENS_BEFORE {kind: "token"}]->(b);`, ids["GenerateTokenTrace"], ids["ReceiveTokenTrace"]),
+	} {
+		if !strings.Contains(cypher, expected) {
+			t.Fatalf("expected the statements to contain\n%s\ngot\n%s", expected, cypher)
+		}
+	}
+	if n := strings.Count(cypher, "HAPPENS_BEFORE"); n != 3 {
+		t.Fatalf("expected 3 relationships, got %d:\n%s", n, cypher)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var nodes, relationships strings.Builder
+	if err := WriteCSV(&nodes, &relationships, records(t)); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(nodes.String()), "\n"); len(lines) != 5 || lines[0] != "id:ID,tracer,trace,tag,clock,body,:LABEL" {
+		t.Fatalf("unexpected nodes:\n%s", nodes.String())
+	}
+	if lines := strings.Split(strings.TrimSpace(relationships.String()), "\n"); len(lines) != 4 || lines[0] != ":START_ID,:END_ID,:TYPE,kind" {
+		t.Fatalf("unexpected relationships:\n%s", relationships.String())
+	}
+}
@@ -0,0 +1,253 @@
+package tracing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSegmentSize = 16 << 20
+	// maxPendingSegments bounds the finished segments waiting to be
+	// uploaded, past which writing records blocks.
+	maxPendingSegments = 4
+	maxUploadAttempts  = 8
+	minUploadBackoff   = 100 * time.Millisecond
+	maxUploadBackoff   = 30 * time.Second
+)
+
+// ObjectStoreConfig configures the upload of the records to an object store
+// bucket, as segments of the output file (JSON objects, one per line),
+// named Prefix + "<time the server opened>-<segment number>.log". Objects
+// are uploaded with the S3 API, which GCS supports too, with HMAC keys and
+// the https://storage.googleapis.com endpoint.
+type ObjectStoreConfig struct {
+	Endpoint string // e.g. https://s3.us-east-1.amazonaws.com, or https://storage.googleapis.com
+	Region   string // the region of the bucket, "auto" for GCS
+	Bucket   string
+	Prefix   string
+
+	// AccessKeyID and SecretAccessKey default to the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY environment variables.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SegmentSize is the size, in bytes, above which a segment is finished
+	// and uploaded (16MB by default). Segments are also finished when the
+	// server syncs its output (see TracingServerConfig.SyncInterval) and
+	// when it is closed.
+	SegmentSize int
+}
+
+// objectSegment is a finished segment, waiting to be uploaded.
+type objectSegment struct {
+	key  string
+	data []byte
+}
+
+// objectStoreSink buffers records into segments in memory, and uploads the
+// finished ones in the background.
+type objectStoreSink struct {
+	config  ObjectStoreConfig
+	client  *http.Client
+	run     string
+	n       int
+	segment bytes.Buffer
+	encoder *json.Encoder
+
+	uploads chan objectSegment
+	pending sync.WaitGroup
+	done    chan struct{}
+
+	lock sync.Mutex
+	err  error // of the last segment given up on
+}
+
+func newObjectStoreSink(config ObjectStoreConfig) *objectStoreSink {
+	if config.AccessKeyID == "" {
+		config.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if config.SecretAccessKey == "" {
+		config.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultSegmentSize
+	}
+	s := &objectStoreSink{
+		config:  config,
+		client:  &http.Client{Timeout: 5 * time.Minute},
+		run:     time.Now().UTC().Format("20060102T150405Z"),
+		uploads: make(chan objectSegment, maxPendingSegments),
+		done:    make(chan struct{}),
+	}
+	s.encoder = json.NewEncoder(&s.segment)
+	go s.upload()
+	return s
+}
+
+func (s *objectStoreSink) Write(record TraceRecord) error {
+	if err := s.encoder.Encode(record); err != nil {
+		return err
+	}
+	if s.segment.Len() >= s.config.SegmentSize {
+		s.finishSegment()
+	}
+	return nil
+}
+
+// Flush uploads the current segment, and waits for all the finished ones
+// to be uploaded.
+func (s *objectStoreSink) Flush() error {
+	s.finishSegment()
+	s.pending.Wait()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+func (s *objectStoreSink) Close() error {
+	s.finishSegment()
+	close(s.uploads)
+	<-s.done
+	return s.err
+}
+
+func (s *objectStoreSink) finishSegment() {
+	if s.segment.Len() == 0 {
+		return
+	}
+	s.n++
+	data := make([]byte, s.segment.Len())
+	copy(data, s.segment.Bytes())
+	s.segment.Reset()
+	s.pending.Add(1)
+	s.uploads <- objectSegment{
+		key:  fmt.Sprintf("%s%s-%06d.log", s.config.Prefix, s.run, s.n),
+		data: data,
+	}
+}
+
+// upload uploads the finished segments, retrying with exponential backoff,
+// until the sink is closed.
+func (s *objectStoreSink) upload() {
+	defer close(s.done)
+
+	for segment := range s.uploads {
+		backoff := minUploadBackoff
+		var err error
+		for attempt := 1; attempt <= maxUploadAttempts; attempt++ {
+			if err = s.put(segment); err == nil {
+				break
+			}
+			log.Printf("error uploading %s, retrying in %v: %v", segment.key, backoff, err)
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxUploadBackoff {
+				backoff = maxUploadBackoff
+			}
+		}
+		if err != nil {
+			log.Printf("giving up uploading %s: %v", segment.key, err)
+			s.lock.Lock()
+			s.err = err
+			s.lock.Unlock()
+		}
+		s.pending.Done()
+	}
+}
+
+// put uploads segment with a PUT Object request, signed with AWS Signature
+// Version 4.
+func (s *objectStoreSink) put(segment objectSegment) error {
+	path := "/" + s.config.Bucket + "/" + awsURIEncode(segment.key, false)
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(s.config.Endpoint, "/")+path,
+		bytes.NewReader(segment.data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	signAWSRequest(req, path, segment.data, s.config, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// signAWSRequest signs req, whose escaped path is path, with AWS Signature
+// Version 4, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func signAWSRequest(req *http.Request, path string, payload []byte, config ObjectStoreConfig, now time.Time) {
+	date := now.UTC().Format("20060102T150405Z")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + date,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date[:8] + "/" + config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + config.SecretAccessKey)
+	for _, part := range []string{date[:8], config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsURIEncode escapes s as Signature Version 4 requires: everything but
+// unreserved characters is percent-encoded, and so are slashes if
+// encodeSlash is set.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
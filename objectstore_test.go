@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestObjectStoreOutput(t *testing.T) {
+	var lock sync.Mutex
+	objects := make(map[string]string)
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/bucket/traces/") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != sha256Hex(body) {
+			t.Errorf("payload hash %s does not match the body", hash)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+		lock.Lock()
+		objects[r.URL.Path] = string(body)
+		lock.Unlock()
+	}))
+	defer bucket.Close()
+
+	// no local output files
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		ObjectStore: &ObjectStoreConfig{
+			Endpoint:        bucket.URL,
+			Region:          "us-east-1",
+			Bucket:          "bucket",
+			Prefix:          "traces/",
+			AccessKeyID:     "key",
+			SecretAccessKey: "secret",
+			SegmentSize:     256,
+		},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	for i := 0; i < 4; i++ {
+		c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	}
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) < 2 {
+		t.Fatalf("expected the records to be uploaded in several segments, got %v", objects)
+	}
+	var keys []string
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var records []string
+	for _, key := range keys {
+		records = append(records, strings.Split(strings.TrimSuffix(objects[key], "\n"), "\n")...)
+	}
+	if len(records) != 8 {
+		t.Fatalf("expected 8 records, got %q", records)
+	}
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	if encoded := awsURIEncode("traces/a b+c=~.log", false); encoded != "traces/a%20b%2Bc%3D~.log" {
+		t.Fatalf("unexpected encoding %s", encoded)
+	}
+}
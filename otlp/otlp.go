@@ -0,0 +1,250 @@
+// Package otlp provides a sink for tracing servers that mirrors records as
+// OpenTelemetry spans, exported over OTLP/HTTP (in its JSON encoding) to a
+// collector, or to a backend accepting OTLP like Jaeger or Grafana Tempo:
+// 	tracing.TracingServerConfig{
+// 		...
+// 		Sinks: []tracing.Sink{otlp.NewSink("http://localhost:4318")},
+// 	}
+// Each record becomes a span of the OpenTelemetry trace of its trace ID,
+// named after its tag, from the service named after its tracer identity.
+// The reception of a token is linked to the span of its generation. Spans
+// are exported asynchronously, in batches, and may be lost if the endpoint
+// is unreachable: the server's output file remains the source of truth.
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+const (
+	// batchSize is the number of spans exported at once.
+	batchSize = 512
+	// maxExports is the number of batches being exported at once, past
+	// which Write blocks.
+	maxExports = 4
+	// spanKindInternal is the OTLP kind of the spans.
+	spanKindInternal = 1
+	// scopeName is the instrumentation scope of the spans.
+	scopeName = "github.com/DistributedClocks/tracing"
+)
+
+// Sink exports records to an OTLP/HTTP endpoint, as spans. Records carry no
+// time, so spans start and end when the sink receives their record.
+type Sink struct {
+	url    string
+	client *http.Client
+	now    func() time.Time
+
+	lock    sync.Mutex
+	batch   []span
+	exports chan struct{} // a semaphore bounding the ongoing exports
+	pending sync.WaitGroup
+
+	errLock sync.Mutex
+	err     error // of the last failed export
+}
+
+// NewSink returns a sink exporting spans to endpoint, the base URL of an
+// OTLP/HTTP receiver (spans are POSTed to endpoint/v1/traces).
+func NewSink(endpoint string) *Sink {
+	return &Sink{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+		exports: make(chan struct{}, maxExports),
+	}
+}
+
+// Write adds the span of record to the batch being built, and exports it
+// once full.
+func (s *Sink) Write(record tracing.TraceRecord) error {
+	span := newSpan(record, s.now())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.batch = append(s.batch, span)
+	if len(s.batch) >= batchSize {
+		s.export()
+	}
+	return nil
+}
+
+// export exports the batch being built, asynchronously. The caller must
+// hold lock.
+func (s *Sink) export() {
+	if len(s.batch) == 0 {
+		return
+	}
+	batch := s.batch
+	s.batch = nil
+	s.exports <- struct{}{}
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		defer func() { <-s.exports }()
+		if err := s.post(batch); err != nil {
+			s.errLock.Lock()
+			s.err = err
+			s.errLock.Unlock()
+		}
+	}()
+}
+
+// post sends batch to the endpoint.
+func (s *Sink) post(batch []span) error {
+	body, err := json.Marshal(newExportRequest(batch))
+	if err != nil {
+		return err
+	}
+	response, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("exporting %d spans: %s: %s", len(batch), response.Status, message)
+	}
+	return nil
+}
+
+// Flush exports the spans written so far, and returns the last error
+// exporting spans since the previous call, if any.
+func (s *Sink) Flush() error {
+	s.lock.Lock()
+	s.export()
+	s.lock.Unlock()
+	s.pending.Wait()
+
+	s.errLock.Lock()
+	defer s.errLock.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// Close exports the pending spans.
+func (s *Sink) Close() error {
+	return s.Flush()
+}
+
+// traceID returns the OpenTelemetry trace ID of the trace traceID, which
+// fills its lower 8 bytes.
+func traceID(id uint64) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[8:], id)
+	return hex.EncodeToString(b[:])
+}
+
+// spanID returns the span ID of the event id (see events.ID).
+func spanID(id uint64) string {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	return hex.EncodeToString(b[:])
+}
+
+// The OTLP/JSON encoding of an ExportTraceServiceRequest, as far as the
+// sink uses it.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []attribute `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope  `json:"scope"`
+		Spans []span `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	span struct {
+		service string // the tracer identity
+
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []attribute `json:"attributes"`
+		Links             []link      `json:"links,omitempty"`
+	}
+	link struct {
+		TraceID string `json:"traceId"`
+		SpanID  string `json:"spanId"`
+	}
+	attribute struct {
+		Key   string         `json:"key"`
+		Value attributeValue `json:"value"`
+	}
+	attributeValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+func stringAttribute(key, value string) attribute {
+	return attribute{Key: key, Value: attributeValue{StringValue: value}}
+}
+
+// newSpan returns the span of record, received at t.
+func newSpan(record tracing.TraceRecord, t time.Time) span {
+	clock, _ := json.Marshal(record.VectorClock)
+	timestamp := strconv.FormatInt(t.UnixNano(), 10)
+	s := span{
+		service:           record.TracerIdentity,
+		TraceID:           traceID(record.TraceID),
+		SpanID:            spanID(events.RecordID(record)),
+		Name:              record.Tag,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: timestamp,
+		EndTimeUnixNano:   timestamp,
+		Attributes: []attribute{
+			stringAttribute("tracing.tracer_identity", record.TracerIdentity),
+			stringAttribute("tracing.vector_clock", string(clock)),
+			stringAttribute("tracing.body", string(record.Body)),
+		},
+	}
+	if source, ok := events.TokenSource(record); ok {
+		// link the reception of a token to its generation
+		s.Links = []link{{TraceID: s.TraceID, SpanID: spanID(source)}}
+	}
+	return s
+}
+
+// newExportRequest groups the spans of batch by service.
+func newExportRequest(batch []span) exportRequest {
+	var request exportRequest
+	services := make(map[string]int)
+	for _, s := range batch {
+		i, ok := services[s.service]
+		if !ok {
+			i = len(request.ResourceSpans)
+			services[s.service] = i
+			request.ResourceSpans = append(request.ResourceSpans, resourceSpans{
+				Resource:   resource{Attributes: []attribute{stringAttribute("service.name", s.service)}},
+				ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}}},
+			})
+		}
+		request.ResourceSpans[i].ScopeSpans[0].Spans = append(request.ResourceSpans[i].ScopeSpans[0].Spans, s)
+	}
+	return request
+}
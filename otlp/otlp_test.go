@@ -0,0 +1,85 @@
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestSink(t *testing.T) {
+	var lock sync.Mutex
+	var requests []exportRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var request exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lock.Lock()
+		requests = append(requests, request)
+		lock.Unlock()
+	}))
+	defer collector.Close()
+
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind: ":0",
+		Sinks:      []tracing.Sink{NewSink(collector.URL)},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client1 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client2.ReceiveToken(trace.GenerateToken())
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]span)
+	services := make(map[string]string)
+	for _, request := range requests {
+		for _, resourceSpans := range request.ResourceSpans {
+			service := resourceSpans.Resource.Attributes[0].Value.StringValue
+			for _, s := range resourceSpans.ScopeSpans[0].Spans {
+				if s.TraceID != traceID(trace.ID) {
+					t.Fatalf("expected the spans to be of trace %s, got %s", traceID(trace.ID), s.TraceID)
+				}
+				spans[s.Name] = s
+				services[s.Name] = service
+			}
+		}
+	}
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %v", spans)
+	}
+	if services["ReceiveTokenTrace"] != "client2" || services["TestAction"] != "client1" {
+		t.Fatalf("unexpected services %v", services)
+	}
+	links := spans["ReceiveTokenTrace"].Links
+	if len(links) != 1 || links[0].SpanID != spans["GenerateTokenTrace"].SpanID {
+		t.Fatalf("expected the reception to link to the generation %s, got %v", spans["GenerateTokenTrace"].SpanID, links)
+	}
+}
@@ -0,0 +1,265 @@
+// Package parquet writes the records of a tracing server to Apache Parquet
+// files, to query large datasets, e.g. the output files of a whole course,
+// with DuckDB or Spark:
+//
+//	SELECT TracerIdentity, count(*) FROM 'records.parquet' GROUP BY 1
+//
+// Each record is a row, of the columns:
+//   - TracerIdentity, Tag: strings;
+//   - TraceID, TracerSeq: unsigned 64-bit integers;
+//   - VectorClock, Body: JSON strings, for the JSON functions of the query
+//     engine (e.g. Body->>'$.Foo' in DuckDB).
+//
+// The files are uncompressed and plain encoded, which every reader supports.
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+const (
+	// magic starts and ends Parquet files.
+	magic = "PAR1"
+	// rowGroupSize is the size of the values buffered, past which they are
+	// written as a row group.
+	rowGroupSize = 64 << 20
+	// createdBy is the application writing the files.
+	createdBy = "github.com/DistributedClocks/tracing"
+)
+
+// Parquet's physical types, and converted types, of the columns.
+const (
+	typeInt64     = 2
+	typeByteArray = 6
+
+	convertedUTF8   = 0
+	convertedUint64 = 14
+	convertedJSON   = 19
+)
+
+// column is a column of the files.
+type column struct {
+	name          string
+	typ           int32
+	convertedType int32
+}
+
+var columns = []column{
+	{name: "TracerIdentity", typ: typeByteArray, convertedType: convertedUTF8},
+	{name: "TraceID", typ: typeInt64, convertedType: convertedUint64},
+	{name: "Tag", typ: typeByteArray, convertedType: convertedUTF8},
+	{name: "TracerSeq", typ: typeInt64, convertedType: convertedUint64},
+	{name: "VectorClock", typ: typeByteArray, convertedType: convertedJSON},
+	{name: "Body", typ: typeByteArray, convertedType: convertedJSON},
+}
+
+// columnChunk is the data of a column in a row group.
+type columnChunk struct {
+	offset int64 // of its page in the file
+	size   int64 // of its page, header included
+}
+
+// rowGroup is a row group written.
+type rowGroup struct {
+	rows   int64
+	chunks []columnChunk
+}
+
+// Writer writes records to a Parquet file, in row groups of the records
+// written since the previous one. The file is complete once the writer is
+// closed.
+type Writer struct {
+	w      io.Writer
+	offset int64 // of the next byte written to w
+
+	values    [][]byte // plain encoded, by column
+	buffered  int      // the size of values
+	rows      int64    // the number of rows in values
+	rowGroups []rowGroup
+	closed    bool
+}
+
+// NewWriter returns a writer of records to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, values: make([][]byte, len(columns))}
+}
+
+// Write adds record to the row group being built, and writes it once
+// large enough.
+func (w *Writer) Write(record tracing.TraceRecord) error {
+	if w.closed {
+		return errors.New("parquet: write to a closed writer")
+	}
+	clock, err := json.Marshal(record.VectorClock)
+	if err != nil {
+		return err
+	}
+	body := record.Body
+	if len(body) == 0 {
+		body = []byte("null")
+	}
+	w.appendByteArray(0, []byte(record.TracerIdentity))
+	w.appendInt64(1, record.TraceID)
+	w.appendByteArray(2, []byte(record.Tag))
+	w.appendInt64(3, record.TracerSeq)
+	w.appendByteArray(4, clock)
+	w.appendByteArray(5, body)
+	w.rows++
+	if w.buffered >= rowGroupSize {
+		return w.writeRowGroup()
+	}
+	return nil
+}
+
+func (w *Writer) appendInt64(column int, v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	w.values[column] = append(w.values[column], b[:]...)
+	w.buffered += len(b)
+}
+
+func (w *Writer) appendByteArray(column int, v []byte) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(v)))
+	w.values[column] = append(append(w.values[column], b[:]...), v...)
+	w.buffered += len(b) + len(v)
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	return err
+}
+
+// writeMagic starts the file, if not yet.
+func (w *Writer) writeMagic() error {
+	if w.offset > 0 {
+		return nil
+	}
+	return w.write([]byte(magic))
+}
+
+// writeRowGroup writes the buffered values as a row group, of a single
+// data page by column.
+func (w *Writer) writeRowGroup() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+	group := rowGroup{rows: w.rows}
+	for i, values := range w.values {
+		var header thriftWriter
+		header.begin()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(values)))
+		header.i32(3, int32(len(values)))
+		header.structField(5)
+		header.i32(1, int32(w.rows))
+		header.i32(2, 0) // PLAIN
+		header.i32(3, 3) // RLE, with no levels for required columns
+		header.i32(4, 3)
+		header.end()
+		header.end()
+
+		chunk := columnChunk{offset: w.offset, size: int64(len(header.buf) + len(values))}
+		if err := w.write(header.buf); err != nil {
+			return err
+		}
+		if err := w.write(values); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		w.values[i] = values[:0]
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.buffered = 0
+	w.rows = 0
+	return nil
+}
+
+// Close writes the buffered records and the footer of the file. It does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.writeRowGroup(); err != nil {
+		return err
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+	footer := w.fileMetaData()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, length[:], []byte(magic)} {
+		if err := w.write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileMetaData returns the encoded FileMetaData of the file.
+func (w *Writer) fileMetaData() []byte {
+	var rows int64
+	for _, group := range w.rowGroups {
+		rows += group.rows
+	}
+
+	var m thriftWriter
+	m.begin()
+	m.i32(1, 1) // version
+	m.list(2, thriftStruct, len(columns)+1)
+	m.begin()
+	m.string(4, "schema")
+	m.i32(5, int32(len(columns)))
+	m.end()
+	for _, c := range columns {
+		m.begin()
+		m.i32(1, c.typ)
+		m.i32(3, 0) // REQUIRED
+		m.string(4, c.name)
+		m.i32(6, c.convertedType)
+		m.end()
+	}
+	m.i64(3, rows)
+	m.list(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		var size int64
+		for _, chunk := range group.chunks {
+			size += chunk.size
+		}
+		m.begin()
+		m.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			m.begin()
+			m.i64(2, chunk.offset)
+			m.structField(3)
+			m.i32(1, columns[i].typ)
+			m.listI32(2, 0, 3) // PLAIN, RLE
+			m.listString(3, columns[i].name)
+			m.i32(4, 0) // UNCOMPRESSED
+			m.i64(5, group.rows)
+			m.i64(6, chunk.size)
+			m.i64(7, chunk.size)
+			m.i64(9, chunk.offset)
+			m.end()
+			m.end()
+		}
+		m.i64(2, size)
+		m.i64(3, group.rows)
+		m.end()
+	}
+	m.string(6, createdBy)
+	m.end()
+	return m.buf
+}
@@ -0,0 +1,138 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+// thriftReader decodes the Thrift compact protocol, to structs as maps of
+// their fields by ID, and lists as slices.
+type thriftReader struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.buf) == 0 {
+		r.t.Fatal("unexpected end of the Thrift data")
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.t.Fatal("invalid varint")
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := r.uvarint()
+		v := string(r.buf[:n])
+		r.buf = r.buf[n:]
+		return v
+	case thriftList:
+		header := r.byte()
+		n := uint64(header >> 4)
+		if n == 15 {
+			n = r.uvarint()
+		}
+		list := []interface{}{}
+		for i := uint64(0); i < n; i++ {
+			list = append(list, r.value(header&0x0f))
+		}
+		return list
+	case thriftStruct:
+		fields := make(map[int16]interface{})
+		var id int16
+		for {
+			header := r.byte()
+			if header == 0 {
+				return fields
+			}
+			if delta := header >> 4; delta != 0 {
+				id += int16(delta)
+			} else {
+				id = int16(r.varint())
+			}
+			fields[id] = r.value(header & 0x0f)
+		}
+	}
+	r.t.Fatalf("unexpected Thrift type %d", typ)
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(TestAction{Foo: "foo"})
+	})
+
+	var buffer bytes.Buffer
+	w := NewWriter(&buffer)
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file := buffer.Bytes()
+	if !bytes.HasPrefix(file, []byte(magic)) || !bytes.HasSuffix(file, []byte(magic)) {
+		t.Fatalf("expected the file to start and end with %s", magic)
+	}
+	length := binary.LittleEndian.Uint32(file[len(file)-8:])
+	footer := &thriftReader{t: t, buf: file[len(file)-8-int(length) : len(file)-8]}
+	metadata := footer.value(thriftStruct).(map[int16]interface{})
+	if len(footer.buf) != 0 {
+		t.Fatalf("expected the footer to be %d bytes long, %d more", length, len(footer.buf))
+	}
+	if rows := metadata[3].(int64); rows != int64(len(records)) {
+		t.Fatalf("expected %d rows, got %d", len(records), rows)
+	}
+	if schema := metadata[2].([]interface{}); len(schema) != len(columns)+1 {
+		t.Fatalf("expected %d schema elements, got %d", len(columns)+1, len(schema))
+	}
+
+	// the Tag column of the row group
+	group := metadata[4].([]interface{})[0].(map[int16]interface{})
+	chunk := group[1].([]interface{})[2].(map[int16]interface{})[3].(map[int16]interface{})
+	if path := chunk[3].([]interface{}); path[0] != "Tag" {
+		t.Fatalf("expected the third column to be Tag, got %v", path)
+	}
+	page := &thriftReader{t: t, buf: file[chunk[9].(int64):]}
+	header := page.value(thriftStruct).(map[int16]interface{})
+	values := page.buf[:header[2].(int64)]
+	var tags []string
+	for len(values) > 0 {
+		n := binary.LittleEndian.Uint32(values)
+		tags = append(tags, string(values[4:4+n]))
+		values = values[4+n:]
+	}
+	if len(tags) != 2 || tags[0] != "CreateTrace" || tags[1] != "TestAction" {
+		t.Fatalf("expected the tags CreateTrace and TestAction, got %v", tags)
+	}
+}
@@ -0,0 +1,105 @@
+package parquet
+
+import (
+	"encoding/binary"
+)
+
+// The types of the fields of the Thrift compact protocol.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Parquet's metadata in the Thrift compact protocol, as
+// far as the writer uses it.
+type thriftWriter struct {
+	buf     []byte
+	lastIDs []int16 // of the fields written, by nested struct
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.uvarint(uint64(v<<1) ^ uint64(v>>63)) // zigzag
+}
+
+// field writes the header of the field id, of type typ, of the current
+// struct.
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.lastIDs[len(w.lastIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) string(id int16, v string) {
+	w.field(id, thriftBinary)
+	w.uvarint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// list writes the header of the list field id, of n elements of type typ,
+// which follow.
+func (w *thriftWriter) list(id int16, typ byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|typ)
+	} else {
+		w.buf = append(w.buf, 0xf0|typ)
+		w.uvarint(uint64(n))
+	}
+}
+
+// listI32 writes the elements of a list of i32.
+func (w *thriftWriter) listI32(id int16, vs ...int32) {
+	w.list(id, thriftI32, len(vs))
+	for _, v := range vs {
+		w.varint(int64(v))
+	}
+}
+
+// listString writes the elements of a list of strings.
+func (w *thriftWriter) listString(id int16, vs ...string) {
+	w.list(id, thriftBinary, len(vs))
+	for _, v := range vs {
+		w.uvarint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	}
+}
+
+// structField starts the struct field id, ended by end.
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+// begin starts a struct, e.g. an element of a list, ended by end.
+func (w *thriftWriter) begin() {
+	w.lastIDs = append(w.lastIDs, 0)
+}
+
+// end ends the current struct.
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, 0) // stop
+	w.lastIDs = w.lastIDs[:len(w.lastIDs)-1]
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// partitionedSink writes each record to one of its sinks, according to its
+// trace ID, so that the records of a trace all end up in the same
+// partition.
+type partitionedSink []*recordFileSink
+
+// partitionFileName returns the name of the i-th partition of fileName,
+// with the partition number inserted before its extension.
+func partitionFileName(fileName string, i int) string {
+	ext := filepath.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "." + strconv.Itoa(i) + ext
+}
+
+// partition returns the partition of the records of traceID, among n.
+func partition(traceID uint64, n int) int {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], traceID)
+	h := fnv.New64a()
+	h.Write(b[:])
+	return int(h.Sum64() % uint64(n))
+}
+
+func (s partitionedSink) Write(record TraceRecord) error {
+	return s[partition(record.TraceID, len(s))].Write(record)
+}
+
+func (s partitionedSink) Flush() error {
+	for _, sink := range s {
+		if err := sink.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s partitionedSink) Close() error {
+	var err error
+	for _, sink := range s {
+		if closeErr := sink.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// flushBuffers writes the records buffered to each file.
+func (s partitionedSink) flushBuffers() error {
+	var err error
+	for _, sink := range s {
+		if flushErr := sink.flushBuffer(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+	return err
+}
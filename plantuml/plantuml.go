@@ -0,0 +1,92 @@
+// Package plantuml renders the records of a tracing server as PlantUML
+// sequence diagrams, one per trace, see cmd/plantuml-export.
+//
+// Each tracer of a trace is a lane (a participant), where its actions are
+// notes, in an order consistent with the happens-before relation. The
+// reception of a token is an arrow from the lane of the tracer that
+// generated it.
+package plantuml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Write writes the sequence diagrams of the traces of records to w, in the
+// order of their first record.
+func Write(w io.Writer, records []tracing.TraceRecord) error {
+	var traceIDs []uint64
+	traces := make(map[uint64][]tracing.TraceRecord)
+	for _, record := range records {
+		if _, ok := traces[record.TraceID]; !ok {
+			traceIDs = append(traceIDs, record.TraceID)
+		}
+		traces[record.TraceID] = append(traces[record.TraceID], record)
+	}
+
+	b := bufio.NewWriter(w)
+	for i, traceID := range traceIDs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeDiagram(b, traceID, traces[traceID])
+	}
+	return b.Flush()
+}
+
+// writeDiagram writes the sequence diagram of the trace traceID, whose
+// records are records.
+func writeDiagram(w *bufio.Writer, traceID uint64, records []tracing.TraceRecord) {
+	// the sum of the entries of the clocks grows along happens-before, and
+	// the records of a tracer are already in order
+	sorted := append([]tracing.TraceRecord(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return events.LogicalTime(sorted[i].VectorClock) < events.LogicalTime(sorted[j].VectorClock)
+	})
+
+	// the lanes, in the order tracers join the trace, and the tracers of
+	// the events generating tokens
+	lanes := make(map[string]string)
+	tracers := make(map[uint64]string)
+	fmt.Fprintf(w, "@startuml\ntitle Trace %d\n", traceID)
+	for _, record := range sorted {
+		if _, ok := lanes[record.TracerIdentity]; !ok {
+			lanes[record.TracerIdentity] = fmt.Sprintf("T%d", len(lanes)+1)
+			fmt.Fprintf(w, "participant %s as %s\n", quote(record.TracerIdentity), lanes[record.TracerIdentity])
+		}
+		tracers[events.RecordID(record)] = record.TracerIdentity
+	}
+
+	for _, record := range sorted {
+		lane := lanes[record.TracerIdentity]
+		if source, ok := events.TokenSource(record); ok {
+			if sender, ok := tracers[source]; ok {
+				fmt.Fprintf(w, "%s -> %s : token\n", lanes[sender], lane)
+			}
+		}
+		fmt.Fprintf(w, "hnote over %s : %s\n", lane, label(record))
+	}
+	w.WriteString("@enduml\n")
+}
+
+// label returns the text of the note of record: its tag, and its body, if
+// not empty.
+func label(record tracing.TraceRecord) string {
+	text := record.Tag
+	if body := string(record.Body); body != "" && body != "{}" {
+		text += " " + body
+	}
+	// notes span a single line
+	return strings.NewReplacer("\n", " ", "\r", " ").Replace(text)
+}
+
+// quote returns s as a PlantUML string.
+func quote(s string) string {
+	return `"` + strings.Replace(s, `"`, `'`, -1) + `"`
+}
@@ -0,0 +1,50 @@
+package plantuml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestWrite(t *testing.T) {
+	var trace *tracing.Trace
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace = client1.CreateTrace()
+		trace.RecordAction(TestAction{Foo: "foo"})
+		client2.ReceiveToken(trace.GenerateToken())
+	})
+
+	var b strings.Builder
+	if err := Write(&b, records); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	expected := []string{
+		"@startuml",
+		fmt.Sprintf("title Trace %d", trace.ID),
+		`participant "client1" as T1`,
+		`participant "client2" as T2`,
+		"hnote over T1 : CreateTrace",
+		`hnote over T1 : TestAction {"Foo":"foo"}`,
+		"hnote over T1 : GenerateTokenTrace",
+		"T1 -> T2 : token",
+		"hnote over T2 : ReceiveTokenTrace",
+		"@enduml",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected the diagram\n%s\ngot\n%s", strings.Join(expected, "\n"), b.String())
+	}
+	for i, line := range lines {
+		// the bodies of the token records hold the token
+		if line != expected[i] && !strings.HasPrefix(line, expected[i]+" {") {
+			t.Fatalf("expected line %d to be %q, got %q", i, expected[i], line)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing/tracingpb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxProtobufMessageSize bounds the size of the messages of the framed
+// protobuf protocol, so that a corrupt length prefix cannot exhaust memory.
+const maxProtobufMessageSize = 64 << 20
+
+var errProtobufMessageSize = errors.New("protobuf message exceeds the maximum size")
+
+func (arg RecordActionArg) toProto() *tracingpb.RecordActionArg {
+	return &tracingpb.RecordActionArg{
+		TracerIdentity: arg.TracerIdentity,
+		TraceId:        arg.TraceID,
+		RecordName:     arg.RecordName,
+		Record:         arg.Record,
+		VectorClock:    arg.VectorClock,
+	}
+}
+
+func recordActionArgFromProto(arg *tracingpb.RecordActionArg) RecordActionArg {
+	return RecordActionArg{
+		TracerIdentity: arg.TracerIdentity,
+		TraceID:        arg.TraceId,
+		RecordName:     arg.RecordName,
+		Record:         arg.Record,
+		VectorClock:    arg.VectorClock,
+	}
+}
+
+// writeDelimited writes message to w, prefixed with its varint-encoded length.
+func writeDelimited(w io.Writer, message proto.Message) error {
+	encoded, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(protowire.AppendVarint(nil, uint64(len(encoded))), encoded...))
+	return err
+}
+
+// readDelimited reads a message written by writeDelimited from r.
+func readDelimited(r *bufio.Reader, message proto.Message) error {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if n > maxProtobufMessageSize {
+		return errProtobufMessageSize
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return proto.Unmarshal(buf, message)
+}
+
+// protobufTransport calls a tracing server over the framed protobuf
+// protocol.
+type protobufTransport struct{}
+
+func (protobufTransport) Dial(address string) (TransportConn, error) {
+	return dialProtobuf(address)
+}
+
+func (protobufTransport) Listen(address string, handler TransportHandler) (io.Closer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := &protobufServer{
+		handler:  handler,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// protobufConn calls the tracing server over the framed protobuf protocol,
+// see tracingpb.Request.
+type protobufConn struct {
+	lock   sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialProtobuf(address string) (*protobufConn, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &protobufConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *protobufConn) call(request *tracingpb.Request) (*tracingpb.Response, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := writeDelimited(c.conn, request); err != nil {
+		return nil, err
+	}
+	response := new(tracingpb.Response)
+	if err := readDelimited(c.reader, response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	return response, nil
+}
+
+func (c *protobufConn) Send(arg RecordActionArg) error {
+	_, err := c.call(&tracingpb.Request{Method: &tracingpb.Request_RecordAction{
+		RecordAction: arg.toProto(),
+	}})
+	return err
+}
+
+func (c *protobufConn) GetLastVC(identity string) (vclock.VClock, error) {
+	response, err := c.call(&tracingpb.Request{Method: &tracingpb.Request_GetLastVc{
+		GetLastVc: &tracingpb.GetLastVCArg{TracerIdentity: identity},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return response.GetGetLastVc().GetVectorClock(), nil
+}
+
+func (c *protobufConn) Ping() error {
+	_, err := c.call(&tracingpb.Request{Method: &tracingpb.Request_Ping{Ping: &tracingpb.PingArg{}}})
+	return err
+}
+
+func (c *protobufConn) Close() error {
+	return c.conn.Close()
+}
+
+// protobufServer serves the framed protobuf protocol to the connections
+// accepted by listener.
+type protobufServer struct {
+	handler  TransportHandler
+	listener net.Listener
+
+	lock  sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func (s *protobufServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		if s.conns == nil {
+			// closed in the meantime
+			s.lock.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *protobufServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		var request tracingpb.Request
+		if err := readDelimited(reader, &request); err != nil {
+			return
+		}
+		if err := writeDelimited(conn, s.handle(&request)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *protobufServer) handle(request *tracingpb.Request) *tracingpb.Response {
+	switch method := request.Method.(type) {
+	case *tracingpb.Request_RecordAction:
+		err := s.handler.RecordAction(recordActionArgFromProto(method.RecordAction))
+		if err != nil {
+			return &tracingpb.Response{Error: err.Error()}
+		}
+		return &tracingpb.Response{Result: &tracingpb.Response_RecordAction{
+			RecordAction: &tracingpb.RecordActionResult{},
+		}}
+	case *tracingpb.Request_GetLastVc:
+		vc, err := s.handler.GetLastVC(method.GetLastVc.TracerIdentity)
+		if err != nil {
+			return &tracingpb.Response{Error: err.Error()}
+		}
+		return &tracingpb.Response{Result: &tracingpb.Response_GetLastVc{
+			GetLastVc: &tracingpb.GetLastVCResult{VectorClock: vc},
+		}}
+	case *tracingpb.Request_Ping:
+		return &tracingpb.Response{Result: &tracingpb.Response_Ping{Ping: &tracingpb.PingResult{}}}
+	default:
+		return &tracingpb.Response{Error: "unknown method"}
+	}
+}
+
+// Close stops accepting connections, closes the open ones, and waits for
+// their in-flight requests to complete.
+func (s *protobufServer) Close() error {
+	err := s.listener.Close()
+	s.lock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.lock.Unlock()
+	s.wg.Wait()
+	return err
+}
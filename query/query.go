@@ -0,0 +1,439 @@
+// Package query filters the records of a tracing server with expressions,
+// see cmd/trace-query, e.g.:
+//
+//	tracer == "server1" && tag == "PutRecvd" && body.Key == "k1"
+//
+// The fields of records are:
+//   - tracer: the identity of its tracer;
+//   - tag: the tag of its action;
+//   - trace: the ID of its trace;
+//   - seq: its number among the records of its tracer, or 0;
+//   - clock.ID: the entry of the tracer ID in its vector clock, if any;
+//   - body.A.B: the field B of the field A of its body, if any.
+//
+// They are compared with the operators ==, !=, <, <=, > and >= to strings
+// (in double quotes, with Go escapes), numbers, true, false, null, or other
+// fields. Strings compare lexically, and numbers numerically. The operator
+// =~ matches a field to a regular expression, in a string. The operator ~
+// searches the body, or one of its fields, for all the words of a string,
+// as the search of the query API of servers (see tracing.MatchSearch), e.g.
+// body ~ "k42" or body.Key ~ "k42". A missing field is null. Comparisons combine with &&, || and !, and parentheses. A field
+// alone is true if it is true.
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Query is a parsed expression.
+type Query struct {
+	source string
+	root   matcher
+}
+
+// Parse parses the expression s.
+func Parse(s string) (*Query, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %v", s, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err == nil && len(p.tokens) > 0 {
+		err = fmt.Errorf("unexpected %s", p.tokens[0].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %v", s, err)
+	}
+	return &Query{source: s, root: root}, nil
+}
+
+// String returns the expression of the query.
+func (q *Query) String() string {
+	return q.source
+}
+
+// Match returns whether record matches the query.
+func (q *Query) Match(record tracing.TraceRecord) bool {
+	return q.root.match(&view{record: record})
+}
+
+// view is a record being matched, with its body decoded once needed.
+type view struct {
+	record  tracing.TraceRecord
+	decoded bool
+	body    interface{}
+}
+
+// field returns the value of the field at path, numbers as json.Number, or
+// nil if the record has no such field.
+func (v *view) field(path []string) interface{} {
+	switch path[0] {
+	case "tracer":
+		return v.record.TracerIdentity
+	case "tag":
+		return v.record.Tag
+	case "trace":
+		return json.Number(strconv.FormatUint(v.record.TraceID, 10))
+	case "seq":
+		return json.Number(strconv.FormatUint(v.record.TracerSeq, 10))
+	case "clock":
+		if ticks, ok := v.record.VectorClock[strings.Join(path[1:], ".")]; ok {
+			return json.Number(strconv.FormatUint(ticks, 10))
+		}
+		return nil
+	}
+	// body, which the parser checked
+	if !v.decoded {
+		v.decoded = true
+		decoder := json.NewDecoder(bytes.NewReader(v.record.Body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v.body); err != nil {
+			v.body = nil
+		}
+	}
+	value := v.body
+	for _, name := range path[1:] {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// matcher is a boolean node of an expression.
+type matcher interface {
+	match(v *view) bool
+}
+
+// operand is a value node of an expression.
+type operand interface {
+	value(v *view) interface{}
+}
+
+type literal struct{ v interface{} }
+
+func (l literal) value(*view) interface{} { return l.v }
+
+type field []string
+
+func (f field) value(v *view) interface{} { return v.field(f) }
+
+// truth matches if its operand is true.
+type truth struct{ operand }
+
+func (t truth) match(v *view) bool { return t.value(v) == true }
+
+type not struct{ matcher }
+
+func (n not) match(v *view) bool { return !n.matcher.match(v) }
+
+type and struct{ a, b matcher }
+
+func (n and) match(v *view) bool { return n.a.match(v) && n.b.match(v) }
+
+type or struct{ a, b matcher }
+
+func (n or) match(v *view) bool { return n.a.match(v) || n.b.match(v) }
+
+type comparison struct {
+	op   string
+	a, b operand
+}
+
+func (c comparison) match(v *view) bool {
+	a, b := c.a.value(v), c.b.value(v)
+	if c.op == "!=" {
+		return !equal(a, b)
+	}
+	if c.op == "==" {
+		return equal(a, b)
+	}
+	order, ok := compare(a, b)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	}
+	return order >= 0
+}
+
+type regexpMatch struct {
+	a  operand
+	re *regexp.Regexp
+}
+
+func (m regexpMatch) match(v *view) bool {
+	s, ok := m.a.value(v).(string)
+	return ok && m.re.MatchString(s)
+}
+
+// search matches if the body field at path contains the words of text.
+type search struct {
+	path []string
+	text string
+}
+
+func (m search) match(v *view) bool {
+	return tracing.MatchSearch(v.record, m.text, m.path[1:])
+}
+
+// equal returns whether the values a and b are equal.
+func equal(a, b interface{}) bool {
+	if order, ok := compare(a, b); ok {
+		return order == 0
+	}
+	switch a.(type) {
+	case nil, bool:
+		return a == b
+	}
+	return false
+}
+
+// compare returns the order of a and b, if both are strings or numbers.
+func compare(a, b interface{}) (int, bool) {
+	if a, ok := a.(string); ok {
+		b, ok := b.(string)
+		return strings.Compare(a, b), ok
+	}
+	x, ok := a.(json.Number)
+	y, yOK := b.(json.Number)
+	if !ok || !yOK {
+		return 0, false
+	}
+	// Compare integers exactly, since trace IDs do not fit in a float64.
+	if i, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+		if j, err := strconv.ParseInt(string(y), 10, 64); err == nil {
+			return compareOrdered(i < j, i > j), true
+		}
+	}
+	if i, err := strconv.ParseUint(string(x), 10, 64); err == nil {
+		if j, err := strconv.ParseUint(string(y), 10, 64); err == nil {
+			return compareOrdered(i < j, i > j), true
+		}
+	}
+	f, err := x.Float64()
+	g, gErr := y.Float64()
+	if err != nil || gErr != nil {
+		return 0, false
+	}
+	return compareOrdered(f < g, f > g), true
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// token is a token of an expression.
+type token struct {
+	kind string // "field", "string", "number", or the operator or parenthesis itself
+	text string
+}
+
+// operators are the operators of expressions, longest first.
+var operators = []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!", "~", "(", ")"}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		var t token
+		switch c := s[0]; {
+		case c == '"':
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			t = token{kind: "string", text: s[:end+1]}
+		case c == '-' || c >= '0' && c <= '9':
+			end := 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+			t = token{kind: "number", text: s[:end]}
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			end := 1
+			for end < len(s) && (s[end] == '_' || s[end] == '.' || s[end] >= 'a' && s[end] <= 'z' || s[end] >= 'A' && s[end] <= 'Z' || s[end] >= '0' && s[end] <= '9') {
+				end++
+			}
+			t = token{kind: "field", text: s[:end]}
+		default:
+			for _, op := range operators {
+				if strings.HasPrefix(s, op) {
+					t = token{kind: op, text: op}
+					break
+				}
+			}
+			if t.kind == "" {
+				return nil, fmt.Errorf("unexpected %q", s[:1])
+			}
+		}
+		tokens = append(tokens, t)
+		s = s[len(t.text):]
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser of expressions.
+type parser struct {
+	tokens []token
+}
+
+// accept consumes the next token if it is of kind.
+func (p *parser) accept(kind string) bool {
+	if len(p.tokens) > 0 && p.tokens[0].kind == kind {
+		p.tokens = p.tokens[1:]
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (matcher, error) {
+	m, err := p.and()
+	for err == nil && p.accept("||") {
+		var n matcher
+		if n, err = p.and(); err == nil {
+			m = or{m, n}
+		}
+	}
+	return m, err
+}
+
+func (p *parser) and() (matcher, error) {
+	m, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var n matcher
+		if n, err = p.unary(); err == nil {
+			m = and{m, n}
+		}
+	}
+	return m, err
+}
+
+func (p *parser) unary() (matcher, error) {
+	if p.accept("!") {
+		m, err := p.unary()
+		return not{m}, err
+	}
+	if p.accept("(") {
+		m, err := p.or()
+		if err == nil && !p.accept(")") {
+			err = fmt.Errorf("expected )")
+		}
+		return m, err
+	}
+	a, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return truth{a}, nil
+	}
+	switch op := p.tokens[0].kind; op {
+	case "=~":
+		p.tokens = p.tokens[1:]
+		if len(p.tokens) == 0 || p.tokens[0].kind != "string" {
+			return nil, fmt.Errorf("expected a regular expression after =~")
+		}
+		b, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(b.(literal).v.(string))
+		if err != nil {
+			return nil, err
+		}
+		return regexpMatch{a: a, re: re}, nil
+	case "~":
+		p.tokens = p.tokens[1:]
+		path, ok := a.(field)
+		if !ok || path[0] != "body" {
+			return nil, fmt.Errorf("expected the body or one of its fields before ~")
+		}
+		if len(p.tokens) == 0 || p.tokens[0].kind != "string" {
+			return nil, fmt.Errorf("expected a string after ~")
+		}
+		b, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return search{path: path, text: b.(literal).v.(string)}, nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.tokens = p.tokens[1:]
+		b, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{op: op, a: a, b: b}, nil
+	}
+	return truth{a}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	t := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	switch t.kind {
+	case "string":
+		s, err := strconv.Unquote(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", t.text)
+		}
+		return literal{s}, nil
+	case "number":
+		if _, err := strconv.ParseFloat(t.text, 64); err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return literal{json.Number(t.text)}, nil
+	case "field":
+		switch t.text {
+		case "true", "false":
+			return literal{t.text == "true"}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		path := strings.Split(t.text, ".")
+		switch path[0] {
+		case "tracer", "tag", "trace", "seq":
+			if len(path) == 1 {
+				return field(path), nil
+			}
+		case "clock":
+			if len(path) > 1 {
+				return field(path), nil
+			}
+		case "body":
+			return field(path), nil
+		}
+		return nil, fmt.Errorf("unknown field %s", t.text)
+	}
+	return nil, fmt.Errorf("unexpected %s", t.text)
+}
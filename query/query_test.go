@@ -0,0 +1,67 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing"
+)
+
+func TestMatch(t *testing.T) {
+	record := tracing.TraceRecord{
+		TracerIdentity: "server1",
+		TraceID:        18446744073709551615,
+		Tag:            "PutRecvd",
+		Body:           json.RawMessage(`{"Key":"k1","Value":{"Size":3,"Done":true},"Ratio":0.5}`),
+		VectorClock:    vclock.VClock{"server1": 4, "client1": 2},
+		TracerSeq:      4,
+	}
+	for query, expected := range map[string]bool{
+		`tracer == "server1" && tag == "PutRecvd" && body.Key == "k1"`: true,
+		`tracer == "server1" && body.Key == "k2"`:                      false,
+		`tracer == "server2" || body.Key == "k1"`:                      true,
+		`!(tag == "PutRecvd")`:                                         false,
+		`trace == 18446744073709551615`:                                true,
+		`trace < 18446744073709551614`:                                 false,
+		`clock.server1 >= 4 && clock.client1 < clock.server1`:          true,
+		`clock.client2 == null`:                                        true,
+		`seq > 3.5`:                                                    true,
+		`body.Value.Size <= 3 && body.Value.Done`:                      true,
+		`body.Ratio != 0.5`:                                            false,
+		`body.Missing.Field == "x"`:                                    false,
+		`body.Missing.Field != "x"`:                                    true,
+		`tag =~ "^Put"`:                                                true,
+		`body.Value =~ "3"`:                                            false,
+		`tracer == "server\"1"`:                                        false,
+		`body ~ "K1 size"`:                                             true,
+		`body.Value ~ "k1"`:                                            false,
+	} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Match(record) != expected {
+			t.Fatalf("expected %s to be %v", query, expected)
+		}
+	}
+
+	for _, query := range []string{
+		``,
+		`tracer ==`,
+		`tracer == "server1`,
+		`foo == 1`,
+		`clock == 1`,
+		`(tag == "Put"`,
+		`tag == "Put")`,
+		`tag =~ "["`,
+		`tag =~ tracer`,
+		`tag # 1`,
+		`tag ~ "Put"`,
+		`body ~ 1`,
+	} {
+		if _, err := Parse(query); err == nil {
+			t.Fatalf("expected an error parsing %s", query)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errQueryStorage = errors.New("QueryBind requires InMemory, or an unencrypted OutputFile other than a standard stream, and relays cannot serve it")
+
+// queryAPI serves the read-only HTTP API querying the records of a server,
+// see TracingServerConfig.QueryBind:
+//   - /tracers: the tracers, with their number of records;
+//   - /traces: the traces, in the order of their first record, with their
+//     number of records and their tracers;
+//   - /traces/ID: the records of the trace ID;
+//   - /records: the records, filtered by the query parameters tracer, tag
+//     and trace, each repeatable or separated by commas, as the tail
+//     endpoint, e.g. /records?tag=Put,Get;
+//   - /search: the records whose body contains all the words of the query
+//     parameter q, or its field at the path field, see MatchSearch, also
+//     filtered as /records, e.g. /search?q=k42&field=Key;
+//   - /shiviz: the ShiViz log of the records of the traces of the query
+//     parameter trace, also filtered as /records, e.g. /shiviz?trace=1,2.
+//
+// Records are in the order the server wrote them, as JSON objects as in
+// the output files. Searches use the index of a sink of the server that is
+// a RecordSearcher, if any.
+type queryAPI struct {
+	server *TracingServer
+}
+
+// canQuery returns whether the server keeps its records where queryAPI can
+// query them: in memory, or in output files.
+func (tracingServer *TracingServer) canQuery() bool {
+	return tracingServer.Config.InMemory || tracingServer.Config.OutputFile != "" && outputStream(tracingServer.Config.OutputFile) == nil &&
+		len(tracingServer.Config.EncryptionKey) == 0
+}
+
+// scanRecords calls visit with each record the server wrote, kept in
+// memory if InMemory is set, and read from the current output files
+// otherwise, up to the last record written, in the order of each file.
+func (tracingServer *TracingServer) scanRecords(visit func(record TraceRecord)) error {
+	if tracingServer.memoryStore != nil {
+		for _, record := range tracingServer.Records() {
+			visit(record)
+		}
+		return nil
+	}
+
+	type recordFile struct {
+		name string
+		size int64
+	}
+	var recordFiles []recordFile
+	tracingServer.recordLock.Lock()
+	if err := tracingServer.recordSinks.flushBuffers(); err != nil {
+		tracingServer.recordLock.Unlock()
+		return err
+	}
+	for _, sink := range tracingServer.recordSinks {
+		recordFiles = append(recordFiles, recordFile{name: sink.file.Name(), size: sink.size})
+	}
+	tracingServer.recordLock.Unlock()
+
+	for _, recordFile := range recordFiles {
+		// records written after the size was taken, maybe partially, are
+		// left out
+		file, err := os.Open(recordFile.name)
+		if err != nil {
+			return err
+		}
+		reader, err := NewRecordReader(io.LimitReader(file, recordFile.size), tracingServer.Config.OutputFormat)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				file.Close()
+				return err
+			}
+			visit(record)
+		}
+		file.Close()
+	}
+	return nil
+}
+
+// queryTracer is a tracer in the response to /tracers.
+type queryTracer struct {
+	Identity string
+	Records  int
+}
+
+// queryTrace is a trace in the response to /traces.
+type queryTrace struct {
+	TraceID uint64
+	Records int
+	Tracers []string
+}
+
+func (api queryAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var response interface{}
+	var err error
+	switch path := r.URL.Path; {
+	case path == "/tracers":
+		response, err = api.tracers()
+	case path == "/traces":
+		response, err = api.traces()
+	case strings.HasPrefix(path, "/traces/"):
+		id, parseErr := strconv.ParseUint(strings.TrimPrefix(path, "/traces/"), 10, 64)
+		if parseErr != nil {
+			http.Error(w, "invalid trace ID", http.StatusBadRequest)
+			return
+		}
+		records, scanErr := api.records(tailFilter{traces: map[uint64]bool{id: true}})
+		if scanErr == nil && len(records) == 0 {
+			http.Error(w, "no trace "+strconv.FormatUint(id, 10), http.StatusNotFound)
+			return
+		}
+		response, err = records, scanErr
+	case path == "/records":
+		filter, parseErr := parseTailFilter(r)
+		if parseErr != nil {
+			http.Error(w, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+		response, err = api.records(filter)
+	case path == "/shiviz":
+		filter, parseErr := parseTailFilter(r)
+		if parseErr != nil {
+			http.Error(w, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(filter.traces) == 0 {
+			http.Error(w, "trace expects the IDs of the traces to export", http.StatusBadRequest)
+			return
+		}
+		api.shiviz(w, filter)
+		return
+	case path == "/search":
+		text := r.URL.Query().Get("q")
+		if len(SearchWords(text)) == 0 {
+			http.Error(w, "q expects words to search", http.StatusBadRequest)
+			return
+		}
+		var fieldPath []string
+		if field := r.URL.Query().Get("field"); field != "" {
+			fieldPath = strings.Split(field, ".")
+		}
+		filter, parseErr := parseTailFilter(r)
+		if parseErr != nil {
+			http.Error(w, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+		response, err = api.search(text, fieldPath, filter)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (api queryAPI) tracers() ([]queryTracer, error) {
+	records := make(map[string]int)
+	err := api.server.scanRecords(func(record TraceRecord) {
+		records[record.TracerIdentity]++
+	})
+	tracers := []queryTracer{}
+	for identity, n := range records {
+		tracers = append(tracers, queryTracer{Identity: identity, Records: n})
+	}
+	sort.Slice(tracers, func(i, j int) bool {
+		return tracers[i].Identity < tracers[j].Identity
+	})
+	return tracers, err
+}
+
+func (api queryAPI) traces() ([]queryTrace, error) {
+	traces := []queryTrace{}
+	index := make(map[uint64]int)
+	tracers := make(map[uint64]map[string]bool)
+	err := api.server.scanRecords(func(record TraceRecord) {
+		i, ok := index[record.TraceID]
+		if !ok {
+			i = len(traces)
+			index[record.TraceID] = i
+			traces = append(traces, queryTrace{TraceID: record.TraceID})
+			tracers[record.TraceID] = make(map[string]bool)
+		}
+		traces[i].Records++
+		if !tracers[record.TraceID][record.TracerIdentity] {
+			tracers[record.TraceID][record.TracerIdentity] = true
+			traces[i].Tracers = append(traces[i].Tracers, record.TracerIdentity)
+		}
+	})
+	for i := range traces {
+		sort.Strings(traces[i].Tracers)
+	}
+	return traces, err
+}
+
+func (api queryAPI) records(filter tailFilter) ([]TraceRecord, error) {
+	records := []TraceRecord{}
+	err := api.server.scanRecords(func(record TraceRecord) {
+		if filter.match(record) {
+			records = append(records, record)
+		}
+	})
+	return records, err
+}
+
+func (api queryAPI) search(text string, fieldPath []string, filter tailFilter) ([]TraceRecord, error) {
+	records := []TraceRecord{}
+	visit := func(record TraceRecord) {
+		if filter.match(record) && MatchSearch(record, text, fieldPath) {
+			records = append(records, record)
+		}
+	}
+	for _, sink := range api.server.Config.Sinks {
+		if searcher, ok := sink.(RecordSearcher); ok {
+			found, err := searcher.SearchRecords(text)
+			for _, record := range found {
+				visit(record)
+			}
+			return records, err
+		}
+	}
+	err := api.server.scanRecords(visit)
+	return records, err
+}
+
+// shiviz writes the ShiViz log of the records matching filter to w, as
+// a file to save, for ShiViz cannot open the logs of whole runs.
+func (api queryAPI) shiviz(w http.ResponseWriter, filter tailFilter) {
+	records, err := api.records(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="shiviz_output.log"`)
+	shiviz, err := NewShivizWriter(w)
+	if err != nil {
+		return
+	}
+	for _, record := range records {
+		if err := shiviz.Write(record); err != nil {
+			return
+		}
+	}
+}
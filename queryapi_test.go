@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQueryAPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, config := range []TracingServerConfig{
+		{InMemory: true},
+		{OutputFile: filepath.Join(dir, "trace_output.log"), OutputPartitions: 2},
+		{OutputFile: filepath.Join(dir, "trace_output.bin"), OutputFormat: OutputFormatBinary},
+	} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		queryBind := listener.Addr().String()
+		listener.Close()
+
+		config.ServerBind, config.QueryBind = ":0", queryBind
+		server := NewTracingServer(config)
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+
+		client1 := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+		})
+		client2 := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client2",
+		})
+		trace1 := client1.CreateTrace()
+		trace1.RecordAction(TestAction{Foo: "foo"})
+		client2.ReceiveToken(trace1.GenerateToken())
+		trace2 := client2.CreateTrace()
+		trace2.RecordAction(TestAction{Foo: "bar"})
+		client1.Close()
+		client2.Close()
+
+		get := func(path string, response interface{}) int {
+			resp, err := http.Get("http://" + queryBind + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return resp.StatusCode
+		}
+
+		var tracers []queryTracer
+		get("/tracers", &tracers)
+		if expected := []queryTracer{{"client1", 3}, {"client2", 3}}; !cmp.Equal(tracers, expected) {
+			t.Fatalf("%s: unexpected tracers: %s", config.OutputFile, cmp.Diff(expected, tracers))
+		}
+		var traces []queryTrace
+		get("/traces", &traces)
+		expected := map[uint64]queryTrace{
+			trace1.ID: {TraceID: trace1.ID, Records: 4, Tracers: []string{"client1", "client2"}},
+			trace2.ID: {TraceID: trace2.ID, Records: 2, Tracers: []string{"client2"}},
+		}
+		if len(traces) != 2 || !cmp.Equal(traces[0], expected[traces[0].TraceID]) || !cmp.Equal(traces[1], expected[traces[1].TraceID]) {
+			t.Fatalf("%s: unexpected traces %v", config.OutputFile, traces)
+		}
+		var records []TraceRecord
+		get(fmt.Sprintf("/traces/%d", trace2.ID), &records)
+		if len(records) != 2 || records[0].Tag != "CreateTrace" || string(records[1].Body) != `{"Foo":"bar"}` {
+			t.Fatalf("%s: unexpected records of trace 2 %v", config.OutputFile, records)
+		}
+		records = nil
+		get("/records?tag=TestAction&tracer=client1", &records)
+		if len(records) != 1 || string(records[0].Body) != `{"Foo":"foo"}` {
+			t.Fatalf("%s: unexpected actions of client1 %v", config.OutputFile, records)
+		}
+		records = nil
+		get("/search?q=FOO", &records)
+		if len(records) != 2 {
+			t.Fatalf("%s: expected the 2 actions, with a field Foo, got %v", config.OutputFile, records)
+		}
+		records = nil
+		get("/search?q=foo&field=Foo&tag=TestAction", &records)
+		if len(records) != 1 || string(records[0].Body) != `{"Foo":"foo"}` {
+			t.Fatalf("%s: unexpected action with Foo foo %v", config.OutputFile, records)
+		}
+		if code := get("/search?q=,", &records); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected a search without words to be rejected, got status %d", config.OutputFile, code)
+		}
+		resp, err := http.Get(fmt.Sprintf("http://%s/shiviz?trace=%d", queryBind, trace2.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		shiviz, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(string(shiviz)), "\n"); len(lines) != 6 || lines[0] != header || lines[5] != fmt.Sprintf(`%d TestAction {"Foo":"bar"}`, trace2.ID) {
+			t.Fatalf("%s: unexpected ShiViz log of trace 2:\n%s", config.OutputFile, shiviz)
+		}
+		if code := get("/shiviz", &records); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected an export without traces to be rejected, got status %d", config.OutputFile, code)
+		}
+		if code := get("/traces/1", &records); code != http.StatusNotFound {
+			t.Fatalf("%s: expected an unknown trace not to be found, got status %d", config.OutputFile, code)
+		}
+		if code := get("/records?trace=nonsense", &records); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected an invalid trace ID to be rejected, got status %d", config.OutputFile, code)
+		}
+
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := NewTracingServer(TracingServerConfig{ServerBind: ":0", QueryBind: ":0", OutputFile: "-"})
+	if err := server.Open(); err != errQueryStorage {
+		t.Fatalf("expected an error querying the standard output, got %v", err)
+	}
+}
+
+func TestSearchWords(t *testing.T) {
+	words := SearchWords(`{"Key":"k42","Value":"Café au_lait"}`)
+	if expected := []string{"key", "k42", "value", "café", "au", "lait"}; !cmp.Equal(words, expected) {
+		t.Fatalf("unexpected words: %s", cmp.Diff(expected, words))
+	}
+	record := TraceRecord{Body: []byte(`{"Key":"k42","Nested":{"Value":"v1 v2"}}`)}
+	for _, test := range []struct {
+		text    string
+		path    []string
+		matches bool
+	}{
+		{"K42 key", nil, true},
+		{"k4", nil, false},
+		{"v2 v1", []string{"Nested", "Value"}, true},
+		{"k42", []string{"Nested"}, false},
+		{"k42", []string{"Missing"}, false},
+	} {
+		if MatchSearch(record, test.text, test.path) != test.matches {
+			t.Errorf("expected a search of %q in %v to match: %v", test.text, test.path, test.matches)
+		}
+	}
+}
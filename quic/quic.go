@@ -0,0 +1,195 @@
+// Package quic provides a QUIC transport for the tracing library, which
+// gives tracer connections lower handshake latency and connection migration,
+// for tracers on unstable networks.
+//
+// Importing the package registers the transport for the quic:// scheme, so
+// that tracers can connect to a server with a ServerAddress of the form
+// "quic://ip:port", while the server listens with:
+// 	tracing.TracingServerConfig{
+// 		...
+// 		Transports: []tracing.TransportBinding{{Transport: quic.Transport{}, Address: ":6667"}},
+// 	}
+// The package is a separate module, as QUIC requires a more recent Go
+// release than the rest of the library.
+package quic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/quic-go/quic-go"
+)
+
+// nextProto is the ALPN protocol negotiated by tracers and servers.
+const nextProto = "distributedclocks-tracing"
+
+const (
+	dialTimeout     = 10 * time.Second
+	keepAlivePeriod = 15 * time.Second
+)
+
+func init() {
+	tracing.RegisterTransport("quic", Transport{})
+}
+
+// Transport carries the requests of tracers to a tracing server over QUIC.
+//
+// As with the TCP transport, connections are not authenticated: unless
+// TLSConfig is set, servers present a self-signed certificate, which tracers
+// do not verify.
+type Transport struct {
+	TLSConfig *tls.Config // optional TLS configuration, for both ends
+}
+
+// Dial connects to the server listening on address, an ip:port pair.
+func (t Transport) Dial(address string) (tracing.TransportConn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	if t.TLSConfig != nil {
+		tlsConfig = t.TLSConfig.Clone()
+	}
+	tlsConfig.NextProtos = []string{nextProto}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err := quic.DialAddr(ctx, address, tlsConfig, &quic.Config{KeepAlivePeriod: keepAlivePeriod})
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		conn.CloseWithError(0, "")
+		return nil, err
+	}
+	return tracing.NewStreamConn(&streamConn{Stream: stream, conn: conn}), nil
+}
+
+// Listen serves the tracers connecting to address, an ip:port pair.
+func (t Transport) Listen(address string, handler tracing.TransportHandler) (io.Closer, error) {
+	tlsConfig := t.TLSConfig
+	if tlsConfig == nil {
+		certificate, err := selfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	tlsConfig.NextProtos = []string{nextProto}
+
+	ql, err := quic.ListenAddr(address, tlsConfig, &quic.Config{KeepAlivePeriod: keepAlivePeriod})
+	if err != nil {
+		return nil, err
+	}
+	l := &listener{
+		listener: ql,
+		handler:  handler,
+		conns:    make(map[quic.Connection]struct{}),
+	}
+	go l.serve()
+	return l, nil
+}
+
+// streamConn is the stream of a tracer connection, closing the connection
+// along with the stream.
+type streamConn struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+func (c *streamConn) Close() error {
+	c.Stream.Close()
+	return c.conn.CloseWithError(0, "")
+}
+
+// listener serves the connections of tracers, one stream each.
+type listener struct {
+	listener *quic.Listener
+	handler  tracing.TransportHandler
+
+	lock  sync.Mutex
+	conns map[quic.Connection]struct{}
+	wg    sync.WaitGroup
+}
+
+func (l *listener) serve() {
+	for {
+		conn, err := l.listener.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		l.lock.Lock()
+		if l.conns == nil {
+			// closed in the meantime
+			l.lock.Unlock()
+			conn.CloseWithError(0, "")
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.wg.Add(1)
+		l.lock.Unlock()
+		go l.serveConn(conn)
+	}
+}
+
+func (l *listener) serveConn(conn quic.Connection) {
+	defer l.wg.Done()
+	defer func() {
+		l.lock.Lock()
+		delete(l.conns, conn)
+		l.lock.Unlock()
+		conn.CloseWithError(0, "")
+	}()
+
+	stream, err := conn.AcceptStream(context.Background())
+	if err != nil {
+		return
+	}
+	tracing.ServeStream(l.handler, &streamConn{Stream: stream, conn: conn})
+}
+
+// Close stops accepting connections, closes the open ones, and waits for
+// their in-flight requests to complete.
+func (l *listener) Close() error {
+	err := l.listener.Close()
+	l.lock.Lock()
+	for conn := range l.conns {
+		conn.CloseWithError(0, "")
+	}
+	l.conns = nil
+	l.lock.Unlock()
+	l.wg.Wait()
+	return err
+}
+
+// selfSignedCertificate generates a certificate for servers without a TLS
+// configuration.
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "tracing"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
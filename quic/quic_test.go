@@ -0,0 +1,72 @@
+package quic
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestQUICTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quicBind := conn.LocalAddr().String()
+	conn.Close()
+
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		Transports:       []tracing.TransportBinding{{Transport: Transport{}, Address: quicBind}},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	for i := 0; i < 2; i++ {
+		// the second tracer rejoins, fetching the last clock over QUIC
+		client := tracing.NewTracer(tracing.TracerConfig{
+			ServerAddress:  "quic://" + quicBind,
+			TracerIdentity: "client1",
+		})
+		client.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+		if err := client.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	outputFile, err := os.Open(server.Config.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outputFile.Close()
+	records, err := tracing.ReadRecords(outputFile, tracing.OutputFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %v", records)
+	}
+	if last := records[3].VectorClock["client1"]; last != 4 {
+		t.Fatalf("expected the rejoined tracer to resume from the last clock, got %d", last)
+	}
+}
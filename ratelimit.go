@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultBanDuration = time.Minute
+
+var errConnectionRateLimit = errors.New("connection exceeded its rate limit, and was closed")
+
+// rateBucket is a token bucket, which allows rate records per second on
+// average, in bursts of up to burst records.
+type rateBucket struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+func newRateBucket(rate float64, burst int, now time.Time) *rateBucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(rate, 1)
+	}
+	return &rateBucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+// take takes a token from the bucket at now, and returns whether there
+// was one left.
+func (b *rateBucket) take(now time.Time) bool {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter enforces the IdentityRateLimit and ConnectionRateLimit of a
+// server, and the bans of the identities and hosts exceeding them.
+type rateLimiter struct {
+	config *TracingServerConfig
+
+	lock        sync.Mutex
+	identities  map[string]*rateBucket
+	bannedUntil map[string]time.Time // by identity
+	hostsUntil  map[string]time.Time // the bans of hosts
+}
+
+func newRateLimiter(config *TracingServerConfig) *rateLimiter {
+	return &rateLimiter{
+		config:      config,
+		identities:  make(map[string]*rateBucket),
+		bannedUntil: make(map[string]time.Time),
+		hostsUntil:  make(map[string]time.Time),
+	}
+}
+
+func (l *rateLimiter) banDuration() time.Duration {
+	if l.config.BanDuration > 0 {
+		return l.config.BanDuration
+	}
+	return defaultBanDuration
+}
+
+// allowRecord returns an error wrapping ErrRecordRejected if the tracer
+// identity is banned, or exceeds its rate limit, which bans it.
+func (l *rateLimiter) allowRecord(identity string) error {
+	rate := l.config.IdentityRateLimit
+	if rate <= 0 {
+		return nil
+	}
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if until, ok := l.bannedUntil[identity]; ok {
+		if now.Before(until) {
+			return fmt.Errorf("%w: tracer %s exceeded its rate limit, and is banned until %v", ErrRecordRejected, identity, until.Format(time.RFC3339))
+		}
+		delete(l.bannedUntil, identity)
+		// the tracer starts over with a full bucket
+		delete(l.identities, identity)
+	}
+	bucket, ok := l.identities[identity]
+	if !ok {
+		bucket = newRateBucket(rate, l.config.RateLimitBurst, now)
+		l.identities[identity] = bucket
+	}
+	if bucket.take(now) {
+		return nil
+	}
+	until := now.Add(l.banDuration())
+	l.bannedUntil[identity] = until
+	log.Printf("tracer %s exceeded the rate limit of %g records per second, banned for %v", identity, rate, l.banDuration())
+	return fmt.Errorf("%w: tracer %s exceeded its rate limit, and is banned until %v", ErrRecordRejected, identity, until.Format(time.RFC3339))
+}
+
+// hostOf returns the host of the remote address of conn.
+func hostOf(conn net.Conn) string {
+	address := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// allowConnection returns whether the host of conn is not banned.
+func (l *rateLimiter) allowConnection(conn net.Conn) bool {
+	host := hostOf(conn)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	until, ok := l.hostsUntil[host]
+	if ok && !time.Now().Before(until) {
+		delete(l.hostsUntil, host)
+		return true
+	}
+	return !ok
+}
+
+// takeConnection takes a token from bucket, the bucket of conn, and closes
+// conn, banning its host, if there was none left.
+func (l *rateLimiter) takeConnection(bucket *rateBucket, conn net.Conn) bool {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if bucket.take(now) {
+		return true
+	}
+	host := hostOf(conn)
+	if _, ok := l.hostsUntil[host]; !ok {
+		log.Printf("connection from %s exceeded the rate limit of %g records per second, host banned for %v",
+			conn.RemoteAddr(), l.config.ConnectionRateLimit, l.banDuration())
+	}
+	l.hostsUntil[host] = now.Add(l.banDuration())
+	conn.Close()
+	return false
+}
+
+// connectionLimitHandler enforces the ConnectionRateLimit of a connection.
+type connectionLimitHandler struct {
+	TransportHandler
+	limiter *rateLimiter
+	bucket  *rateBucket
+	conn    net.Conn
+}
+
+func (h connectionLimitHandler) RecordAction(arg RecordActionArg) error {
+	if !h.limiter.takeConnection(h.bucket, h.conn) {
+		return errConnectionRateLimit
+	}
+	return h.TransportHandler.RecordAction(arg)
+}
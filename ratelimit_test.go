@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newRateBucket(2, 3, now)
+	for i := 0; i < 3; i++ {
+		if !bucket.take(now) {
+			t.Fatalf("record %d of the burst not allowed", i+1)
+		}
+	}
+	if bucket.take(now) {
+		t.Fatal("record past the burst allowed")
+	}
+	// 2 records per second
+	if !bucket.take(now.Add(500*time.Millisecond)) || bucket.take(now.Add(500*time.Millisecond)) {
+		t.Fatal("the bucket does not refill at its rate")
+	}
+}
+
+func TestIdentityRateLimit(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:        ":0",
+		InMemory:          true,
+		IdentityRateLimit: 1,
+		RateLimitBurst:    3,
+		BanDuration:       time.Hour,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	// client1 loops on RecordAction, and is banned past its burst, unlike
+	// client2
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := client1.CreateTrace()
+	for i := 0; i < 10; i++ {
+		trace.RecordAction(TestAction{Foo: "foo"})
+	}
+	client1.Close()
+	if err := server.limiter.allowRecord("client1"); !isRejected(err) {
+		t.Fatalf("expected the records of the banned client1 to be rejected for good, got %v", err)
+	}
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	client2.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for _, record := range server.Records() {
+		counts[record.TracerIdentity]++
+	}
+	if counts["client1"] != 3 || counts["client2"] != 2 {
+		t.Fatalf("expected 3 records of client1 and 2 of client2, got %v", counts)
+	}
+}
+
+func TestConnectionRateLimit(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:          "127.0.0.1:0",
+		InMemory:            true,
+		ConnectionRateLimit: 1,
+		RateLimitBurst:      2,
+		BanDuration:         time.Hour,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	// the connection is closed past its burst, and the host banned
+	for _, identity := range []string{"client1", "client2"} {
+		tracer := NewTracerNonFatal(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: identity,
+		})
+		if tracer == nil {
+			continue
+		}
+		trace := tracer.CreateTrace()
+		for i := 0; i < 5; i++ {
+			trace.RecordAction(TestAction{Foo: "foo"})
+		}
+		tracer.Close()
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := server.Records(); len(records) != 2 || records[0].TracerIdentity != "client1" || records[1].TracerIdentity != "client1" {
+		t.Fatalf("expected the first 2 records of client1, got %v", records)
+	}
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// RecordReader reads the records of an output file.
+type RecordReader interface {
+	// Read returns the next record, and io.EOF after the last one.
+	Read() (TraceRecord, error)
+	// Header returns the header of the file, the zero OutputHeader if it
+	// has none.
+	Header() OutputHeader
+}
+
+// recordDecoder decodes the records of an output file, its header included.
+type recordDecoder interface {
+	Read() (TraceRecord, error)
+}
+
+// NewRecordReader returns a reader of the records in r, an output file in
+// format (see TracingServerConfig.OutputFormat). It reads the header of the
+// file first, and fails if the file is of an output schema version this
+// release cannot read.
+func NewRecordReader(r io.Reader, format string) (RecordReader, error) {
+	var decoder recordDecoder
+	switch format {
+	case "", OutputFormatJSON:
+		decoder = &jsonRecordReader{decoder: json.NewDecoder(bufio.NewReader(r))}
+	case OutputFormatBinary:
+		decoder = NewBinaryRecordReader(r)
+	default:
+		return nil, errOutputFormat
+	}
+	return newHeaderRecordReader(decoder)
+}
+
+// jsonRecordReader reads an output file of JSON objects.
+type jsonRecordReader struct {
+	decoder *json.Decoder
+}
+
+func (r *jsonRecordReader) Read() (TraceRecord, error) {
+	var record TraceRecord
+	err := r.decoder.Decode(&record)
+	return record, err
+}
+
+// ReadRecords reads all the records in r, an output file in format.
+func ReadRecords(r io.Reader, format string) ([]TraceRecord, error) {
+	reader, err := NewRecordReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+	var records []TraceRecord
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// maxWriteBatch bounds the records the writer writes at once, under a
+// single hold of recordLock, so that syncs and queries are not starved.
+const maxWriteBatch = 256
+
+var errServerClosed = errors.New("tracing server closed")
+
+// recordWrite is a record queued for the writer, with arg, the request it
+// was received with, and the channel its error is sent on once written.
+type recordWrite struct {
+	arg    RecordActionArg
+	record TraceRecord
+	done   chan error
+}
+
+// recordQueue feeds the records received by the server to a single writer
+// goroutine, which encodes and writes them in batches, instead of the
+// handlers of concurrent requests contending for recordLock to write each.
+type recordQueue struct {
+	lock    sync.RWMutex // read-locked to queue records, locked to close
+	closed  bool
+	writes  chan recordWrite
+	done    chan struct{}
+	pending int32 // the records queued and not written yet, atomically
+}
+
+func (tracingServer *TracingServer) startWriter() {
+	tracingServer.queue = &recordQueue{
+		writes: make(chan recordWrite, maxWriteBatch),
+		done:   make(chan struct{}),
+	}
+	go tracingServer.runWriter(tracingServer.queue)
+}
+
+// runWriter writes the records queued, in order, until the queue is
+// closed and drained.
+func (tracingServer *TracingServer) runWriter(q *recordQueue) {
+	defer close(q.done)
+
+	batch := make([]recordWrite, 0, maxWriteBatch)
+	for write := range q.writes {
+		batch = append(batch[:0], write)
+	drain:
+		for len(batch) < maxWriteBatch {
+			select {
+			case write, ok := <-q.writes:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, write)
+			default:
+				break drain
+			}
+		}
+
+		tracingServer.recordLock.Lock()
+		for _, write := range batch {
+			err := tracingServer.writeRecord(write.record)
+			if err == nil {
+				tracingServer.accountRecord(write.arg)
+			}
+			write.done <- err
+		}
+		tracingServer.recordLock.Unlock()
+	}
+}
+
+// write queues record, received with arg, and returns once the writer
+// wrote it, with its error.
+func (q *recordQueue) write(arg RecordActionArg, record TraceRecord) error {
+	done := make(chan error, 1)
+	q.lock.RLock()
+	if q.closed {
+		q.lock.RUnlock()
+		return errServerClosed
+	}
+	atomic.AddInt32(&q.pending, 1)
+	defer atomic.AddInt32(&q.pending, -1)
+	q.writes <- recordWrite{arg: arg, record: record, done: done}
+	q.lock.RUnlock()
+	return <-done
+}
+
+// stopWriter stops the writer, once it wrote the records queued. The
+// records received afterwards are rejected.
+func (tracingServer *TracingServer) stopWriter() {
+	q := tracingServer.queue
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.writes)
+	}
+	q.lock.Unlock()
+	<-q.done
+}
@@ -0,0 +1,239 @@
+package tracing
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+const (
+	// defaultRelayBufferSize is the default of
+	// TracingServerConfig.RelayBufferSize.
+	defaultRelayBufferSize = 100000
+	// maxRelayBatch bounds the number of records forwarded at once.
+	maxRelayBatch = 1000
+
+	minRelayBackoff = 100 * time.Millisecond
+	maxRelayBackoff = 30 * time.Second
+)
+
+// TransportBatchSender is implemented by TransportConn instances that can
+// send several records at once, which relaying servers use to forward the
+// records they receive in batches. SendBatch returns the number of records
+// recorded, the first ones, along with the error the server rejected the
+// record after them with, if any.
+type TransportBatchSender interface {
+	SendBatch(args []RecordActionArg) (int, error)
+}
+
+// relay forwards the records received by a server in relay mode to the
+// upstream server, in batches, over a single connection. While the upstream
+// server is unreachable, records are buffered, up to maxBuffered of them.
+type relay struct {
+	address     string
+	maxBuffered int
+	credentials credentials // sign the records and requests sent upstream
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	buffer  []RecordActionArg
+	dropped uint64
+	closed  bool
+	wakeup  chan struct{} // closed on close, to interrupt backoffs
+	done    chan struct{}
+
+	connLock sync.Mutex
+	conn     TransportConn
+}
+
+func newRelay(address string, maxBuffered int, credentials credentials) *relay {
+	if maxBuffered <= 0 {
+		maxBuffered = defaultRelayBufferSize
+	}
+	r := &relay{
+		address:     address,
+		maxBuffered: maxBuffered,
+		credentials: credentials,
+		wakeup:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	r.cond = sync.NewCond(&r.lock)
+	go r.run()
+	return r
+}
+
+// enqueue buffers arg to be forwarded, or drops it if the buffer is full.
+func (r *relay) enqueue(arg RecordActionArg) {
+	// the record was decompressed, which invalidated the tracer's MAC
+	key, _ := r.credentials.key(arg.TracerIdentity)
+	signRecord(key, &arg)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.buffer) >= r.maxBuffered {
+		if r.dropped == 0 {
+			log.Printf("relay buffer full (%d records), dropping records until the upstream server at %s is reachable",
+				r.maxBuffered, r.address)
+		}
+		r.dropped++
+		return
+	}
+	r.buffer = append(r.buffer, arg)
+	r.cond.Signal()
+}
+
+// run forwards the buffered records, in order, until the relay is closed
+// and its buffer drained (or the upstream server unreachable). The records
+// the upstream server rejects for good are dropped, the others sent again
+// until it records them, paced while it tells the relay to slow down.
+func (r *relay) run() {
+	defer close(r.done)
+
+	backoff := minRelayBackoff
+	for {
+		r.lock.Lock()
+		for len(r.buffer) == 0 && !r.closed {
+			r.cond.Wait()
+		}
+		if len(r.buffer) == 0 {
+			r.lock.Unlock()
+			return
+		}
+		batch := r.buffer
+		if len(batch) > maxRelayBatch {
+			batch = batch[:maxRelayBatch]
+		}
+		closed := r.closed
+		r.lock.Unlock()
+
+		sent, err := r.send(batch)
+		if isRejected(err) {
+			log.Printf("the upstream server at %s rejected a record of %s, dropping it: %v",
+				r.address, batch[sent].TracerIdentity, err)
+			sent++
+			err = nil
+		}
+		r.lock.Lock()
+		r.buffer = r.buffer[sent:]
+		if len(r.buffer) == 0 {
+			// release the backing array, which may have grown during an outage
+			r.buffer = nil
+		}
+		r.lock.Unlock()
+
+		if isSlowDown(err) {
+			// the upstream server is reachable but overloaded: the records
+			// it did not record are sent again once it keeps up, even while
+			// closing
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxRelayBackoff {
+				backoff = maxRelayBackoff
+			}
+			continue
+		}
+		if err != nil {
+			if closed {
+				r.lock.Lock()
+				log.Printf("could not forward %d records to the upstream server at %s: %v",
+					len(r.buffer), r.address, err)
+				r.buffer = nil
+				r.lock.Unlock()
+				return
+			}
+			log.Printf("error forwarding records to the upstream server at %s, retrying in %v: %v",
+				r.address, backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-r.wakeup:
+			}
+			if backoff *= 2; backoff > maxRelayBackoff {
+				backoff = maxRelayBackoff
+			}
+			continue
+		}
+		backoff = minRelayBackoff
+	}
+}
+
+// send forwards batch to the upstream server, (re)connecting if needed, and
+// returns the number of records it recorded, the first ones of batch.
+func (r *relay) send(batch []RecordActionArg) (int, error) {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	if err := r.connect(); err != nil {
+		return 0, err
+	}
+	var sent int
+	var err error
+	if sender, ok := r.conn.(TransportBatchSender); ok {
+		sent, err = sender.SendBatch(batch)
+	} else {
+		for _, arg := range batch {
+			if err = r.conn.Send(arg); err != nil {
+				break
+			}
+			sent++
+		}
+	}
+	if err != nil && !isRejected(err) && !isSlowDown(err) {
+		r.conn.Close()
+		r.conn = nil
+	}
+	return sent, err
+}
+
+// connect connects to the upstream server, unless already connected. The
+// caller must hold connLock.
+func (r *relay) connect() error {
+	if r.conn != nil {
+		return nil
+	}
+	transport, address := transportFor(r.address)
+	conn, err := withSecret(transport, "", r.credentials.secret).Dial(address)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	return nil
+}
+
+// getLastVC fetches the last vector clock of identity from the upstream
+// server.
+func (r *relay) getLastVC(identity string) (vclock.VClock, error) {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	key, _ := r.credentials.key(identity)
+	return r.conn.GetLastVC(signIdentity(key, identity))
+}
+
+// close forwards the records still buffered, giving up if the upstream
+// server is unreachable, and closes the upstream connection.
+func (r *relay) close() error {
+	r.lock.Lock()
+	r.closed = true
+	close(r.wakeup)
+	r.cond.Signal()
+	dropped := r.dropped
+	r.lock.Unlock()
+	<-r.done
+
+	if dropped > 0 {
+		log.Printf("dropped %d records while the relay buffer was full", dropped)
+	}
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
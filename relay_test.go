@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/google/go-cmp/cmp"
+)
+
+func startRelay(t *testing.T, upstreamAddress string) *TracingServer {
+	relay := NewTracingServer(TracingServerConfig{
+		ServerBind:      ":0",
+		UpstreamAddress: upstreamAddress,
+	})
+	if err := relay.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go relay.Accept()
+	return relay
+}
+
+func TestRelay(t *testing.T) {
+	upstream, closeUpstream := startTestServer(t)
+	relay := startRelay(t, upstream.Listener.Addr().String())
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  relay.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  upstream.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	client2.ReceiveToken(trace.GenerateToken())
+	client1.Close()
+	client2.Close()
+
+	// a tracer rejoining through another relay resumes from the last clock
+	// recorded upstream, once forwarded
+	if err := relay.Close(); err != nil {
+		t.Fatal(err)
+	}
+	otherRelay := startRelay(t, upstream.Listener.Addr().String())
+	rejoined := NewTracer(TracerConfig{
+		ServerAddress:  otherRelay.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	if vc := rejoined.logger.GetCurrentVC(); !cmp.Equal(vc, vclock.VClock{"client1": 2}) {
+		t.Fatalf("rejoined tracer clock %v does not resume from the last recorded one", vc)
+	}
+	rejoined.Close()
+	if err := otherRelay.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	closeUpstream()
+	outputs := readTraceOutputFile(t, upstream.Config.OutputFile)
+	if len(outputs) != 3 {
+		t.Fatalf("expected 3 records, got %v", outputs)
+	}
+}
+
+func TestRelayOutage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstreamBind := listener.Addr().String()
+	listener.Close()
+
+	// records are buffered until the upstream server comes up
+	relay := startRelay(t, upstreamBind)
+	client := NewTracer(TracerConfig{
+		ServerAddress:  relay.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+
+	upstream := NewTracingServer(TracingServerConfig{
+		ServerBind:       upstreamBind,
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := upstream.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go upstream.Accept()
+
+	if err := relay.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := upstream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if outputs := readTraceOutputFile(t, upstream.Config.OutputFile); len(outputs) != 2 {
+		t.Fatalf("expected 2 records, got %v", outputs)
+	}
+}
+
+func TestRelayRejectedRecord(t *testing.T) {
+	upstream := NewTracingServer(TracingServerConfig{
+		ServerBind:    ":0",
+		InMemory:      true,
+		MaxRecordSize: 1024,
+	})
+	if err := upstream.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go upstream.Accept()
+	relay := startRelay(t, upstream.Listener.Addr().String())
+
+	// the record past the limit of the upstream server is dropped, once,
+	// and the records after it still forwarded
+	client := NewTracer(TracerConfig{
+		ServerAddress:  relay.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	trace.RecordAction(TestAction{Foo: strings.Repeat("x", 4096)})
+	trace.RecordAction(TestAction{Foo: "bar"})
+	client.Close()
+	if err := relay.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := upstream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ticks []uint64
+	for _, record := range upstream.Records() {
+		ticks = append(ticks, record.VectorClock["client1"])
+	}
+	if expected := []uint64{1, 2, 4}; !cmp.Equal(ticks, expected) {
+		t.Fatalf("expected the records ticking client1 at %v upstream, got %v", expected, ticks)
+	}
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import "fmt"
+
+// Replay sends records, e.g. read from an output file, to the tracing
+// server at config.ServerAddress, as the tracers that recorded them did:
+// with their identities, trace IDs, tags, bodies, vector clocks and
+// sequence numbers, in order, over a single connection, to exercise the
+// server with a captured workload (see cmd/trace-replay). Of config, only
+// the settings of the connection are used: ServerAddress, Transport, the
+// TLS files, Compression and Secret, which signs the records. A server
+// checking the certificates of tracers only accepts the records of the
+// identity of the certificate, and one with Identities only those of the
+// identities not registered.
+//
+// The records of each tracer are sent in a session of their own, and in a
+// new one each time their sequence numbers restart, as when the tracer
+// reconnected. With a Secret, the records with no sequence number are
+// numbered in a session of their own, as servers checking the signatures
+// of records reject those not numbered.
+func Replay(config TracerConfig, records []TraceRecord) error {
+	_, _, conn, err := config.dial()
+	if err != nil {
+		return err
+	}
+	compression, err := negotiateCompression(conn, config.Compression)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	type session struct {
+		id      uint64
+		lastSeq uint64
+	}
+	numbered := make(map[string]*session)
+	// the sessions of the records not numbered, numbered if signed
+	unnumbered := make(map[string]*session)
+	for i, record := range records {
+		arg := RecordActionArg{
+			TracerIdentity: record.TracerIdentity,
+			TraceID:        record.TraceID,
+			RecordName:     record.Tag,
+			Record:         record.Body,
+			VectorClock:    record.VectorClock,
+		}
+		seq, sessions := record.TracerSeq, numbered
+		if seq == 0 && len(config.Secret) > 0 {
+			seq, sessions = 1, unnumbered
+			if s := sessions[record.TracerIdentity]; s != nil {
+				seq = s.lastSeq + 1
+			}
+		}
+		if seq != 0 {
+			s := sessions[record.TracerIdentity]
+			if s == nil || seq <= s.lastSeq {
+				seededIDLock.Lock()
+				s = &session{id: seededIDGen.Uint64()}
+				seededIDLock.Unlock()
+				sessions[record.TracerIdentity] = s
+			}
+			s.lastSeq = seq
+			arg.Session, arg.Seq = s.id, seq
+		}
+		if err := compressRecord(&arg, compression); err != nil {
+			conn.Close()
+			return err
+		}
+		signRecord(config.Secret, &arg)
+		if err := conn.Send(arg); err != nil {
+			conn.Close()
+			return fmt.Errorf("replaying record %d of %s: %v", i+1, record.TracerIdentity, err)
+		}
+	}
+	return conn.Close()
+}
@@ -0,0 +1,236 @@
+package tracing
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rotationTimeLayout is the layout of the time in the names of rotated
+// output files.
+const rotationTimeLayout = "20060102T150405.000000000Z"
+
+// pruneInterval is how often the pruner looks for rotated files to prune.
+const pruneInterval = time.Minute
+
+// rotatedFileName returns the name fileName is renamed to when rotated at
+// t, with the time inserted before its extension.
+func rotatedFileName(fileName string, t time.Time) string {
+	ext := filepath.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "." + t.UTC().Format(rotationTimeLayout) + ext
+}
+
+// rotate renames the file of the sink after the time now, and starts a new
+// one in its place.
+func (s *recordFileSink) rotate(now time.Time) error {
+	fileName := s.file.Name()
+	if err := s.writeTrailer(); err != nil {
+		return err
+	}
+	if err := s.flushBuffer(); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	rotated := rotatedFileName(fileName, now)
+	// rotations within the clock's resolution must not overwrite each other
+	for _, err := os.Stat(rotated); err == nil; _, err = os.Stat(rotated) {
+		now = now.Add(time.Nanosecond)
+		rotated = rotatedFileName(fileName, now)
+	}
+	if err := os.Rename(fileName, rotated); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.size = 0
+	s.frames = 0
+	if s.buffer != nil {
+		s.buffer.Reset(file)
+	}
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+
+	if s.index != nil {
+		if err := s.index.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(indexFileName(fileName), indexFileName(rotated)); err != nil {
+			return err
+		}
+		if s.index, err = createIndex(indexFileName(fileName)); err != nil {
+			return err
+		}
+	}
+	if s.signatures != nil {
+		// the new file starts a chain of its own
+		if err := s.signatures.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(signatureFileName(fileName), signatureFileName(rotated)); err != nil {
+			return err
+		}
+		if s.signatures, err = openSignatures(signatureFileName(fileName), s.signatures.key, os.O_TRUNC); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateRecordFiles rotates the output files grown past RotateSize. The
+// records written so far are flushed first, and the write-ahead log
+// checkpointed, so that none is recovered into the new files after a
+// crash. The caller must hold recordLock.
+func (tracingServer *TracingServer) rotateRecordFiles() error {
+	for _, sink := range tracingServer.recordSinks {
+		if sink.size < tracingServer.Config.RotateSize || sink.file == os.Stdout || sink.file == os.Stderr {
+			continue
+		}
+		if err := tracingServer.flushSinks(); err != nil {
+			return err
+		}
+		if err := sink.rotate(time.Now()); err != nil {
+			return err
+		}
+		if tracingServer.walFile != nil {
+			if err := tracingServer.checkpointWAL(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rotatedFile is an output file rotated at time.
+type rotatedFile struct {
+	name string
+	time time.Time
+	size int64
+}
+
+// rotatedFiles returns the rotated files of fileName, oldest first.
+func rotatedFiles(fileName string) ([]rotatedFile, error) {
+	ext := filepath.Ext(fileName)
+	prefix := strings.TrimSuffix(fileName, ext) + "."
+	matches, err := filepath.Glob(globEscape(prefix) + "*" + globEscape(ext))
+	if err != nil {
+		return nil, err
+	}
+	var files []rotatedFile
+	for _, match := range matches {
+		t, err := time.Parse(rotationTimeLayout, strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext))
+		if err != nil {
+			// e.g. a partition of fileName
+			continue
+		}
+		info, err := os.Stat(match)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files = append(files, rotatedFile{name: match, time: t, size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].time.Before(files[j].time)
+	})
+	return files, nil
+}
+
+// globEscape escapes the metacharacters of filepath.Match in s.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// pruneRotatedFiles removes the rotated output files older than
+// RetentionAge at now, and the oldest ones past a total size of
+// RetentionSize, for each output file (or partition).
+func (tracingServer *TracingServer) pruneRotatedFiles(now time.Time) error {
+	for _, fileName := range tracingServer.recordFileNames() {
+		if outputStream(fileName) != nil {
+			continue
+		}
+		files, err := rotatedFiles(fileName)
+		if err != nil {
+			return err
+		}
+		var total int64
+		for i := len(files) - 1; i >= 0; i-- {
+			file := files[i]
+			total += file.size
+			if age := tracingServer.Config.RetentionAge; age > 0 && now.Sub(file.time) > age ||
+				tracingServer.Config.RetentionSize > 0 && total > tracingServer.Config.RetentionSize {
+				if err := os.Remove(file.name); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				if err := os.Remove(indexFileName(file.name)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				if err := os.Remove(signatureFileName(file.name)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// pruner periodically prunes the rotated output files, every
+// pruneInterval.
+type pruner struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (tracingServer *TracingServer) startPruner() {
+	tracingServer.pruner = &pruner{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go tracingServer.runPruner(tracingServer.pruner)
+}
+
+func (tracingServer *TracingServer) runPruner(p *pruner) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if err := tracingServer.pruneRotatedFiles(time.Now()); err != nil {
+			log.Print("error pruning rotated files: ", err)
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (tracingServer *TracingServer) stopPruner() {
+	if tracingServer.pruner == nil {
+		return
+	}
+	close(tracingServer.pruner.stop)
+	<-tracingServer.pruner.done
+	tracingServer.pruner = nil
+}
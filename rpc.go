@@ -0,0 +1,284 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+)
+
+// TracedArgs can be embedded in the argument type of an RPC method served
+// with NewServerCodec, to give the method access to the trace the call is
+// part of:
+// 	type PutArgs struct {
+// 		tracing.TracedArgs
+// 		Key, Value string
+// 	}
+//
+// 	func (s *KVServer) Put(args PutArgs, reply *PutReply) error {
+// 		args.Trace().RecordAction(PutRecvd{Key: args.Key})
+// 		...
+// 	}
+// The trace itself is not transmitted as part of the arguments, but as a
+// token alongside the call.
+type TracedArgs struct {
+	TraceID uint64 // the ID of the call's trace, set on reception
+	trace   *Trace
+}
+
+// Trace returns the trace of the call these arguments were received with, or
+// nil if the caller did not send a token.
+func (args *TracedArgs) Trace() *Trace {
+	return args.trace
+}
+
+func (args *TracedArgs) setTrace(trace *Trace) {
+	args.trace = trace
+	if trace != nil {
+		args.TraceID = trace.ID
+	}
+}
+
+type traceSetter interface {
+	setTrace(trace *Trace)
+}
+
+// RPCClient is a net/rpc client that automatically propagates traces: every
+// call carries a freshly generated token of the caller's trace, and the token
+// sent back with the reply is received by the client's tracer. It must be
+// used with servers serving connections with NewServerCodec.
+type RPCClient struct {
+	client *rpc.Client
+	codec  *tracedClientCodec
+	lock   sync.Mutex
+}
+
+// DialRPC connects to a traced RPC server at the specified network address,
+// using tracer to generate and receive tokens.
+func DialRPC(tracer *Tracer, network, address string) (*RPCClient, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewRPCClient(tracer, conn), nil
+}
+
+// NewRPCClient returns a traced RPC client communicating over conn.
+func NewRPCClient(tracer *Tracer, conn io.ReadWriteCloser) *RPCClient {
+	codec := &tracedClientCodec{
+		tracer: tracer,
+		conn:   conn,
+		buf:    bufio.NewWriter(conn),
+		dec:    gob.NewDecoder(conn),
+	}
+	codec.enc = gob.NewEncoder(codec.buf)
+	return &RPCClient{
+		client: rpc.NewClientWithCodec(codec),
+		codec:  codec,
+	}
+}
+
+// Call invokes the named function as part of trace, waits for it to
+// complete, and returns its error status. trace may be nil, in which case
+// no token is sent.
+func (client *RPCClient) Call(trace *Trace, serviceMethod string, args interface{}, reply interface{}) error {
+	call := <-client.Go(trace, serviceMethod, args, reply, make(chan *rpc.Call, 1)).Done
+	return call.Error
+}
+
+// Go invokes the function asynchronously, as part of trace; see rpc.Client.Go.
+func (client *RPCClient) Go(trace *Trace, serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	// rpc.Client.Go writes the request before returning, so the codec picks
+	// up the trace of this very call
+	client.codec.setNextTrace(trace)
+	return client.client.Go(serviceMethod, args, reply, done)
+}
+
+// Close closes the underlying connection.
+func (client *RPCClient) Close() error {
+	return client.client.Close()
+}
+
+// tracedClientCodec mirrors net/rpc's gob client codec, with a token written
+// after each request header and read after each response header.
+type tracedClientCodec struct {
+	tracer *Tracer
+	conn   io.ReadWriteCloser
+	buf    *bufio.Writer
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+
+	lock      sync.Mutex
+	nextTrace *Trace
+}
+
+func (c *tracedClientCodec) setNextTrace(trace *Trace) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.nextTrace = trace
+}
+
+func (c *tracedClientCodec) WriteRequest(r *rpc.Request, body interface{}) (err error) {
+	c.lock.Lock()
+	trace := c.nextTrace
+	c.nextTrace = nil
+	c.lock.Unlock()
+
+	var token TracingToken
+	if trace != nil {
+		token = trace.GenerateToken()
+	}
+	if err = c.enc.Encode(r); err != nil {
+		return
+	}
+	if err = c.enc.Encode(token); err != nil {
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		return
+	}
+	return c.buf.Flush()
+}
+
+func (c *tracedClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	if err := c.dec.Decode(r); err != nil {
+		return err
+	}
+	var token TracingToken
+	if err := c.dec.Decode(&token); err != nil {
+		return err
+	}
+	if len(token) > 0 {
+		c.tracer.ReceiveToken(token)
+	}
+	return nil
+}
+
+func (c *tracedClientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *tracedClientCodec) Close() error {
+	return c.conn.Close()
+}
+
+// NewServerCodec returns an rpc.ServerCodec to be used with
+// rpc.Server.ServeCodec for connections from RPCClient instances. The token
+// accompanying each call is received by tracer, and the resulting trace is
+// made available to the method through TracedArgs, if its argument type
+// embeds it. A token of that trace is sent back with the reply.
+func NewServerCodec(tracer *Tracer, conn io.ReadWriteCloser) rpc.ServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &tracedServerCodec{
+		tracer:  tracer,
+		conn:    conn,
+		buf:     buf,
+		enc:     gob.NewEncoder(buf),
+		dec:     gob.NewDecoder(conn),
+		pending: make(map[uint64]*Trace),
+	}
+}
+
+// ServeConn runs the traced server codec over a single connection, using
+// server to dispatch the calls. ServeConn blocks, serving the connection
+// until the client hangs up.
+func ServeConn(tracer *Tracer, server *rpc.Server, conn io.ReadWriteCloser) {
+	server.ServeCodec(NewServerCodec(tracer, conn))
+}
+
+// tracedServerCodec mirrors net/rpc's gob server codec, with a token read
+// after each request header and written after each response header.
+type tracedServerCodec struct {
+	tracer *Tracer
+	conn   io.ReadWriteCloser
+	buf    *bufio.Writer
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+	closed bool
+
+	// current is the trace of the request being read
+	current *Trace
+
+	lock    sync.Mutex
+	pending map[uint64]*Trace
+}
+
+func (c *tracedServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.dec.Decode(r); err != nil {
+		return err
+	}
+	var token TracingToken
+	if err := c.dec.Decode(&token); err != nil {
+		return err
+	}
+
+	c.current = nil
+	if len(token) > 0 {
+		c.current = c.tracer.ReceiveToken(token)
+	}
+	c.lock.Lock()
+	c.pending[r.Seq] = c.current
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *tracedServerCodec) ReadRequestBody(body interface{}) error {
+	if err := c.dec.Decode(body); err != nil {
+		return err
+	}
+	if setter, ok := body.(traceSetter); ok {
+		setter.setTrace(c.current)
+	}
+	return nil
+}
+
+func (c *tracedServerCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	c.lock.Lock()
+	trace := c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.lock.Unlock()
+
+	var token TracingToken
+	if trace != nil {
+		token = trace.GenerateToken()
+	}
+	if err = c.enc.Encode(r); err != nil {
+		if c.buf.Flush() == nil {
+			// Gob couldn't encode the header. Should not happen, so if it
+			// does, shut down the connection to signal that the connection
+			// is broken.
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(token); err != nil {
+		if c.buf.Flush() == nil {
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.buf.Flush() == nil {
+			// Was a gob problem encoding the body but the header has been
+			// written. Shut down the connection to signal that the
+			// connection is broken.
+			c.Close()
+		}
+		return
+	}
+	return c.buf.Flush()
+}
+
+func (c *tracedServerCodec) Close() error {
+	if c.closed {
+		// Only call c.conn.Close once; otherwise the semantics are undefined.
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/google/go-cmp/cmp"
+)
+
+type EchoArgs struct {
+	TracedArgs
+	Message string
+}
+
+type EchoRecvd struct {
+	Message string
+}
+
+type Echo struct{}
+
+func (Echo) Echo(args EchoArgs, reply *string) error {
+	args.Trace().RecordAction(EchoRecvd{Message: args.Message})
+	*reply = args.Message
+	return nil
+}
+
+func TestTracedRPC(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	defer closeServer()
+	serverBind := server.Listener.Addr().String()
+
+	clientTracer := NewTracer(TracerConfig{
+		ServerAddress:  serverBind,
+		TracerIdentity: "client",
+	})
+	defer clientTracer.Close()
+	serverTracer := NewTracer(TracerConfig{
+		ServerAddress:  serverBind,
+		TracerIdentity: "server",
+	})
+	defer serverTracer.Close()
+
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.Register(Echo{}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		ServeConn(serverTracer, rpcServer, conn)
+	}()
+
+	client, err := DialRPC(clientTracer, "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	trace := clientTracer.CreateTrace()
+	var reply string
+	if err := client.Call(trace, "Echo.Echo", EchoArgs{Message: "hello"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hello" {
+		t.Fatalf("expected reply %q, got %q", "hello", reply)
+	}
+
+	// create, send, receive reply on the client; receive, record, send reply
+	// on the server
+	if vc, expected := clientTracer.logger.GetCurrentVC(), (vclock.VClock{"client": 3, "server": 3}); !cmp.Equal(vc, expected) {
+		t.Fatalf("expected client clock %v, got %v", expected, vc)
+	}
+	if vc, expected := serverTracer.logger.GetCurrentVC(), (vclock.VClock{"client": 2, "server": 3}); !cmp.Equal(vc, expected) {
+		t.Fatalf("expected server clock %v, got %v", expected, vc)
+	}
+}
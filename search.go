@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RecordSearcher is implemented by sinks that index the bodies of the
+// records they store, e.g. sqlite.Store, which the query API of a server
+// searches rather than scanning its records, see
+// TracingServerConfig.QueryBind.
+type RecordSearcher interface {
+	// SearchRecords returns, in the order they were written, at least the
+	// records whose body contains all the words of text, see SearchWords.
+	SearchRecords(text string) ([]TraceRecord, error)
+}
+
+// SearchWords returns the words of text that searches match: its runs of
+// ASCII letters and digits and of non-ASCII characters, with ASCII letters
+// in lower case. These are the terms of the simple tokenizer of the SQLite
+// full-text search, so that searches of an index and of the records match
+// the same.
+func SearchWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case 'A' <= c && c <= 'Z':
+			word.WriteByte(c - 'A' + 'a')
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9', c >= 0x80:
+			word.WriteByte(c)
+		default:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// MatchSearch returns whether the body of record contains all the words of
+// text, or its field at path if path is not empty, e.g. []string{"Key"}.
+func MatchSearch(record TraceRecord, text string, path []string) bool {
+	body := record.Body
+	for _, name := range path {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) != nil {
+			return false
+		}
+		field, ok := fields[name]
+		if !ok {
+			return false
+		}
+		body = field
+	}
+	words := make(map[string]bool)
+	for _, word := range SearchWords(string(body)) {
+		words[word] = true
+	}
+	for _, word := range SearchWords(text) {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
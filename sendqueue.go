@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Policies of a tracer whose queue of records to send is full, see
+// TracerConfig.QueuePolicy.
+const (
+	QueueBlock      = "block"
+	QueueDropOldest = "drop-oldest"
+	QueueDropNewest = "drop-newest"
+)
+
+// queuedRecord is a record queued for the sender, with the index of the
+// connection it is sent over.
+type queuedRecord struct {
+	conn int
+	arg  RecordActionArg
+}
+
+// sendQueue holds the records a tracer recorded and did not send yet, up
+// to a bound, past which its policy decides which records are dropped.
+type sendQueue struct {
+	lock    sync.Mutex
+	changed *sync.Cond     // signaled when records are queued or taken, or the queue is closed
+	records []queuedRecord // a ring of the records queued, the n ones from head
+	head    int
+	n       int
+	policy  string
+	closed  bool
+	dropped uint64
+	done    chan struct{} // closed once the sender sent every record queued
+}
+
+func newSendQueue(size int, policy string) (*sendQueue, error) {
+	switch policy {
+	case "":
+		policy = QueueBlock
+	case QueueBlock, QueueDropOldest, QueueDropNewest:
+	default:
+		return nil, fmt.Errorf("unknown QueuePolicy %q", policy)
+	}
+	q := &sendQueue{
+		records: make([]queuedRecord, size),
+		policy:  policy,
+		done:    make(chan struct{}),
+	}
+	q.changed = sync.NewCond(&q.lock)
+	return q, nil
+}
+
+// push queues record, unless its policy drops it as the queue is full, or
+// the queue is closed.
+func (q *sendQueue) push(record queuedRecord) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.n == len(q.records) && !q.closed {
+		switch q.policy {
+		case QueueDropNewest:
+			q.dropped++
+			return
+		case QueueDropOldest:
+			q.records[q.head] = queuedRecord{}
+			q.head = (q.head + 1) % len(q.records)
+			q.n--
+			q.dropped++
+		default:
+			q.changed.Wait()
+		}
+	}
+	if q.closed {
+		return
+	}
+	q.records[(q.head+q.n)%len(q.records)] = record
+	q.n++
+	q.changed.Broadcast()
+}
+
+// pop takes the oldest record queued, waiting for one if there is none,
+// and returns false once the queue is closed and empty.
+func (q *sendQueue) pop() (queuedRecord, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.n == 0 && !q.closed {
+		q.changed.Wait()
+	}
+	if q.n == 0 {
+		return queuedRecord{}, false
+	}
+	record := q.records[q.head]
+	q.records[q.head] = queuedRecord{}
+	q.head = (q.head + 1) % len(q.records)
+	q.n--
+	q.changed.Broadcast()
+	return record, true
+}
+
+func (q *sendQueue) close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.closed = true
+	q.changed.Broadcast()
+}
+
+func (tracer *Tracer) startSender(size int, policy string) error {
+	q, err := newSendQueue(size, policy)
+	if err != nil {
+		return err
+	}
+	tracer.queue = q
+	go tracer.runSender(q)
+	return nil
+}
+
+// runSender sends the records queued, one at a time and in order, until
+// the queue is closed and drained.
+func (tracer *Tracer) runSender(q *sendQueue) {
+	defer close(q.done)
+
+	for {
+		record, ok := q.pop()
+		if !ok {
+			return
+		}
+		s := &tracer.stripes[record.conn]
+		s.Lock()
+		tracer.sendRecord(record.conn, record.arg)
+		s.Unlock()
+	}
+}
+
+// stopSender sends the records queued, if any, and stops the sender.
+func (tracer *Tracer) stopSender() {
+	q := tracer.queue
+	if q == nil {
+		return
+	}
+	q.close()
+	<-q.done
+	if dropped := tracer.DroppedRecords(); dropped > 0 {
+		log.Printf("[%s] dropped %d records, with %d queued already", tracer.identity, dropped, len(q.records))
+	}
+}
+
+// DroppedRecords returns the number of records the tracer dropped as its
+// queue was full, see TracerConfig.QueuePolicy. The server accounts for
+// them as missing, except those dropped after the last record it received
+// from the tracer, see TracingServer.MissingRecords.
+func (tracer *Tracer) DroppedRecords() uint64 {
+	q := tracer.queue
+	if q == nil {
+		return 0
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dropped
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// gatedTransport wraps an inProcessTransport, whose connections hold the
+// records sent over them until the gate is opened, signaling on sending
+// each time they start to.
+type gatedTransport struct {
+	*inProcessTransport
+	sending chan struct{}
+	gate    chan struct{}
+}
+
+type gatedConn struct {
+	TransportConn
+	transport *gatedTransport
+}
+
+func (t *gatedTransport) Dial(address string) (TransportConn, error) {
+	conn, err := t.inProcessTransport.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return gatedConn{TransportConn: conn, transport: t}, nil
+}
+
+func (c gatedConn) Send(arg RecordActionArg) error {
+	select {
+	case c.transport.sending <- struct{}{}:
+	default:
+	}
+	<-c.transport.gate
+	return c.TransportConn.Send(arg)
+}
+
+func TestQueuePolicy(t *testing.T) {
+	for _, test := range []struct {
+		policy  string
+		foos    []string // the actions recorded, after the CreateTrace record
+		dropped uint64
+		missing uint64
+	}{
+		{QueueBlock, []string{"1", "2", "3", "4"}, 0, 0},
+		// the last records are dropped, which the server cannot tell
+		{QueueDropNewest, []string{"1", "2"}, 2, 0},
+		{QueueDropOldest, []string{"3", "4"}, 2, 2},
+	} {
+		t.Run(test.policy, func(t *testing.T) {
+			transport := &gatedTransport{
+				inProcessTransport: &inProcessTransport{handlers: make(map[string]TransportHandler)},
+				sending:            make(chan struct{}, 1),
+				gate:               make(chan struct{}),
+			}
+			server := NewTracingServer(TracingServerConfig{
+				ServerBind: ":0",
+				InMemory:   true,
+				Transports: []TransportBinding{{Transport: transport, Address: "server"}},
+			})
+			if err := server.Open(); err != nil {
+				t.Fatal(err)
+			}
+			go server.Accept()
+
+			client := NewTracer(TracerConfig{
+				ServerAddress:  "server",
+				TracerIdentity: "client1",
+				Transport:      transport,
+				QueueSize:      2,
+				QueuePolicy:    test.policy,
+			})
+			// the sender holds the CreateTrace record, while the actions
+			// fill the queue
+			trace := client.CreateTrace()
+			<-transport.sending
+			recorded := make(chan struct{})
+			go func() {
+				defer close(recorded)
+				for _, foo := range []string{"1", "2", "3", "4"} {
+					trace.RecordAction(TestAction{Foo: foo})
+				}
+			}()
+			select {
+			case <-recorded:
+				if test.policy == QueueBlock {
+					t.Fatal("recorded more actions than the queue holds")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if test.policy != QueueBlock {
+					t.Fatal("blocked recording actions with the queue full")
+				}
+			}
+			close(transport.gate)
+			<-recorded
+			client.Close()
+			if dropped := client.DroppedRecords(); dropped != test.dropped {
+				t.Fatalf("expected %d records dropped, got %d", test.dropped, dropped)
+			}
+
+			if err := server.Close(); err != nil {
+				t.Fatal(err)
+			}
+			var foos []string
+			for _, record := range server.Records()[1:] {
+				var action TestAction
+				if err := json.Unmarshal(record.Body, &action); err != nil {
+					t.Fatal(err)
+				}
+				foos = append(foos, action.Foo)
+			}
+			if !cmp.Equal(foos, test.foos) {
+				t.Fatalf("expected the actions %v to be recorded, got %v", test.foos, foos)
+			}
+			if missing := server.MissingRecords()["client1"]; missing != test.missing {
+				t.Fatalf("expected %d missing records, got %d", test.missing, missing)
+			}
+		})
+	}
+}
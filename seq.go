@@ -0,0 +1,229 @@
+package tracing
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// reorderTimeout bounds how long a record waits for those numbered before
+// it in its session, which may have been lost.
+const reorderTimeout = time.Second
+
+// orderIdleTimeout is how long the order of a session is kept once its
+// records stop arriving, e.g. as its tracer closed.
+const orderIdleTimeout = time.Minute
+
+var errUnnumberedRecord = errors.New("signed records must be numbered with a Seq, for the server to detect replays")
+
+// tracerSession tracks the sequence numbers of the records written for one
+// tracer instance, identified by RecordActionArg.Session.
+type tracerSession struct {
+	identity string
+	lastSeq  uint64
+	missing  uint64
+}
+
+// accountRecord updates the gap accounting of the session of arg, once
+// written: every sequence number skipped since the last record written for
+// the session is counted as missing, until it arrives late. Records not
+// numbered by their tracer are not accounted for. The caller must hold
+// recordLock.
+func (tracingServer *TracingServer) accountRecord(arg RecordActionArg) {
+	if arg.Seq == 0 {
+		return
+	}
+	session, ok := tracingServer.sessions[arg.Session]
+	if !ok {
+		// the records before the first one written, e.g. those sent to a
+		// previous run of the server, are not accounted for
+		tracingServer.sessions[arg.Session] = &tracerSession{identity: arg.TracerIdentity, lastSeq: arg.Seq}
+		return
+	}
+	if arg.Seq > session.lastSeq {
+		session.missing += arg.Seq - session.lastSeq - 1
+		session.lastSeq = arg.Seq
+	} else if session.missing > 0 {
+		session.missing--
+	}
+}
+
+// MissingRecords returns, for each tracer identity, the number of records
+// missing from those the server wrote, based on the gaps in the sequence
+// numbers its tracers stamp the records with. Records missing before the
+// first written or after the last written for a tracer instance cannot be
+// accounted for.
+func (tracingServer *TracingServer) MissingRecords() map[string]uint64 {
+	tracingServer.recordLock.Lock()
+	defer tracingServer.recordLock.Unlock()
+
+	missing := make(map[string]uint64)
+	for _, session := range tracingServer.sessions {
+		missing[session.identity] += session.missing
+	}
+	return missing
+}
+
+// logMissingRecords reports the records missing at Close.
+func (tracingServer *TracingServer) logMissingRecords() {
+	for identity, missing := range tracingServer.MissingRecords() {
+		if missing > 0 {
+			log.Printf("missing %d records of %s, based on their sequence numbers", missing, identity)
+		}
+	}
+}
+
+// signedSession identifies a session of (signed) records. The sessions of
+// different identities are told apart, so that a tracer cannot preempt, or
+// stall, the session of another.
+type signedSession struct {
+	identity string
+	session  uint64
+}
+
+// checkReplay returns whether arg is to be recorded, if the server checks
+// the signatures of records (see TracingServerConfig.Secret): the records
+// signed by their tracer must be numbered, and their sequence numbers
+// increase within their session, or captured requests could be replayed to
+// duplicate or reorder the records. The records whose sequence number does
+// not, replayed or arriving out of order over UDP, are dropped without an
+// error, and counted as replayed, see ReplayedRecords.
+func (tracingServer *TracingServer) checkReplay(arg RecordActionArg) (bool, error) {
+	if !tracingServer.credentials().enabled() {
+		return true, nil
+	}
+	if arg.Seq == 0 {
+		return false, errUnnumberedRecord
+	}
+	session := signedSession{identity: arg.TracerIdentity, session: arg.Session}
+	tracingServer.replayLock.Lock()
+	defer tracingServer.replayLock.Unlock()
+	if arg.Seq <= tracingServer.signedSeqs[session] {
+		tracingServer.replayed[arg.TracerIdentity]++
+		return false, nil
+	}
+	tracingServer.signedSeqs[session] = arg.Seq
+	return true, nil
+}
+
+// ReplayedRecords returns, for each tracer identity, the number of signed
+// records dropped because their sequence number was not greater than that
+// of the last record accepted in their session, see checkReplay.
+func (tracingServer *TracingServer) ReplayedRecords() map[string]uint64 {
+	tracingServer.replayLock.Lock()
+	defer tracingServer.replayLock.Unlock()
+
+	replayed := make(map[string]uint64, len(tracingServer.replayed))
+	for identity, n := range tracingServer.replayed {
+		replayed[identity] = n
+	}
+	return replayed
+}
+
+// logReplayedRecords reports the replayed records dropped at Close.
+func (tracingServer *TracingServer) logReplayedRecords() {
+	for identity, replayed := range tracingServer.ReplayedRecords() {
+		log.Printf("dropped %d replayed records of %s, based on their sequence numbers", replayed, identity)
+	}
+}
+
+// sessionOrder orders the records of a session, which a tracer sends over
+// several connections (see TracerConfig.Connections).
+type sessionOrder struct {
+	next     uint64                   // the sequence number of the record expected next
+	waiters  map[uint64]chan struct{} // closed when the record they number is next
+	lastUsed time.Time                // when a record of the session was last handled
+}
+
+// awaitTurn blocks until the records numbered before arg in its session
+// were handled, if its tracer sends them over several connections (see
+// RecordActionArg.Striped), for the records of the tracer to be written in
+// the order it sent them nonetheless, as the checkers and lastVCs expect.
+// Records wait up to reorderTimeout for the missing records before them,
+// e.g. lost to a failing connection, or sent to a previous run of the
+// server, or to the server before it forgot the session, idle for
+// orderIdleTimeout. The returned function must be called once arg was
+// handled, with its error: a record told to slow down is sent again, so the
+// records after it keep waiting.
+func (tracingServer *TracingServer) awaitTurn(arg RecordActionArg) (done func(err error)) {
+	if arg.Seq == 0 || !arg.Striped {
+		return func(error) {}
+	}
+	key := signedSession{identity: arg.TracerIdentity, session: arg.Session}
+	tracingServer.orderLock.Lock()
+	defer tracingServer.orderLock.Unlock()
+	now := time.Now()
+	if now.Sub(tracingServer.ordersSwept) >= orderIdleTimeout {
+		tracingServer.forgetIdleOrders(now)
+	}
+	order, ok := tracingServer.orders[key]
+	if !ok {
+		order = &sessionOrder{next: 1, waiters: make(map[uint64]chan struct{})}
+		tracingServer.orders[key] = order
+	}
+	order.lastUsed = now
+	for arg.Seq > order.next {
+		if _, ok := order.waiters[arg.Seq]; ok {
+			// a copy of a record already waiting, which the replay checks
+			// drop if signed
+			break
+		}
+		ready := make(chan struct{})
+		order.waiters[arg.Seq] = ready
+		tracingServer.orderLock.Unlock()
+		timer := time.NewTimer(reorderTimeout)
+		select {
+		case <-ready:
+		case <-timer.C:
+		}
+		timer.Stop()
+		tracingServer.orderLock.Lock()
+		if order.waiters[arg.Seq] == ready {
+			// timed out: the records before arg are missing, unless some
+			// still wait, which go first
+			delete(order.waiters, arg.Seq)
+			if !order.waitingBefore(arg.Seq) {
+				order.next = arg.Seq
+			}
+		}
+	}
+	return func(err error) {
+		if isSlowDown(err) {
+			return
+		}
+		tracingServer.orderLock.Lock()
+		defer tracingServer.orderLock.Unlock()
+		order.lastUsed = time.Now()
+		if arg.Seq < order.next {
+			return
+		}
+		order.next = arg.Seq + 1
+		if ready, ok := order.waiters[order.next]; ok {
+			delete(order.waiters, order.next)
+			close(ready)
+		}
+	}
+}
+
+// forgetIdleOrders forgets the order of the sessions whose records stopped
+// arriving orderIdleTimeout ago, for the orders of the sessions of closed
+// tracers not to accumulate. The caller must hold orderLock.
+func (tracingServer *TracingServer) forgetIdleOrders(now time.Time) {
+	for key, order := range tracingServer.orders {
+		if len(order.waiters) == 0 && now.Sub(order.lastUsed) >= orderIdleTimeout {
+			delete(tracingServer.orders, key)
+		}
+	}
+	tracingServer.ordersSwept = now
+}
+
+// waitingBefore returns whether a record numbered before seq waits for its
+// turn. The caller must hold orderLock.
+func (order *sessionOrder) waitingBefore(seq uint64) bool {
+	for waiting := range order.waiters {
+		if waiting < seq {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,19 @@
 package tracing
 
 import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/rpc"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/DistributedClocks/GoVector/govec/vclock"
 )
@@ -16,32 +21,316 @@ import (
 // TracingServerConfig contains the necessary configuration options for a
 // tracing server.
 type TracingServerConfig struct {
-	ServerBind       string // the ip:port pair to which the server should bind, as one might pass to net.Listen
-	Secret           []byte
-	OutputFile       string // the output filename, where the tracing records JSON will be written
-	ShivizOutputFile string // the shiviz-compatible output filename
+	ServerBind string // the ip:port pair to which the server should bind, as one might pass to net.Listen, or unix:///path/to/socket
+
+	// OutputFile, if set, is the output filename, where the tracing records
+	// JSON will be written: "-" or "stdout://" for the standard output, and
+	// "stderr://" for the standard error.
+	OutputFile       string
+	ShivizOutputFile string // the shiviz-compatible output filename, if set, or standard stream as for OutputFile
+	OutputFormat     string // the format of the output file: "json" (the default), one JSON object per line, or "binary", see BinaryRecordReader
+
+	// AppendOutput, if set, appends to existing output files instead of
+	// truncating them, and resumes from the last vector clocks they record.
+	AppendOutput bool
+
+	// IndexOutput, if set, also writes an index of each output file, named
+	// after it with ".idx" appended, locating the records of each trace and
+	// tracer in it, see ReadIndex.
+	IndexOutput bool
+
+	// EncryptionKey, if set, is the 16, 24 or 32-byte AES key the output
+	// file is encrypted with, as the traces may hold personal data, see
+	// NewDecryptingReader. The other output files are not encrypted, and
+	// AppendOutput, IndexOutput and WALFile are not supported.
+	EncryptionKey []byte
+
+	// SigningKey, if set, also writes the signatures of each output file,
+	// named after it with ".sig" appended: a chain of HMAC-SHA256s of its
+	// records keyed with SigningKey, for graders to detect edits of the
+	// file, see VerifySignatures.
+	SigningKey []byte
+
+	// OutputPartitions, if greater than 1, is the number of files the
+	// records are partitioned into by trace ID, named after OutputFile
+	// (trace_output.log is partitioned into trace_output.0.log,
+	// trace_output.1.log, ...). Standard streams are not partitioned.
+	OutputPartitions int
+
+	UDPBind       string // optional ip:port pair on which the server also accepts records sent over UDP
+	WebSocketBind string // optional ip:port pair on which the server also serves its RPCs to WebSocket clients, as JSON-RPC
+	GRPCBind      string // optional ip:port pair on which the server also serves its RPCs as a gRPC service
+	HTTPBind      string // optional ip:port pair on which the server also serves its RPCs over HTTP, and accepts records POSTed as JSON to /record
+	ProtobufBind  string // optional ip:port pair on which the server also serves its RPCs as length-delimited protobuf messages, see tracingpb.Request
+
+	// TLSCertFile and TLSKeyFile, if set, are the PEM-encoded certificate
+	// and key the server's Listener serves TLS with. If ClientCAFile is set
+	// too, tracers must present a certificate signed by one of the CAs it
+	// contains, and can only record actions under the identity in its
+	// subject common name. Client authentication is only supported on
+	// ServerBind, so it precludes the other binds and Transports.
+	//
+	// If TLSBind is set, the server serves TLS on its TLSListener, bound to
+	// TLSBind, instead, alongside the plaintext Listener, e.g. for local
+	// testing; with ClientCAFile, ServerBind must then be a loopback address
+	// or a Unix domain socket, as it lets unauthenticated tracers in.
+	TLSCertFile  string
+	TLSKeyFile   string
+	ClientCAFile string
+	TLSBind      string
+
+	// Secret, if set, is the key tracers must sign their requests with, see
+	// TracerConfig.Secret, and prove they know when connecting to
+	// ServerBind, see serverHandshake. RecordAction is then rejected over
+	// gRPC and framed protobuf, which cannot carry a MAC, and the records
+	// replayed are dropped, see checkReplay.
+	Secret []byte
+
+	// Identities, if set, registers the identities of tracers, with the
+	// key each must use as its TracerConfig.Secret (base64-encoded in
+	// configuration files), so that a tracer cannot record actions under,
+	// or resume from the clock of, the identity of another. The requests
+	// of the identities not registered are checked against Secret, and
+	// rejected if it is not set.
+	Identities map[string][]byte
+
+	// IdentityRateLimit and ConnectionRateLimit, if positive, limit the
+	// rate of the records of each tracer identity, and of each connection
+	// to the Listener (or TLSListener), in records per second, with bursts
+	// of up to RateLimitBurst records (a second's worth by default), so
+	// that a runaway tracer cannot starve the others. An identity past its
+	// limit has its records rejected for BanDuration (a minute by default,
+	// in nanoseconds in configuration files); a connection past its limit
+	// is closed, and the connections of its host refused for BanDuration.
+	// Relays forward the records of many tracers over one connection.
+	IdentityRateLimit   float64
+	ConnectionRateLimit float64
+	RateLimitBurst      int
+	BanDuration         time.Duration
+
+	// MaxRecordSize and MaxRecordFields, if positive, limit the size of
+	// the bodies of records, in bytes once decompressed, and the number of
+	// fields of their JSON objects, nested ones included, so that a tracer
+	// serializing, e.g., its whole state cannot blow up the memory of the
+	// server, or its output files. The records past the limits are
+	// rejected, or, if TruncateRecords is set, replaced with a notice, a
+	// TruncatedRecord, so that their trace and vector clock are recorded.
+	// Records are still received in full, up to the limits of their
+	// transport.
+	MaxRecordSize   int
+	MaxRecordFields int
+	TruncateRecords bool
+
+	// MaxQueuedRecords, if positive, makes the server reject the records
+	// it receives with ErrSlowDown while more records than this are queued
+	// for writing, rather than letting the latency of RecordAction grow
+	// unnoticed, which would distort the timing of the tracers. Tracers
+	// told to slow down send the record again, and pace the records they
+	// send until the server keeps up.
+	MaxQueuedRecords int
+
+	// AuditLogFile, if set, is appended the audit log of the connections to
+	// the Listener (or TLSListener), to investigate suspected forgeries or
+	// misattributed records: one AuditEntry per line, in JSON, when a
+	// tracer connects, or is refused, and when it disconnects, with the
+	// identity it authenticated as and the records it sent by identity.
+	// The other binds and Transports are not audited.
+	AuditLogFile string
+
+	// UpstreamAddress, if set, puts the server in relay mode: instead of
+	// writing them to its output files, the server forwards the records it
+	// receives to the tracing server at this address (in the format of
+	// TracerConfig.ServerAddress), in batches. Up to RelayBufferSize
+	// records (100000 by default) are buffered while the upstream server
+	// is unreachable.
+	// A relay with a Secret, or Identities, signs the records it forwards,
+	// and its requests of vector clocks, with the key of their tracer, as
+	// upstream servers check them against theirs, and connects with the
+	// Secret.
+	UpstreamAddress string
+	RelayBufferSize int
+
+	// StateFile, if set, is where the last vector clock recorded for each
+	// tracer is saved, every StateSaveInterval (10s by default, in
+	// nanoseconds in configuration files) and on Close, and loaded from on
+	// Open. Tracers rejoining after a server restart then resume from their
+	// last clocks, as they would without the restart.
+	StateFile         string
+	StateSaveInterval time.Duration
+
+	// SyncEvery and SyncInterval set when the output files (and the other
+	// sinks) are synced to stable storage: after every SyncEvery records
+	// (1 syncs every record before acknowledging it), and every
+	// SyncInterval (in nanoseconds in configuration files). They trade
+	// throughput for durability: by default, the records are only synced
+	// when the operating system decides to, so a machine crash can lose an
+	// arbitrary tail of them.
+	SyncEvery    int
+	SyncInterval time.Duration
+
+	// FlushEvery and FlushInterval, if either is set, buffer the writes to
+	// the output files (each partition, if partitioned), rather than
+	// writing each record to them as it is received, to save system calls
+	// under load: the records buffered are written after every FlushEvery
+	// records, every FlushInterval (in nanoseconds in configuration files),
+	// when the buffer fills up, when synced (see SyncEvery), and on Close
+	// or Flush. The records still buffered are lost if the server crashes.
+	FlushEvery    int
+	FlushInterval time.Duration
+
+	// WALFile, if set, is the write-ahead log the records are synced to
+	// before being written to the output files. On Open, the records a
+	// crash left partially written (or not written at all) are recovered
+	// from it, instead of leaving a corrupt tail in the output files. It
+	// implies AppendOutput. The sinks in Sinks may receive the recovered
+	// records again.
+	WALFile string
+
+	// RotateSize, if set, is the size in bytes past which the output file
+	// (each partition, if partitioned) is rotated: renamed after the time
+	// of the rotation (trace_output.log to
+	// trace_output.20060102T150405.000000000Z.log), and started anew.
+	// The rotated files older than RetentionAge (in nanoseconds in
+	// configuration files), and the oldest ones past a total size of
+	// RetentionSize bytes, are pruned in the background. AppendOutput only
+	// resumes from the clocks in the current files, StateFile also keeps
+	// those of the tracers idle since the last rotation.
+	RotateSize    int64
+	RetentionAge  time.Duration
+	RetentionSize int64
+
+	// GoVectorLogDir, if set, is the directory where a GoVector log is
+	// also written for each tracer, as InitGoVector would have written it
+	// (client1-Log.txt for client1), for the tools consuming them. Unlike
+	// InitGoVector's, the logs have no "Initialization Complete" event:
+	// the clock of each tracer starts with its first record.
+	GoVectorLogDir string
+
+	// ObjectStore, if set, is the bucket the records are also uploaded to,
+	// in segments. With no OutputFile and ShivizOutputFile, the server then
+	// does not depend on local disk.
+	ObjectStore *ObjectStoreConfig
+
+	// Checkers are the names of the checkers (see RegisterChecker) the
+	// records are fed to as they are written, e.g. "causality" or
+	// "statemachine:assignment2.json" once
+	// github.com/DistributedClocks/tracing/check is imported, to catch
+	// anomalies during the run. The violations they find are logged, and
+	// written to CheckReportFile, if set, one JSON object per line. Relays
+	// do not check records, their upstream server does.
+	Checkers        []string
+	CheckReportFile string
+
+	// ViolationRecords, if set, also writes the violations the checkers
+	// find as records, in the trace of the record revealing them, and
+	// after it, tagged Violation, of the tracer ViolationTracer, so that
+	// they show among the actions of the trace, e.g. in ShiViz. Those found
+	// at the end of the run are not written.
+	ViolationRecords bool
+
+	// TailBind, if set, is the ip:port pair on which the server streams the
+	// records it writes to HTTP clients as they arrive, as server-sent
+	// events, or over WebSocket, filtered by tracer, tag or trace, see
+	// cmd/trace-tail. Clients that do not keep up miss records, rather than
+	// slowing down the server. Relays do not stream records, their upstream
+	// server does.
+	TailBind string
+
+	// DashboardBind, if set, is the ip:port pair on which the server serves
+	// a web dashboard, to monitor a run: the tracers active in the last
+	// minute, the rate of records, and the recent traces, with their
+	// records. Relays do not serve it, their upstream server does.
+	DashboardBind string
+
+	// QueryBind, if set, is the ip:port pair on which the server serves a
+	// read-only HTTP API querying the records it wrote, in JSON:
+	// /tracers, /traces, /traces/ID, /records?tracer=...&tag=...&trace=...,
+	// /search?q=...&field=..., which searches the bodies for words, and
+	// /shiviz?trace=..., the ShiViz log of some traces.
+	// It queries the records kept InMemory, if set, and the current output
+	// files otherwise, which must not be encrypted, so it requires one of
+	// them. Searches use the index of a sink that is a RecordSearcher
+	// instead, e.g. sqlite.Store.
+	QueryBind string
+
+	// InMemory, if set, keeps the records in memory, where Records and
+	// RecordsForTrace query them, e.g. for tests to assert on the traces
+	// without any output file.
+	InMemory bool
+
+	// Transports are custom transports the server also listens on.
+	Transports []TransportBinding `json:"-"`
+
+	// Sinks are where the records are also written, besides the output
+	// files. They are closed along with the server. Relays do not write
+	// records, their upstream server does.
+	Sinks []Sink `json:"-"`
 }
 
 // TracingServer should be used with rpc.Register, as an RPC target.
 type TracingServer struct {
-	Listener         net.Listener
-	acceptDone       chan struct{}
-	rpcServer        *rpc.Server
-	recordFile       *os.File
-	recordEncoder    *json.Encoder
-	Config           *TracingServerConfig
-	shivizRecordFile *os.File
-	shivizLogger     *shivizLogger
+	Listener    net.Listener
+	TLSListener net.Listener // if TLSBind is set
+	acceptDone  chan struct{}
+	rpcServer   *rpc.Server
+	Config      *TracingServerConfig
+	limiter     *rateLimiter
+	audit       *auditLog // if AuditLogFile is set
+
+	// recordLock serializes the writes of records to the sinks, numbered
+	// by recordSeq, unsynced of which have not been flushed yet
+	recordLock sync.Mutex
+	recordSeq  uint64
+	unsynced   int
+	sinks      []*serverSink
+	queue      *recordQueue // of the records to write, once open
+	syncer     *syncer
+	flusher    *syncer // flushes the buffers of the output files, every FlushInterval
+	walFile    *os.File
+	sessions   map[uint64]*tracerSession // the tracer instances whose records were written
+
+	// replayLock guards the last sequence number accepted for each session
+	// of signed records, and the replayed records dropped, by identity
+	replayLock sync.Mutex
+	signedSeqs map[signedSession]uint64
+	replayed   map[string]uint64
+
+	// orderLock guards the order of the records of each session, see
+	// awaitTurn, and when the idle sessions were last forgotten
+	orderLock   sync.Mutex
+	orders      map[signedSession]*sessionOrder
+	ordersSwept time.Time
 
-	lock    sync.RWMutex
-	lastVCs map[string]vclock.VClock
+	slowDowns uint32 // the records rejected with ErrSlowDown, atomically
+
+	// recordSinks write to the output file, or its partitions, and are
+	// rotated past RotateSize
+	recordSinks partitionedSink
+	pruner      *pruner
+
+	memoryStore *memoryStore
+	tailSink    *tailSink
+	tailServer  *httpServer
+
+	dashboardSink *dashboardSink
+	checkerSink   *checkerSink
+
+	lastVCs    *clockMap // the last vector clock recorded for each tracer
+	stateSaver *stateSaver
+
+	// listeners are the closers of the transports the server listens on,
+	// besides Listener
+	listeners   []io.Closer
+	udpListener *udpListener
+
+	relay *relay
 }
 
 // RPCProvider is an abstraction to prevent registering non-RPC functions
 // in the RPC server. RPCProvider should be used with rpc.Register, as an
 // RPC target.
 type RPCProvider struct {
-	server *TracingServer
+	handler TransportHandler
 }
 
 // NewTracingServerFromFile instantiates a new tracing server from a configuration file.
@@ -73,63 +362,433 @@ func NewTracingServer(config TracingServerConfig) *TracingServer {
 	tracingServer := &TracingServer{
 		acceptDone: make(chan struct{}),
 		Config:     &config,
-		lastVCs:    make(map[string]vclock.VClock),
+		lastVCs:    newClockMap(),
+		sessions:   make(map[uint64]*tracerSession),
+		signedSeqs: make(map[signedSession]uint64),
+		replayed:   make(map[string]uint64),
+		orders:     make(map[signedSession]*sessionOrder),
 	}
+	tracingServer.limiter = newRateLimiter(tracingServer.Config)
 	return tracingServer
 }
 
 // Open creates the related files for the tracing server and starts an RPC server
 // on the specified address.
 func (tracingServer *TracingServer) Open() error {
-	if tracingServer.recordFile == nil {
-		recordFile, err := os.Create(tracingServer.Config.OutputFile)
-		if err != nil {
+	tlsConfig, err := tracingServer.Config.serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil && tracingServer.Config.TLSBind != "" {
+		return errTLSBind
+	}
+	if tlsConfig != nil && tlsConfig.ClientCAs != nil && len(tracingServer.transportBindings()) > 0 {
+		return errTLSBinds
+	}
+	if tlsConfig != nil && tlsConfig.ClientCAs != nil && tracingServer.Config.TLSBind != "" && !isLoopbackAddress(tracingServer.Config.ServerBind) {
+		return errTLSServerBind
+	}
+
+	if tracingServer.Config.QueryBind != "" && (tracingServer.Config.UpstreamAddress != "" || !tracingServer.canQuery()) {
+		return errQueryStorage
+	}
+
+	if tracingServer.Config.StateFile != "" {
+		if err := tracingServer.loadState(); err != nil {
 			return err
 		}
-		tracingServer.recordFile = recordFile
-		tracingServer.recordEncoder = json.NewEncoder(recordFile)
+		tracingServer.startStateSaver(tracingServer.Config.StateSaveInterval)
 	}
-	if tracingServer.shivizRecordFile == nil {
-		shivizRecordFile, err := os.Create(tracingServer.Config.ShivizOutputFile)
-		if err != nil {
+
+	if tracingServer.Config.UpstreamAddress != "" {
+		tracingServer.relay = newRelay(tracingServer.Config.UpstreamAddress, tracingServer.Config.RelayBufferSize, tracingServer.credentials())
+		// unused, but closed along with the server
+		tracingServer.sinks = newServerSinks(nil, tracingServer.Config.Sinks)
+	} else {
+		if err := tracingServer.openOutputFiles(); err != nil {
 			return err
 		}
-		shivizLogger, err := newShivizLogger(shivizRecordFile)
-		if err != nil {
-			return err
+		if tracingServer.Config.SyncInterval > 0 {
+			tracingServer.startSyncer(tracingServer.Config.SyncInterval)
+		}
+		if tracingServer.Config.FlushInterval > 0 {
+			tracingServer.startFlusher(tracingServer.Config.FlushInterval)
+		}
+		tracingServer.startWriter()
+		if tracingServer.Config.RetentionAge > 0 || tracingServer.Config.RetentionSize > 0 {
+			tracingServer.startPruner()
 		}
-		tracingServer.shivizRecordFile = shivizRecordFile
-		tracingServer.shivizLogger = shivizLogger
 	}
 
-	tracingServer.rpcServer = rpc.NewServer()
-	rpcProvider := &RPCProvider{server: tracingServer}
-	err := tracingServer.rpcServer.Register(rpcProvider)
+	rpcServer, err := newRPCServer(transportHandler{server: tracingServer})
 	if err != nil {
 		return err
 	}
+	tracingServer.rpcServer = rpcServer
+
+	if tracingServer.Config.AuditLogFile != "" {
+		if tracingServer.audit, err = openAuditLog(tracingServer.Config.AuditLogFile); err != nil {
+			return err
+		}
+	}
 
-	listener, err := net.Listen("tcp", tracingServer.Config.ServerBind)
+	listener, err := net.Listen(parseAddress(tracingServer.Config.ServerBind))
 	if err != nil {
 		return err
 	}
+	if tlsConfig != nil && tracingServer.Config.TLSBind == "" {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 	tracingServer.Listener = listener
+	if tlsConfig != nil && tracingServer.Config.TLSBind != "" {
+		if err := tracingServer.listenTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range tracingServer.transportBindings() {
+		_, unordered := binding.Transport.(udpTransport)
+		closer, err := binding.Transport.Listen(binding.Address, transportHandler{server: tracingServer, unordered: unordered})
+		if err != nil {
+			return err
+		}
+		tracingServer.listeners = append(tracingServer.listeners, closer)
+		if udpListener, ok := closer.(*udpListener); ok {
+			tracingServer.udpListener = udpListener
+		}
+	}
+	if tracingServer.tailSink != nil {
+		listener, err := net.Listen("tcp", tracingServer.Config.TailBind)
+		if err != nil {
+			return err
+		}
+		tracingServer.tailServer = serveHTTP(listener, tracingServer.tailSink, true)
+	}
+	if tracingServer.dashboardSink != nil {
+		listener, err := net.Listen("tcp", tracingServer.Config.DashboardBind)
+		if err != nil {
+			return err
+		}
+		tracingServer.listeners = append(tracingServer.listeners, serveHTTP(listener, tracingServer.dashboardSink, true))
+	}
+	if tracingServer.Config.QueryBind != "" {
+		listener, err := net.Listen("tcp", tracingServer.Config.QueryBind)
+		if err != nil {
+			return err
+		}
+		tracingServer.listeners = append(tracingServer.listeners, serveHTTP(listener, queryAPI{server: tracingServer}, true))
+	}
 
 	return nil
 }
 
+func (tracingServer *TracingServer) openOutputFiles() error {
+	var walHeader *walHeader
+	var walEntries []walEntry
+	if tracingServer.Config.WALFile != "" {
+		var err error
+		if walHeader, walEntries, err = tracingServer.recoverWAL(); err != nil {
+			return err
+		}
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if tracingServer.Config.AppendOutput || tracingServer.Config.WALFile != "" {
+		flag = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	}
+	// the built-in sinks are named after their configuration field
+	var sinks []*serverSink
+	if tracingServer.Config.OutputFile != "" {
+		recordSink, err := tracingServer.openRecordSink(flag)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, &serverSink{Sink: recordSink, name: "OutputFile"})
+	}
+	if tracingServer.Config.ShivizOutputFile != "" {
+		shivizSink, err := tracingServer.openShivizSink(flag)
+		if err != nil {
+			for _, sink := range sinks {
+				sink.Close()
+			}
+			return err
+		}
+		sinks = append(sinks, &serverSink{Sink: shivizSink, name: "ShivizOutputFile"})
+	}
+	if tracingServer.Config.GoVectorLogDir != "" {
+		goVectorSink, err := newGoVectorSink(tracingServer.Config.GoVectorLogDir, flag)
+		if err != nil {
+			for _, sink := range sinks {
+				sink.Close()
+			}
+			return err
+		}
+		sinks = append(sinks, &serverSink{Sink: goVectorSink, name: "GoVectorLogDir"})
+	}
+	if tracingServer.Config.ObjectStore != nil {
+		sinks = append(sinks, &serverSink{Sink: newObjectStoreSink(*tracingServer.Config.ObjectStore), name: "ObjectStore"})
+	}
+	if tracingServer.Config.InMemory {
+		tracingServer.memoryStore = newMemoryStore()
+		sinks = append(sinks, &serverSink{Sink: tracingServer.memoryStore, name: "InMemory"})
+	}
+	if tracingServer.Config.TailBind != "" {
+		tracingServer.tailSink = newTailSink()
+		sinks = append(sinks, &serverSink{Sink: tracingServer.tailSink, name: "TailBind"})
+	}
+	if tracingServer.Config.DashboardBind != "" {
+		tracingServer.dashboardSink = newDashboardSink()
+		sinks = append(sinks, &serverSink{Sink: tracingServer.dashboardSink, name: "DashboardBind"})
+	}
+	if len(tracingServer.Config.Checkers) > 0 {
+		checkerSink, err := newCheckerSink(tracingServer.Config.Checkers, tracingServer.Config.CheckReportFile, flag)
+		if err != nil {
+			for _, sink := range sinks {
+				sink.Close()
+			}
+			return err
+		}
+		checkerSink.records = tracingServer.Config.ViolationRecords
+		tracingServer.checkerSink = checkerSink
+		sinks = append(sinks, &serverSink{Sink: checkerSink, name: "Checkers"})
+	}
+	tracingServer.sinks = newServerSinks(sinks, tracingServer.Config.Sinks)
+
+	if tracingServer.Config.WALFile != "" {
+		return tracingServer.openWAL(walHeader, walEntries)
+	}
+	return nil
+}
+
+// openShivizSink opens the ShiViz output file.
+func (tracingServer *TracingServer) openShivizSink(flag int) (*shivizSink, error) {
+	if stream := outputStream(tracingServer.Config.ShivizOutputFile); stream != nil {
+		shivizLogger, err := newShivizLogger(stream)
+		if err != nil {
+			return nil, err
+		}
+		return &shivizSink{file: stream, logger: shivizLogger}, nil
+	}
+	shivizRecordFile, err := os.OpenFile(tracingServer.Config.ShivizOutputFile, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	info, err := shivizRecordFile.Stat()
+	if err != nil {
+		shivizRecordFile.Close()
+		return nil, err
+	}
+	shivizLogger := &shivizLogger{w: shivizRecordFile}
+	if info.Size() == 0 {
+		// the header only starts the log, not each appended run
+		shivizLogger, err = newShivizLogger(shivizRecordFile)
+		if err != nil {
+			shivizRecordFile.Close()
+			return nil, err
+		}
+	}
+	return &shivizSink{file: shivizRecordFile, logger: shivizLogger}, nil
+}
+
+// recordFileNames returns the names of the output file, or of its
+// partitions if OutputPartitions is set.
+func (tracingServer *TracingServer) recordFileNames() []string {
+	n := tracingServer.Config.OutputPartitions
+	if tracingServer.Config.OutputFile == "" {
+		return nil
+	} else if n <= 1 || outputStream(tracingServer.Config.OutputFile) != nil {
+		return []string{tracingServer.Config.OutputFile}
+	}
+	fileNames := make([]string, n)
+	for i := range fileNames {
+		fileNames[i] = partitionFileName(tracingServer.Config.OutputFile, i)
+	}
+	return fileNames
+}
+
+// openRecordSink opens the output file, or its partitions if
+// OutputPartitions is set, resuming them in append mode.
+func (tracingServer *TracingServer) openRecordSink(flag int) (Sink, error) {
+	var binary bool
+	switch tracingServer.Config.OutputFormat {
+	case "", OutputFormatJSON:
+	case OutputFormatBinary:
+		binary = true
+	default:
+		return nil, errOutputFormat
+	}
+	var aead cipher.AEAD
+	if key := tracingServer.Config.EncryptionKey; len(key) > 0 {
+		if flag&os.O_APPEND != 0 || tracingServer.Config.IndexOutput {
+			return nil, errEncryptedOutput
+		}
+		var err error
+		if aead, err = newOutputCipher(key); err != nil {
+			return nil, err
+		}
+	}
+	var sinks partitionedSink
+	lastVCs := make(map[string]vclock.VClock)
+	for _, fileName := range tracingServer.recordFileNames() {
+		sink, err := tracingServer.openRecordFile(fileName, flag, binary, aead, lastVCs)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		if tracingServer.Config.FlushEvery > 0 || tracingServer.Config.FlushInterval > 0 {
+			sink.bufferWrites(tracingServer.Config.FlushEvery)
+		}
+		sinks = append(sinks, sink)
+	}
+	tracingServer.recordSinks = sinks
+
+	// the vector clocks of the output files are at least as recent as
+	// those saved to the StateFile, if any
+	for identity, vc := range lastVCs {
+		tracingServer.lastVCs.store(identity, vc)
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
+
+// openRecordFile opens the output file (or partition) fileName, its index
+// if IndexOutput is set, and its signatures if SigningKey is set, resuming
+// them in append mode.
+func (tracingServer *TracingServer) openRecordFile(fileName string, flag int, binary bool, aead cipher.AEAD, lastVCs map[string]vclock.VClock) (*recordFileSink, error) {
+	if stream := outputStream(fileName); stream != nil {
+		return newRecordFileSink(stream, binary, aead)
+	}
+	recordFile, err := os.OpenFile(fileName, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	var index *indexWriter
+	if tracingServer.Config.IndexOutput {
+		// the index is rebuilt when resuming, it may lag behind the output
+		// file after a crash
+		if index, err = createIndex(indexFileName(fileName)); err != nil {
+			recordFile.Close()
+			return nil, err
+		}
+	}
+	var signatures *signatureWriter
+	if key := tracingServer.Config.SigningKey; len(key) > 0 {
+		if signatures, err = openSignatures(signatureFileName(fileName), key, flag); err != nil {
+			recordFile.Close()
+			if index != nil {
+				index.Close()
+			}
+			return nil, err
+		}
+	}
+	if flag&os.O_APPEND != 0 {
+		err = tracingServer.resumeRecordFile(recordFile, lastVCs, index)
+	}
+	var sink *recordFileSink
+	if err == nil {
+		sink, err = newRecordFileSink(recordFile, binary, aead)
+	}
+	if err != nil {
+		recordFile.Close()
+		if index != nil {
+			index.Close()
+		}
+		if signatures != nil {
+			signatures.Close()
+		}
+		return nil, err
+	}
+	sink.index = index
+	sink.signatures = signatures
+	return sink, nil
+}
+
+// resumeRecordFile adds the last vector clocks recorded in recordFile, an
+// output file being appended to, to lastVCs, where the latest clock of each
+// tracer (the one with the greatest entry of its own) is kept. The records
+// appended are numbered after the existing ones. A record only partially
+// written when a previous run crashed is truncated, so that appended
+// records start on a line of their own. The records are indexed in index,
+// if not nil.
+func (tracingServer *TracingServer) resumeRecordFile(recordFile *os.File, lastVCs map[string]vclock.VClock, index *indexWriter) error {
+	if tracingServer.Config.OutputFormat == OutputFormatBinary {
+		return tracingServer.resumeBinaryRecordFile(recordFile, lastVCs, index)
+	}
+	reader := bufio.NewReader(recordFile)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("truncating the partial record at the end of %s", recordFile.Name())
+				if err := recordFile.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		start := offset
+		offset += int64(len(line))
+		var record TraceRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+		if _, ok := outputHeaderOf(record); ok {
+			continue
+		}
+		if index != nil {
+			if err := index.write(record, start); err != nil {
+				return err
+			}
+		}
+		identity := record.TracerIdentity
+		if last, ok := lastVCs[identity]; !ok || record.VectorClock[identity] >= last[identity] {
+			lastVCs[identity] = record.VectorClock
+		}
+		tracingServer.recordSeq++
+	}
+}
+
+// transportBindings returns the transports the server listens on besides
+// Listener: the built-in ones enabled in the configuration, then the custom
+// ones.
+func (tracingServer *TracingServer) transportBindings() []TransportBinding {
+	var bindings []TransportBinding
+	for _, builtin := range []struct {
+		transport Transport
+		address   string
+	}{
+		{grpcTransport{}, tracingServer.Config.GRPCBind},
+		{wsTransport{}, tracingServer.Config.WebSocketBind},
+		{httpTransport{}, tracingServer.Config.HTTPBind},
+		{protobufTransport{}, tracingServer.Config.ProtobufBind},
+		{udpTransport{}, tracingServer.Config.UDPBind},
+	} {
+		if builtin.address != "" {
+			bindings = append(bindings, TransportBinding{Transport: builtin.transport, Address: builtin.address})
+		}
+	}
+	return append(bindings, tracingServer.Config.Transports...)
+}
+
 // Accept accepts connections on the listener and serves requests for each incoming
 // connection. Accept blocks until the listener returns a non-nil error.
 // This implementation matches exactly the implementation of `rpc.Accept` from
 // https://golang.org/src/net/rpc/server.go?s=18334:18380#L613,
-// except it does not log the listner.Accept error.
+// except it does not log the listner.Accept error, and binds the tracers
+// authenticated with a client certificate to their identity (see serveConn).
 func (tracingServer *TracingServer) Accept() {
 	for {
 		conn, err := tracingServer.Listener.Accept()
 		if err != nil {
 			break
 		}
-		go tracingServer.rpcServer.ServeConn(conn)
+		go tracingServer.serveConn(conn)
 	}
 	tracingServer.acceptDone <- struct{}{}
 }
@@ -141,18 +800,75 @@ func (tracingServer *TracingServer) Close() error {
 	}
 	<-tracingServer.acceptDone
 
-	// close the output files, once the request loop is fully complete
-	if err := tracingServer.recordFile.Close(); err != nil {
+	for _, listener := range tracingServer.listeners {
+		if err := listener.Close(); err != nil {
+			return err
+		}
+	}
+	tracingServer.listeners = nil
+	if tracingServer.audit != nil {
+		// the connections still open are not audited anymore
+		if err := tracingServer.audit.Close(); err != nil {
+			return err
+		}
+	}
+	// the records received from now on are rejected
+	tracingServer.stopWriter()
+
+	if err := tracingServer.stopStateSaver(); err != nil {
 		return err
 	}
-	tracingServer.recordFile = nil
 
-	if err := tracingServer.shivizRecordFile.Close(); err != nil {
+	tracingServer.logMissingRecords()
+	tracingServer.logReplayedRecords()
+	tracingServer.logSlowDowns()
+
+	if tracingServer.relay != nil {
+		// forward the records still buffered
+		err := tracingServer.relay.close()
+		tracingServer.relay = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	tracingServer.stopPruner()
+	tracingServer.stopSyncer()
+	tracingServer.stopFlusher()
+	if tracingServer.Config.SyncEvery > 0 || tracingServer.Config.SyncInterval > 0 || tracingServer.walFile != nil {
+		tracingServer.recordLock.Lock()
+		err := tracingServer.flushSinks()
+		tracingServer.recordLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tracingServer.closeWAL(); err != nil {
 		return err
 	}
-	tracingServer.shivizRecordFile = nil
 
-	return nil
+	err := tracingServer.closeSinks()
+	if tracingServer.tailServer != nil {
+		// closing the sinks ended the streams, once they send the records
+		// left
+		tracingServer.tailServer.closeWithin(tailCloseTimeout)
+		tracingServer.tailServer = nil
+	}
+	return err
+}
+
+// closeSinks closes the sinks, once the request loop is fully complete. A
+// sink failing to close does not keep the others open.
+func (tracingServer *TracingServer) closeSinks() error {
+	sinks := tracingServer.sinks
+	tracingServer.sinks = nil
+	var err error
+	for _, sink := range sinks {
+		if closeErr := sink.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // RecordActionArg indicates RecordAction RPC argument.
@@ -162,6 +878,22 @@ type RecordActionArg struct {
 	RecordName     string
 	Record         []byte
 	VectorClock    vclock.VClock
+	Compression    string `json:",omitempty"` // the algorithm Record is compressed with, if any
+
+	// Session identifies the tracer instance, and Seq numbers the records
+	// it sent, counting from 1, for the server to detect missing records,
+	// e.g. lost over UDP, see MissingRecords. Records with no Seq are not
+	// accounted for.
+	Session uint64 `json:",omitempty"`
+	Seq     uint64 `json:",omitempty"`
+	// Striped is set by tracers sending their records over several
+	// connections (see TracerConfig.Connections), for the server to write
+	// them in the order of Seq nonetheless, see awaitTurn.
+	Striped bool `json:",omitempty"`
+
+	// MAC is the HMAC-SHA256 of the other fields, keyed with the tracer's
+	// Secret, which servers with a Secret check.
+	MAC []byte `json:",omitempty"`
 }
 
 // RecordActionResult indicates RecordActionRPC output.
@@ -174,6 +906,8 @@ type TraceRecord struct {
 	Tag            string
 	Body           json.RawMessage
 	VectorClock    vclock.VClock
+	TracerSeq      uint64 `json:",omitempty"` // the number of the record among those its tracer instance sent, counting from 1, if numbered
+	Seq            uint64 `json:"-"`          // the number of the record among those the server wrote, counting from 1
 }
 
 // RecordAction writes the Record field of the argument as a JSON-encoded record,
@@ -181,39 +915,196 @@ type TraceRecord struct {
 // It also tags the result with TracerIdentity, which tracks the identity given
 // to the tracer reporting the event.
 func (rp *RPCProvider) RecordAction(arg RecordActionArg, result *RecordActionResult) error {
+	return rp.handler.RecordAction(arg)
+}
+
+func (tracingServer *TracingServer) recordAction(arg RecordActionArg) error {
+	if err := tracingServer.decodeRecord(&arg); err != nil {
+		return err
+	}
 	wrappedRecord := TraceRecord{
 		TracerIdentity: arg.TracerIdentity,
 		TraceID:        arg.TraceID,
 		Tag:            arg.RecordName,
 		Body:           arg.Record,
 		VectorClock:    arg.VectorClock,
+		TracerSeq:      arg.Seq,
 	}
 
-	rp.server.lock.Lock()
-	rp.server.lastVCs[arg.TracerIdentity] = arg.VectorClock
-	rp.server.lock.Unlock()
+	tracingServer.lastVCs.store(arg.TracerIdentity, arg.VectorClock)
 
-	if err := rp.server.recordEncoder.Encode(wrappedRecord); err != nil {
+	if tracingServer.relay != nil {
+		tracingServer.relay.enqueue(arg)
+		return nil
+	}
+
+	if tracingServer.queue != nil {
+		return tracingServer.queue.write(arg, wrappedRecord)
+	}
+	tracingServer.recordLock.Lock()
+	defer tracingServer.recordLock.Unlock()
+	if err := tracingServer.writeRecord(wrappedRecord); err != nil {
 		return err
 	}
-	if err := rp.server.shivizLogger.log(wrappedRecord); err != nil {
+	tracingServer.accountRecord(arg)
+	return nil
+}
+
+// writeRecord numbers record, stages it in the write-ahead log if any, and
+// writes it to the sinks. The caller must hold recordLock.
+func (tracingServer *TracingServer) writeRecord(record TraceRecord) error {
+	tracingServer.recordSeq++
+	record.Seq = tracingServer.recordSeq
+	if tracingServer.walFile != nil {
+		if err := tracingServer.stageRecord(record); err != nil {
+			return err
+		}
+	}
+	// a failing sink does not fail the record, unless no sink is left to
+	// write it
+	var err error
+	written := 0
+	for _, sink := range tracingServer.sinks {
+		if writeErr := sink.Write(record); writeErr != nil {
+			sink.fail("writing a record", writeErr)
+			err = writeErr
+			continue
+		}
+		sink.succeed()
+		written++
+	}
+	if written == 0 && err != nil {
 		return err
 	}
+	if tracingServer.checkerSink != nil {
+		for _, violation := range tracingServer.checkerSink.takeRecords() {
+			if err := tracingServer.writeRecord(violation); err != nil {
+				return err
+			}
+		}
+	}
+	tracingServer.unsynced++
+	if tracingServer.Config.RotateSize > 0 {
+		if err := tracingServer.rotateRecordFiles(); err != nil {
+			return err
+		}
+	}
+	if every := tracingServer.Config.SyncEvery; every > 0 && tracingServer.unsynced >= every ||
+		tracingServer.walFile != nil && tracingServer.unsynced >= walCheckpointRecords {
+		return tracingServer.flushSinks()
+	}
+	return nil
+}
+
+// RecordActionsArg indicates RecordActions RPC argument.
+type RecordActionsArg []RecordActionArg
+
+// RecordActionsResult indicates RecordActions RPC output.
+type RecordActionsResult struct {
+	Recorded int    // the number of actions recorded, the first ones
+	Error    string // the error the action after them was rejected with, if any
+}
+
+// RecordActions records several actions at once, in order, as relaying
+// servers forward them, up to the first one rejected. Its error is returned
+// in the result, along with the number of actions recorded before it, as
+// net/rpc drops the results of calls returning an error, so that relays
+// only send the others again.
+func (rp *RPCProvider) RecordActions(arg RecordActionsArg, result *RecordActionsResult) error {
+	for i, action := range arg {
+		if err := rp.handler.RecordAction(action); err != nil {
+			*result = RecordActionsResult{Recorded: i, Error: err.Error()}
+			return nil
+		}
+	}
+	*result = RecordActionsResult{Recorded: len(arg)}
 	return nil
 }
 
+// PingArg indicates Ping RPC argument.
+type PingArg struct{}
+
+// PingResult indicates Ping RPC output.
+type PingResult struct{}
+
+// Ping does nothing; tracers call it periodically to detect dead
+// connections, see TracerConfig.HeartbeatInterval.
+func (rp *RPCProvider) Ping(arg PingArg, result *PingResult) error {
+	return nil
+}
+
+// Compressions returns the record compression algorithms the server
+// supports, see TracerConfig.Compression.
+func (rp *RPCProvider) Compressions(arg CompressionsArg, result *CompressionsResult) error {
+	*result = supportedCompressions()
+	return nil
+}
+
+// CompressionsArg indicates Compressions RPC argument.
+type CompressionsArg struct{}
+
+// CompressionsResult indicates Compressions RPC output.
+type CompressionsResult []string
+
+// transportHandler handles the requests of all transports on behalf of a
+// tracing server. Unless unordered, as over UDP, which loses and reorders
+// records anyway, the records of each tracer are handled in the order it
+// sent them.
+type transportHandler struct {
+	server    *TracingServer
+	unordered bool
+}
+
+func (h transportHandler) RecordAction(arg RecordActionArg) (err error) {
+	if err := h.server.authenticateRecord(arg); err != nil {
+		return err
+	}
+	if !h.unordered {
+		done := h.server.awaitTurn(arg)
+		defer func() { done(err) }()
+	}
+	if err := h.server.checkBackpressure(); err != nil {
+		return err
+	}
+	if err := h.server.limiter.allowRecord(arg.TracerIdentity); err != nil {
+		return err
+	}
+	if ok, err := h.server.checkReplay(arg); !ok {
+		return err
+	}
+	return h.server.recordAction(arg)
+}
+
+func (h transportHandler) GetLastVC(arg string) (vclock.VClock, error) {
+	identity, err := h.server.authenticateIdentity(arg)
+	if err != nil {
+		return nil, err
+	}
+	return h.server.getLastVC(identity)
+}
+
 type GetLastVCArg string
 
 type GetLastVCResult vclock.VClock
 
 func (rp *RPCProvider) GetLastVC(arg GetLastVCArg, result *GetLastVCResult) error {
-	rp.server.lock.RLock()
-	defer rp.server.lock.RUnlock()
-
-	vc, ok := rp.server.lastVCs[string(arg)]
-	if !ok {
-		return errors.New("not found")
+	vc, err := rp.handler.GetLastVC(string(arg))
+	if err != nil {
+		return err
 	}
 	*result = GetLastVCResult(vc)
 	return nil
 }
+
+func (tracingServer *TracingServer) getLastVC(identity string) (vclock.VClock, error) {
+	if vc, ok := tracingServer.lastVCs.load(identity); ok {
+		return vc, nil
+	}
+	if tracingServer.relay != nil {
+		// the tracer may have recorded actions through another relay
+		if vc, err := tracingServer.relay.getLastVC(identity); err == nil {
+			return vc, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
@@ -37,3 +37,24 @@ func (s *shivizLogger) log(tRecord TraceRecord) error {
 	}
 	return nil
 }
+
+// ShivizWriter writes records in the format of a server's ShivizOutputFile,
+// e.g. to regenerate it from the records of its OutputFile.
+type ShivizWriter struct {
+	logger *shivizLogger
+}
+
+// NewShivizWriter returns a writer of records to w, after writing the
+// header of the log.
+func NewShivizWriter(w io.Writer) (*ShivizWriter, error) {
+	logger, err := newShivizLogger(w)
+	if err != nil {
+		return nil, err
+	}
+	return &ShivizWriter{logger: logger}, nil
+}
+
+// Write writes record to the log.
+func (w *ShivizWriter) Write(record TraceRecord) error {
+	return w.logger.log(record)
+}
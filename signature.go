@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// signatureFileName returns the name of the signatures of the output file
+// fileName.
+func signatureFileName(fileName string) string {
+	return fileName + ".sig"
+}
+
+// chainSignature returns the signature of record, following the signature
+// previous in the chain: the HMAC-SHA256, keyed with key, of previous and
+// the fields of record. The body is signed as written in JSON output files,
+// compact and with HTML characters escaped, so that the signatures of a file
+// do not depend on its format.
+func chainSignature(key, previous []byte, record TraceRecord) []byte {
+	body, err := json.Marshal(record.Body)
+	if err != nil {
+		body = record.Body
+	}
+	mac := macWriter{hmac.New(sha256.New, key)}
+	mac.writeBytes(previous)
+	mac.writeBytes([]byte(record.TracerIdentity))
+	mac.writeUint(record.TraceID)
+	mac.writeBytes([]byte(record.Tag))
+	mac.writeBytes(body)
+	mac.writeVectorClock(record.VectorClock)
+	mac.writeUint(record.TracerSeq)
+	return mac.Sum(nil)
+}
+
+// signatureWriter writes the signatures of an output file, one per line,
+// hex-encoded, in the order of its records.
+type signatureWriter struct {
+	file *os.File
+	key  []byte
+	last []byte // the last signature of the chain
+}
+
+// openSignatures opens the signatures fileName of an output file, resuming
+// their chain from the last one in append mode.
+func openSignatures(fileName string, key []byte, flag int) (*signatureWriter, error) {
+	w := &signatureWriter{key: key}
+	if flag&os.O_APPEND != 0 {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if lines := bytes.Fields(data); len(lines) > 0 {
+			if w.last, err = hex.DecodeString(string(lines[len(lines)-1])); err != nil {
+				return nil, fmt.Errorf("corrupt signatures %s: %v", fileName, err)
+			}
+		}
+	}
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|flag&(os.O_APPEND|os.O_TRUNC), 0666)
+	if err != nil {
+		return nil, err
+	}
+	w.file = file
+	return w, nil
+}
+
+// write signs record, the next record of the output file.
+func (w *signatureWriter) write(record TraceRecord) error {
+	w.last = chainSignature(w.key, w.last, record)
+	_, err := fmt.Fprintf(w.file, "%x\n", w.last)
+	return err
+}
+
+func (w *signatureWriter) Close() error {
+	return w.file.Close()
+}
+
+// VerifySignatures checks the records of outputFile, in the given format
+// (see TracingServerConfig.OutputFormat), against its signatures, written
+// by a server whose SigningKey is key, e.g. for graders to detect that a
+// submitted file was edited after the fact. It returns an error locating
+// the first record modified, inserted, removed or moved since the server
+// wrote it. The records a server wrote just before crashing may not have
+// been signed.
+func VerifySignatures(outputFile, format string, key []byte) error {
+	file, err := os.Open(outputFile)
+	if err != nil {
+		return err
+	}
+	records, err := ReadRecords(file, format)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	signatureFile, err := os.Open(signatureFileName(outputFile))
+	if err != nil {
+		return err
+	}
+	defer signatureFile.Close()
+
+	var previous []byte
+	scanner := bufio.NewScanner(signatureFile)
+	for i, record := range records {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("record %d of %s is not signed", i+1, outputFile)
+		}
+		signature, err := hex.DecodeString(scanner.Text())
+		if err != nil || !hmac.Equal(signature, chainSignature(key, previous, record)) {
+			return fmt.Errorf("record %d of %s does not match its signature", i+1, outputFile)
+		}
+		previous = signature
+	}
+	if scanner.Scan() {
+		return fmt.Errorf("signed records are missing at the end of %s", outputFile)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,320 @@
+package tracing
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Sink receives the records a tracing server writes. The server's output
+// files are sinks themselves; custom sinks, plugged into a server with
+// TracingServerConfig.Sinks, can ship records to other systems, e.g. to a
+// database where they can be queried by trace (see
+// github.com/DistributedClocks/tracing/sqlite). A sink failing does not fail
+// the records the others write, see TracingServer.SinkErrors.
+type Sink interface {
+	// Write writes record. Write is called for one record at a time, in
+	// the order of record.Seq.
+	Write(record TraceRecord) error
+	// Flush makes the records written so far durable.
+	Flush() error
+	// Close is called when the server is closed, after the last record has
+	// been written.
+	Close() error
+}
+
+// serverSink is one of the sinks of a server. Its failures are logged and
+// counted, rather than failing the records the other sinks write.
+type serverSink struct {
+	Sink
+	name    string
+	errors  uint64
+	failing bool // whether the last operation failed
+}
+
+// newServerSinks returns builtin followed by the custom sinks, named after
+// their index in TracingServerConfig.Sinks.
+func newServerSinks(builtin []*serverSink, custom []Sink) []*serverSink {
+	for i, sink := range custom {
+		builtin = append(builtin, &serverSink{Sink: sink, name: fmt.Sprintf("Sinks[%d]", i)})
+	}
+	return builtin
+}
+
+// fail counts err, which failed an operation of the sink, only logging the
+// first of consecutive failures.
+func (s *serverSink) fail(operation string, err error) {
+	s.errors++
+	if !s.failing {
+		log.Printf("error %s to %s, records are still written to the other sinks: %v", operation, s.name, err)
+	}
+	s.failing = true
+}
+
+// succeed notes the success of an operation of the sink, logging its
+// recovery from failure.
+func (s *serverSink) succeed() {
+	if s.failing {
+		log.Printf("%s recovered, after %d errors in total", s.name, s.errors)
+	}
+	s.failing = false
+}
+
+// SinkErrors returns, for each of the server's sinks, the number of times
+// it failed to write or flush records. The sinks are named after their
+// configuration field, OutputFile, ShivizOutputFile, GoVectorLogDir,
+// ObjectStore, InMemory, TailBind, DashboardBind or Checkers, and Sinks[i]
+// for the custom ones.
+func (tracingServer *TracingServer) SinkErrors() map[string]uint64 {
+	tracingServer.recordLock.Lock()
+	defer tracingServer.recordLock.Unlock()
+	errors := make(map[string]uint64)
+	for _, sink := range tracingServer.sinks {
+		errors[sink.name] = sink.errors
+	}
+	return errors
+}
+
+// outputStream returns the standard stream fileName refers to, if any: "-"
+// and "stdout://" refer to the standard output, "stderr://" to the standard
+// error.
+func outputStream(fileName string) *os.File {
+	switch fileName {
+	case "-", "stdout://":
+		return os.Stdout
+	case "stderr://":
+		return os.Stderr
+	}
+	return nil
+}
+
+// syncFile syncs file, unless it is a standard stream, which the process
+// does not own, and which may not support syncing (e.g. pipes).
+func syncFile(file *os.File) error {
+	if file == os.Stdout || file == os.Stderr {
+		return nil
+	}
+	return file.Sync()
+}
+
+// closeFile closes file, unless it is a standard stream.
+func closeFile(file *os.File) error {
+	if file == os.Stdout || file == os.Stderr {
+		return nil
+	}
+	return file.Close()
+}
+
+// recordFileSink writes records to the output file, as JSON objects, one per
+// line, or in the binary format (see BinaryRecordReader), each encrypted in
+// a frame of its own if aead is set (see NewDecryptingReader).
+type recordFileSink struct {
+	file    *os.File
+	binary  bool
+	aead    cipher.AEAD
+	encoder *json.Encoder
+	size    int64        // the size of the file, to rotate it
+	frames  uint64       // the number of frames of the file, if aead is set
+	index   *indexWriter // the index of the file, if IndexOutput is set
+
+	// buffer, if FlushEvery or FlushInterval is set, buffers the writes to
+	// the file, up to flushEvery records (if positive), buffered of which
+	// are not flushed yet
+	buffer     *bufio.Writer
+	flushEvery int
+	buffered   int
+
+	signatures *signatureWriter // the signatures of the file, if SigningKey is set
+}
+
+func newRecordFileSink(file *os.File, binary bool, aead cipher.AEAD) (*recordFileSink, error) {
+	s := &recordFileSink{file: file, binary: binary, aead: aead}
+	s.encoder = json.NewEncoder(sizeWriter{s})
+	if file != os.Stdout && file != os.Stderr {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		s.size = info.Size()
+	}
+	if s.size == 0 {
+		if err := s.writeHeader(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// writeHeader starts the file with the record of its header, which is not
+// indexed, after the magic of encrypted files if aead is set.
+func (s *recordFileSink) writeHeader() error {
+	if s.aead != nil {
+		n, err := s.writer().Write([]byte(encryptedOutputMagic))
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	record := newOutputHeaderRecord()
+	if s.binary {
+		return writeDelimited(sizeWriter{s}, record.toProto())
+	}
+	return s.encoder.Encode(record)
+}
+
+func (s *recordFileSink) Write(record TraceRecord) error {
+	offset := s.size
+	var err error
+	if s.binary {
+		err = writeDelimited(sizeWriter{s}, record.toProto())
+	} else {
+		err = s.encoder.Encode(record)
+	}
+	if err != nil {
+		return err
+	}
+	if s.index != nil {
+		if err := s.index.write(record, offset); err != nil {
+			return err
+		}
+	}
+	if s.signatures != nil {
+		if err := s.signatures.write(record); err != nil {
+			return err
+		}
+	}
+	if s.buffer != nil {
+		s.buffered++
+		if s.flushEvery > 0 && s.buffered >= s.flushEvery {
+			return s.flushBuffer()
+		}
+	}
+	return nil
+}
+
+// outputBufferSize is the size of the buffers of the output files, large
+// enough for FlushEvery to decide when most writes are flushed.
+const outputBufferSize = 64 << 10
+
+// bufferWrites buffers the writes to the file, flushing them every
+// flushEvery records, if positive, and when Flush or flushBuffer is called.
+func (s *recordFileSink) bufferWrites(flushEvery int) {
+	s.buffer = bufio.NewWriterSize(s.file, outputBufferSize)
+	s.flushEvery = flushEvery
+}
+
+// writer returns the writer of the file, its buffer if any.
+func (s *recordFileSink) writer() io.Writer {
+	if s.buffer != nil {
+		return s.buffer
+	}
+	return s.file
+}
+
+// flushBuffer writes the records buffered to the file, if any.
+func (s *recordFileSink) flushBuffer() error {
+	if s.buffer == nil {
+		return nil
+	}
+	s.buffered = 0
+	return s.buffer.Flush()
+}
+
+// sizeWriter writes to the file of a recordFileSink, keeping count of its
+// size. Records are written at once, so that each is encrypted in a frame
+// of its own.
+type sizeWriter struct {
+	sink *recordFileSink
+}
+
+func (w sizeWriter) Write(p []byte) (int, error) {
+	if w.sink.aead == nil {
+		n, err := w.sink.writer().Write(p)
+		w.sink.size += int64(n)
+		return n, err
+	}
+	frame, err := sealFrame(w.sink.aead, w.sink.frames, p)
+	if err != nil {
+		return 0, err
+	}
+	w.sink.frames++
+	n, err := w.sink.writer().Write(frame)
+	w.sink.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeTrailer ends the file with the last frame, holding no data, if aead
+// is set, so that readers can tell a truncated file from a complete one.
+func (s *recordFileSink) writeTrailer() error {
+	if s.aead == nil {
+		return nil
+	}
+	_, err := sizeWriter{s}.Write(nil)
+	return err
+}
+
+func (s *recordFileSink) Flush() error {
+	if err := s.flushBuffer(); err != nil {
+		return err
+	}
+	if s.index != nil {
+		if err := s.index.file.Sync(); err != nil {
+			return err
+		}
+	}
+	if s.signatures != nil {
+		if err := s.signatures.file.Sync(); err != nil {
+			return err
+		}
+	}
+	return syncFile(s.file)
+}
+
+func (s *recordFileSink) Close() error {
+	if err := s.writeTrailer(); err != nil {
+		closeFile(s.file)
+		return err
+	}
+	if err := s.flushBuffer(); err != nil {
+		closeFile(s.file)
+		return err
+	}
+	if s.index != nil {
+		if err := s.index.Close(); err != nil {
+			closeFile(s.file)
+			return err
+		}
+	}
+	if s.signatures != nil {
+		if err := s.signatures.Close(); err != nil {
+			closeFile(s.file)
+			return err
+		}
+	}
+	return closeFile(s.file)
+}
+
+// shivizSink writes records to the ShiViz output file.
+type shivizSink struct {
+	file   *os.File
+	logger *shivizLogger
+}
+
+func (s *shivizSink) Write(record TraceRecord) error {
+	return s.logger.log(record)
+}
+
+func (s *shivizSink) Flush() error {
+	return syncFile(s.file)
+}
+
+func (s *shivizSink) Close() error {
+	return closeFile(s.file)
+}
@@ -0,0 +1,26 @@
+module github.com/DistributedClocks/tracing/sqlite
+
+go 1.19
+
+require (
+	github.com/DistributedClocks/tracing v0.0.0
+	github.com/mattn/go-sqlite3 v1.14.22
+)
+
+require (
+	github.com/DistributedClocks/GoVector v0.0.0-20210402100930-db949c81a0af // indirect
+	github.com/daviddengcn/go-colortext v1.0.0 // indirect
+	github.com/golang/protobuf v1.5.0 // indirect
+	github.com/golang/snappy v0.0.4 // indirect
+	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.1.4 // indirect
+	github.com/vmihailenco/tagparser v0.1.2 // indirect
+	golang.org/x/net v0.0.0-20190311183353-d8887717615a // indirect
+	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a // indirect
+	golang.org/x/text v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+	google.golang.org/grpc v1.38.0 // indirect
+	google.golang.org/protobuf v1.26.0 // indirect
+)
+
+replace github.com/DistributedClocks/tracing => ../
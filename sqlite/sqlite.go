@@ -0,0 +1,197 @@
+// Package sqlite provides a SQLite storage sink for tracing servers,
+// which inserts the records they receive into an indexed table, where they
+// can be queried with SQL, e.g. all the records of a trace, in an order
+// consistent with causality:
+// 	SELECT tracer, tag, body FROM records WHERE trace_id = ? ORDER BY seq
+// The server keeps writing its JSON output files, with:
+// 	store, err := sqlite.Open("traces.db")
+// 	...
+// 	tracing.TracingServerConfig{
+// 		...
+// 		Sinks: []tracing.Sink{store},
+// 	}
+// The bodies of the records are indexed for full-text search, see
+// Store.SearchRecords, which the query API of a server with the store among
+// its sinks uses for its searches.
+//
+// Trace IDs are stored as the signed 64-bit integers SQLite supports: IDs
+// above math.MaxInt64 are stored as negative numbers (use
+// int64(traceID) in queries).
+//
+// The package is a separate module, as the SQLite driver requires cgo.
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS records (
+	seq          INTEGER NOT NULL,
+	trace_id     INTEGER NOT NULL,
+	tracer       TEXT NOT NULL,
+	tag          TEXT NOT NULL,
+	body         TEXT NOT NULL,
+	vector_clock TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS records_trace_id ON records (trace_id, seq);
+CREATE INDEX IF NOT EXISTS records_tracer ON records (tracer, seq);
+CREATE VIRTUAL TABLE IF NOT EXISTS records_body USING fts4(content="records", body);
+`
+
+// recordColumns are the columns readRecords reads.
+const recordColumns = "records.seq, records.trace_id, records.tracer, records.tag, records.body, records.vector_clock"
+
+// Store stores records in the records table of a SQLite database, whose
+// columns are:
+// 	- seq, the Seq of the record
+// 	- trace_id, tracer and tag, the TraceID, TracerIdentity and Tag of the
+// 	  record
+// 	- body, the record itself, as JSON
+// 	- vector_clock, the vector clock of the record, as a JSON object
+// The full-text index of the bodies is the records_body table, by rowid.
+type Store struct {
+	db          *sql.DB
+	insert      *sql.Stmt
+	insertIndex *sql.Stmt
+}
+
+// Open opens the SQLite database file, creating it along with the records
+// table if needed.
+func Open(file string) (*Store, error) {
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		return nil, err
+	}
+	var indexed int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'records_body'").Scan(&indexed); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// the write-ahead log avoids a sync per inserted record
+	statements := []string{"PRAGMA journal_mode=WAL", "PRAGMA synchronous=NORMAL", schema}
+	if indexed == 0 {
+		// index the records of databases created before the index
+		statements = append(statements, "INSERT INTO records_body (records_body) VALUES ('rebuild')")
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	insert, err := db.Prepare("INSERT INTO records (seq, trace_id, tracer, tag, body, vector_clock) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	insertIndex, err := db.Prepare("INSERT INTO records_body (docid, body) VALUES (?, ?)")
+	if err != nil {
+		insert.Close()
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db, insert: insert, insertIndex: insertIndex}, nil
+}
+
+// DB returns the database of the store, for queries.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Write inserts record, and indexes its body.
+func (s *Store) Write(record tracing.TraceRecord) error {
+	vectorClock, err := json.Marshal(record.VectorClock)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	result, err := tx.Stmt(s.insert).Exec(int64(record.Seq), int64(record.TraceID), record.TracerIdentity,
+		record.Tag, string(record.Body), string(vectorClock))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	rowid, err := result.LastInsertId()
+	if err == nil {
+		_, err = tx.Stmt(s.insertIndex).Exec(rowid, string(record.Body))
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Flush checkpoints the write-ahead log into the database file, since the
+// commits in the log are not synced as they happen.
+func (s *Store) Flush() error {
+	_, err := s.db.Exec("PRAGMA wal_checkpoint(FULL)")
+	return err
+}
+
+// TraceRecords returns the records of a trace, in the order they were
+// received, which is consistent with causality.
+func (s *Store) TraceRecords(traceID uint64) ([]tracing.TraceRecord, error) {
+	rows, err := s.db.Query("SELECT "+recordColumns+" FROM records WHERE trace_id = ? ORDER BY seq",
+		int64(traceID))
+	if err != nil {
+		return nil, err
+	}
+	return readRecords(rows)
+}
+
+// SearchRecords returns the records whose body contains all the words of
+// text, as tracing.SearchWords splits them, in the order they were
+// inserted, using the full-text index.
+func (s *Store) SearchRecords(text string) ([]tracing.TraceRecord, error) {
+	words := tracing.SearchWords(text)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	// the words are quoted, so that they are not read as operators
+	match := `"` + strings.Join(words, `" "`) + `"`
+	rows, err := s.db.Query("SELECT "+recordColumns+" FROM records_body JOIN records ON records.rowid = records_body.docid "+
+		"WHERE records_body MATCH ? ORDER BY records.rowid", match)
+	if err != nil {
+		return nil, err
+	}
+	return readRecords(rows)
+}
+
+// readRecords reads the records of rows, of recordColumns, and closes
+// rows.
+func readRecords(rows *sql.Rows) ([]tracing.TraceRecord, error) {
+	defer rows.Close()
+	var records []tracing.TraceRecord
+	for rows.Next() {
+		var record tracing.TraceRecord
+		var seq, traceID int64
+		var body, vectorClock string
+		if err := rows.Scan(&seq, &traceID, &record.TracerIdentity, &record.Tag, &body, &vectorClock); err != nil {
+			return nil, err
+		}
+		record.Seq, record.TraceID = uint64(seq), uint64(traceID)
+		record.Body = json.RawMessage(body)
+		if err := json.Unmarshal([]byte(vectorClock), &record.VectorClock); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	s.insert.Close()
+	s.insertIndex.Close()
+	return s.db.Close()
+}
@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "traces.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		Sinks:            []tracing.Sink{store},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client1 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client2.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+	received := client2.ReceiveToken(trace.GenerateToken())
+	received.RecordAction(TestAction{Foo: "baz"})
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = Open(filepath.Join(dir, "traces.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	records, err := store.TraceRecords(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		tracer, tag string
+	}{
+		{"client1", "CreateTrace"},
+		{"client1", "TestAction"},
+		{"client1", "GenerateTokenTrace"},
+		{"client2", "ReceiveTokenTrace"},
+		{"client2", "TestAction"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %v", len(expected), records)
+	}
+	for i, record := range records {
+		if record.TracerIdentity != expected[i].tracer || record.Tag != expected[i].tag {
+			t.Fatalf("expected record %d to be %v, got %v", i, expected[i], record)
+		}
+	}
+	if body := string(records[4].Body); body != `{"Foo":"baz"}` {
+		t.Fatalf("unexpected record body %s", body)
+	}
+	if vc := records[4].VectorClock; vc["client1"] != 3 || vc["client2"] != 4 {
+		t.Fatalf("unexpected vector clock %v", vc)
+	}
+
+	found, err := store.SearchRecords("BAZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].TraceID != trace.ID || string(found[0].Body) != `{"Foo":"baz"}` {
+		t.Fatalf("unexpected records found %v", found)
+	}
+	if found, err = store.SearchRecords("foo"); err != nil || len(found) != 3 {
+		t.Fatalf("expected the 3 actions, with a field Foo, got %v, %v", found, err)
+	}
+}
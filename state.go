@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+const defaultStateSaveInterval = 10 * time.Second
+
+// stateSaver periodically saves the last vector clocks of the tracers to
+// the server's StateFile, so that tracers rejoining after a restart resume
+// from them rather than from scratch.
+type stateSaver struct {
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// loadState loads the last vector clocks saved to the StateFile, if it
+// exists.
+func (tracingServer *TracingServer) loadState() error {
+	data, err := ioutil.ReadFile(tracingServer.Config.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var lastVCs map[string]vclock.VClock
+	if err := json.Unmarshal(data, &lastVCs); err != nil {
+		return err
+	}
+	for identity, vc := range lastVCs {
+		tracingServer.lastVCs.storeMissing(identity, vc)
+	}
+	return nil
+}
+
+// saveState saves the last vector clocks to the StateFile, if they changed
+// since the last save. The file is replaced atomically, so that a crash
+// while saving leaves the previous state intact.
+func (tracingServer *TracingServer) saveState() error {
+	if !tracingServer.lastVCs.takeDirty() {
+		return nil
+	}
+	data, err := json.Marshal(tracingServer.lastVCs.snapshot())
+	if err == nil {
+		err = writeFileAtomic(tracingServer.Config.StateFile, data)
+	}
+	if err != nil {
+		tracingServer.lastVCs.markDirty()
+	}
+	return err
+}
+
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (tracingServer *TracingServer) startStateSaver(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStateSaveInterval
+	}
+	tracingServer.stateSaver = &stateSaver{
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go tracingServer.runStateSaver(tracingServer.stateSaver)
+}
+
+func (tracingServer *TracingServer) runStateSaver(saver *stateSaver) {
+	defer close(saver.done)
+
+	ticker := time.NewTicker(saver.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-saver.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := tracingServer.saveState(); err != nil {
+			log.Print("error saving the last vector clocks: ", err)
+		}
+	}
+}
+
+// stopStateSaver stops the periodic saves, and saves the final state.
+func (tracingServer *TracingServer) stopStateSaver() error {
+	if tracingServer.stateSaver == nil {
+		return nil
+	}
+	close(tracingServer.stateSaver.stop)
+	<-tracingServer.stateSaver.done
+	tracingServer.stateSaver = nil
+	return tracingServer.saveState()
+}
@@ -0,0 +1,168 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// builtinTags are the tags of the actions the library records itself.
+var builtinTags = map[string]bool{
+	"CreateTrace":        true,
+	"PrepareTokenTrace":  true,
+	"GenerateTokenTrace": true,
+	"ReceiveTokenTrace":  true,
+	"ReceiveTokensTrace": true,
+}
+
+// Declaration declares the action types that the tracers whose identity
+// matches Tracers, a pattern in the syntax of path.Match, must record.
+type Declaration struct {
+	Tracers string
+	Types   []string
+}
+
+// ParseDeclarations parses the action types of an assignment in r, one
+// declaration per line: the types all tracers must record, separated by
+// spaces, or the types of some tracers, after a pattern of their identities
+// and a colon, e.g.:
+//
+//	# the types of all tracers
+//	CreateKey
+//	client*: Put Get
+//	server*: PutRecvd GetRecvd
+//
+// Empty lines and lines starting with # are ignored.
+func ParseDeclarations(r io.Reader) ([]Declaration, error) {
+	var declarations []Declaration
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		declaration := Declaration{Tracers: "*"}
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			declaration.Tracers, text = strings.TrimSpace(text[:i]), text[i+1:]
+			if _, err := path.Match(declaration.Tracers, ""); err != nil || declaration.Tracers == "" {
+				return nil, fmt.Errorf("line %d: invalid tracer pattern %q", line, declaration.Tracers)
+			}
+		}
+		declaration.Types = strings.Fields(text)
+		if len(declaration.Types) == 0 {
+			return nil, fmt.Errorf("line %d: no action type", line)
+		}
+		declarations = append(declarations, declaration)
+	}
+	return declarations, scanner.Err()
+}
+
+// TracerCoverage is the coverage of the action types declared for a tracer.
+type TracerCoverage struct {
+	Tracer   string
+	Recorded map[string]int // the number of actions of each declared type the tracer recorded
+	Missing  []string       // the declared types it did not record, sorted
+}
+
+// Coverage is the coverage of the declared action types by the tracers of
+// a run, to catch incomplete instrumentation.
+type Coverage struct {
+	Tracers    []TracerCoverage // by identity
+	Undeclared map[string]int   // the number of actions of the types recorded but declared for no tracer, e.g. typos
+}
+
+// ComputeCoverage returns the coverage of declarations by the tracers of
+// records. The actions the library records itself, e.g. CreateTrace, are
+// not reported as undeclared, nor are the records of violations.
+func ComputeCoverage(records []tracing.TraceRecord, declarations []Declaration) Coverage {
+	counts := make(map[string]map[string]int)
+	for _, record := range records {
+		if record.TracerIdentity == tracing.ViolationTracer {
+			continue
+		}
+		if counts[record.TracerIdentity] == nil {
+			counts[record.TracerIdentity] = make(map[string]int)
+		}
+		counts[record.TracerIdentity][record.Tag]++
+	}
+
+	coverage := Coverage{Undeclared: make(map[string]int)}
+	for _, tracer := range sortedTracers(counts) {
+		declared := make(map[string]bool)
+		for _, declaration := range declarations {
+			if ok, _ := path.Match(declaration.Tracers, tracer); ok {
+				for _, t := range declaration.Types {
+					declared[t] = true
+				}
+			}
+		}
+		tracerCoverage := TracerCoverage{Tracer: tracer, Recorded: make(map[string]int), Missing: []string{}}
+		for t := range declared {
+			if n := counts[tracer][t]; n > 0 {
+				tracerCoverage.Recorded[t] = n
+			} else {
+				tracerCoverage.Missing = append(tracerCoverage.Missing, t)
+			}
+		}
+		sort.Strings(tracerCoverage.Missing)
+		for tag, n := range counts[tracer] {
+			if !declared[tag] && !builtinTags[tag] {
+				coverage.Undeclared[tag] += n
+			}
+		}
+		coverage.Tracers = append(coverage.Tracers, tracerCoverage)
+	}
+	return coverage
+}
+
+func sortedTracers(counts map[string]map[string]int) []string {
+	var tracers []string
+	for tracer := range counts {
+		tracers = append(tracers, tracer)
+	}
+	sort.Strings(tracers)
+	return tracers
+}
+
+// Complete returns whether every tracer recorded all its declared types.
+func (c Coverage) Complete() bool {
+	for _, tracer := range c.Tracers {
+		if len(tracer.Missing) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes the coverage to w, human-readable: a line per tracer, with
+// the declared types it recorded, with their number of actions, and those
+// it is missing, then the undeclared types.
+func (c Coverage) Write(w io.Writer) error {
+	for _, tracer := range c.Tracers {
+		var recorded []string
+		for _, t := range sortedKeys(tracer.Recorded) {
+			recorded = append(recorded, fmt.Sprintf("%s %d", t, tracer.Recorded[t]))
+		}
+		line := fmt.Sprintf("%s: recorded %d of %d types", tracer.Tracer, len(recorded), len(recorded)+len(tracer.Missing))
+		if len(recorded) > 0 {
+			line += " (" + strings.Join(recorded, ", ") + ")"
+		}
+		if len(tracer.Missing) > 0 {
+			line += ", missing " + strings.Join(tracer.Missing, ", ")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	for _, t := range sortedKeys(c.Undeclared) {
+		if _, err := fmt.Fprintf(w, "undeclared type %s: %d actions\n", t, c.Undeclared[t]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Package stats computes per-trace metrics from the records of a tracing
+// server, for bulk analysis of runs, e.g. when grading, see
+// cmd/trace-stats.
+//
+// Records carry no time, so durations are logical: the difference between
+// the logical times (the sums of the entries of the vector clocks) of the
+// first and last events of a trace, which counts the events of all tracers
+// that happened in between, as far as the trace knows.
+package stats
+
+import (
+	stdcsv "encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/criticalpath"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Trace is the metrics of a trace.
+type Trace struct {
+	TraceID         uint64
+	Actions         int    // the number of records of the trace
+	Tracers         int    // the number of distinct tracers that recorded them
+	Tokens          int    // the number of tokens generated in the trace
+	Depth           int    // the number of events of the longest causal chain, see package criticalpath
+	LogicalDuration uint64 // the logical time from the first event of the trace to the last
+}
+
+// columns are the CSV columns of the metrics.
+var columns = []string{"TraceID", "Actions", "Tracers", "Tokens", "Depth", "LogicalDuration"}
+
+// Compute returns the metrics of each trace of records, in the order of
+// their first record.
+func Compute(records []tracing.TraceRecord) []Trace {
+	var traces []Trace
+	index := make(map[uint64]int)
+	tracers := make(map[uint64]map[string]bool)
+	first := make(map[uint64]uint64) // the smallest logical time of each trace
+	last := make(map[uint64]uint64)  // the largest
+	for _, record := range records {
+		i, ok := index[record.TraceID]
+		if !ok {
+			i = len(traces)
+			index[record.TraceID] = i
+			traces = append(traces, Trace{TraceID: record.TraceID})
+			tracers[record.TraceID] = make(map[string]bool)
+		}
+		trace := &traces[i]
+		trace.Actions++
+		tracers[record.TraceID][record.TracerIdentity] = true
+		trace.Tracers = len(tracers[record.TraceID])
+		if record.Tag == "GenerateTokenTrace" {
+			trace.Tokens++
+		}
+		time := events.LogicalTime(record.VectorClock)
+		if !ok || time < first[record.TraceID] {
+			first[record.TraceID] = time
+		}
+		if time > last[record.TraceID] {
+			last[record.TraceID] = time
+		}
+	}
+	for i, path := range criticalpath.Paths(records) {
+		traces[i].Depth = len(path.Records)
+		traces[i].LogicalDuration = last[path.TraceID] - first[path.TraceID]
+	}
+	return traces
+}
+
+// WriteJSON writes the metrics of the traces of records to w, as a JSON
+// array.
+func WriteJSON(w io.Writer, records []tracing.TraceRecord) error {
+	traces := Compute(records)
+	if traces == nil {
+		traces = []Trace{}
+	}
+	return json.NewEncoder(w).Encode(traces)
+}
+
+// WriteCSV writes the metrics of the traces of records to w, as CSV with a
+// header row.
+func WriteCSV(w io.Writer, records []tracing.TraceRecord) error {
+	writer := stdcsv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, trace := range Compute(records) {
+		if err := writer.Write([]string{
+			strconv.FormatUint(trace.TraceID, 10),
+			strconv.Itoa(trace.Actions),
+			strconv.Itoa(trace.Tracers),
+			strconv.Itoa(trace.Tokens),
+			strconv.Itoa(trace.Depth),
+			strconv.FormatUint(trace.LogicalDuration, 10),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
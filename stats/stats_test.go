@@ -0,0 +1,160 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+	"github.com/google/go-cmp/cmp"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+func TestCompute(t *testing.T) {
+	var trace1, trace2 *tracing.Trace
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace1 = client1.CreateTrace()
+		trace2 = client1.CreateTrace()
+		trace1.RecordAction(TestAction{Foo: "foo"})
+		client2.ReceiveToken(trace1.GenerateToken()).RecordAction(TestAction{Foo: "bar"})
+	})
+
+	// trace1 is recorded at {client1:1}, {client1:3} and {client1:4} by
+	// client1, then at {client1:4, client2:1} and {client1:4, client2:2} by
+	// client2, from logical time 1 to 6.
+	expected := []Trace{
+		{TraceID: trace1.ID, Actions: 5, Tracers: 2, Tokens: 1, Depth: 5, LogicalDuration: 5},
+		{TraceID: trace2.ID, Actions: 1, Tracers: 1, Depth: 1},
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	var traces []Trace
+	if err := json.Unmarshal(buf.Bytes(), &traces); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(traces, expected) {
+		t.Fatalf("unexpected metrics: %s", cmp.Diff(expected, traces))
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	expectedCSV := fmt.Sprintf("TraceID,Actions,Tracers,Tokens,Depth,LogicalDuration\n%d,5,2,1,5,5\n%d,1,1,0,1,0\n", trace1.ID, trace2.ID)
+	if buf.String() != expectedCSV {
+		t.Fatalf("expected\n%s\ngot\n%s", expectedCSV, buf.String())
+	}
+}
+
+func TestSummary(t *testing.T) {
+	recorded := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		client2.CreateTrace()
+		trace1 := client1.CreateTrace()
+		client2.ReceiveToken(trace1.GenerateToken()).RecordAction(TestAction{Foo: "foo"})
+		trace2 := client1.CreateTrace()
+		trace2.GenerateToken()
+	})
+
+	// Lose the reception, and corrupt a line.
+	var buf bytes.Buffer
+	records := append([]tracing.TraceRecord(nil), recorded...)
+	for i, record := range records {
+		if record.Tag == "ReceiveTokenTrace" {
+			records = append(records[:i:i], records[i+1:]...)
+			break
+		}
+	}
+	encoder := json.NewEncoder(&buf)
+	for i, record := range records {
+		records[i].Seq = 0 // not written
+		if err := encoder.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.WriteString(`{"TracerIdentity":"client1","Tag":` + "\n")
+
+	read, parseErrors, err := Read(&buf, tracing.OutputFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parseErrors != 1 || !cmp.Equal(read, records) {
+		t.Fatalf("expected the records and a parse error, got %d parse errors and %s", parseErrors, cmp.Diff(records, read))
+	}
+
+	summary := Summarize(read)
+	expected := Summary{
+		Records:         6,
+		Tracers:         map[string]int{"client1": 4, "client2": 2},
+		Tags:            map[string]int{"CreateTrace": 3, "GenerateTokenTrace": 2, "TestAction": 1},
+		TracesStarted:   3,
+		TracesCompleted: 1,
+		TokensGenerated: 2,
+		Missing:         map[string]uint64{"client2": 1},
+	}
+	if !cmp.Equal(summary, expected) {
+		t.Fatalf("unexpected summary: %s", cmp.Diff(expected, summary))
+	}
+
+	summary = Summarize(recorded)
+	if summary.TracesCompleted != 2 || summary.TokensReceived != 1 || summary.Missing["client2"] != 0 {
+		t.Fatalf("expected 2 traces completed, a token received and no record missing, got %+v", summary)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	declarations, err := ParseDeclarations(strings.NewReader(`
+# every tracer
+Start
+client*: Put Get
+server*: PutRecvd
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []tracing.TraceRecord{
+		{TracerIdentity: "client1", Tag: "CreateTrace"},
+		{TracerIdentity: "client1", Tag: "Start"},
+		{TracerIdentity: "client1", Tag: "Put"},
+		{TracerIdentity: "client1", Tag: "Put"},
+		{TracerIdentity: "server1", Tag: "Start"},
+		{TracerIdentity: "server1", Tag: "PutRecvd"},
+		{TracerIdentity: "server1", Tag: "GetRecived"},
+	}
+	coverage := ComputeCoverage(records, declarations)
+	expected := Coverage{
+		Tracers: []TracerCoverage{
+			{Tracer: "client1", Recorded: map[string]int{"Start": 1, "Put": 2}, Missing: []string{"Get"}},
+			{Tracer: "server1", Recorded: map[string]int{"Start": 1, "PutRecvd": 1}, Missing: []string{}},
+		},
+		Undeclared: map[string]int{"GetRecived": 1},
+	}
+	if !cmp.Equal(coverage, expected) {
+		t.Fatalf("unexpected coverage: %s", cmp.Diff(expected, coverage))
+	}
+	if coverage.Complete() {
+		t.Fatal("expected the coverage to be incomplete")
+	}
+	var buf bytes.Buffer
+	if err := coverage.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "client1: recorded 2 of 3 types (Put 2, Start 1), missing Get\n" +
+		"server1: recorded 2 of 2 types (PutRecvd 1, Start 1)\n" +
+		"undeclared type GetRecived: 1 actions\n"; buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	for _, invalid := range []string{"client*:", "client[: Put"} {
+		if _, err := ParseDeclarations(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("expected an error parsing %q", invalid)
+		}
+	}
+}
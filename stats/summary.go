@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Summary is the summary of a run, and of the health of its output files.
+type Summary struct {
+	Records         int
+	Tracers         map[string]int    // the number of records of each tracer
+	Tags            map[string]int    // the number of records of each tag
+	TracesStarted   int               // the number of traces created
+	TracesCompleted int               // the number of traces created whose tokens were all received
+	TokensGenerated int               // the number of tokens generated
+	TokensReceived  int               // the number of tokens received, those received several times included
+	Missing         map[string]uint64 // the number of records missing of the tracers missing some, see Summarize
+	ParseErrors     int               // the number of records that could not be read, see Read
+}
+
+// Summarize returns the summary of records, in the order they were written.
+//
+// The records missing of each tracer are found from the gaps in their
+// sequence numbers, which tracers number their records with from 1. A
+// record numbered 1, or numbered at most as the previous one when none is
+// missing, starts a new tracer instance. Records missing before the first
+// of an instance, or after its last, cannot be accounted for, as by the
+// server, see tracing.TracingServer.MissingRecords.
+func Summarize(records []tracing.TraceRecord) Summary {
+	summary := Summary{
+		Records: len(records),
+		Tracers: make(map[string]int),
+		Tags:    make(map[string]int),
+		Missing: make(map[string]uint64),
+	}
+	type session struct {
+		lastSeq uint64
+		missing uint64
+	}
+	sessions := make(map[string]*session) // the current session of each tracer
+	started := make(map[uint64]bool)
+	generated := make(map[uint64][]uint64) // the events that generated the tokens of each trace
+	received := make(map[uint64]bool)      // the events that generated received tokens
+	for _, record := range records {
+		summary.Tracers[record.TracerIdentity]++
+		summary.Tags[record.Tag]++
+		switch record.Tag {
+		case "CreateTrace":
+			if !started[record.TraceID] {
+				started[record.TraceID] = true
+				summary.TracesStarted++
+			}
+		case "GenerateTokenTrace":
+			summary.TokensGenerated++
+			generated[record.TraceID] = append(generated[record.TraceID], events.RecordID(record))
+		}
+		for _, token := range events.ReceivedTokens(record) {
+			summary.TokensReceived++
+			received[events.ID(token.Tracer, token.VectorClock[token.Tracer])] = true
+		}
+
+		if record.TracerSeq == 0 {
+			continue
+		}
+		s := sessions[record.TracerIdentity]
+		switch {
+		case s == nil || record.TracerSeq == 1 || record.TracerSeq <= s.lastSeq && s.missing == 0:
+			sessions[record.TracerIdentity] = &session{lastSeq: record.TracerSeq}
+		case record.TracerSeq > s.lastSeq+1:
+			missing := record.TracerSeq - s.lastSeq - 1
+			s.lastSeq, s.missing = record.TracerSeq, s.missing+missing
+			summary.Missing[record.TracerIdentity] += missing
+		case record.TracerSeq == s.lastSeq+1:
+			s.lastSeq = record.TracerSeq
+		default: // arrived late
+			s.missing--
+			if summary.Missing[record.TracerIdentity]--; summary.Missing[record.TracerIdentity] == 0 {
+				delete(summary.Missing, record.TracerIdentity)
+			}
+		}
+	}
+	for id := range started {
+		completed := true
+		for _, token := range generated[id] {
+			completed = completed && received[token]
+		}
+		if completed {
+			summary.TracesCompleted++
+		}
+	}
+	return summary
+}
+
+// Read reads the records of r, an output file in format, like
+// tracing.ReadRecords, but skips the lines of a JSON file that are not
+// records, e.g. one truncated by a crash, and returns the number of those
+// it skipped. A binary file cannot be read past an invalid record: it is
+// counted as one, and ends the file.
+func Read(r io.Reader, format string) ([]tracing.TraceRecord, int, error) {
+	parseErrors := 0
+	if format == "" || format == tracing.OutputFormatJSON {
+		var valid bytes.Buffer
+		lines := bufio.NewReader(r)
+		for {
+			line, err := lines.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var record tracing.TraceRecord
+				if json.Unmarshal(line, &record) != nil {
+					parseErrors++
+				} else {
+					valid.Write(bytes.TrimSpace(line))
+					valid.WriteByte('\n')
+				}
+			}
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, parseErrors, err
+			}
+		}
+		r = &valid
+	}
+
+	reader, err := tracing.NewRecordReader(r, format)
+	if err != nil {
+		return nil, parseErrors, err
+	}
+	var records []tracing.TraceRecord
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, parseErrors, nil
+		} else if err != nil {
+			return records, parseErrors + 1, nil
+		}
+		records = append(records, record)
+	}
+}
+
+// Write writes the summary to w, in text.
+func (s Summary) Write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%d records, %d parse errors\n", s.Records, s.ParseErrors); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%d traces started, %d completed\n", s.TracesStarted, s.TracesCompleted); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%d tokens generated, %d received\n", s.TokensGenerated, s.TokensReceived); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "records per tracer:"); err != nil {
+		return err
+	}
+	for _, tracer := range sortedKeys(s.Tracers) {
+		line := fmt.Sprintf("\t%s\t%d", tracer, s.Tracers[tracer])
+		if missing := s.Missing[tracer]; missing > 0 {
+			line += fmt.Sprintf(" (%d missing)", missing)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w, "records per tag:"); err != nil {
+		return err
+	}
+	for _, tag := range sortedKeys(s.Tags) {
+		if _, err := fmt.Fprintf(w, "\t%s\t%d\n", tag, s.Tags[tag]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(counts map[string]int) []string {
+	var keys []string
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,316 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// tailBufferSize is the number of records buffered for each client of the
+// tail endpoint, past which the records are dropped for the client rather
+// than slowing down the server.
+const tailBufferSize = 1024
+
+// tailCloseTimeout is how long closing the server waits for the clients of
+// the tail endpoint to receive the records left, before disconnecting them.
+const tailCloseTimeout = 5 * time.Second
+
+// tailSink streams the records the server writes to the clients of the
+// tail endpoint, see TracingServerConfig.TailBind.
+type tailSink struct {
+	lock        sync.Mutex
+	subscribers map[*tailSubscriber]bool
+	closed      bool
+}
+
+// tailSubscriber is a client of the tail endpoint.
+type tailSubscriber struct {
+	filter  tailFilter
+	records chan TraceRecord
+	dropped uint64 // the records dropped since the last sent, guarded by the lock of the sink
+}
+
+// tailFilter selects the records streamed to a client: those of one of
+// tracers, of one of tags and of one of traces, each unrestricted if empty.
+type tailFilter struct {
+	tracers map[string]bool
+	tags    map[string]bool
+	traces  map[uint64]bool
+}
+
+func (f tailFilter) match(record TraceRecord) bool {
+	return (len(f.tracers) == 0 || f.tracers[record.TracerIdentity]) &&
+		(len(f.tags) == 0 || f.tags[record.Tag]) &&
+		(len(f.traces) == 0 || f.traces[record.TraceID])
+}
+
+// parseTailFilter parses the filter of the query parameters of a request to
+// the tail endpoint, tracer, tag and trace, each repeatable or separated by
+// commas.
+func parseTailFilter(r *http.Request) (tailFilter, error) {
+	values := func(name string) []string {
+		var values []string
+		for _, value := range r.URL.Query()[name] {
+			for _, v := range strings.Split(value, ",") {
+				if v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+		return values
+	}
+	filter := tailFilter{
+		tracers: make(map[string]bool),
+		tags:    make(map[string]bool),
+		traces:  make(map[uint64]bool),
+	}
+	for _, tracer := range values("tracer") {
+		filter.tracers[tracer] = true
+	}
+	for _, tag := range values("tag") {
+		filter.tags[tag] = true
+	}
+	for _, trace := range values("trace") {
+		id, err := strconv.ParseUint(trace, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid trace ID %q", trace)
+		}
+		filter.traces[id] = true
+	}
+	return filter, nil
+}
+
+// tailSubscription is a message of a WebSocket client of the tail endpoint,
+// which replaces the filter of its stream: the records of one of Tracers,
+// of one of Tags and of one of TraceIDs, each unrestricted if empty.
+type tailSubscription struct {
+	Tracers  []string
+	Tags     []string
+	TraceIDs []uint64
+}
+
+func (subscription tailSubscription) filter() tailFilter {
+	filter := tailFilter{
+		tracers: make(map[string]bool),
+		tags:    make(map[string]bool),
+		traces:  make(map[uint64]bool),
+	}
+	for _, tracer := range subscription.Tracers {
+		filter.tracers[tracer] = true
+	}
+	for _, tag := range subscription.Tags {
+		filter.tags[tag] = true
+	}
+	for _, id := range subscription.TraceIDs {
+		filter.traces[id] = true
+	}
+	return filter
+}
+
+// tailMessage is a message of the tail endpoint to a WebSocket client: a
+// record, or the number of records dropped before the next one.
+type tailMessage struct {
+	Record  *TraceRecord `json:",omitempty"`
+	Dropped uint64       `json:",omitempty"`
+}
+
+func newTailSink() *tailSink {
+	return &tailSink{subscribers: make(map[*tailSubscriber]bool)}
+}
+
+func (s *tailSink) Write(record TraceRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for subscriber := range s.subscribers {
+		if !subscriber.filter.match(record) {
+			continue
+		}
+		select {
+		case subscriber.records <- record:
+		default:
+			subscriber.dropped++
+		}
+	}
+	return nil
+}
+
+func (s *tailSink) Flush() error {
+	return nil
+}
+
+// Close ends the streams of the clients.
+func (s *tailSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for subscriber := range s.subscribers {
+		close(subscriber.records)
+		delete(s.subscribers, subscriber)
+	}
+	return nil
+}
+
+// subscribe adds a client streaming the records matching filter, or returns
+// nil if the sink is closed.
+func (s *tailSink) subscribe(filter tailFilter) *tailSubscriber {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	subscriber := &tailSubscriber{filter: filter, records: make(chan TraceRecord, tailBufferSize)}
+	s.subscribers[subscriber] = true
+	return subscriber
+}
+
+func (s *tailSink) unsubscribe(subscriber *tailSubscriber) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.subscribers, subscriber)
+}
+
+// setFilter replaces the filter of subscriber.
+func (s *tailSink) setFilter(subscriber *tailSubscriber, filter tailFilter) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	subscriber.filter = filter
+}
+
+// takeDropped returns the number of records dropped for subscriber since
+// the last call.
+func (s *tailSink) takeDropped(subscriber *tailSubscriber) uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	dropped := subscriber.dropped
+	subscriber.dropped = 0
+	return dropped
+}
+
+// ServeHTTP streams the records the server writes from now on as
+// server-sent events, each a JSON object as in the output files, e.g.:
+//
+//	data: {"TracerIdentity":"client1","TraceID":1,"Tag":"Put",...}
+//
+// filtered by the query parameters tracer, tag and trace, each repeatable or
+// separated by commas, e.g. /?tracer=client1,client2&tag=Put. The records
+// dropped because the client did not keep up are reported by a dropped
+// event, whose data is their number.
+//
+// WebSocket clients are streamed the records in tailMessage messages
+// instead, and can replace the filter of their stream at any time with a
+// tailSubscription message.
+func (s *tailSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	filter, err := parseTailFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subscriber := s.subscribe(filter)
+	if subscriber == nil {
+		http.Error(w, "server closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case record, ok := <-subscriber.records:
+			if !ok {
+				return
+			}
+			if dropped := s.takeDropped(subscriber); dropped > 0 {
+				if _, err := fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", dropped); err != nil {
+					return
+				}
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// serveWebSocket streams the records to a WebSocket client, see ServeHTTP.
+func (s *tailSink) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTailFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subscriber := s.subscribe(filter)
+	if subscriber == nil {
+		http.Error(w, "server closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(subscriber)
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade replied with the error
+		return
+	}
+	defer conn.Close()
+
+	// read the subscriptions until the client leaves, or sends an invalid
+	// one
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			var subscription tailSubscription
+			if err := conn.ReadJSON(&subscription); err != nil {
+				return
+			}
+			s.setFilter(subscriber, subscription.filter())
+		}
+	}()
+	for {
+		select {
+		case <-gone:
+			return
+		case record, ok := <-subscriber.records:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "server closed"))
+				return
+			}
+			if dropped := s.takeDropped(subscriber); dropped > 0 {
+				if err := conn.WriteJSON(tailMessage{Dropped: dropped}); err != nil {
+					return
+				}
+			}
+			if err := conn.WriteJSON(tailMessage{Record: &record}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTail(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		TailBind:   tailBind,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	resp, err := http.Get("http://" + tailBind + "/?trace=nonsense")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an invalid trace ID to be rejected, got status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get("http://" + tailBind + "/?tracer=client1&tag=TestAction,CreateTrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %s, of type %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client2.ReceiveToken(trace.GenerateToken()).RecordAction(TestAction{Foo: "bar"})
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream ends with the server
+	var tags []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if !strings.HasPrefix(scanner.Text(), "data: ") {
+			continue
+		}
+		var record TraceRecord
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(scanner.Text(), "data: ")), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.TracerIdentity != "client1" || record.TraceID != trace.ID {
+			t.Fatalf("unexpected record %v", record)
+		}
+		tags = append(tags, record.Tag)
+	}
+	if strings.Join(tags, ",") != "CreateTrace,TestAction" {
+		t.Fatalf("expected the records of client1 tagged CreateTrace and TestAction, got %v", tags)
+	}
+}
+
+func TestTailWebSocket(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		TailBind:   tailBind,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+tailBind+"/?tracer=client1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	messages := make(chan tailMessage)
+	go func() {
+		defer close(messages)
+		for {
+			var message tailMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			messages <- message
+		}
+	}()
+	next := func() TraceRecord {
+		select {
+		case message, ok := <-messages:
+			if !ok || message.Record == nil {
+				t.Fatalf("expected a record, got %+v", message)
+			}
+			return *message.Record
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a record")
+		}
+		return TraceRecord{}
+	}
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	if record := next(); record.TracerIdentity != "client1" || record.Tag != "CreateTrace" {
+		t.Fatalf("expected the CreateTrace record of client1, got %v", record)
+	}
+
+	// the subscription applies asynchronously: probe until it does
+	if err := conn.WriteJSON(tailSubscription{Tracers: []string{"client2"}, Tags: []string{"TestAction"}}); err != nil {
+		t.Fatal(err)
+	}
+	probe := client2.CreateTrace()
+	for subscribed := false; !subscribed; {
+		probe.RecordAction(TestAction{Foo: "probe"})
+		select {
+		case message := <-messages:
+			subscribed = message.Record != nil && message.Record.TracerIdentity == "client2"
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client2.CreateTrace().RecordAction(TestAction{Foo: "last"})
+	for {
+		record := next()
+		if record.TracerIdentity != "client2" || record.Tag != "TestAction" {
+			t.Fatalf("expected the TestAction records of client2, got %v", record)
+		}
+		if string(record.Body) == `{"Foo":"last"}` {
+			break
+		}
+	}
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the stream ends with the server
+	for range messages {
+	}
+}
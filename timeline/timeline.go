@@ -0,0 +1,63 @@
+// Package timeline converts the records of a tracing server to a flat
+// dataset of events, to plot the interleaving of the actions of tracers with
+// Vega-Lite or Plotly, see cmd/timeline-export. E.g. in Vega-Lite:
+//
+//	{
+//	  "data": {"url": "timeline.json"},
+//	  "mark": "point",
+//	  "encoding": {
+//	    "x": {"field": "timestamp", "type": "quantitative"},
+//	    "y": {"field": "tracer", "type": "nominal"},
+//	    "color": {"field": "trace", "type": "nominal"},
+//	    "tooltip": {"field": "event"}
+//	  }
+//	}
+//
+// Records carry no time, so events are placed on a logical time axis
+// instead: the sum of the entries of their vector clock, which preserves the
+// happens-before order.
+package timeline
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Event is an event of the dataset, the action of a record.
+type Event struct {
+	Event     string        `json:"event"`     // the tag of the record
+	Tracer    string        `json:"tracer"`    // the identity of its tracer
+	Trace     string        `json:"trace"`     // the ID of its trace, as a string, which JavaScript numbers cannot hold
+	Timestamp uint64        `json:"timestamp"` // its logical time
+	Clock     vclock.VClock `json:"clock"`     // its vector clock
+}
+
+// Convert returns the events of records, in the order of their timestamps,
+// and of records otherwise.
+func Convert(records []tracing.TraceRecord) []Event {
+	dataset := make([]Event, 0, len(records))
+	for _, record := range records {
+		dataset = append(dataset, Event{
+			Event:     record.Tag,
+			Tracer:    record.TracerIdentity,
+			Trace:     strconv.FormatUint(record.TraceID, 10),
+			Timestamp: events.LogicalTime(record.VectorClock),
+			Clock:     record.VectorClock,
+		})
+	}
+	sort.SliceStable(dataset, func(i, j int) bool {
+		return dataset[i].Timestamp < dataset[j].Timestamp
+	})
+	return dataset
+}
+
+// Write writes the events of records to w, as a JSON array.
+func Write(w io.Writer, records []tracing.TraceRecord) error {
+	return json.NewEncoder(w).Encode(Convert(records))
+}
@@ -0,0 +1,45 @@
+package timeline
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+func TestWrite(t *testing.T) {
+	records := []tracing.TraceRecord{
+		{
+			TracerIdentity: "client1",
+			TraceID:        18446744073709551615,
+			Tag:            "CreateTrace",
+			Body:           json.RawMessage(`{}`),
+			VectorClock:    map[string]uint64{"client1": 1},
+		},
+		{
+			TracerIdentity: "client2",
+			TraceID:        18446744073709551615,
+			Tag:            "ReceiveTokenTrace",
+			Body:           json.RawMessage(`{}`),
+			VectorClock:    map[string]uint64{"client1": 2, "client2": 1},
+		},
+		{
+			TracerIdentity: "client1",
+			TraceID:        18446744073709551615,
+			Tag:            "GenerateTokenTrace",
+			Body:           json.RawMessage(`{}`),
+			VectorClock:    map[string]uint64{"client1": 2},
+		},
+	}
+	var b strings.Builder
+	if err := Write(&b, records); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"event":"CreateTrace","tracer":"client1","trace":"18446744073709551615","timestamp":1,"clock":{"client1":1}},` +
+		`{"event":"GenerateTokenTrace","tracer":"client1","trace":"18446744073709551615","timestamp":2,"clock":{"client1":2}},` +
+		`{"event":"ReceiveTokenTrace","tracer":"client2","trace":"18446744073709551615","timestamp":3,"clock":{"client1":2,"client2":1}}]` + "\n"
+	if b.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+}
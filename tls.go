@@ -0,0 +1,219 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+var errTLSTransport = errors.New("TLS is only supported over TCP and Unix domain sockets")
+
+var errTLSBinds = errors.New("client certificate authentication is only supported on ServerBind, " +
+	"other listeners would let unauthenticated tracers in")
+
+var errTLSServerBind = errors.New("with client certificate authentication on TLSBind, ServerBind must be a loopback address " +
+	"or a Unix domain socket, as it would let unauthenticated tracers in")
+
+var errTLSBind = errors.New("TLSBind requires TLSCertFile and TLSKeyFile")
+
+// serverTLSConfig returns the TLS configuration of the server's listener,
+// or nil if TLS is not configured.
+func (config *TracingServerConfig) serverTLSConfig() (*tls.Config, error) {
+	if config.TLSCertFile == "" && config.ClientCAFile == "" {
+		return nil, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}}
+	if config.ClientCAFile != "" {
+		clientCAs, err := loadCertPool(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// tracerTLSConfig returns the TLS configuration of the tracer's connection,
+// or nil if TLS is not configured.
+func (config *TracerConfig) tracerTLSConfig() (*tls.Config, error) {
+	if config.TLSCertFile == "" && config.RootCAFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{}
+	if config.TLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if config.RootCAFile != "" {
+		rootCAs, err := loadCertPool(config.RootCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	return tlsConfig, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// isLoopbackAddress returns whether bind, as in ServerBind, can only be
+// reached from the local host.
+func isLoopbackAddress(bind string) bool {
+	network, address := parseAddress(bind)
+	if network == "unix" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenTLS serves the RPCs of the tracers connecting to TLSBind over TLS,
+// as those of the Listener, until the server is closed.
+func (tracingServer *TracingServer) listenTLS(tlsConfig *tls.Config) error {
+	listener, err := net.Listen(parseAddress(tracingServer.Config.TLSBind))
+	if err != nil {
+		return err
+	}
+	listener = tls.NewListener(listener, tlsConfig)
+	tracingServer.TLSListener = listener
+	l := &rpcListener{listener: listener, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go tracingServer.serveConn(conn)
+		}
+	}()
+	tracingServer.listeners = append(tracingServer.listeners, l)
+	return nil
+}
+
+// serveConn serves the RPCs of a tracer connected to the server's Listener,
+// once it passed the handshake of serverHandshake if the server has a
+// Secret or Identities, unless its host is banned for exceeding the
+// ConnectionRateLimit. Over TLS connections with a client certificate, the
+// tracer may only use the identity in the certificate's subject common
+// name. The connection is recorded in the AuditLogFile, if any.
+func (tracingServer *TracingServer) serveConn(conn net.Conn) {
+	audit := newConnectionAudit(conn)
+	refuse := func(reason string) {
+		if tracingServer.audit != nil {
+			tracingServer.audit.refuse(audit, reason)
+		}
+		conn.Close()
+	}
+	if !tracingServer.limiter.allowConnection(conn) {
+		refuse("host banned for exceeding the connection rate limit")
+		return
+	}
+	if credentials := tracingServer.credentials(); credentials.enabled() {
+		audit.authentication = "handshake"
+		identity, err := serverHandshake(conn, credentials)
+		audit.identity = identity
+		if err != nil {
+			refuse(err.Error())
+			return
+		}
+	}
+	var handler TransportHandler
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			refuse(err.Error())
+			return
+		}
+		if peerCertificates := tlsConn.ConnectionState().PeerCertificates; len(peerCertificates) > 0 {
+			audit.authentication = "certificate"
+			audit.identity = peerCertificates[0].Subject.CommonName
+			handler = identityHandler{
+				TransportHandler: transportHandler{server: tracingServer},
+				identity:         peerCertificates[0].Subject.CommonName,
+			}
+		}
+	}
+	if rate := tracingServer.Config.ConnectionRateLimit; rate > 0 {
+		if handler == nil {
+			handler = transportHandler{server: tracingServer}
+		}
+		handler = connectionLimitHandler{
+			TransportHandler: handler,
+			limiter:          tracingServer.limiter,
+			bucket:           newRateBucket(rate, tracingServer.Config.RateLimitBurst, time.Now()),
+			conn:             conn,
+		}
+	}
+	if tracingServer.audit != nil {
+		if handler == nil {
+			handler = transportHandler{server: tracingServer}
+		}
+		handler = auditHandler{TransportHandler: handler, audit: audit}
+		tracingServer.audit.connect(audit)
+		defer tracingServer.audit.disconnect(audit)
+	}
+	if handler == nil {
+		tracingServer.rpcServer.ServeConn(conn)
+		return
+	}
+	rpcServer, err := newRPCServer(handler)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	rpcServer.ServeConn(conn)
+}
+
+// identityHandler restricts the requests of a tracer authenticated as
+// identity to that identity, so that it cannot impersonate other tracers.
+type identityHandler struct {
+	TransportHandler
+	identity string
+}
+
+func (h identityHandler) RecordAction(arg RecordActionArg) error {
+	if arg.TracerIdentity != h.identity {
+		return fmt.Errorf("tracer identity %q does not match the client certificate's %q",
+			arg.TracerIdentity, h.identity)
+	}
+	return h.TransportHandler.RecordAction(arg)
+}
+
+func (h identityHandler) GetLastVC(arg string) (vclock.VClock, error) {
+	if identity, _ := splitIdentity(arg); identity != h.identity {
+		return nil, fmt.Errorf("tracer identity %q does not match the client certificate's %q",
+			identity, h.identity)
+	}
+	return h.TransportHandler.GetLastVC(arg)
+}
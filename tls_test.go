@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues the certificates of the mutual TLS tests.
+type testCA struct {
+	t           *testing.T
+	dir         string
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	serial      int64
+}
+
+func newTestCA(t *testing.T, dir string) *testCA {
+	ca := &testCA{t: t, dir: dir}
+	ca.certificate, ca.key = ca.issue("ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	return ca
+}
+
+// issue signs template with the CA (or self-signs it for the CA itself),
+// and writes the certificate and key to name.pem and name.key.
+func (ca *testCA) issue(name string, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	ca.serial++
+	template.SerialNumber = big.NewInt(ca.serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, parentKey := ca.certificate, ca.key
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	ca.writePEM(name+".pem", "CERTIFICATE", der)
+	ca.writePEM(name+".key", "EC PRIVATE KEY", keyDER)
+	return certificate, key
+}
+
+func (ca *testCA) writePEM(name, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(ca.dir, name), data, 0600); err != nil {
+		ca.t.Fatal(err)
+	}
+}
+
+func (ca *testCA) file(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	ca.issue("server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	ca.issue("client1", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client1"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       "127.0.0.1:0",
+		OutputFile:       filepath.Join(dir, "output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		TLSCertFile:      ca.file("server.pem"),
+		TLSKeyFile:       ca.file("server.key"),
+		ClientCAFile:     ca.file("ca.pem"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	for _, config := range []TracerConfig{
+		// the provisioned tracer
+		{TracerIdentity: "client1", TLSCertFile: ca.file("client1.pem"), TLSKeyFile: ca.file("client1.key")},
+		// a tracer impersonating another one
+		{TracerIdentity: "client2", TLSCertFile: ca.file("client1.pem"), TLSKeyFile: ca.file("client1.key")},
+		// a tracer without certificate
+		{TracerIdentity: "client3"},
+	} {
+		config.ServerAddress = server.Listener.Addr().String()
+		config.RootCAFile = ca.file("ca.pem")
+		tracer := NewTracerNonFatal(config)
+		if tracer == nil {
+			t.Fatalf("%s could not connect", config.TracerIdentity)
+		}
+		tracer.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+		tracer.Close()
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := readTraceOutputFile(t, server.Config.OutputFile)
+	if len(outputs) != 2 {
+		t.Fatalf("expected the 2 records of client1, got %v", outputs)
+	}
+	for _, output := range outputs {
+		if identity := output.(map[string]interface{})["TracerIdentity"]; identity != "client1" {
+			t.Fatalf("unexpected record from %v", identity)
+		}
+	}
+}
+
+func TestClientCertificatesOtherBinds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	ca.issue("server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	})
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       "127.0.0.1:0",
+		OutputFile:       filepath.Join(dir, "output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		UDPBind:          "127.0.0.1:0",
+		TLSCertFile:      ca.file("server.pem"),
+		TLSKeyFile:       ca.file("server.key"),
+		ClientCAFile:     ca.file("ca.pem"),
+	})
+	if err := server.Open(); err != errTLSBinds {
+		t.Fatalf("expected %v, got %v", errTLSBinds, err)
+	}
+}
+
+func TestTLSBind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	ca.issue("server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	config := TracingServerConfig{
+		ServerBind:  ":0",
+		TLSBind:     "127.0.0.1:0",
+		InMemory:    true,
+		TLSCertFile: ca.file("server.pem"),
+		TLSKeyFile:  ca.file("server.key"),
+	}
+	server := NewTracingServer(config)
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	// a tracer over TLS, and a local one over the plaintext listener
+	for _, config := range []TracerConfig{
+		{ServerAddress: server.TLSListener.Addr().String(), TracerIdentity: "client1", RootCAFile: ca.file("ca.pem")},
+		{ServerAddress: server.Listener.Addr().String(), TracerIdentity: "client2"},
+	} {
+		tracer := NewTracerNonFatal(config)
+		if tracer == nil {
+			t.Fatalf("%s could not connect", config.TracerIdentity)
+		}
+		tracer.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+		tracer.Close()
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if records := server.Records(); len(records) != 4 {
+		t.Fatalf("expected the 4 records of client1 and client2, got %v", records)
+	}
+
+	// the plaintext listener would let tracers without certificate in
+	config.ClientCAFile = ca.file("ca.pem")
+	if err := NewTracingServer(config).Open(); err != errTLSServerBind {
+		t.Fatalf("expected %v, got %v", errTLSServerBind, err)
+	}
+}
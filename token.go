@@ -0,0 +1,412 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/DistributedClocks/tracing/tracingpb"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+	"google.golang.org/protobuf/proto"
+)
+
+// Token format versions. Every token starts with its version byte, except
+// for tokens produced by releases that predate versioning, which are plain
+// msgpack-encoded GoVector payloads and always start with a msgpack string
+// header (the sender's identity).
+const (
+	tokenVersionLegacy   byte = 0x00 // never written; identifies unversioned tokens
+	tokenVersionCompact  byte = 0x01 // see encodeCompactToken
+	tokenVersionMsgpack  byte = 0x02 // a msgpack-encoded GoVector payload
+	tokenVersionProtobuf byte = 0x03 // a tracingpb.TracingToken message
+)
+
+// tokenDecoders holds a decoder for every token version this release can
+// read. Decoders for older versions must be kept around, so that traces and
+// tokens produced by older clients can still be consumed.
+var tokenDecoders = map[byte]func(token []byte, d *govec.VClockPayload) error{
+	tokenVersionLegacy:  decodeMsgpackToken,
+	tokenVersionCompact: decodeCompactToken,
+	tokenVersionMsgpack: func(token []byte, d *govec.VClockPayload) error {
+		return decodeMsgpackToken(token[1:], d)
+	},
+	tokenVersionProtobuf: decodeProtobufToken,
+}
+
+var errMalformedToken = errors.New("malformed tracing token")
+
+// tokenPayload is the tracing-specific content of a token, carried alongside
+// the sender's vector clock.
+type tokenPayload struct {
+	TraceID   uint64
+	Metadata  []byte // opaque caller data, see Trace.GenerateTokenWithMetadata
+	Broadcast bool   // whether the token may legitimately be received more than once
+}
+
+// compact format flags
+const (
+	tokenFlagBroadcast byte = 1 << iota
+)
+
+// EncodeMsgpack encodes the payload of a msgpack-format token. Payloads
+// without metadata or flags are encoded as the bare trace ID, as they have
+// always been, so that older tracers can still decode them.
+func (p *tokenPayload) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if p.Metadata == nil && !p.Broadcast {
+		return enc.EncodeUint(p.TraceID)
+	}
+	if err := enc.EncodeArrayLen(3); err != nil {
+		return err
+	}
+	if err := enc.EncodeUint(p.TraceID); err != nil {
+		return err
+	}
+	if err := enc.EncodeBytes(p.Metadata); err != nil {
+		return err
+	}
+	return enc.EncodeBool(p.Broadcast)
+}
+
+// DecodeMsgpack decodes the payload of a msgpack-format token, accepting
+// both the bare trace ID and the [trace ID, metadata, broadcast] forms.
+func (p *tokenPayload) DecodeMsgpack(dec *msgpack.Decoder) error {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+	if !msgpcode.IsFixedArray(code) && code != msgpcode.Array16 && code != msgpcode.Array32 {
+		p.TraceID, err = dec.DecodeUint64()
+		return err
+	}
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+	if n != 3 {
+		return errMalformedToken
+	}
+	if p.TraceID, err = dec.DecodeUint64(); err != nil {
+		return err
+	}
+	if p.Metadata, err = dec.DecodeBytes(); err != nil {
+		return err
+	}
+	p.Broadcast, err = dec.DecodeBool()
+	return err
+}
+
+// TokenInfo describes the contents of a TracingToken.
+type TokenInfo struct {
+	TraceID     uint64        // the ID of the trace the token belongs to
+	Tracer      string        // the identity of the tracer that generated the token
+	VectorClock vclock.VClock // the generating tracer's vector clock at generation time
+	Metadata    []byte        // the metadata embedded in the token, if any
+	Broadcast   bool          // whether the token is a broadcast token
+}
+
+// InspectToken decodes token and returns its contents, without recording
+// anything or affecting the state of any tracer. It is meant for debugging,
+// e.g. to log which trace a message is part of; use Tracer.ReceiveToken to
+// actually receive a token.
+func InspectToken(token TracingToken) (TokenInfo, error) {
+	var payload tokenPayload
+	d := govec.VClockPayload{Payload: &payload}
+	if err := decodeToken(token, &d); err != nil {
+		return TokenInfo{}, err
+	}
+	return TokenInfo{
+		TraceID:     payload.TraceID,
+		Tracer:      d.Pid,
+		VectorClock: vclock.VClock(d.VcMap),
+		Metadata:    payload.Metadata,
+		Broadcast:   payload.Broadcast,
+	}, nil
+}
+
+// tokenCodec implements GoVector's encoding and decoding strategies for
+// tracing tokens.
+//
+// Tokens are encoded as version, one of tokenVersionMsgpack,
+// tokenVersionCompact and tokenVersionProtobuf. Decoding accepts any version
+// in tokenDecoders regardless of version, so tracers with different settings
+// (or from different releases) can exchange tokens.
+type tokenCodec struct {
+	version byte
+}
+
+func (c tokenCodec) encode(payload interface{}) ([]byte, error) {
+	d, p, err := unwrapTokenPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	switch c.version {
+	case tokenVersionCompact:
+		return encodeCompactToken(p, d.Pid, d.VcMap), nil
+	case tokenVersionProtobuf:
+		return encodeProtobufToken(p, d.Pid, d.VcMap)
+	}
+	encoded, err := msgpack.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tokenVersionMsgpack}, encoded...), nil
+}
+
+func (c tokenCodec) decode(buf []byte, payload interface{}) error {
+	d, _, err := unwrapTokenPayload(payload)
+	if err != nil {
+		return err
+	}
+	return decodeToken(buf, d)
+}
+
+func unwrapTokenPayload(payload interface{}) (*govec.VClockPayload, *tokenPayload, error) {
+	d, ok := payload.(*govec.VClockPayload)
+	if !ok {
+		return nil, nil, errors.New("unexpected token payload type")
+	}
+	p, ok := d.Payload.(*tokenPayload)
+	if !ok {
+		return nil, nil, errors.New("unexpected token payload type")
+	}
+	return d, p, nil
+}
+
+// tokenVersion returns the format version of token.
+func tokenVersion(token []byte) byte {
+	if len(token) == 0 {
+		return tokenVersionLegacy
+	}
+	switch code := token[0]; {
+	case msgpcode.IsFixedString(code), code == msgpcode.Str8,
+		code == msgpcode.Str16, code == msgpcode.Str32:
+		return tokenVersionLegacy
+	default:
+		return code
+	}
+}
+
+// decodeToken decodes token, of any supported version, into d, whose Payload
+// must be a *tokenPayload.
+func decodeToken(token []byte, d *govec.VClockPayload) error {
+	version := tokenVersion(token)
+	decoder, ok := tokenDecoders[version]
+	if !ok {
+		return fmt.Errorf("unsupported tracing token version %d", version)
+	}
+	return decoder(token, d)
+}
+
+// decodeMsgpackToken decodes a msgpack-encoded GoVector payload. It mirrors
+// govec.VClockPayload.DecodeMsgpack, which also tries to decode a trailing
+// copy of the fields that is never written.
+func decodeMsgpackToken(token []byte, d *govec.VClockPayload) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(token))
+	pid, err := dec.DecodeString()
+	if err != nil {
+		return errMalformedToken
+	}
+	if err := dec.Decode(d.Payload); err != nil {
+		return errMalformedToken
+	}
+	n, err := dec.DecodeMapLen()
+	if err != nil || n < 0 {
+		return errMalformedToken
+	}
+	clock := make(map[string]uint64, n)
+	for i := 0; i < n; i++ {
+		id, err := dec.DecodeString()
+		if err != nil {
+			return errMalformedToken
+		}
+		if clock[id], err = dec.DecodeUint64(); err != nil {
+			return errMalformedToken
+		}
+	}
+	d.Pid = pid
+	d.VcMap = clock
+	return nil
+}
+
+type clockEntry struct {
+	id    string
+	ticks uint64
+}
+
+// encodeCompactToken encodes a token in the compact format:
+//  0x01 | flags | traceID | len(meta) meta | len(pid) pid | clock[pid] | n | n * (len(id) id | delta)
+// where flags is a single byte and every integer is an unsigned varint. The
+// sender's identity is written once rather than repeated as a clock entry,
+// entries with a zero value are pruned (merging them is a no-op), and the
+// remaining entries are sorted by value and delta-encoded, which keeps the
+// varints short.
+func encodeCompactToken(p *tokenPayload, pid string, clock map[string]uint64) []byte {
+	entries := make([]clockEntry, 0, len(clock))
+	for id, ticks := range clock {
+		if id == pid || ticks == 0 {
+			continue
+		}
+		entries = append(entries, clockEntry{id: id, ticks: ticks})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ticks != entries[j].ticks {
+			return entries[i].ticks < entries[j].ticks
+		}
+		return entries[i].id < entries[j].id
+	})
+
+	var buf bytes.Buffer
+	buf.WriteByte(tokenVersionCompact)
+	var flags byte
+	if p.Broadcast {
+		flags |= tokenFlagBroadcast
+	}
+	buf.WriteByte(flags)
+	putUvarint(&buf, p.TraceID)
+	putBytes(&buf, p.Metadata)
+	putString(&buf, pid)
+	putUvarint(&buf, clock[pid])
+	putUvarint(&buf, uint64(len(entries)))
+	var prev uint64
+	for _, entry := range entries {
+		putString(&buf, entry.id)
+		putUvarint(&buf, entry.ticks-prev)
+		prev = entry.ticks
+	}
+	return buf.Bytes()
+}
+
+func decodeCompactToken(token []byte, d *govec.VClockPayload) error {
+	p, ok := d.Payload.(*tokenPayload)
+	if !ok {
+		return errors.New("unexpected token payload type")
+	}
+	r := bytes.NewReader(token)
+	if version, err := r.ReadByte(); err != nil || version != tokenVersionCompact {
+		return errMalformedToken
+	}
+	flags, err := r.ReadByte()
+	if err != nil {
+		return errMalformedToken
+	}
+	p.Broadcast = flags&tokenFlagBroadcast != 0
+	if p.TraceID, err = binary.ReadUvarint(r); err != nil {
+		return errMalformedToken
+	}
+	if p.Metadata, err = readBytes(r); err != nil {
+		return err
+	}
+	pid, err := readString(r)
+	if err != nil {
+		return err
+	}
+	ownTicks, err := binary.ReadUvarint(r)
+	if err != nil {
+		return errMalformedToken
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return errMalformedToken
+	}
+	clock := make(map[string]uint64, n+1)
+	clock[pid] = ownTicks
+	var prev uint64
+	for i := uint64(0); i < n; i++ {
+		id, err := readString(r)
+		if err != nil {
+			return err
+		}
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errMalformedToken
+		}
+		prev += delta
+		clock[id] = prev
+	}
+	if r.Len() != 0 {
+		return errMalformedToken
+	}
+	d.Pid = pid
+	d.VcMap = clock
+	return nil
+}
+
+// encodeProtobufToken encodes a token as the version byte followed by a
+// tracingpb.TracingToken message, which tracers written in other languages
+// can decode with code generated from tracing.proto.
+func encodeProtobufToken(p *tokenPayload, pid string, clock map[string]uint64) ([]byte, error) {
+	encoded, err := proto.Marshal(&tracingpb.TracingToken{
+		TraceId:        p.TraceID,
+		TracerIdentity: pid,
+		VectorClock:    clock,
+		Metadata:       p.Metadata,
+		Broadcast:      p.Broadcast,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tokenVersionProtobuf}, encoded...), nil
+}
+
+func decodeProtobufToken(token []byte, d *govec.VClockPayload) error {
+	p, ok := d.Payload.(*tokenPayload)
+	if !ok {
+		return errors.New("unexpected token payload type")
+	}
+	var message tracingpb.TracingToken
+	if err := proto.Unmarshal(token[1:], &message); err != nil {
+		return errMalformedToken
+	}
+	p.TraceID = message.TraceId
+	p.Metadata = message.Metadata
+	p.Broadcast = message.Broadcast
+	d.Pid = message.TracerIdentity
+	d.VcMap = message.VectorClock
+	if d.VcMap == nil {
+		d.VcMap = make(map[string]uint64)
+	}
+	return nil
+}
+
+func putUvarint(buf *bytes.Buffer, x uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutUvarint(scratch[:], x)])
+}
+
+func putString(buf *bytes.Buffer, s string) {
+	putUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func putBytes(buf *bytes.Buffer, b []byte) {
+	putUvarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	b, err := readBytes(r)
+	return string(b), err
+}
+
+// readBytes reads a length-prefixed byte string, returning nil for an empty
+// one.
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return nil, errMalformedToken
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, errMalformedToken
+	}
+	return b, nil
+}
@@ -0,0 +1,357 @@
+package tracing
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCompactTokenRoundTrip(t *testing.T) {
+	clock := map[string]uint64{
+		"client1": 7,
+		"client2": 3,
+		"client3": 3,
+		"client4": 0,
+		"client5": 1 << 40,
+	}
+	codec := tokenCodec{version: tokenVersionCompact}
+	payload := &tokenPayload{TraceID: 42, Metadata: []byte("request-7")}
+	token, err := codec.encode(&govec.VClockPayload{Pid: "client1", VcMap: clock, Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token[0] != tokenVersionCompact {
+		t.Fatalf("expected compact token, got leading byte %#x", token[0])
+	}
+
+	var decodedPayload tokenPayload
+	decoded := govec.VClockPayload{Payload: &decodedPayload}
+	if err := codec.decode(token, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	delete(clock, "client4")
+	if !cmp.Equal(&decodedPayload, payload) || decoded.Pid != "client1" || !cmp.Equal(decoded.VcMap, clock) {
+		t.Fatalf("decoded token (%v, %s, %v) does not match the encoded one", decodedPayload, decoded.Pid, decoded.VcMap)
+	}
+
+	for i := 1; i < len(token); i++ {
+		if err := codec.decode(token[:i], &govec.VClockPayload{Payload: &tokenPayload{}}); err == nil {
+			t.Fatalf("decoding a token truncated to %d bytes did not fail", i)
+		}
+	}
+}
+
+func TestCompactTokenActions(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	defer closeServer()
+	serverBind := server.Listener.Addr().String()
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  serverBind,
+		TracerIdentity: "client1",
+		CompactTokens:  true,
+	})
+	defer client1.Close()
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  serverBind,
+		TracerIdentity: "client2",
+	})
+	defer client2.Close()
+
+	trace1 := client1.CreateTrace()
+	token := trace1.GenerateToken()
+	if token[0] != tokenVersionCompact {
+		t.Fatalf("expected compact token, got leading byte %#x", token[0])
+	}
+	trace2 := client2.ReceiveToken(token)
+	if trace1.ID != trace2.ID {
+		t.Fatalf("trace1.ID and trace2.ID are not equal")
+	}
+
+	// tokens generated by a tracer using the default format are still
+	// understood by a tracer using the compact one
+	trace2.RecordAction(TestAction{Foo: "foo"})
+	client1.ReceiveToken(trace2.GenerateToken())
+
+	expected := vclock.VClock{"client1": 3, "client2": 3}
+	if vc := client1.logger.GetCurrentVC(); !cmp.Equal(vc, expected) {
+		t.Fatalf("expected clock %v, got %v", expected, vc)
+	}
+}
+
+func TestTokenMetadata(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	defer closeServer()
+	serverBind := server.Listener.Addr().String()
+
+	for _, compact := range []bool{false, true} {
+		client1 := NewTracer(TracerConfig{
+			ServerAddress:  serverBind,
+			TracerIdentity: "client1",
+			CompactTokens:  compact,
+		})
+		defer client1.Close()
+		client2 := NewTracer(TracerConfig{
+			ServerAddress:  serverBind,
+			TracerIdentity: "client2",
+		})
+		defer client2.Close()
+
+		trace1 := client1.CreateTrace()
+		trace2, metadata := client2.ReceiveTokenWithMetadata(trace1.GenerateTokenWithMetadata([]byte("shard-3")))
+		if trace1.ID != trace2.ID {
+			t.Fatalf("trace1.ID and trace2.ID are not equal")
+		}
+		if string(metadata) != "shard-3" {
+			t.Fatalf("expected metadata %q, got %q", "shard-3", metadata)
+		}
+
+		_, metadata = client1.ReceiveTokenWithMetadata(trace2.GenerateToken())
+		if metadata != nil {
+			t.Fatalf("expected no metadata, got %q", metadata)
+		}
+	}
+}
+
+func TestGenerateTokenFor(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	serverBind := server.Listener.Addr().String()
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  serverBind,
+		TracerIdentity: "client1",
+	})
+	trace := client1.CreateTrace()
+	token := trace.GenerateTokenFor("client2")
+	client1.Close()
+	closeServer()
+
+	bToken, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := readTraceOutputFile(t, server.Config.OutputFile)
+	expected := map[string]interface{}{
+		"TracerIdentity": "client1",
+		"TracerSeq":      intToJSONNubmer(2),
+		"TraceID":        traceIDtoJSONNumber(trace.ID),
+		"Tag":            "GenerateTokenTrace",
+		"Body": map[string]interface{}{
+			"Token":     strings.Trim(string(bToken), "\""),
+			"Recipient": "client2",
+		},
+		"VectorClock": map[string]interface{}{
+			"client1": intToJSONNubmer(2),
+		},
+	}
+	if len(outputs) != 2 || !cmp.Equal(outputs[1], expected) {
+		t.Fatalf("expected record %v, got trace %v", expected, outputs)
+	}
+}
+
+func TestDuplicateTokenReception(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	serverBind := server.Listener.Addr().String()
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  serverBind,
+		TracerIdentity: "client1",
+	})
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  serverBind,
+		TracerIdentity: "client2",
+		CompactTokens:  true,
+	})
+
+	trace := client1.CreateTrace()
+	token := trace.GenerateToken()
+	client2.ReceiveToken(token)
+	client2.ReceiveToken(token)
+	broadcastToken := trace.GenerateBroadcastToken()
+	client2.ReceiveToken(broadcastToken)
+	client2.ReceiveToken(broadcastToken)
+	client1.Close()
+	client2.Close()
+	closeServer()
+
+	var duplicates []bool
+	for _, output := range readTraceOutputFile(t, server.Config.OutputFile) {
+		record := output.(map[string]interface{})
+		if record["Tag"] == "ReceiveTokenTrace" {
+			_, duplicate := record["Body"].(map[string]interface{})["Duplicate"]
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+	if expected := []bool{false, true, false, false}; !cmp.Equal(duplicates, expected) {
+		t.Fatalf("expected duplicate flags %v, got %v", expected, duplicates)
+	}
+}
+
+func TestTokenSet(t *testing.T) {
+	s := newTokenSet(2)
+	digests := make([][sha256.Size]byte, 3)
+	for i := range digests {
+		digests[i] = sha256.Sum256([]byte{byte(i)})
+	}
+	if s.add(digests[0]) || s.add(digests[1]) || !s.add(digests[0]) {
+		t.Fatal("expected only the second reception of a token to be a duplicate")
+	}
+	// the oldest token is forgotten past the maximum
+	if s.add(digests[2]) || !s.add(digests[1]) || s.add(digests[0]) {
+		t.Fatal("expected the oldest token to be forgotten")
+	}
+	if len(s.digests) != 2 {
+		t.Fatalf("expected 2 tokens held, got %d", len(s.digests))
+	}
+}
+
+func TestTokenVersions(t *testing.T) {
+	clock := map[string]uint64{"client1": 4, "client2": 2}
+
+	// tokens produced by releases that predate token versioning carry the
+	// bare trace ID as their GoVector payload
+	legacyToken, err := msgpack.Marshal(&govec.VClockPayload{Pid: "client1", VcMap: clock, Payload: uint64(42)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgpackToken, err := tokenCodec{version: tokenVersionMsgpack}.encode(&govec.VClockPayload{
+		Pid: "client1", VcMap: clock, Payload: &tokenPayload{TraceID: 42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactToken, err := tokenCodec{version: tokenVersionCompact}.encode(&govec.VClockPayload{
+		Pid: "client1", VcMap: clock, Payload: &tokenPayload{TraceID: 42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protobufToken, err := tokenCodec{version: tokenVersionProtobuf}.encode(&govec.VClockPayload{
+		Pid: "client1", VcMap: clock, Payload: &tokenPayload{TraceID: 42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for version, token := range map[byte]TracingToken{
+		tokenVersionLegacy:   legacyToken,
+		tokenVersionMsgpack:  msgpackToken,
+		tokenVersionCompact:  compactToken,
+		tokenVersionProtobuf: protobufToken,
+	} {
+		if v := tokenVersion(token); v != version {
+			t.Fatalf("expected token version %d, got %d", version, v)
+		}
+		var payload tokenPayload
+		decoded := govec.VClockPayload{Payload: &payload}
+		if err := decodeToken(token, &decoded); err != nil {
+			t.Fatalf("decoding version %d token: %v", version, err)
+		}
+		if payload.TraceID != 42 || decoded.Pid != "client1" || !cmp.Equal(decoded.VcMap, clock) {
+			t.Fatalf("decoded version %d token (%v, %s, %v) does not match the encoded one",
+				version, payload, decoded.Pid, decoded.VcMap)
+		}
+	}
+
+	unknownToken := append([]byte{0x7f}, compactToken[1:]...)
+	if err := decodeToken(unknownToken, &govec.VClockPayload{Payload: &tokenPayload{}}); err == nil {
+		t.Fatal("decoding a token of an unknown version did not fail")
+	}
+}
+
+func TestInspectToken(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	defer closeServer()
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	defer client1.Close()
+
+	trace := client1.CreateTrace()
+	token := trace.GenerateTokenWithMetadata([]byte("request-1"))
+	vcBefore := client1.logger.GetCurrentVC().Copy()
+
+	info, err := InspectToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TokenInfo{
+		TraceID:     trace.ID,
+		Tracer:      "client1",
+		VectorClock: vclock.VClock{"client1": 2},
+		Metadata:    []byte("request-1"),
+	}
+	if !cmp.Equal(info, expected) {
+		t.Fatalf("expected token info %v, got %v", expected, info)
+	}
+	if vc := client1.logger.GetCurrentVC(); !cmp.Equal(vc, vcBefore) {
+		t.Fatalf("inspecting a token changed the tracer clock from %v to %v", vcBefore, vc)
+	}
+
+	if _, err := InspectToken(TracingToken{0x01, 0x00}); err == nil {
+		t.Fatal("inspecting a malformed token did not fail")
+	}
+}
+
+func TestReceiveTokens(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	serverBind := server.Listener.Addr().String()
+
+	var clients []*Tracer
+	for _, identity := range []string{"client1", "client2", "client3"} {
+		clients = append(clients, NewTracer(TracerConfig{
+			ServerAddress:  serverBind,
+			TracerIdentity: identity,
+		}))
+	}
+
+	trace1 := clients[0].CreateTrace()
+	trace2 := clients[1].ReceiveToken(trace1.GenerateToken())
+	trace2.RecordAction(TestAction{Foo: "foo"})
+	// the trace is that of the first token decoded
+	trace3 := clients[2].ReceiveTokens([]TracingToken{TracingToken("garbage"), trace1.GenerateToken(), trace2.GenerateToken()})
+	if trace3.ID != trace1.ID {
+		t.Fatalf("expected trace ID %d, got %d", trace1.ID, trace3.ID)
+	}
+	for _, client := range clients {
+		client.Close()
+	}
+	closeServer()
+
+	outputs := readTraceOutputFile(t, server.Config.OutputFile)
+	last := outputs[len(outputs)-1].(map[string]interface{})
+	expectedClock := map[string]interface{}{
+		"client1": intToJSONNubmer(3),
+		"client2": intToJSONNubmer(3),
+		"client3": intToJSONNubmer(1),
+	}
+	if last["Tag"] != "ReceiveTokensTrace" || !cmp.Equal(last["VectorClock"], expectedClock) {
+		t.Fatalf("expected a single ReceiveTokensTrace with clock %v, got %v", expectedClock, last)
+	}
+	if tokens := last["Body"].(map[string]interface{})["Tokens"].([]interface{}); len(tokens) != 3 {
+		t.Fatalf("expected 3 recorded tokens, got %d", len(tokens))
+	}
+}
+
+func TestTokenBudgetReport(t *testing.T) {
+	clock := vclock.VClock{"client1": 3, "a-much-longer-tracer-identity": 1}
+	payload := &tokenPayload{TraceID: 42, Metadata: []byte("meta")}
+	budget := tokenBudget{maxSize: 8, version: tokenVersionCompact}
+	token := encodeCompactToken(payload, "client1", clock)
+
+	report := budget.report(token, payload, clock)
+	expected := fmt.Sprintf("token of %d bytes exceeds the maximum token size of 8 bytes: "+
+		"metadata 4 bytes, 2 clock entries 40 bytes (a-much-longer-tracer-identity=31B, client1=9B)", len(token))
+	if report != expected {
+		t.Fatalf("expected report %q, got %q", expected, report)
+	}
+}
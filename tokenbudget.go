@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxReportedTokenEntries bounds the number of clock entries listed in a
+// token size report.
+const maxReportedTokenEntries = 10
+
+// tokenBudget enforces TracerConfig.MaxTokenSize on generated tokens.
+type tokenBudget struct {
+	maxSize int
+	strict  bool
+	version byte // the format of the checked tokens
+}
+
+// check reports token if it exceeds the budget: it logs the size breakdown,
+// or exits with it in strict mode.
+func (b tokenBudget) check(token TracingToken, payload *tokenPayload, clock vclock.VClock) {
+	if b.maxSize <= 0 || len(token) <= b.maxSize {
+		return
+	}
+	report := b.report(token, payload, clock)
+	if b.strict {
+		log.Fatal(report)
+	}
+	log.Print(report)
+}
+
+// report describes where the bytes of an oversized token go: the embedded
+// metadata, and the clock entries, largest first.
+func (b tokenBudget) report(token TracingToken, payload *tokenPayload, clock vclock.VClock) string {
+	type entrySize struct {
+		id   string
+		size int
+	}
+	entries := make([]entrySize, 0, len(clock))
+	clockSize := 0
+	for id, ticks := range clock {
+		size := b.entrySize(id, ticks)
+		entries = append(entries, entrySize{id: id, size: size})
+		clockSize += size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].size != entries[j].size {
+			return entries[i].size > entries[j].size
+		}
+		return entries[i].id < entries[j].id
+	})
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "token of %d bytes exceeds the maximum token size of %d bytes: "+
+		"metadata %d bytes, %d clock entries %d bytes (",
+		len(token), b.maxSize, len(payload.Metadata), len(entries), clockSize)
+	for i, entry := range entries {
+		if i == maxReportedTokenEntries {
+			fmt.Fprintf(&report, ", ... %d more", len(entries)-i)
+			break
+		}
+		if i > 0 {
+			report.WriteString(", ")
+		}
+		fmt.Fprintf(&report, "%s=%dB", entry.id, entry.size)
+	}
+	report.WriteString(")")
+	return report.String()
+}
+
+// entrySize returns the (approximate, for the compact format, where values
+// are delta-encoded) number of bytes a clock entry takes up in a token.
+func (b tokenBudget) entrySize(id string, ticks uint64) int {
+	switch b.version {
+	case tokenVersionCompact:
+		return protowire.SizeBytes(len(id)) + protowire.SizeVarint(ticks)
+	case tokenVersionProtobuf:
+		// a map entry: a length-delimited message of a key and a value field
+		entry := protowire.SizeTag(1) + protowire.SizeBytes(len(id)) +
+			protowire.SizeTag(2) + protowire.SizeVarint(ticks)
+		return protowire.SizeTag(3) + protowire.SizeBytes(entry)
+	}
+	encodedID, _ := msgpack.Marshal(id)
+	encodedTicks, _ := msgpack.Marshal(ticks)
+	return len(encodedID) + len(encodedTicks)
+}
@@ -30,9 +30,9 @@ type Trace struct {
 //  [TracerID] TraceID=ID MyRecord Foo="foo", Bar="bar"
 func (trace *Trace) RecordAction(record interface{}) {
 	trace.Tracer.lock.Lock()
-	defer trace.Tracer.lock.Unlock()
-
-	trace.Tracer.recordAction(trace, record, true)
+	send := trace.Tracer.recordAction(trace, record, true)
+	trace.Tracer.lock.Unlock()
+	send()
 }
 
 // PrepareTokenTrace is an action that indicates start of generating a tracing
@@ -41,18 +41,57 @@ type PrepareTokenTrace struct{}
 
 // GenerateTokenTrace is an action that indicates generation of a tracing token.
 type GenerateTokenTrace struct {
-	Token TracingToken // the generated tracing token
+	Token     TracingToken // the generated tracing token
+	Recipient string       `json:",omitempty"` // the intended receiving tracer, if known
+	Broadcast bool         `json:",omitempty"` // whether the token may be received more than once
 }
 
 // GenerateToken produces a fresh TracingToken, and records the event via RecordAction.
 // This allows analysis of the resulting trace to correlate token generation
 // and token reception.
 func (trace *Trace) GenerateToken() TracingToken {
-	trace.Tracer.lock.Lock()
-	defer trace.Tracer.lock.Unlock()
+	return trace.GenerateTokenWithMetadata(nil)
+}
+
+// GenerateTokenWithMetadata behaves like GenerateToken, but also embeds
+// metadata in the token. The metadata is carried opaquely and handed back by
+// Tracer.ReceiveTokenWithMetadata, so small pieces of context (e.g., a request
+// ID or shard number) can travel with the token instead of in a separate
+// message field. Since it is sent along with every token, metadata should
+// be kept small.
+func (trace *Trace) GenerateTokenWithMetadata(metadata []byte) TracingToken {
+	return trace.generateToken(&tokenPayload{TraceID: trace.ID, Metadata: metadata}, "")
+}
 
+// GenerateTokenFor behaves like GenerateToken, but also records recipient,
+// the identity of the tracer the token is meant for, in the resulting
+// GenerateTokenTrace. This allows analysis to match the token generation
+// with its intended reception, even when the token is never received
+// (e.g., because the message carrying it was lost).
+func (trace *Trace) GenerateTokenFor(recipient string) TracingToken {
+	return trace.generateToken(&tokenPayload{TraceID: trace.ID}, recipient)
+}
+
+// GenerateBroadcastToken behaves like GenerateToken, but marks the token as
+// a broadcast token. A tracer receiving the same token more than once flags
+// the repeated receptions as duplicates (see ReceiveTokenTrace), unless the
+// token is a broadcast token, which may legitimately arrive several times
+// (e.g., when it is gossiped along several paths).
+func (trace *Trace) GenerateBroadcastToken() TracingToken {
+	return trace.generateToken(&tokenPayload{TraceID: trace.ID, Broadcast: true}, "")
+}
+
+func (trace *Trace) generateToken(payload *tokenPayload, recipient string) TracingToken {
+	trace.Tracer.lock.Lock()
 	token := trace.Tracer.logger.PrepareSend(trace.Tracer.getLogString(trace, PrepareTokenTrace{}),
-		trace.ID, govec.GetDefaultLogOptions())
-	trace.Tracer.recordAction(trace, GenerateTokenTrace{Token: token}, false)
+		payload, govec.GetDefaultLogOptions())
+	trace.Tracer.tokenBudget.check(token, payload, trace.Tracer.logger.GetCurrentVC())
+	send := trace.Tracer.recordAction(trace, GenerateTokenTrace{
+		Token:     token,
+		Recipient: recipient,
+		Broadcast: payload.Broadcast,
+	}, false)
+	trace.Tracer.lock.Unlock()
+	send()
 	return token
 }
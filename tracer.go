@@ -1,7 +1,7 @@
 package tracing
 
 import (
-	"fmt"
+	"crypto/sha256"
 	"log"
 	"math/rand"
 	"reflect"
@@ -10,10 +10,8 @@ import (
 
 	"encoding/json"
 	"io/ioutil"
-	"net/rpc"
 
 	"github.com/DistributedClocks/GoVector/govec"
-	"github.com/DistributedClocks/GoVector/govec/vclock"
 )
 
 // TracingToken is an abstract token to be used when tracing
@@ -26,27 +24,127 @@ type TracingToken []byte
 
 // TracerConfig contains the necessary configuration options for a tracer.
 type TracerConfig struct {
-	ServerAddress  string // address of the server to send traces to
+	ServerAddress  string // address of the server to send traces to: ip:port, unix:///path/to/socket, udp://ip:port, ws://ip:port, grpc://ip:port, proto://ip:port, or http://ip:port
 	TracerIdentity string // a unique string identifying the tracer
-	Secret         []byte // TODO
+	CompactTokens  bool   // encode generated tokens in the compact format, which prunes and delta-encodes clock entries
+	ProtobufTokens bool   // encode generated tokens as tracingpb.TracingToken messages, for tracers written in other languages; takes precedence over CompactTokens
+
+	// Secret, if set, is the key requests, and the handshake of connections
+	// over the default transport, are signed with, which must be the key of
+	// TracerIdentity in the server's Identities, or its Secret.
+	Secret []byte
+
+	// MaxTokenSize is the size, in bytes, above which generated tokens are
+	// reported along with a breakdown of their size (0 means no limit).
+	// When StrictTokenSize is set, exceeding it is fatal instead.
+	MaxTokenSize    int
+	StrictTokenSize bool
+
+	// Transport, if set, is dialed with ServerAddress instead of the
+	// built-in transport for its scheme.
+	Transport Transport `json:"-"`
+
+	// TLSCertFile and TLSKeyFile, if set, are the PEM-encoded certificate
+	// and key the tracer authenticates to the server with, when the server
+	// requires client certificates (see TracingServerConfig.ClientCAFile);
+	// the certificate's subject common name must be TracerIdentity.
+	// RootCAFile, if set, holds the CAs the server's certificate is verified
+	// against, instead of the system's. Setting any of them connects over
+	// TLS, which is only supported over TCP and Unix domain sockets.
+	TLSCertFile string
+	TLSKeyFile  string
+	RootCAFile  string
+
+	// Compression, if set to CompressionGzip or CompressionSnappy, is the
+	// algorithm large records are compressed with, when the server supports
+	// it. It is negotiated at connection time, over the net/rpc transports.
+	Compression string
+
+	// HeartbeatInterval, if positive, is the interval at which the tracer
+	// pings the server, to detect dead connections and reconnect. A ping
+	// fails if no reply arrives within HeartbeatTimeout, which defaults to
+	// HeartbeatInterval. Both are in nanoseconds in configuration files.
+	// Heartbeats are only supported by connection-oriented transports.
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+
+	// Connections, if greater than 1, is the number of connections the
+	// tracer opens to the server, to send records over several at once,
+	// as a single connection waits for each record to be acknowledged
+	// before sending the next. The records are sent over the connections
+	// in turn, and the server still writes them in the order the tracer
+	// recorded them, except over UDP, gRPC and framed protobuf, which do
+	// not carry their sequence numbers.
+	Connections int
+
+	// QueueSize, if positive, makes the tracer send its records in the
+	// background, one at a time and in order, for recording actions not to
+	// wait for the server: up to QueueSize records are queued to be sent.
+	// QueuePolicy is what recording an action does while the queue is
+	// full: QueueBlock (the default) waits for room in the queue, while
+	// QueueDropOldest and QueueDropNewest drop the oldest record queued, or
+	// the record of the action, see Tracer.DroppedRecords. Close sends the
+	// records queued first.
+	QueueSize   int
+	QueuePolicy string
 }
 
 // Tracer is the tracing client.
 type Tracer struct {
 	lock        sync.Mutex
 	identity    string
-	client      *rpc.Client
 	secret      []byte
 	shouldPrint bool
 	logger      *govec.GoLog
+	tokenBudget tokenBudget
+
+	// receivedTokens holds the digests of the last received tokens, to
+	// detect duplicate receptions
+	receivedTokens tokenSet
+
+	// conns are replaced by the heartbeat when they die, hence their own
+	// lock
+	connLock  sync.Mutex
+	conns     []TransportConn
+	transport Transport
+	address   string
+	heartbeat *heartbeat
+
+	compression string
+
+	// session identifies the tracer instance to the server, and seq
+	// numbers the records it sent, under lock
+	session uint64
+	seq     uint64
+	// stripes are held from numbering a record until it is sent over the
+	// connection of the same index
+	stripes []sync.Mutex
+	// pacer paces the records sent while the server is overloaded
+	pacer pacer
+	// queue holds the records to send in the background, if QueueSize is
+	// set
+	queue *sendQueue
 }
 
 // NewTracerFromFile instantiates a fresh tracer client from a configuration file.
 //
 // Configuration is loaded from the JSON-formatted configFile, which should specify:
-// 	- ServerAddress, an ip:port pair identifying a tracing server, as one might pass to rpc.Dial
+// 	- ServerAddress, an ip:port pair identifying a tracing server, as one might pass to rpc.Dial,
+// 	  or unix:///path/to/socket for a server listening on a Unix domain socket, or udp://ip:port
+// 	  to send records as UDP datagrams to a server's UDPBind address, without acknowledgement,
+// 	  ws://ip:port to connect to a server's WebSocketBind address, grpc://ip:port to connect
+// 	  to a server's GRPCBind address, proto://ip:port to connect to a server's ProtobufBind address,
+// 	  or http://ip:port[/prefix] to call the RPCs of a server over HTTP, at its HTTPBind address
+// 	  or wherever its HTTPHandler is mounted
 // 	- TracerIdentity, a unique string giving the tracer an identity that tracks which tracer reported which action
-// 	- Secret [TODO]
+// 	- Secret, the base64-encoded key signing requests and the connection handshake, if the server requires one (optional)
+// 	- CompactTokens, whether generated tokens should use the compact encoding (optional)
+// 	- ProtobufTokens, whether generated tokens should use the protobuf encoding (optional)
+// 	- MaxTokenSize and StrictTokenSize, a token size budget and whether exceeding it is fatal (optional)
+// 	- Compression, "gzip" or "snappy" to compress large records (optional)
+// 	- HeartbeatInterval and HeartbeatTimeout, in nanoseconds, to detect dead connections and reconnect (optional)
+// 	- Connections, the number of connections to send records over at once (optional)
+// 	- QueueSize and QueuePolicy, to send records in the background and what to do once too many are queued (optional)
 //
 // Note that each instance of Tracer is thread-safe.
 func NewTracerFromFile(configFile string) *Tracer {
@@ -66,59 +164,119 @@ func NewTracerFromFile(configFile string) *Tracer {
 
 // NewTracer instantiates a fresh tracer client.
 func NewTracer(config TracerConfig) *Tracer {
-	client, err := rpc.Dial("tcp", config.ServerAddress)
+	tracer, err := dialTracer(config)
 	if err != nil {
 		log.Fatal("dialing server: ", err)
 	}
-
-	goLogConfig := govec.GetDefaultConfig()
-	goLogConfig.LogToFile = false
-
-	// TODO: make this call optional
-	var initialVC vclock.VClock
-	err = client.Call("RPCProvider.GetLastVC", config.TracerIdentity, &initialVC)
-	if err == nil {
-		goLogConfig.InitialVC = initialVC.Copy()
-	}
-
-	tracer := &Tracer{
-		client:      client,
-		identity:    config.TracerIdentity,
-		shouldPrint: true,
-		logger: govec.InitGoVector(config.TracerIdentity,
-			"GoVector-"+config.TracerIdentity, goLogConfig),
-	}
-
 	return tracer
 }
 
 // NewTracer instantiates a fresh tracer client.
 // Not calling Log.Fatal when rpc connection fails
 func NewTracerNonFatal(config TracerConfig) *Tracer {
-	client, err := rpc.Dial("tcp", config.ServerAddress)
+	tracer, err := dialTracer(config)
 	if err != nil {
 		return nil
 	}
+	return tracer
+}
 
+func dialTracer(config TracerConfig) (*Tracer, error) {
+	tokenVersion := tokenVersionMsgpack
+	switch {
+	case config.ProtobufTokens:
+		tokenVersion = tokenVersionProtobuf
+	case config.CompactTokens:
+		tokenVersion = tokenVersionCompact
+	}
+	codec := tokenCodec{version: tokenVersion}
 	goLogConfig := govec.GetDefaultConfig()
 	goLogConfig.LogToFile = false
+	goLogConfig.EncodingStrategy = codec.encode
+	goLogConfig.DecodingStrategy = codec.decode
+
+	tracer := &Tracer{
+		identity:    config.TracerIdentity,
+		secret:      config.Secret,
+		shouldPrint: true,
+		tokenBudget: tokenBudget{
+			maxSize: config.MaxTokenSize,
+			strict:  config.StrictTokenSize,
+			version: tokenVersion,
+		},
+		receivedTokens: newTokenSet(maxReceivedTokens),
+	}
+	transport, address, conn, err := config.dial()
+	if err != nil {
+		return nil, err
+	}
+	tracer.conns = []TransportConn{conn}
+	tracer.transport = transport
+	tracer.address = address
+	for len(tracer.conns) < config.Connections {
+		conn, err := transport.Dial(address)
+		if err != nil {
+			tracer.closeConns()
+			return nil, err
+		}
+		tracer.conns = append(tracer.conns, conn)
+	}
+	tracer.stripes = make([]sync.Mutex, len(tracer.conns))
+	seededIDLock.Lock()
+	tracer.session = seededIDGen.Uint64()
+	seededIDLock.Unlock()
+
+	tracer.compression, err = negotiateCompression(conn, config.Compression)
+	if err != nil {
+		tracer.closeConns()
+		return nil, err
+	}
 
 	// TODO: make this call optional
-	var initialVC vclock.VClock
-	err = client.Call("RPCProvider.GetLastVC", config.TracerIdentity, &initialVC)
+	initialVC, err := conn.GetLastVC(signIdentity(config.Secret, config.TracerIdentity))
 	if err == nil {
 		goLogConfig.InitialVC = initialVC.Copy()
 	}
 
-	tracer := &Tracer{
-		client:      client,
-		identity:    config.TracerIdentity,
-		shouldPrint: true,
-		logger: govec.InitGoVector(config.TracerIdentity,
-			"GoVector-"+config.TracerIdentity, goLogConfig),
+	tracer.logger = govec.InitGoVector(config.TracerIdentity,
+		"GoVector-"+config.TracerIdentity, goLogConfig)
+	if config.QueueSize > 0 {
+		if err := tracer.startSender(config.QueueSize, config.QueuePolicy); err != nil {
+			tracer.closeConns()
+			return nil, err
+		}
+	}
+	if config.HeartbeatInterval > 0 {
+		tracer.startHeartbeat(config.HeartbeatInterval, config.HeartbeatTimeout)
 	}
+	return tracer, nil
+}
 
-	return tracer
+// dial connects to the server at config.ServerAddress, with the transport
+// of config, and returns the transport and the address it dialed too.
+func (config TracerConfig) dial() (Transport, string, TransportConn, error) {
+	transport, address := config.Transport, config.ServerAddress
+	if transport == nil {
+		transport, address = transportFor(config.ServerAddress)
+		tlsConfig, err := config.tracerTLSConfig()
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if tlsConfig != nil {
+			t, ok := transport.(rpcTransport)
+			if !ok {
+				return nil, "", nil, errTLSTransport
+			}
+			t.tlsConfig = tlsConfig
+			transport = t
+		}
+		transport = withSecret(transport, config.TracerIdentity, config.Secret)
+	}
+	conn, err := transport.Dial(address)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return transport, address, conn, nil
 }
 
 var (
@@ -153,43 +311,26 @@ func (tracer *Tracer) CreateTrace() *Trace {
 // Note that we are not logging vector clock, but we send it to the
 // tracing server.
 func (tracer *Tracer) getLogString(trace *Trace, record interface{}) string {
-	recVal := reflect.ValueOf(record)
-	recType := reflect.TypeOf(record)
-	numFields := recVal.NumField()
-
-	logFormat := "[%s] %s"
-	logParams := []interface{}{tracer.identity, recType.Name()}
-	if trace != nil {
-		logFormat = "[%s] TraceID=%d %s"
-		logParams = []interface{}{tracer.identity, trace.ID, recType.Name()}
-	}
-	{
-		isFirst := true
-		for i := 0; i < numFields; i++ {
-			if !isFirst {
-				logFormat += ", "
-			} else {
-				logFormat += " "
-				isFirst = false
-			}
-			logFormat += recType.Field(i).Name + "=%v"
-			// strip all pointer types (when not nil), so we log the pointed-to value
-			valueToLog := recVal.Field(i)
-			for valueToLog.Kind() == reflect.Ptr && !valueToLog.IsNil() {
-				valueToLog = reflect.Indirect(valueToLog)
-			}
-			logParams = append(logParams, valueToLog.Interface())
-		}
-	}
-	return fmt.Sprintf(logFormat, logParams...)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	tracer.writeLogString(buf, trace, record)
+	return buf.String()
 }
 
-func (tracer *Tracer) recordAction(trace *Trace, record interface{}, isLocalEvent bool) {
-	if isLocalEvent {
-		tracer.logger.LogLocalEvent(tracer.getLogString(trace, record), govec.GetDefaultLogOptions())
-	}
-	if tracer.shouldPrint {
-		log.Print(tracer.getLogString(trace, record))
+// recordAction records record, in trace, and returns the function sending
+// it to the server, which the caller must call once it released the lock
+// of the tracer, for the next records to be sent meanwhile. The server
+// writes the records in the order recordAction was called, see awaitTurn.
+// If the tracer has a queue, the record is queued instead, in that order.
+func (tracer *Tracer) recordAction(trace *Trace, record interface{}, isLocalEvent bool) (send func()) {
+	if isLocalEvent || tracer.shouldPrint {
+		logString := tracer.getLogString(trace, record)
+		if isLocalEvent {
+			tracer.logger.LogLocalEvent(logString, govec.GetDefaultLogOptions())
+		}
+		if tracer.shouldPrint {
+			log.Print(logString)
+		}
 	}
 
 	// send data to tracer server
@@ -197,49 +338,218 @@ func (tracer *Tracer) recordAction(trace *Trace, record interface{}, isLocalEven
 	if err != nil {
 		log.Print("error marshaling record: ", err)
 	}
-	err = tracer.client.Call("RPCProvider.RecordAction", RecordActionArg{
+	arg := RecordActionArg{
 		TracerIdentity: tracer.identity,
 		TraceID:        trace.ID,
-		RecordName:     reflect.TypeOf(record).Name(),
+		RecordName:     getActionType(reflect.TypeOf(record)).name,
 		Record:         marshaledRecord,
-		VectorClock:    tracer.logger.GetCurrentVC(),
-	}, nil)
-	if err != nil {
+		// a copy, as the clock ticks on while the record is sent
+		VectorClock: tracer.logger.GetCurrentVC().Copy(),
+	}
+	// the sequence number is consumed even if the record cannot be sent,
+	// so that the server accounts for it as missing
+	tracer.seq++
+	arg.Session, arg.Seq = tracer.session, tracer.seq
+	i := int(tracer.seq % uint64(len(tracer.stripes)))
+	if tracer.queue != nil {
+		// the sender sends the records in order, the server need not
+		// reorder them
+		tracer.queue.push(queuedRecord{conn: i, arg: arg})
+		return func() {}
+	}
+	arg.Striped = len(tracer.stripes) > 1
+	s := &tracer.stripes[i]
+	s.Lock()
+	return func() {
+		defer s.Unlock()
+		tracer.sendRecord(i, arg)
+	}
+}
+
+// sendRecord compresses and signs arg, then sends it over the i-th
+// connection of the tracer, whose stripe the caller must hold.
+func (tracer *Tracer) sendRecord(i int, arg RecordActionArg) {
+	if err := compressRecord(&arg, tracer.compression); err != nil {
+		log.Print("error compressing record: ", err)
+	}
+	signRecord(tracer.secret, &arg)
+	if err := tracer.send(i, arg); err != nil {
 		log.Print("error recording action to remote: ", err)
 	}
 }
 
 // ReceiveTokenTrace is an action that indicated receiption of a token.
 type ReceiveTokenTrace struct {
-	Token TracingToken // the token that was received.
+	Token     TracingToken // the token that was received.
+	Duplicate bool         `json:",omitempty"` // whether this tracer already received this (non-broadcast) token
 }
 
 // ReceiveToken records the token by calling RecordAction with
 // ReceiveTokenTrace.
 func (tracer *Tracer) ReceiveToken(token TracingToken) *Trace {
+	trace, _ := tracer.ReceiveTokenWithMetadata(token)
+	return trace
+}
+
+// ReceiveTokenWithMetadata behaves like ReceiveToken, and additionally returns
+// the metadata embedded in the token by Trace.GenerateTokenWithMetadata (nil
+// if there is none).
+func (tracer *Tracer) ReceiveTokenWithMetadata(token TracingToken) (*Trace, []byte) {
 	tracer.lock.Lock()
-	defer tracer.lock.Unlock()
 
 	record := ReceiveTokenTrace{Token: token}
+	var payload tokenPayload
+	tracer.logger.UnpackReceive(tracer.getLogString(nil, record),
+		token, &payload, govec.GetDefaultLogOptions())
+	record.Duplicate = tracer.markReceived(token, &payload)
+	trace := &Trace{
+		ID:     payload.TraceID,
+		Tracer: tracer,
+	}
+	send := tracer.recordAction(trace, record, false)
+	tracer.lock.Unlock()
+	send()
+	return trace, payload.Metadata
+}
+
+// ReceiveTokensTrace is an action that indicates the joint reception of
+// several tokens.
+type ReceiveTokensTrace struct {
+	Tokens    []TracingToken // the tokens that were received.
+	Duplicate bool           `json:",omitempty"` // whether this tracer already received any of the (non-broadcast) tokens
+}
+
+// ReceiveTokens receives several tokens at once, e.g. when a node waits for
+// the responses of several peers before proceeding. The clocks of all tokens
+// are merged into a single reception event, recorded as ReceiveTokensTrace,
+// rather than the chain of intermediate events that calling ReceiveToken
+// once per token would create.
+//
+// All tokens are expected to belong to the same trace, which is returned;
+// tokens belonging to other traces are still merged, but a warning is
+// logged. tokens must not be empty.
+func (tracer *Tracer) ReceiveTokens(tokens []TracingToken) *Trace {
+	if len(tokens) == 0 {
+		panic("tracing: ReceiveTokens called without tokens")
+	}
+
+	tracer.lock.Lock()
+
+	record := ReceiveTokensTrace{Tokens: tokens}
 	var traceID uint64
+	first := true
+	merged := make(map[string]uint64)
+	for _, token := range tokens {
+		var payload tokenPayload
+		d := govec.VClockPayload{Payload: &payload}
+		if err := decodeToken(token, &d); err != nil {
+			log.Print("error decoding token: ", err)
+			continue
+		}
+		if first {
+			// the trace of the first token decoded
+			traceID, first = payload.TraceID, false
+		} else if payload.TraceID != traceID {
+			log.Printf("[%s] merging token of trace %d into trace %d", tracer.identity, payload.TraceID, traceID)
+		}
+		for id, ticks := range d.VcMap {
+			if ticks > merged[id] {
+				merged[id] = ticks
+			}
+		}
+		if tracer.markReceived(token, &payload) {
+			record.Duplicate = true
+		}
+	}
+
+	// GoVector can only merge clocks by receiving a token, so the joint
+	// reception is a reception of a token carrying the merged clock
+	joinedToken := encodeCompactToken(&tokenPayload{TraceID: traceID}, tracer.identity, merged)
 	tracer.logger.UnpackReceive(tracer.getLogString(nil, record),
-		token, &traceID, govec.GetDefaultLogOptions())
+		joinedToken, &tokenPayload{}, govec.GetDefaultLogOptions())
 	trace := &Trace{
 		ID:     traceID,
 		Tracer: tracer,
 	}
-	tracer.recordAction(trace, record, false)
+	send := tracer.recordAction(trace, record, false)
+	tracer.lock.Unlock()
+	send()
 	return trace
 }
 
+// markReceived remembers token as received, and returns whether it had
+// already been received. Broadcast tokens are never reported as duplicates.
+func (tracer *Tracer) markReceived(token TracingToken, payload *tokenPayload) bool {
+	if payload.Broadcast {
+		return false
+	}
+	return tracer.receivedTokens.add(sha256.Sum256(token))
+}
+
+// maxReceivedTokens bounds the tokens a tracer remembers as received, for
+// long-running tracers not to leak memory: duplicates are expected to be
+// received shortly after the original, e.g. when a message is resent.
+const maxReceivedTokens = 1 << 16
+
+// tokenSet holds the digests of the last tokens received, up to a maximum,
+// past which the oldest are forgotten.
+type tokenSet struct {
+	digests map[[sha256.Size]byte]struct{}
+	ring    [][sha256.Size]byte // the digests held, in the order they were added, from next
+	next    int
+}
+
+func newTokenSet(max int) tokenSet {
+	return tokenSet{
+		digests: make(map[[sha256.Size]byte]struct{}),
+		ring:    make([][sha256.Size]byte, 0, max),
+	}
+}
+
+// add adds digest to the set, forgetting the oldest digest if the set is
+// full, and returns whether it already held digest.
+func (s *tokenSet) add(digest [sha256.Size]byte) bool {
+	if _, ok := s.digests[digest]; ok {
+		return true
+	}
+	if len(s.ring) < cap(s.ring) {
+		s.ring = append(s.ring, digest)
+	} else {
+		delete(s.digests, s.ring[s.next])
+		s.ring[s.next] = digest
+		s.next = (s.next + 1) % len(s.ring)
+	}
+	s.digests[digest] = struct{}{}
+	return false
+}
+
 // Close cleans up the connection to the tracing server.
 // To allow for tracing long-running processes and Ctrl^C, this call is
 // unnecessary, as there is no connection state. After this call, the use of
 // any previously generated local Trace instances leads to undefined behavior.
 func (tracer *Tracer) Close() error {
+	tracer.stopSender()
+	tracer.stopHeartbeat()
+
 	tracer.lock.Lock()
 	defer tracer.lock.Unlock()
-	return tracer.client.Close()
+	return tracer.closeConns()
+}
+
+// closeConns closes the connections of the tracer, once the records being
+// sent over them are.
+func (tracer *Tracer) closeConns() error {
+	var err error
+	for i := range tracer.conns {
+		if i < len(tracer.stripes) {
+			tracer.stripes[i].Lock()
+			defer tracer.stripes[i].Unlock()
+		}
+		if closeErr := tracer.getConn(i).Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // SetShouldPrint determines whether RecordAction should log the action being
@@ -0,0 +1,197 @@
+// Package tracereader reads the output files of a tracing server into typed
+// records, e.g. for grading scripts to check the traces of a run:
+//
+//	records, err := tracereader.ReadFile("trace_output.log", PutStart{}, PutEnd{})
+//	...
+//	for _, trace := range tracereader.GroupByTrace(records) {
+//		for _, record := range trace.Records {
+//			switch action := record.Action.(type) {
+//			case PutStart:
+//				...
+//			case tracing.ReceiveTokenTrace:
+//				...
+//			}
+//		}
+//	}
+//
+// The bodies of the records are unmarshaled into the action structs
+// registered with the name of their tag, as the tracers that recorded them
+// did: the actions of the library (CreateTrace, GenerateTokenTrace, ...) are
+// always registered.
+package tracereader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+// Record is a record of an output file, with its action.
+type Record struct {
+	tracing.TraceRecord
+	// Action is the body of the record unmarshaled into a value of the
+	// action struct registered for its tag, nil if there is none.
+	Action interface{}
+}
+
+// Trace is the records of a trace.
+type Trace struct {
+	ID      uint64
+	Records []Record
+}
+
+// builtinActions are the actions the library records.
+var builtinActions = []interface{}{
+	tracing.CreateTrace{},
+	tracing.PrepareTokenTrace{},
+	tracing.GenerateTokenTrace{},
+	tracing.ReceiveTokenTrace{},
+	tracing.ReceiveTokensTrace{},
+}
+
+// Reader reads the records of an output file.
+type Reader struct {
+	records tracing.RecordReader
+	actions map[string]reflect.Type // by tag
+}
+
+// NewReader returns a reader of the records in r, an output file in format
+// (see tracing.TracingServerConfig.OutputFormat), with the actions of the
+// library registered.
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	records, err := tracing.NewRecordReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+	reader := &Reader{records: records, actions: make(map[string]reflect.Type)}
+	reader.Register(builtinActions...)
+	return reader, nil
+}
+
+// Register registers the struct types of actions (or of the structs they
+// point to), for the bodies of the records tagged with their names to be
+// unmarshaled into.
+func (r *Reader) Register(actions ...interface{}) {
+	for _, action := range actions {
+		t := reflect.TypeOf(action)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		r.actions[t.Name()] = t
+	}
+}
+
+// Header returns the header of the file, see tracing.OutputHeader.
+func (r *Reader) Header() tracing.OutputHeader {
+	return r.records.Header()
+}
+
+// Read returns the next record, and io.EOF after the last one.
+func (r *Reader) Read() (Record, error) {
+	traceRecord, err := r.records.Read()
+	if err != nil {
+		return Record{}, err
+	}
+	record := Record{TraceRecord: traceRecord}
+	if t, ok := r.actions[traceRecord.Tag]; ok {
+		action := reflect.New(t)
+		if err := json.Unmarshal(traceRecord.Body, action.Interface()); err != nil {
+			return Record{}, fmt.Errorf("unmarshaling the %s of %s in trace %d: %v", traceRecord.Tag, traceRecord.TracerIdentity, traceRecord.TraceID, err)
+		}
+		record.Action = action.Elem().Interface()
+	}
+	return record, nil
+}
+
+// ReadAll returns the remaining records.
+func (r *Reader) ReadAll() ([]Record, error) {
+	var records []Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// ReadFile reads the records of the output file fileName, in the JSON
+// format, with actions registered along with those of the library.
+func ReadFile(fileName string, actions ...interface{}) ([]Record, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := NewReader(file, tracing.OutputFormatJSON)
+	if err != nil {
+		return nil, err
+	}
+	reader.Register(actions...)
+	return reader.ReadAll()
+}
+
+// ReadFiles reads the records of the output files fileNames, in format, one
+// file after the other, e.g. the partitions or rotated files of a run, or
+// those of the standard input if fileNames is empty, as the commands of the
+// module do.
+func ReadFiles(fileNames []string, format string) ([]tracing.TraceRecord, error) {
+	var records []tracing.TraceRecord
+	err := EachFile(fileNames, func(r io.Reader) error {
+		fileRecords, err := tracing.ReadRecords(r, format)
+		records = append(records, fileRecords...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// EachFile calls read with each of the files fileNames in turn, or with the
+// standard input if fileNames is empty, for the commands reading output
+// files otherwise than ReadFiles does, e.g. record by record. The errors of
+// read are returned along with the name of the file.
+func EachFile(fileNames []string, read func(r io.Reader) error) error {
+	if len(fileNames) == 0 {
+		if err := read(os.Stdin); err != nil {
+			return fmt.Errorf("reading the standard input: %v", err)
+		}
+		return nil
+	}
+	for _, fileName := range fileNames {
+		file, err := os.Open(fileName)
+		if err != nil {
+			return err
+		}
+		err = read(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %v", fileName, err)
+		}
+	}
+	return nil
+}
+
+// GroupByTrace groups records by trace, in the order of their first
+// record. The records of each trace stay in order.
+func GroupByTrace(records []Record) []Trace {
+	var traces []Trace
+	indexes := make(map[uint64]int)
+	for _, record := range records {
+		i, ok := indexes[record.TraceID]
+		if !ok {
+			i = len(traces)
+			indexes[record.TraceID] = i
+			traces = append(traces, Trace{ID: record.TraceID})
+		}
+		traces[i].Records = append(traces[i].Records, record)
+	}
+	return traces
+}
@@ -0,0 +1,116 @@
+package tracereader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/tracing"
+)
+
+type TestAction struct {
+	Foo string
+}
+
+type UnregisteredAction struct {
+	Bar int
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracereader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputFile := filepath.Join(dir, "output.log")
+
+	server := tracing.NewTracingServer(tracing.TracingServerConfig{
+		ServerBind: ":0",
+		OutputFile: outputFile,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client1 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := tracing.NewTracer(tracing.TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace1 := client1.CreateTrace()
+	trace2 := client1.CreateTrace()
+	trace1.RecordAction(TestAction{Foo: "foo"})
+	trace2.RecordAction(UnregisteredAction{Bar: 1})
+	client2.ReceiveToken(trace1.GenerateToken())
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadFile(outputFile, TestAction{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	traces := GroupByTrace(records)
+	if len(traces) != 2 || traces[0].ID != trace1.ID || traces[1].ID != trace2.ID {
+		t.Fatalf("expected traces %d and %d, got %v", trace1.ID, trace2.ID, traces)
+	}
+	if len(traces[0].Records) != 4 || len(traces[1].Records) != 2 {
+		t.Fatalf("expected 4 and 2 records, got %v", traces)
+	}
+
+	if _, ok := traces[0].Records[0].Action.(tracing.CreateTrace); !ok {
+		t.Fatalf("expected a CreateTrace, got %#v", traces[0].Records[0].Action)
+	}
+	if action, ok := traces[0].Records[1].Action.(TestAction); !ok || action.Foo != "foo" {
+		t.Fatalf("expected TestAction{Foo: foo}, got %#v", traces[0].Records[1].Action)
+	}
+	if action, ok := traces[0].Records[3].Action.(tracing.ReceiveTokenTrace); !ok || len(action.Token) == 0 || traces[0].Records[3].TracerIdentity != "client2" {
+		t.Fatalf("expected the reception of the token by client2, got %#v", traces[0].Records[3])
+	}
+	if record := traces[1].Records[1]; record.Action != nil || record.Tag != "UnregisteredAction" || string(record.Body) != `{"Bar":1}` {
+		t.Fatalf("expected the unregistered action to have no action, got %#v", record)
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracereader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the records of the files are read in order, e.g. those of partitions
+	var fileNames []string
+	for i, identity := range []string{"client1", "client2"} {
+		fileName := filepath.Join(dir, identity+".log")
+		data := fmt.Sprintf(`{"TracerIdentity":%q,"TraceID":%d,"Tag":"CreateTrace","Body":{},"VectorClock":{%q:1}}`+"\n", identity, i+1, identity)
+		if err := ioutil.WriteFile(fileName, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fileNames = append(fileNames, fileName)
+	}
+	records, err := ReadFiles(fileNames, tracing.OutputFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].TracerIdentity != "client1" || records[1].TracerIdentity != "client2" {
+		t.Fatalf("expected the records of client1 then client2, got %v", records)
+	}
+
+	// the errors name the file
+	invalid := filepath.Join(dir, "invalid.log")
+	if err := ioutil.WriteFile(invalid, []byte("not a record\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFiles([]string{fileNames[0], invalid}, tracing.OutputFormatJSON); err == nil || !strings.HasPrefix(err.Error(), "reading "+invalid+": ") {
+		t.Fatalf("expected an error reading %s, got %v", invalid, err)
+	}
+}
@@ -2,14 +2,22 @@ package tracing
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -37,6 +45,9 @@ func readTraceOutputFile(t *testing.T, fileName string) (outputs []interface{})
 		if err != nil {
 			t.Fatal(err)
 		}
+		if record, ok := output.(map[string]interface{}); ok && record["Tag"] == outputHeaderTag {
+			continue // see TestOutputHeader
+		}
 		outputs = append(outputs, output)
 	}
 	return
@@ -65,6 +76,39 @@ func traceIDtoJSONNumber(id uint64) json.Number {
 	return json.Number(strconv.FormatUint(id, 10))
 }
 
+// startTestServer starts a tracing server writing to temporary files. The
+// returned function closes the server; the output files are removed when the
+// test finishes.
+func startTestServer(t *testing.T) (server *TracingServer, closeServer func()) {
+	outputFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(outputFile.Name()) })
+
+	shivizOutputFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(shivizOutputFile.Name()) })
+
+	server = NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		Secret:           []byte{},
+		OutputFile:       outputFile.Name(),
+		ShivizOutputFile: shivizOutputFile.Name(),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	return server, func() {
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestOneRecord(t *testing.T) {
 	outputFile, err := ioutil.TempFile("", "")
 	if err != nil {
@@ -115,6 +159,7 @@ func TestOneRecord(t *testing.T) {
 	expected := []interface{}{
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(1),
 			"TraceID":        traceIDtoJSONNumber(traceID),
 			"Tag":            "CreateTrace",
 			"Body":           map[string]interface{}{},
@@ -124,6 +169,7 @@ func TestOneRecord(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(2),
 			"TraceID":        traceIDtoJSONNumber(traceID),
 			"Tag":            "TestAction",
 			"Body":           map[string]interface{}{"Foo": "foo"},
@@ -133,6 +179,7 @@ func TestOneRecord(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(3),
 			"TraceID":        traceIDtoJSONNumber(traceID),
 			"Tag":            "TestAction2",
 			"Body":           map[string]interface{}{"Foo": "bar"},
@@ -142,6 +189,7 @@ func TestOneRecord(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(4),
 			"TraceID":        traceIDtoJSONNumber(traceID),
 			"Tag":            "TestAction2",
 			"Body":           map[string]interface{}{"Foo": nil},
@@ -212,6 +260,7 @@ func TestTwoClients(t *testing.T) {
 	expected := []interface{}{
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(1),
 			"TraceID":        traceIDtoJSONNumber(trace1ID),
 			"Tag":            "CreateTrace",
 			"Body":           map[string]interface{}{},
@@ -221,6 +270,7 @@ func TestTwoClients(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(2),
 			"TraceID":        traceIDtoJSONNumber(trace1ID),
 			"Tag":            "TestAction",
 			"Body":           map[string]interface{}{"Foo": "foo"},
@@ -230,6 +280,7 @@ func TestTwoClients(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client2",
+			"TracerSeq":      intToJSONNubmer(1),
 			"TraceID":        traceIDtoJSONNumber(trace2ID),
 			"Tag":            "CreateTrace",
 			"Body":           map[string]interface{}{},
@@ -239,6 +290,7 @@ func TestTwoClients(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client2",
+			"TracerSeq":      intToJSONNubmer(2),
 			"TraceID":        traceIDtoJSONNumber(trace2ID),
 			"Tag":            "TestAction",
 			"Body":           map[string]interface{}{"Foo": "bar"},
@@ -331,6 +383,7 @@ func TestTokenActions(t *testing.T) {
 	expected := []interface{}{
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(1),
 			"TraceID":        traceIDtoJSONNumber(trace1ID),
 			"Tag":            "CreateTrace",
 			"Body":           map[string]interface{}{},
@@ -340,6 +393,7 @@ func TestTokenActions(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client1",
+			"TracerSeq":      intToJSONNubmer(2),
 			"TraceID":        traceIDtoJSONNumber(trace1ID),
 			"Tag":            "GenerateTokenTrace",
 			"Body":           map[string]interface{}{"Token": string(bToken)},
@@ -349,6 +403,7 @@ func TestTokenActions(t *testing.T) {
 		},
 		map[string]interface{}{
 			"TracerIdentity": "client2",
+			"TracerSeq":      intToJSONNubmer(1),
 			"TraceID":        traceIDtoJSONNumber(trace2ID),
 			"Tag":            "ReceiveTokenTrace",
 			"Body":           map[string]interface{}{"Token": string(bToken)},
@@ -468,3 +523,1481 @@ func TestTracerRejoin(t *testing.T) {
 	})()
 
 }
+
+func TestTracerRejoinAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	startServer := func() *TracingServer {
+		server := NewTracingServer(TracingServerConfig{
+			ServerBind:       ":0",
+			OutputFile:       filepath.Join(dir, "output.log"),
+			ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+			StateFile:        filepath.Join(dir, "state.json"),
+		})
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+		return server
+	}
+
+	server := startServer()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := c.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	vc := c.logger.GetCurrentVC()
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server = startServer()
+	defer server.Close()
+	cRejoined := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	defer cRejoined.Close()
+	if rejoinedVC := cRejoined.logger.GetCurrentVC(); !cmp.Equal(vc, rejoinedVC) {
+		t.Fatalf("rejoined tracer clock %v does not resume from %v after a restart", rejoinedVC, vc)
+	}
+}
+
+func TestAppendOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		AppendOutput:     true,
+	}
+	startServer := func() *TracingServer {
+		server := NewTracingServer(config)
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+		return server
+	}
+
+	server := startServer()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	vc := c.logger.GetCurrentVC()
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash while writing a record
+	outputFile, err := os.OpenFile(config.OutputFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := outputFile.WriteString(`{"TracerIdentity":"cli`); err != nil {
+		t.Fatal(err)
+	}
+	outputFile.Close()
+
+	server = startServer()
+	cRejoined := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	if rejoinedVC := cRejoined.logger.GetCurrentVC(); !cmp.Equal(vc, rejoinedVC) {
+		t.Fatalf("rejoined tracer clock %v does not resume from %v", rejoinedVC, vc)
+	}
+	cRejoined.CreateTrace()
+	cRejoined.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := readTraceOutputFile(t, config.OutputFile)
+	if len(outputs) != 3 {
+		t.Fatalf("expected 3 records, got %v", outputs)
+	}
+	shivizOutput, err := ioutil.ReadFile(config.ShivizOutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(shivizOutput), header); n != 1 {
+		t.Fatalf("expected the ShiViz header once, got it %d times", n)
+	}
+}
+
+// memorySink keeps the records written to it in memory.
+type memorySink struct {
+	records []TraceRecord
+	closed  bool
+
+	lock    sync.Mutex
+	flushed int // the number of records flushed
+	flushes int
+}
+
+func (s *memorySink) Write(record TraceRecord) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memorySink) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.flushed = len(s.records)
+	s.flushes++
+	return nil
+}
+
+func (s *memorySink) flushState() (flushed, flushes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.flushed, s.flushes
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestCustomSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := &memorySink{}
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		Sinks:            []Sink{sink},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !sink.closed {
+		t.Fatal("the sink was not closed along with the server")
+	}
+	if len(sink.records) != 2 {
+		t.Fatalf("expected 2 records, got %v", sink.records)
+	}
+	for i, record := range sink.records {
+		if record.Seq != uint64(i+1) {
+			t.Fatalf("expected record %d to be numbered %d, got %d", i, i+1, record.Seq)
+		}
+	}
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 2 {
+		t.Fatalf("expected the output file to hold the 2 records too, got %v", outputs)
+	}
+}
+
+func TestInMemory(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := c.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	c.CreateTrace().RecordAction(TestAction{Foo: "bar"})
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := server.Records(); len(records) != 4 {
+		t.Fatalf("expected 4 records, got %v", records)
+	}
+	records := server.RecordsForTrace(trace.ID)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records of trace %d, got %v", trace.ID, records)
+	}
+	if records[1].Tag != "TestAction" || string(records[1].Body) != `{"Foo":"foo"}` || records[1].VectorClock["client1"] != 2 {
+		t.Fatalf("unexpected record %v", records[1])
+	}
+}
+
+func TestConcurrentTracers(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	// the records of concurrent tracers are written by a single writer, and
+	// numbered in the order written
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := NewTracer(TracerConfig{
+				ServerAddress:  server.Listener.Addr().String(),
+				TracerIdentity: fmt.Sprintf("client%d", i),
+			})
+			trace := c.CreateTrace()
+			for j := 0; j < 10; j++ {
+				trace.RecordAction(TestAction{Foo: "foo"})
+			}
+			c.Close()
+		}(i)
+	}
+	wg.Wait()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := server.Records()
+	if len(records) != 20*11 {
+		t.Fatalf("expected %d records, got %d", 20*11, len(records))
+	}
+	for i, record := range records {
+		if record.Seq != uint64(i+1) {
+			t.Fatalf("record %d numbered %d", i+1, record.Seq)
+		}
+	}
+	if err := (transportHandler{server: server}).RecordAction(RecordActionArg{TracerIdentity: "client1"}); err != errServerClosed {
+		t.Fatalf("expected records to be rejected once the server is closed, got %v", err)
+	}
+}
+
+func TestBinaryOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outputFile := filepath.Join(dir, "output.bin")
+	for i := 0; i < 2; i++ {
+		server := NewTracingServer(TracingServerConfig{
+			ServerBind:   ":0",
+			OutputFile:   outputFile,
+			OutputFormat: OutputFormatBinary,
+			AppendOutput: true,
+		})
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+		c := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+		})
+		c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+		c.Close()
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// a record the server crashed while writing, truncated on resume
+		file, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte{42, 1, 2})
+		file.Close()
+	}
+
+	file, err := os.Open(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []TraceRecord
+	reader := NewBinaryRecordReader(file)
+	for {
+		record, err := reader.Read()
+		if err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 5 {
+		t.Fatalf("expected the header and 4 records, got %v", records)
+	}
+	if header, ok := outputHeaderOf(records[0]); !ok || header.SchemaVersion != OutputSchemaVersion {
+		t.Fatalf("expected the header first, got %v", records[0])
+	}
+	if record := records[4]; record.Tag != "TestAction" || string(record.Body) != `{"Foo":"foo"}` || record.VectorClock["client1"] != 4 || record.TracerSeq != 2 {
+		t.Fatalf("unexpected record %v", record)
+	}
+}
+
+func TestIndexOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outputFile := filepath.Join(dir, "output.log")
+	var traceIDs []uint64
+	for i := 0; i < 2; i++ {
+		// the index of the appended output file is rebuilt on Open
+		server := NewTracingServer(TracingServerConfig{
+			ServerBind:   ":0",
+			OutputFile:   outputFile,
+			IndexOutput:  true,
+			AppendOutput: true,
+		})
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+		c := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+		})
+		trace := c.CreateTrace()
+		trace.RecordAction(TestAction{Foo: "foo"})
+		traceIDs = append(traceIDs, trace.ID)
+		c.Close()
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := ReadIndex(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offsets := index.TracerOffsets("client1"); len(offsets) != 4 {
+		t.Fatalf("expected 4 records of client1, got %v", offsets)
+	}
+	file, err := os.Open(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for _, traceID := range traceIDs {
+		offsets := index.TraceOffsets(traceID)
+		if len(offsets) != 2 {
+			t.Fatalf("expected 2 records of trace %d, got %v", traceID, offsets)
+		}
+		record, err := ReadRecordAt(file, offsets[1], OutputFormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.TraceID != traceID || string(record.Body) != `{"Foo":"foo"}` {
+			t.Fatalf("unexpected record %v of trace %d", record, traceID)
+		}
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outputFile := filepath.Join(dir, "output.log")
+	key := []byte("grading key")
+	for i := 0; i < 2; i++ {
+		// the chain of the appended output file resumes from its last
+		// signature
+		server := NewTracingServer(TracingServerConfig{
+			ServerBind:   ":0",
+			OutputFile:   outputFile,
+			SigningKey:   key,
+			AppendOutput: true,
+		})
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+		c := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+		})
+		c.CreateTrace().RecordAction(TestAction{Foo: "<foo>"})
+		c.Close()
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := VerifySignatures(outputFile, OutputFormatJSON, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignatures(outputFile, OutputFormatJSON, []byte("other key")); err == nil {
+		t.Fatal("verified the signatures with another key")
+	}
+
+	data, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	for name, edited := range map[string]string{
+		"modified": strings.Replace(string(data), "foo", "bar", 1),
+		"removed":  strings.Join(append(lines[:2:2], lines[3:]...), ""),
+		"moved":    lines[0] + lines[2] + lines[1] + strings.Join(lines[3:], ""),
+	} {
+		if err := ioutil.WriteFile(outputFile, []byte(edited), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySignatures(outputFile, OutputFormatJSON, key); err == nil {
+			t.Fatalf("verified the signatures of the %s records", name)
+		}
+	}
+}
+
+func TestEncryptionKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	config := TracingServerConfig{
+		ServerBind:    ":0",
+		OutputFile:    filepath.Join(dir, "output.log"),
+		EncryptionKey: key,
+	}
+	server := NewTracingServer(config)
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "student42",
+	})
+	c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(config.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("student42")) {
+		t.Fatal("the output file is not encrypted")
+	}
+	plaintext, err := NewDecryptingReader(bytes.NewReader(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadRecords(plaintext, OutputFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].TracerIdentity != "student42" || string(records[1].Body) != `{"Foo":"foo"}` {
+		t.Fatalf("unexpected records %v", records)
+	}
+
+	plaintext, err = NewDecryptingReader(bytes.NewReader(data), []byte("fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadRecords(plaintext, OutputFormatJSON); err == nil {
+		t.Fatal("decrypted the output file with another key")
+	}
+
+	// appending would require decrypting the output file
+	config.AppendOutput = true
+	if err := NewTracingServer(config).Open(); err != errEncryptedOutput {
+		t.Fatalf("expected %v, got %v", errEncryptedOutput, err)
+	}
+}
+
+func TestEncryptedOutputFrames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	config := TracingServerConfig{
+		ServerBind:    ":0",
+		OutputFile:    filepath.Join(dir, "output.log"),
+		EncryptionKey: key,
+	}
+	server := NewTracingServer(config)
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "student42",
+	})
+	trace := c.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	trace.RecordAction(TestAction{Foo: "bar"})
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(config.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header, the 3 records and the last frame
+	var frames [][]byte
+	for rest := data[len(encryptedOutputMagic):]; len(rest) > 0; {
+		n := 4 + int(binary.BigEndian.Uint32(rest))
+		frames = append(frames, rest[:n])
+		rest = rest[n:]
+	}
+	if len(frames) != 5 {
+		t.Fatalf("expected 5 frames, got %d", len(frames))
+	}
+	read := func(frames ...[]byte) error {
+		plaintext, err := NewDecryptingReader(bytes.NewReader(append([]byte(encryptedOutputMagic), bytes.Join(frames, nil)...)), key)
+		if err != nil {
+			return err
+		}
+		_, err = ReadRecords(plaintext, OutputFormatJSON)
+		return err
+	}
+	if err := read(frames...); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"dropped", [][]byte{frames[0], frames[1], frames[3], frames[4]}},
+		{"swapped", [][]byte{frames[0], frames[2], frames[1], frames[3], frames[4]}},
+		{"repeated", [][]byte{frames[0], frames[1], frames[2], frames[3], frames[3], frames[4]}},
+		{"appended to", [][]byte{frames[0], frames[1], frames[2], frames[3], frames[4], frames[3]}},
+	} {
+		if err := read(test.frames...); err == nil || err == io.ErrUnexpectedEOF {
+			t.Fatalf("expected the %s frames to be tampered with, got %v", test.name, err)
+		}
+	}
+	// truncated between frames, or after the last record a server crashed
+	// before closing the file
+	for n := 1; n < len(frames); n++ {
+		if err := read(frames[:n]...); err != io.ErrUnexpectedEOF {
+			t.Fatalf("expected %v reading the first %d frames, got %v", io.ErrUnexpectedEOF, n, err)
+		}
+	}
+}
+
+// failingSink fails to write and flush records.
+type failingSink struct{}
+
+func (failingSink) Write(record TraceRecord) error {
+	return errors.New("failing sink")
+}
+
+func (failingSink) Flush() error {
+	return errors.New("failing sink")
+}
+
+func (failingSink) Close() error {
+	return nil
+}
+
+func TestFailingSink(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		InMemory:   true,
+		SyncEvery:  1,
+		Sinks:      []Sink{failingSink{}},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	defer server.Close()
+
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	c.Close()
+
+	if records := server.Records(); len(records) != 2 {
+		t.Fatalf("expected the other sinks to write 2 records, got %v", records)
+	}
+	expected := map[string]uint64{"InMemory": 0, "Sinks[0]": 4}
+	if sinkErrors := server.SinkErrors(); !cmp.Equal(sinkErrors, expected) {
+		t.Fatalf("expected sink errors %v, got %v", expected, sinkErrors)
+	}
+}
+
+func TestMissingRecords(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{})
+	for _, arg := range []RecordActionArg{
+		{Session: 1, Seq: 1},
+		{Session: 1, Seq: 4}, // 2 and 3 are missing
+		{Session: 1, Seq: 2}, // 2 arrives late
+		{Session: 1, Seq: 5},
+		{Session: 2, Seq: 3}, // a second instance of client1, after a server restart
+		{Session: 2, Seq: 5}, // 4 is missing
+		{},                   // not numbered
+	} {
+		arg.TracerIdentity = "client1"
+		server.accountRecord(arg)
+	}
+	if missing := server.MissingRecords()["client1"]; missing != 2 {
+		t.Fatalf("expected 2 missing records, got %d", missing)
+	}
+}
+
+func TestAwaitTurn(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{})
+	var lock sync.Mutex
+	var handled []uint64
+	var wg sync.WaitGroup
+	// 3 waits for 2, which waits for 1, and 5 for 4, until it times out
+	for _, seq := range []uint64{3, 2, 5, 1} {
+		wg.Add(1)
+		go func(seq uint64) {
+			defer wg.Done()
+			done := server.awaitTurn(RecordActionArg{TracerIdentity: "client1", Session: 1, Seq: seq, Striped: true})
+			lock.Lock()
+			handled = append(handled, seq)
+			lock.Unlock()
+			done(nil)
+		}(seq)
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	if expected := []uint64{1, 2, 3, 5}; !cmp.Equal(handled, expected) {
+		t.Fatalf("expected the records handled in the order %v, got %v", expected, handled)
+	}
+
+	// the order of a session idle for orderIdleTimeout is forgotten
+	idle := time.Now().Add(-orderIdleTimeout)
+	server.orders[signedSession{identity: "client1", session: 1}].lastUsed = idle
+	server.ordersSwept = idle
+	server.awaitTurn(RecordActionArg{TracerIdentity: "client1", Session: 2, Seq: 1, Striped: true})(nil)
+	if _, ok := server.orders[signedSession{identity: "client1", session: 1}]; ok || len(server.orders) != 1 {
+		t.Fatalf("expected only the order of session 2 to be kept, got %v", server.orders)
+	}
+}
+
+func TestShivizWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := c.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	trace.GenerateToken()
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ShiViz log regenerated from the output file is the one written
+	// by the server
+	outputFile, err := os.Open(server.Config.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outputFile.Close()
+	var regenerated strings.Builder
+	writer, err := NewShivizWriter(&regenerated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewRecordReader(outputFile, OutputFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shiviz, err := ioutil.ReadFile(server.Config.ShivizOutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regenerated.String() != string(shiviz) {
+		t.Fatalf("expected the ShiViz log %q, got %q", shiviz, regenerated.String())
+	}
+}
+
+func TestOutputPartitions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		OutputPartitions: 4,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	for i := 0; i < 16; i++ {
+		c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	}
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	partitions := make(map[string]string)
+	records := 0
+	for i := 0; i < 4; i++ {
+		fileName := filepath.Join(dir, fmt.Sprintf("output.%d.log", i))
+		for _, output := range readTraceOutputFile(t, fileName) {
+			traceID := fmt.Sprint(output.(map[string]interface{})["TraceID"])
+			if partition, ok := partitions[traceID]; ok && partition != fileName {
+				t.Fatalf("trace %s is split across %s and %s", traceID, partition, fileName)
+			}
+			partitions[traceID] = fileName
+			records++
+		}
+	}
+	if len(partitions) != 16 || records != 32 {
+		t.Fatalf("expected 32 records of 16 traces, got %d records of %d traces", records, len(partitions))
+	}
+}
+
+func TestSyncPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, config := range []TracingServerConfig{
+		{SyncEvery: 2},
+		{SyncInterval: 10 * time.Millisecond},
+	} {
+		sink := &memorySink{}
+		config.ServerBind = ":0"
+		config.OutputFile = filepath.Join(dir, "output.log")
+		config.ShivizOutputFile = filepath.Join(dir, "shiviz_output.log")
+		config.Sinks = []Sink{sink}
+		server := NewTracingServer(config)
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+
+		c := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+		})
+		trace := c.CreateTrace()
+		trace.RecordAction(TestAction{Foo: "foo"})
+		trace.RecordAction(TestAction{Foo: "bar"})
+
+		if config.SyncEvery > 0 {
+			// the third record waits for the next one
+			if flushed, flushes := sink.flushState(); flushed != 2 || flushes != 1 {
+				t.Fatalf("expected 1 flush of 2 records, got %d flushes of %d records", flushes, flushed)
+			}
+		} else {
+			deadline := time.Now().Add(5 * time.Second)
+			for flushed, _ := sink.flushState(); flushed != 3; flushed, _ = sink.flushState() {
+				if time.Now().After(deadline) {
+					t.Fatalf("expected the 3 records to be flushed, got %d", flushed)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		c.Close()
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if flushed, _ := sink.flushState(); flushed != 3 {
+			t.Fatalf("expected the 3 records to be flushed on close, got %d", flushed)
+		}
+	}
+}
+
+func TestFlushPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, config := range []TracingServerConfig{
+		{FlushEvery: 2},
+		{FlushInterval: 10 * time.Millisecond},
+	} {
+		config.ServerBind = ":0"
+		config.OutputFile = filepath.Join(dir, "output.log")
+		server := NewTracingServer(config)
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+
+		c := NewTracer(TracerConfig{
+			ServerAddress:  server.Listener.Addr().String(),
+			TracerIdentity: "client1",
+		})
+		trace := c.CreateTrace()
+		trace.RecordAction(TestAction{Foo: "foo"})
+		trace.RecordAction(TestAction{Foo: "bar"})
+
+		if config.FlushEvery > 0 {
+			// the third record waits for the next one, or a flush
+			if outputs := readTraceOutputFile(t, config.OutputFile); len(outputs) != 2 {
+				t.Fatalf("expected 2 records flushed, got %v", outputs)
+			}
+			if err := server.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if outputs := readTraceOutputFile(t, config.OutputFile); len(outputs) != 3 {
+				t.Fatalf("expected the 3 records to be flushed, got %v", outputs)
+			}
+		} else {
+			deadline := time.Now().Add(5 * time.Second)
+			for len(readTraceOutputFile(t, config.OutputFile)) != 3 {
+				if time.Now().After(deadline) {
+					t.Fatal("expected the 3 records to be flushed")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		c.Close()
+		if err := server.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if outputs := readTraceOutputFile(t, config.OutputFile); len(outputs) != 3 {
+			t.Fatalf("expected the 3 records after close, got %v", outputs)
+		}
+	}
+}
+
+func TestWALRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		WALFile:          filepath.Join(dir, "wal.log"),
+		SigningKey:       []byte("secret"),
+	}
+	server := NewTracingServer(config)
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := c.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash while writing a third record, staged in the log already
+	entry, err := json.Marshal(walEntry{Seq: 3, Record: TraceRecord{
+		TracerIdentity: "client1",
+		TraceID:        trace.ID,
+		Tag:            "TestAction",
+		Body:           json.RawMessage(`{"Foo":"bar"}`),
+		VectorClock:    map[string]uint64{"client1": 3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fileName, data := range map[string]string{
+		config.WALFile:                       string(entry) + "\n",
+		config.OutputFile:                    `{"TracerIdentity":"cli`,
+		signatureFileName(config.OutputFile): strings.Repeat("00", sha256.Size) + "\n",
+		config.ShivizOutputFile:              "client1 {\"client1\":3}\n",
+	} {
+		f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	server = NewTracingServer(config)
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	cRejoined := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	if vc := cRejoined.logger.GetCurrentVC(); vc["client1"] != 3 {
+		t.Fatalf("expected the rejoined tracer to resume from the recovered record, got %v", vc)
+	}
+	cRejoined.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := readTraceOutputFile(t, config.OutputFile)
+	if len(outputs) != 3 {
+		t.Fatalf("expected 3 records, got %v", outputs)
+	}
+	if body := outputs[2].(map[string]interface{})["Body"]; !cmp.Equal(body, map[string]interface{}{"Foo": "bar"}) {
+		t.Fatalf("expected the recovered record last, got %v", outputs[2])
+	}
+	shivizOutput, err := ioutil.ReadFile(config.ShivizOutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(shivizOutput), "\n"), "\n"); len(lines) != 8 {
+		t.Fatalf("expected the ShiViz header and 3 events, got %q", lines)
+	}
+	if err := VerifySignatures(config.OutputFile, OutputFormatJSON, config.SigningKey); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStandardStreamOutput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	output := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		output <- string(data)
+	}()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		OutputFile: "-",
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server leaves the standard output open
+	if _, err := w.WriteString("end\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	lines := strings.Split(strings.TrimSuffix(<-output, "\n"), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[0], `"Tag":"TracingOutputHeader"`) || !strings.Contains(lines[2], `"Body":{"Foo":"foo"}`) || lines[3] != "end" {
+		t.Fatalf("unexpected standard output %q", lines)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		OutputFile: filepath.Join(dir, "output.log"),
+		RotateSize: 1,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	for i := 0; i < 2; i++ {
+		c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	}
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// each record exceeds RotateSize, and is rotated right away
+	files, err := rotatedFiles(server.Config.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 rotated files, got %v", files)
+	}
+	for _, file := range files {
+		if records := readTraceOutputFile(t, file.name); len(records) != 1 {
+			t.Fatalf("expected 1 record in %s, got %v", file.name, records)
+		}
+	}
+	if records := readTraceOutputFile(t, server.Config.OutputFile); len(records) != 0 {
+		t.Fatalf("expected an empty output file, got %v", records)
+	}
+
+	server.Config.RetentionSize = files[3].size
+	if err := server.pruneRotatedFiles(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, err := rotatedFiles(server.Config.OutputFile); err != nil {
+		t.Fatal(err)
+	} else if len(remaining) != 1 || remaining[0].name != files[3].name {
+		t.Fatalf("expected only the latest rotated file to remain, got %v", remaining)
+	}
+
+	server.Config.RetentionAge = time.Minute
+	if err := server.pruneRotatedFiles(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, err := rotatedFiles(server.Config.OutputFile); err != nil {
+		t.Fatal(err)
+	} else if len(remaining) != 0 {
+		t.Fatalf("expected the rotated files to be pruned, got %v", remaining)
+	}
+}
+
+func TestOutputHeader(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		OutputFile: "test_output_header.log",
+	})
+	t.Cleanup(func() { os.Remove("test_output_header.log") })
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	c := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	c.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+	c.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile("test_output_header.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewRecordReader(bytes.NewReader(data), OutputFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := reader.Header(); header.SchemaVersion != OutputSchemaVersion || header.LibraryVersion == "" {
+		t.Fatalf("unexpected header %+v", header)
+	}
+	if record, err := reader.Read(); err != nil || record.Tag != "CreateTrace" {
+		t.Fatalf("expected the first record after the header, got %v, %v", record, err)
+	}
+
+	// files that predate the header
+	legacy := data[bytes.IndexByte(data, '\n')+1:]
+	if reader, err = NewRecordReader(bytes.NewReader(legacy), OutputFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if header := reader.Header(); header != (OutputHeader{}) {
+		t.Fatalf("expected no header, got %+v", header)
+	}
+	if records, err := ReadRecords(bytes.NewReader(legacy), OutputFormatJSON); err != nil || len(records) != 2 {
+		t.Fatalf("expected the 2 records, got %v, %v", records, err)
+	}
+
+	// files of a later schema version
+	future := `{"Tag":"TracingOutputHeader","Body":{"LibraryVersion":"v9.0.0","SchemaVersion":99}}` + "\n" + string(legacy)
+	if _, err := NewRecordReader(strings.NewReader(future), OutputFormatJSON); err == nil {
+		t.Fatal("expected reading a file of an unknown schema version to fail")
+	}
+}
+
+func TestRecordWriter(t *testing.T) {
+	records := []TraceRecord{
+		{TracerIdentity: "client1", TraceID: 1, Tag: "CreateTrace", Body: []byte(`{}`), VectorClock: map[string]uint64{"client1": 1}, TracerSeq: 1},
+		{TracerIdentity: "client1", TraceID: 1, Tag: "TestAction", Body: []byte(`{"Foo":"foo"}`), VectorClock: map[string]uint64{"client1": 2}, TracerSeq: 2},
+	}
+	for _, format := range []string{OutputFormatJSON, OutputFormatBinary} {
+		var buffer bytes.Buffer
+		writer, err := NewRecordWriter(&buffer, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			if err := writer.Write(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+		reader, err := NewRecordReader(&buffer, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header := reader.Header(); header.SchemaVersion != OutputSchemaVersion {
+			t.Fatalf("%s: unexpected header %+v", format, header)
+		}
+		var read []TraceRecord
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			read = append(read, record)
+		}
+		if diff := cmp.Diff(records, read); diff != "" {
+			t.Fatalf("%s: unexpected records (-want +got):\n%s", format, diff)
+		}
+	}
+}
+
+func TestGoVectorLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "govector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:     ":0",
+		GoVectorLogDir: dir,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	token := trace.GenerateToken()
+	client2.ReceiveToken(token)
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, "client1-Log.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(log), "\n")
+	expected := []string{
+		`client1 {"client1":1}`,
+		fmt.Sprintf("INFO [client1] TraceID=%d CreateTrace {}", trace.ID),
+		`client1 {"client1":2}`,
+		fmt.Sprintf(`INFO [client1] TraceID=%d TestAction {"Foo":"foo"}`, trace.ID),
+		`client1 {"client1":3}`,
+	}
+	if len(lines) != 7 || !cmp.Equal(lines[:5], expected) {
+		t.Fatalf("unexpected GoVector log of client1:\n%s", log)
+	}
+	log, err = ioutil.ReadFile(filepath.Join(dir, "client2-Log.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the entries of the clocks are in no particular order
+	lines = strings.Split(string(log), "\n")
+	if !strings.HasPrefix(lines[0], "client2 {") || !strings.Contains(lines[0], `"client1":3`) || !strings.Contains(lines[0], `"client2":1`) ||
+		lines[1] != fmt.Sprintf(`INFO [client2] TraceID=%d ReceiveTokenTrace {"Token":"%s"}`, trace.ID, base64.StdEncoding.EncodeToString(token)) {
+		t.Fatalf("unexpected GoVector log of client2:\n%s", log)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	newServer := func() *TracingServer {
+		server := NewTracingServer(TracingServerConfig{
+			ServerBind: ":0",
+			InMemory:   true,
+		})
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		go server.Accept()
+		return server
+	}
+
+	server := newServer()
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client2.ReceiveToken(trace.GenerateToken()).RecordAction(TestAction{Foo: "bar"})
+	client1.Close()
+	client2.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	records := server.Records()
+
+	replayServer := newServer()
+	if err := Replay(TracerConfig{ServerAddress: replayServer.Listener.Addr().String()}, records); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayServer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if replayed := replayServer.Records(); !cmp.Equal(replayed, records) {
+		t.Fatalf("unexpected replayed records: %s", cmp.Diff(records, replayed))
+	}
+
+	// the sequence numbers are preserved, for gaps to be detected
+	replayServer = newServer()
+	gapped := append(append([]TraceRecord(nil), records[:1]...), records[2:]...)
+	if err := Replay(TracerConfig{ServerAddress: replayServer.Listener.Addr().String()}, gapped); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayServer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if missing := replayServer.MissingRecords(); missing["client1"] != 1 {
+		t.Fatalf("expected 1 missing record of client1, got %v", missing)
+	}
+}
+
+// tagChecker reports the records tagged BadAction, and the number of
+// records at the end of the run.
+type tagChecker struct {
+	records int
+}
+
+type BadAction struct{}
+
+func (c *tagChecker) Check(record TraceRecord) []Violation {
+	c.records++
+	if record.Tag != "BadAction" {
+		return nil
+	}
+	return []Violation{{Check: "tag", Message: "bad action", Records: []TraceRecord{record}}}
+}
+
+func (c *tagChecker) Finish() []Violation {
+	return []Violation{{Check: "tag", Message: fmt.Sprintf("%d records", c.records)}}
+}
+
+func TestCheckers(t *testing.T) {
+	RegisterChecker("tag", func() Checker { return &tagChecker{} })
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reportFile := filepath.Join(dir, "report.log")
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		Checkers:   []string{"unknown"},
+	})
+	if err := server.Open(); err == nil || !strings.Contains(err.Error(), `unknown checker "unknown"`) {
+		t.Fatalf("expected an unknown checker error, got %v", err)
+	}
+
+	server = NewTracingServer(TracingServerConfig{
+		ServerBind:      ":0",
+		Checkers:        []string{"tag"},
+		CheckReportFile: reportFile,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	trace.RecordAction(BadAction{})
+	client.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var violations []Violation
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var violation Violation
+		if err := decoder.Decode(&violation); err != nil {
+			t.Fatal(err)
+		}
+		violations = append(violations, violation)
+	}
+	if len(violations) != 2 || violations[0].Message != "bad action" || len(violations[0].Records) != 1 ||
+		violations[0].Records[0].VectorClock["client1"] != 3 || violations[1].Message != "3 records" {
+		t.Fatalf("unexpected violations %s", data)
+	}
+}
+
+// taggedChecker reports the actions with a given tag.
+type taggedChecker struct {
+	tag string
+}
+
+func (c taggedChecker) Check(record TraceRecord) []Violation {
+	if record.Tag != c.tag {
+		return nil
+	}
+	return []Violation{{Check: "tagged", Message: "unexpected " + c.tag, Records: []TraceRecord{record}}}
+}
+
+func (c taggedChecker) Finish() []Violation {
+	return nil
+}
+
+func TestViolationRecords(t *testing.T) {
+	RegisterParameterizedChecker("tagged", func(tag string) (Checker, error) {
+		if tag == "" {
+			return nil, errors.New("no tag")
+		}
+		return taggedChecker{tag: tag}, nil
+	})
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		Checkers:   []string{"tagged:"},
+	})
+	if err := server.Open(); err == nil || err.Error() != "checker tagged: no tag" {
+		t.Fatalf("expected an invalid argument error, got %v", err)
+	}
+
+	server = NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		InMemory:         true,
+		Checkers:         []string{"tagged:BadAction", "tagged:Violation"},
+		ViolationRecords: true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+	client := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(BadAction{})
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the records of violations are not checked
+	records := server.Records()
+	if len(records) != 4 {
+		t.Fatalf("expected a record of the violation, got %v", records)
+	}
+	violation := records[2]
+	if violation.TracerIdentity != ViolationTracer || violation.TraceID != trace.ID || violation.Tag != "Violation" ||
+		string(violation.Body) != `{"Check":"tagged","Message":"unexpected BadAction"}` {
+		t.Fatalf("unexpected record of the violation %v", violation)
+	}
+	if vc := violation.VectorClock; vc["client1"] != 2 || vc[ViolationTracer] != 1 {
+		t.Fatalf("expected the record of the violation after the bad action, got clock %v", vc)
+	}
+}
@@ -0,0 +1,11 @@
+// Package tracingpb contains the protocol buffer definitions of the tracing
+// wire protocol, and the gRPC service of the tracing server.
+//
+// Clients in other languages can generate their stubs from tracing.proto,
+// and either call the gRPC service, or exchange Request and Response
+// messages with a server's ProtobufBind address. Tokens in the protobuf
+// format (see TracingToken) can be exchanged with Go tracers configured with
+// ProtobufTokens.
+package tracingpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tracing.proto
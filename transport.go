@@ -0,0 +1,281 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// Address scheme prefixes, for addresses not referring to TCP endpoints.
+const (
+	unixScheme  = "unix://"
+	udpScheme   = "udp://"
+	grpcScheme  = "grpc://"
+	protoScheme = "proto://"
+	httpScheme  = "http://"
+)
+
+// ErrLastVCUnsupported is returned by TransportConn.GetLastVC when the
+// transport cannot fetch the last vector clock recorded by the server.
+var ErrLastVCUnsupported = errors.New("fetching the last vector clock is not supported by this transport")
+
+// Transport carries the requests of tracers to a tracing server. The
+// transport of a tracer is picked according to the scheme of its
+// ServerAddress (see parseAddress), unless TracerConfig.Transport is set;
+// TracingServerConfig.Transports similarly plugs custom transports into a
+// server. Custom transports can for instance connect tracers to a server in
+// the same process in tests, simulate lossy networks, or go through SSH
+// tunnels.
+type Transport interface {
+	// Dial connects to the server listening on address.
+	Dial(address string) (TransportConn, error)
+	// Listen serves the requests of the tracers connecting to address with
+	// handler, until the returned io.Closer is closed. Close must not
+	// return before all the calls to handler have returned.
+	Listen(address string, handler TransportHandler) (io.Closer, error)
+}
+
+// TransportConn is a tracer's connection to a tracing server. Send and
+// GetLastVC are never called concurrently.
+type TransportConn interface {
+	// Send sends a recorded action to the server.
+	Send(arg RecordActionArg) error
+	// GetLastVC returns the last vector clock the server recorded for
+	// identity, or ErrLastVCUnsupported.
+	GetLastVC(identity string) (vclock.VClock, error)
+	Close() error
+}
+
+// TransportPinger is implemented by TransportConn instances of
+// connection-oriented transports, to support TracerConfig.HeartbeatInterval.
+// Ping may be called concurrently with the other methods of the connection,
+// and must return once the connection is closed.
+type TransportPinger interface {
+	// Ping makes a round trip to the server.
+	Ping() error
+}
+
+// TransportHandler serves the requests a Transport receives, on behalf of a
+// tracing server. Its methods may be called concurrently.
+type TransportHandler interface {
+	// RecordAction records an action sent with TransportConn.Send.
+	RecordAction(arg RecordActionArg) error
+	// GetLastVC returns the last vector clock recorded for identity.
+	GetLastVC(identity string) (vclock.VClock, error)
+}
+
+// TransportBinding is a transport a server listens on, along with the
+// address it listens to.
+type TransportBinding struct {
+	Transport Transport
+	Address   string
+}
+
+// parseAddress splits an address, as found in TracingServerConfig.ServerBind
+// or TracerConfig.ServerAddress, into the network and address arguments of
+// net.Listen and net.Dial. Addresses of the form "unix:///path/to/socket"
+// refer to Unix domain sockets, addresses of the form "udp://ip:port" to UDP
+// endpoints, addresses of the form "ws://ip:port" to WebSocket endpoints
+// (for which addr is the full URL), addresses of the form "grpc://ip:port"
+// to gRPC endpoints, addresses of the form "proto://ip:port" to endpoints of
+// the framed protobuf protocol, and addresses of the form
+// "http://ip:port[/prefix]" to HTTP endpoints (for which addr is the full
+// URL); all others are TCP ip:port pairs.
+func parseAddress(address string) (network, addr string) {
+	switch {
+	case strings.HasPrefix(address, unixScheme):
+		return "unix", strings.TrimPrefix(address, unixScheme)
+	case strings.HasPrefix(address, udpScheme):
+		return "udp", strings.TrimPrefix(address, udpScheme)
+	case strings.HasPrefix(address, wsScheme):
+		return "ws", address
+	case strings.HasPrefix(address, grpcScheme):
+		return "grpc", strings.TrimPrefix(address, grpcScheme)
+	case strings.HasPrefix(address, protoScheme):
+		return "proto", strings.TrimPrefix(address, protoScheme)
+	case strings.HasPrefix(address, httpScheme):
+		return "http", address
+	default:
+		return "tcp", address
+	}
+}
+
+var (
+	registeredTransportsLock sync.RWMutex
+	registeredTransports     = make(map[string]Transport)
+)
+
+// RegisterTransport makes tracers whose ServerAddress is of the form
+// scheme://address use transport, dialed with address. It is meant to be
+// called from the init function of packages providing transports, such as
+// github.com/DistributedClocks/tracing/quic; registering a built-in scheme
+// overrides the built-in transport.
+func RegisterTransport(scheme string, transport Transport) {
+	registeredTransportsLock.Lock()
+	defer registeredTransportsLock.Unlock()
+	registeredTransports[scheme] = transport
+}
+
+// transportFor returns the transport for the scheme of address, registered
+// or built-in, and the address to pass to it.
+func transportFor(address string) (Transport, string) {
+	if i := strings.Index(address, "://"); i >= 0 {
+		registeredTransportsLock.RLock()
+		transport, ok := registeredTransports[address[:i]]
+		registeredTransportsLock.RUnlock()
+		if ok {
+			return transport, address[i+len("://"):]
+		}
+	}
+	network, addr := parseAddress(address)
+	switch network {
+	case "udp":
+		return udpTransport{}, addr
+	case "ws":
+		return wsTransport{}, addr
+	case "grpc":
+		return grpcTransport{}, addr
+	case "proto":
+		return protobufTransport{}, addr
+	case "http":
+		return httpTransport{}, addr
+	default:
+		return rpcTransport{network: network}, addr
+	}
+}
+
+// rpcTransport is the default transport, which calls the net/rpc methods
+// of RPCProvider over TCP connections or Unix domain sockets, secured with
+// TLS if tlsConfig is set, and authenticated as identity by the handshake
+// of clientHandshake if secret is set.
+type rpcTransport struct {
+	network   string
+	tlsConfig *tls.Config
+	identity  string
+	secret    []byte
+}
+
+func (t rpcTransport) Dial(address string) (TransportConn, error) {
+	var conn net.Conn
+	var err error
+	if t.tlsConfig == nil {
+		conn, err = net.Dial(t.network, address)
+	} else {
+		conn, err = tls.Dial(t.network, address, t.tlsConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(t.secret) > 0 {
+		if err := clientHandshake(conn, t.identity, t.secret); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rpcConn{client: rpc.NewClient(conn)}, nil
+}
+
+func (t rpcTransport) Listen(address string, handler TransportHandler) (io.Closer, error) {
+	rpcServer, err := newRPCServer(handler)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen(t.network, address)
+	if err != nil {
+		return nil, err
+	}
+	l := &rpcListener{listener: listener, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		rpcServer.Accept(listener)
+	}()
+	return l, nil
+}
+
+func newRPCServer(handler TransportHandler) (*rpc.Server, error) {
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.Register(&RPCProvider{handler: handler}); err != nil {
+		return nil, err
+	}
+	return rpcServer, nil
+}
+
+// rpcListener stops a net/rpc server from accepting connections. As with
+// TracingServer.Listener, connections already accepted are closed by their
+// clients.
+type rpcListener struct {
+	listener net.Listener
+	done     chan struct{}
+}
+
+func (l *rpcListener) Close() error {
+	err := l.listener.Close()
+	<-l.done
+	return err
+}
+
+// NewStreamConn returns a connection calling the tracing server at the
+// other end of stream, which serves it with ServeStream. Together, they
+// turn any reliable byte stream (e.g. a QUIC stream, or an SSH channel)
+// into a transport.
+func NewStreamConn(stream io.ReadWriteCloser) TransportConn {
+	return rpcConn{client: rpc.NewClient(stream)}
+}
+
+// ServeStream serves the requests of the tracer at the other end of stream,
+// a connection of NewStreamConn, with handler. It blocks until the tracer
+// hangs up.
+func ServeStream(handler TransportHandler, stream io.ReadWriteCloser) {
+	rpcServer, err := newRPCServer(handler)
+	if err != nil {
+		// RPCProvider is a valid receiver, registering it cannot fail
+		panic(err)
+	}
+	rpcServer.ServeConn(stream)
+}
+
+// rpcConn calls the tracing server's net/rpc methods.
+type rpcConn struct {
+	client *rpc.Client
+}
+
+func (c rpcConn) Send(arg RecordActionArg) error {
+	return c.client.Call("RPCProvider.RecordAction", arg, nil)
+}
+
+func (c rpcConn) SendBatch(args []RecordActionArg) (int, error) {
+	var result RecordActionsResult
+	if err := c.client.Call("RPCProvider.RecordActions", RecordActionsArg(args), &result); err != nil {
+		return 0, err
+	}
+	if result.Error != "" {
+		return result.Recorded, rpc.ServerError(result.Error)
+	}
+	return result.Recorded, nil
+}
+
+func (c rpcConn) GetLastVC(identity string) (vclock.VClock, error) {
+	var vc vclock.VClock
+	err := c.client.Call("RPCProvider.GetLastVC", identity, &vc)
+	return vc, err
+}
+
+func (c rpcConn) Ping() error {
+	return c.client.Call("RPCProvider.Ping", PingArg{}, nil)
+}
+
+func (c rpcConn) Compressions() ([]string, error) {
+	var result CompressionsResult
+	err := c.client.Call("RPCProvider.Compressions", CompressionsArg{}, &result)
+	return result, err
+}
+
+func (c rpcConn) Close() error {
+	return c.client.Close()
+}
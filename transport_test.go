@@ -0,0 +1,783 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnixSocketTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	address := "unix://" + filepath.Join(dir, "tracing.sock")
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       address,
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  address,
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 2 {
+		t.Fatalf("expected 2 records, got %v", outputs)
+	}
+}
+
+func TestUDPTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		UDPBind:          "127.0.0.1:0",
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  "udp://" + server.udpListener.conn.LocalAddr().String(),
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+
+	// datagrams are not acknowledged, so wait for the server to process them
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		vc, _ := server.lastVCs.load("client1")
+		if ticks, _ := vc.FindTicks("client1"); ticks == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the records sent over UDP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 2 {
+		t.Fatalf("expected 2 records, got %v", outputs)
+	}
+}
+
+func TestUDPLossAccounting(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		UDPBind:    "127.0.0.1:0",
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	recorder, err := newUDPRecorder(server.udpListener.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+	// 3 is lost, and 2 arrives late
+	for _, seq := range []uint64{1, 4, 2} {
+		err := recorder.Send(RecordActionArg{
+			TracerIdentity: "client1",
+			TraceID:        1,
+			RecordName:     "TestAction",
+			Record:         []byte(`{"Foo":"foo"}`),
+			VectorClock:    vclock.VClock{"client1": seq},
+			Session:        1,
+			Seq:            seq,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.Records()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the records sent over UDP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if missing := server.MissingRecords()["client1"]; missing != 1 {
+		t.Fatalf("expected 1 missing record, got %d", missing)
+	}
+}
+
+func TestWebSocketTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		WebSocketBind:    wsBind,
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  "ws://" + wsBind,
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+
+	// a rejoining tracer resumes from the last clock, fetched over WebSocket
+	rejoined := NewTracer(TracerConfig{
+		ServerAddress:  "ws://" + wsBind,
+		TracerIdentity: "client1",
+	})
+	if vc := rejoined.logger.GetCurrentVC(); !cmp.Equal(vc, vclock.VClock{"client1": 2}) {
+		t.Fatalf("rejoined tracer clock %v does not resume from the last recorded one", vc)
+	}
+	rejoined.Close()
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 2 {
+		t.Fatalf("expected 2 records, got %v", outputs)
+	}
+}
+
+func TestGRPCTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		GRPCBind:         grpcBind,
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  "grpc://" + grpcBind,
+		TracerIdentity: "client1",
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+
+	// a rejoining tracer resumes from the last clock, fetched over gRPC
+	rejoined := NewTracer(TracerConfig{
+		ServerAddress:  "grpc://" + grpcBind,
+		TracerIdentity: "client1",
+	})
+	if vc := rejoined.logger.GetCurrentVC(); !cmp.Equal(vc, vclock.VClock{"client1": 2}) {
+		t.Fatalf("rejoined tracer clock %v does not resume from the last recorded one", vc)
+	}
+	rejoined.Close()
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 2 {
+		t.Fatalf("expected 2 records, got %v", outputs)
+	}
+}
+
+func TestHTTPIngestion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		HTTPBind:         httpBind,
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	post := func(body string) int {
+		resp, err := http.Post("http://"+httpBind+"/record", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// the record given as a JSON object, or base64-encoded as in RecordActionArg
+	if code := post(`{"TracerIdentity": "script", "TraceID": 1, "RecordName": "TestAction",
+		"Record": {"Foo": "foo"}, "VectorClock": {"script": 1}}`); code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if code := post(`{"TracerIdentity": "script", "TraceID": 1, "RecordName": "TestAction",
+		"Record": "eyJGb28iOiJiYXIifQ==", "VectorClock": {"script": 2}}`); code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if code := post(`{"TracerIdentity": `); code != http.StatusBadRequest {
+		t.Fatalf("expected a malformed request to be rejected, got status %d", code)
+	}
+	resp, err := http.Get("http://" + httpBind + "/record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET to be rejected, got status %d", resp.StatusCode)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	outputs := readTraceOutputFile(t, server.Config.OutputFile)
+	expected := []interface{}{
+		map[string]interface{}{
+			"TracerIdentity": "script",
+			"TraceID":        json.Number("1"),
+			"Tag":            "TestAction",
+			"Body":           map[string]interface{}{"Foo": "foo"},
+			"VectorClock":    map[string]interface{}{"script": json.Number("1")},
+		},
+		map[string]interface{}{
+			"TracerIdentity": "script",
+			"TraceID":        json.Number("1"),
+			"Tag":            "TestAction",
+			"Body":           map[string]interface{}{"Foo": "bar"},
+			"VectorClock":    map[string]interface{}{"script": json.Number("2")},
+		},
+	}
+	if !cmp.Equal(outputs, expected) {
+		t.Fatal(cmp.Diff(outputs, expected))
+	}
+}
+
+func TestProtobufTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protobufBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		ProtobufBind:     protobufBind,
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client1 := NewTracer(TracerConfig{
+		ServerAddress:  "proto://" + protobufBind,
+		TracerIdentity: "client1",
+		ProtobufTokens: true,
+	})
+	client2 := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client2",
+	})
+	trace := client1.CreateTrace()
+	token := trace.GenerateToken()
+	if token[0] != tokenVersionProtobuf {
+		t.Fatalf("expected protobuf token, got leading byte %#x", token[0])
+	}
+	client2.ReceiveToken(token).RecordAction(TestAction{Foo: "foo"})
+	client1.Close()
+	client2.Close()
+
+	// a rejoining tracer resumes from the last clock, fetched over protobuf
+	rejoined := NewTracer(TracerConfig{
+		ServerAddress:  "proto://" + protobufBind,
+		TracerIdentity: "client1",
+	})
+	if vc := rejoined.logger.GetCurrentVC(); !cmp.Equal(vc, vclock.VClock{"client1": 2}) {
+		t.Fatalf("rejoined tracer clock %v does not resume from the last recorded one", vc)
+	}
+	rejoined.Close()
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 4 {
+		t.Fatalf("expected 4 records, got %v", outputs)
+	}
+}
+
+// inProcessTransport is a custom transport connecting tracers to servers
+// in the same process, by calling their handlers directly.
+type inProcessTransport struct {
+	lock     sync.Mutex
+	handlers map[string]TransportHandler
+}
+
+type inProcessConn struct {
+	handler TransportHandler
+}
+
+type inProcessListener struct {
+	transport *inProcessTransport
+	address   string
+}
+
+func (t *inProcessTransport) Dial(address string) (TransportConn, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	handler, ok := t.handlers[address]
+	if !ok {
+		return nil, fmt.Errorf("no server listening on %s", address)
+	}
+	return inProcessConn{handler: handler}, nil
+}
+
+func (t *inProcessTransport) Listen(address string, handler TransportHandler) (io.Closer, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.handlers[address] = handler
+	return inProcessListener{transport: t, address: address}, nil
+}
+
+func (c inProcessConn) Send(arg RecordActionArg) error {
+	return c.handler.RecordAction(arg)
+}
+
+func (c inProcessConn) GetLastVC(identity string) (vclock.VClock, error) {
+	return c.handler.GetLastVC(identity)
+}
+
+func (c inProcessConn) Close() error {
+	return nil
+}
+
+func (l inProcessListener) Close() error {
+	l.transport.lock.Lock()
+	defer l.transport.lock.Unlock()
+	delete(l.transport.handlers, l.address)
+	return nil
+}
+
+func TestCustomTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	transport := &inProcessTransport{handlers: make(map[string]TransportHandler)}
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		Transports:       []TransportBinding{{Transport: transport, Address: "server"}},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  "server",
+		TracerIdentity: "client1",
+		Transport:      transport,
+	})
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+
+	rejoined := NewTracer(TracerConfig{
+		ServerAddress:  "server",
+		TracerIdentity: "client1",
+		Transport:      transport,
+	})
+	if vc := rejoined.logger.GetCurrentVC(); !cmp.Equal(vc, vclock.VClock{"client1": 2}) {
+		t.Fatalf("rejoined tracer clock %v does not resume from the last recorded one", vc)
+	}
+	rejoined.Close()
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.Dial("server"); err == nil {
+		t.Fatal("closing the server did not close its custom transport listener")
+	}
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 2 {
+		t.Fatalf("expected 2 records, got %v", outputs)
+	}
+}
+
+// blackHoleTransport wraps an inProcessTransport, and black-holes the first
+// connection it dials once the tracer joined: calls over it hang until it
+// is closed, as they would over a half-open TCP connection.
+type blackHoleTransport struct {
+	*inProcessTransport
+	lock  sync.Mutex
+	dials int
+}
+
+type blackHoleConn struct {
+	TransportConn
+	closed chan struct{}
+}
+
+func (t *blackHoleTransport) Dial(address string) (TransportConn, error) {
+	conn, err := t.inProcessTransport.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.dials++
+	if t.dials > 1 {
+		return pingingConn{conn}, nil
+	}
+	return &blackHoleConn{TransportConn: conn, closed: make(chan struct{})}, nil
+}
+
+func (c *blackHoleConn) Send(arg RecordActionArg) error {
+	<-c.closed
+	return errors.New("connection closed")
+}
+
+func (c *blackHoleConn) Ping() error {
+	<-c.closed
+	return errors.New("connection closed")
+}
+
+func (c *blackHoleConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+// pingingConn is a healthy connection supporting heartbeats.
+type pingingConn struct {
+	TransportConn
+}
+
+func (c pingingConn) Ping() error {
+	return nil
+}
+
+func TestHeartbeatReconnection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	transport := &blackHoleTransport{
+		inProcessTransport: &inProcessTransport{handlers: make(map[string]TransportHandler)},
+	}
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+		Transports:       []TransportBinding{{Transport: transport, Address: "server"}},
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:     "server",
+		TracerIdentity:    "client1",
+		Transport:         transport,
+		HeartbeatInterval: 10 * time.Millisecond,
+	})
+	// the CreateTrace record is lost along with the dead connection, without
+	// blocking the tracer forever, and the action is recorded over the new
+	// connection
+	trace := client.CreateTrace()
+	trace.RecordAction(TestAction{Foo: "foo"})
+	client.Close()
+
+	transport.lock.Lock()
+	dials := transport.dials
+	transport.lock.Unlock()
+	if dials != 2 {
+		t.Fatalf("expected the tracer to reconnect once, dialed %d times", dials)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	outputs := readTraceOutputFile(t, server.Config.OutputFile)
+	if len(outputs) != 1 || outputs[0].(map[string]interface{})["Tag"] != "TestAction" {
+		t.Fatalf("expected only the action to be recorded, got %v", outputs)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	server, closeServer := startTestServer(t)
+	defer closeServer()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:     server.Listener.Addr().String(),
+		TracerIdentity:    "client1",
+		HeartbeatInterval: time.Millisecond,
+		HeartbeatTimeout:  time.Second,
+	})
+	conn := client.getConn(0)
+	if err := ping(conn, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if client.getConn(0) != conn {
+		t.Fatal("the tracer reconnected over a healthy connection")
+	}
+	client.Close()
+}
+
+func TestConnections(t *testing.T) {
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind: ":0",
+		Secret:     []byte("course secret"),
+		InMemory:   true,
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	client := NewTracer(TracerConfig{
+		ServerAddress:  server.Listener.Addr().String(),
+		TracerIdentity: "client1",
+		Secret:         []byte("course secret"),
+		Connections:    3,
+	})
+	if len(client.conns) != 3 {
+		t.Fatalf("expected 3 connections, got %d", len(client.conns))
+	}
+	var wg sync.WaitGroup
+	traces := make([]*Trace, 6)
+	for i := range traces {
+		traces[i] = client.CreateTrace()
+		wg.Add(1)
+		go func(trace *Trace) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				trace.RecordAction(TestAction{Foo: "foo"})
+			}
+		}(traces[i])
+	}
+	wg.Wait()
+	client.Close()
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the records of client1 are written in the order it recorded them,
+	// none replayed
+	if replayed := server.ReplayedRecords(); len(replayed) != 0 {
+		t.Fatalf("records dropped as replayed: %v", replayed)
+	}
+	records := server.Records()
+	if len(records) != 6*21 {
+		t.Fatalf("expected the %d records of client1, got %d", 6*21, len(records))
+	}
+	for i, record := range records {
+		if record.VectorClock["client1"] != uint64(i+1) || record.TracerSeq != uint64(i+1) {
+			t.Fatalf("record %d of client1 out of order: %v", i+1, record)
+		}
+	}
+	for _, trace := range traces {
+		if records := server.RecordsForTrace(trace.ID); len(records) != 21 {
+			t.Fatalf("expected the 21 records of trace %d, got %d", trace.ID, len(records))
+		}
+	}
+	if vc, _ := server.lastVCs.load("client1"); vc["client1"] != 6*21 {
+		t.Fatalf("expected the last clock of client1 to be the latest, got %v", vc)
+	}
+}
+
+func TestRecordCompression(t *testing.T) {
+	for _, compression := range []string{CompressionGzip, CompressionSnappy} {
+		t.Run(compression, func(t *testing.T) {
+			server, closeServer := startTestServer(t)
+
+			client := NewTracer(TracerConfig{
+				ServerAddress:  server.Listener.Addr().String(),
+				TracerIdentity: "client1",
+				Compression:    compression,
+			})
+			if client.compression != compression {
+				t.Fatalf("expected %s compression to be negotiated, got %q", compression, client.compression)
+			}
+			arg := RecordActionArg{Record: []byte(strings.Repeat("a", minCompressedRecordSize))}
+			if err := compressRecord(&arg, compression); err != nil {
+				t.Fatal(err)
+			}
+			if arg.Compression != compression || len(arg.Record) >= minCompressedRecordSize {
+				t.Fatalf("record was not compressed: %v", arg)
+			}
+
+			state := strings.Repeat("snapshot", 100)
+			client.CreateTrace().RecordAction(TestAction{Foo: state})
+			client.Close()
+			closeServer()
+
+			outputs := readTraceOutputFile(t, server.Config.OutputFile)
+			if len(outputs) != 2 {
+				t.Fatalf("expected 2 records, got %v", outputs)
+			}
+			body := outputs[1].(map[string]interface{})["Body"].(map[string]interface{})
+			if body["Foo"] != state {
+				t.Fatalf("decompressed record %v does not match the recorded one", body)
+			}
+		})
+	}
+}
+
+func TestRecordCompressionFallback(t *testing.T) {
+	transport := &inProcessTransport{handlers: make(map[string]TransportHandler)}
+	transport.Listen("server", transportHandler{server: NewTracingServer(TracingServerConfig{})})
+
+	// the in-process transport cannot negotiate compression
+	client := NewTracer(TracerConfig{
+		ServerAddress:  "server",
+		TracerIdentity: "client1",
+		Transport:      transport,
+		Compression:    CompressionGzip,
+	})
+	defer client.Close()
+	if client.compression != "" {
+		t.Fatalf("expected records to be sent uncompressed, got %q compression", client.compression)
+	}
+}
+
+func TestHTTPTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpBind := listener.Addr().String()
+	listener.Close()
+
+	server := NewTracingServer(TracingServerConfig{
+		ServerBind:       ":0",
+		HTTPBind:         httpBind,
+		OutputFile:       filepath.Join(dir, "trace_output.log"),
+		ShivizOutputFile: filepath.Join(dir, "shiviz_output.log"),
+	})
+	if err := server.Open(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept()
+
+	// the HTTP endpoints, mounted on an existing mux under a prefix
+	mux := http.NewServeMux()
+	mux.Handle("/tracing/", http.StripPrefix("/tracing", server.HTTPHandler()))
+	mounted := httptest.NewServer(mux)
+	defer mounted.Close()
+
+	for _, address := range []string{"http://" + httpBind, mounted.URL + "/tracing"} {
+		client := NewTracer(TracerConfig{
+			ServerAddress:  address,
+			TracerIdentity: "client1",
+		})
+		client.CreateTrace().RecordAction(TestAction{Foo: "foo"})
+		client.Close()
+	}
+
+	// the second tracer resumed from the clock recorded by the first one
+	if vc, err := server.getLastVC("client1"); err != nil || !cmp.Equal(vc, vclock.VClock{"client1": 4}) {
+		t.Fatalf("unexpected last clock %v (%v)", vc, err)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if outputs := readTraceOutputFile(t, server.Config.OutputFile); len(outputs) != 4 {
+		t.Fatalf("expected 4 records, got %v", outputs)
+	}
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// maxUDPRecordSize is the largest record datagram a tracer sends; larger
+// records are dropped (and accounted for as missing by the server, from
+// the sequence numbers of the records, see MissingRecords).
+const maxUDPRecordSize = 65507
+
+// udpTransport sends records to a tracing server as UDP datagrams.
+type udpTransport struct{}
+
+func (udpTransport) Dial(address string) (TransportConn, error) {
+	return newUDPRecorder(address)
+}
+
+func (udpTransport) Listen(address string, handler TransportHandler) (io.Closer, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	l := &udpListener{
+		conn:    conn,
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+	go l.serve()
+	return l, nil
+}
+
+// udpRecorder sends records to a tracing server as UDP datagrams, without
+// any acknowledgement: records can be lost, but never block the tracer.
+// The server accounts for the records lost from the gaps in their sequence
+// numbers, see RecordActionArg.Seq.
+type udpRecorder struct {
+	conn net.Conn
+}
+
+func newUDPRecorder(address string) (*udpRecorder, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &udpRecorder{conn: conn}, nil
+}
+
+func (r *udpRecorder) Send(arg RecordActionArg) error {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxUDPRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds the maximum datagram size", len(data))
+	}
+	_, err = r.conn.Write(data)
+	return err
+}
+
+// GetLastVC is not supported: records are sent fire-and-forget, so there
+// is no way to fetch the last clock of a previous tracer with the same
+// identity.
+func (r *udpRecorder) GetLastVC(identity string) (vclock.VClock, error) {
+	return nil, ErrLastVCUnsupported
+}
+
+func (r *udpRecorder) Close() error {
+	return r.conn.Close()
+}
+
+// udpListener receives record datagrams.
+type udpListener struct {
+	conn    net.PacketConn
+	handler TransportHandler
+	done    chan struct{}
+}
+
+// serve receives record datagrams until the UDP socket is closed.
+func (l *udpListener) serve() {
+	defer close(l.done)
+
+	buf := make([]byte, maxUDPRecordSize)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var arg RecordActionArg
+		if err := json.Unmarshal(buf[:n], &arg); err != nil {
+			log.Print("error decoding record datagram: ", err)
+			continue
+		}
+		if err := l.handler.RecordAction(arg); err != nil {
+			log.Print("error recording action: ", err)
+		}
+	}
+}
+
+func (l *udpListener) Close() error {
+	err := l.conn.Close()
+	<-l.done
+	return err
+}
@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+)
+
+// walCheckpointRecords is the number of records after which the output
+// files are synced and the write-ahead log is truncated, unless a sync
+// policy does it more often.
+const walCheckpointRecords = 1000
+
+// walHeader starts the write-ahead log: the records numbered up to Seq
+// were synced to the output files, when they had the sizes in Sizes.
+type walHeader struct {
+	Seq   uint64
+	Sizes map[string]int64
+}
+
+// walEntry is a record staged in the write-ahead log.
+type walEntry struct {
+	Seq    uint64
+	Record TraceRecord
+}
+
+// outputFileNames returns the names of the output files, partitions, their
+// signatures and ShiViz log included, but not the standard streams.
+func (tracingServer *TracingServer) outputFileNames() []string {
+	var fileNames []string
+	for _, fileName := range tracingServer.recordFileNames() {
+		if outputStream(fileName) != nil {
+			continue
+		}
+		fileNames = append(fileNames, fileName)
+		// the signatures are truncated with their output file, so that
+		// their chain resumes from its last record
+		if len(tracingServer.Config.SigningKey) > 0 {
+			fileNames = append(fileNames, signatureFileName(fileName))
+		}
+	}
+	if fileName := tracingServer.Config.ShivizOutputFile; fileName != "" && outputStream(fileName) == nil {
+		fileNames = append(fileNames, fileName)
+	}
+	return fileNames
+}
+
+// recoverWAL reads the write-ahead log left by a previous run, if any, and
+// truncates the output files back to their sizes at its last checkpoint,
+// dropping any partially written record. The records staged since then
+// are returned, to be written again.
+func (tracingServer *TracingServer) recoverWAL() (*walHeader, []walEntry, error) {
+	walFile, err := os.Open(tracingServer.Config.WALFile)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer walFile.Close()
+
+	var header *walHeader
+	var entries []walEntry
+	reader := bufio.NewReader(walFile)
+	for {
+		// a line without newline was staged partially, the tracer never
+		// got an acknowledgement for it
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if header == nil {
+			header = &walHeader{}
+			if err := json.Unmarshal(line, header); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if header == nil {
+		return nil, nil, nil
+	}
+
+	for fileName, size := range header.Sizes {
+		info, err := os.Stat(fileName)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if info.Size() > size {
+			log.Printf("recovering %s from the write-ahead log", fileName)
+			if err := os.Truncate(fileName, size); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return header, entries, nil
+}
+
+// openWAL writes the records recovered from the write-ahead log again, then
+// opens it for the records to come.
+func (tracingServer *TracingServer) openWAL(header *walHeader, entries []walEntry) error {
+	walFile, err := os.OpenFile(tracingServer.Config.WALFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	tracingServer.recordLock.Lock()
+	defer tracingServer.recordLock.Unlock()
+	if header != nil {
+		tracingServer.recordSeq = header.Seq
+	}
+	// the recovered records are staged already, and stay so until the
+	// checkpoint below
+	for _, entry := range entries {
+		tracingServer.lastVCs.store(entry.Record.TracerIdentity, entry.Record.VectorClock)
+		if err := tracingServer.writeRecord(entry.Record); err != nil {
+			walFile.Close()
+			return err
+		}
+	}
+	for _, sink := range tracingServer.sinks {
+		if err := sink.Flush(); err != nil {
+			walFile.Close()
+			return err
+		}
+	}
+	tracingServer.unsynced = 0
+	tracingServer.walFile = walFile
+	return tracingServer.checkpointWAL()
+}
+
+// stageRecord appends record to the write-ahead log, and syncs it. The
+// caller must hold recordLock.
+func (tracingServer *TracingServer) stageRecord(record TraceRecord) error {
+	data, err := json.Marshal(walEntry{Seq: record.Seq, Record: record})
+	if err != nil {
+		return err
+	}
+	if _, err := tracingServer.walFile.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return tracingServer.walFile.Sync()
+}
+
+// checkpointWAL truncates the write-ahead log, once the output files are
+// synced. The caller must hold recordLock.
+func (tracingServer *TracingServer) checkpointWAL() error {
+	header := walHeader{Seq: tracingServer.recordSeq, Sizes: make(map[string]int64)}
+	for _, fileName := range tracingServer.outputFileNames() {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			return err
+		}
+		header.Sizes[fileName] = info.Size()
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if err := tracingServer.walFile.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := tracingServer.walFile.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return tracingServer.walFile.Sync()
+}
+
+func (tracingServer *TracingServer) closeWAL() error {
+	if tracingServer.walFile == nil {
+		return nil
+	}
+	err := tracingServer.walFile.Close()
+	tracingServer.walFile = nil
+	return err
+}
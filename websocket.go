@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsScheme is the prefix of addresses referring to WebSocket endpoints.
+const wsScheme = "ws://"
+
+var wsUpgrader = websocket.Upgrader{
+	// browser-based tracers and viewers are served from arbitrary origins
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsConn adapts a WebSocket connection to the io.ReadWriteCloser expected
+// by RPC codecs. Every Write is sent as one text message, and reads span
+// message boundaries, which suits the JSON-RPC codecs: each JSON value is
+// written with a single Write, and decoded from a stream.
+type wsConn struct {
+	conn   *websocket.Conn
+	reader io.Reader
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, reader, err := c.conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = reader
+		}
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.conn.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// wsHandler serves the tracing server's RPC methods over WebSocket
+// connections, using JSON-RPC 1.0 as implemented by net/rpc/jsonrpc, e.g.:
+// 	{"method": "RPCProvider.RecordAction", "params": [{"TracerIdentity": "browser", ...}], "id": 1}
+// Note that, as with any JSON encoding of RecordActionArg, Record is the
+// base64 encoding of the JSON-encoded record.
+func wsHandler(rpcServer *rpc.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade already replied with an HTTP error
+			return
+		}
+		rpcServer.ServeCodec(jsonrpc.NewServerCodec(&wsConn{conn: conn}))
+	})
+}
+
+// wsTransport calls the net/rpc methods of RPCProvider as JSON-RPC over
+// WebSocket connections, see wsHandler.
+type wsTransport struct{}
+
+// Dial connects to the WebSocket endpoint at address, a ws:// URL.
+func (wsTransport) Dial(address string) (TransportConn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(address, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rpcConn{client: jsonrpc.NewClient(&wsConn{conn: conn})}, nil
+}
+
+func (wsTransport) Listen(address string, handler TransportHandler) (io.Closer, error) {
+	rpcServer, err := newRPCServer(handler)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	// hijacked WebSocket connections are not closed by the returned
+	// listener, but by their clients
+	return serveHTTP(listener, wsHandler(rpcServer), false), nil
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// RecordWriter writes records as an output file, e.g. to write a subset of
+// the records of other files, which NewRecordReader reads back.
+type RecordWriter struct {
+	w       io.Writer
+	binary  bool
+	encoder *json.Encoder
+}
+
+// NewRecordWriter returns a writer of records to w, in format (see
+// TracingServerConfig.OutputFormat), after writing the header of the file
+// (see OutputHeader).
+func NewRecordWriter(w io.Writer, format string) (*RecordWriter, error) {
+	writer := &RecordWriter{w: w, encoder: json.NewEncoder(w)}
+	switch format {
+	case "", OutputFormatJSON:
+	case OutputFormatBinary:
+		writer.binary = true
+	default:
+		return nil, errOutputFormat
+	}
+	if err := writer.Write(newOutputHeaderRecord()); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// Write writes record to the file.
+func (w *RecordWriter) Write(record TraceRecord) error {
+	if w.binary {
+		return writeDelimited(w.w, record.toProto())
+	}
+	return w.encoder.Encode(record)
+}
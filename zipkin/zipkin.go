@@ -0,0 +1,168 @@
+// Package zipkin converts the records of a tracing server to Zipkin v2
+// spans, in the JSON format Zipkin's API (POST /api/v2/spans) and UI
+// accept, see cmd/zipkin-export.
+//
+// Spans are inferred from the records of each tracer in each trace:
+//   - a pair of actions, like PutStart and PutEnd, is a span named after
+//     their common prefix (Put), enclosing the spans of the tracer's
+//     records in between, see Pair;
+//   - the generation of a token is a PRODUCER span, and its receptions
+//     are CONSUMER spans, children of it;
+//   - any other record is a span of its own, of no duration.
+//
+// Records carry no time, so spans are placed on a logical time axis
+// instead: the sum of the entries of the vector clock of their records, in
+// microseconds after Options.Start, which preserves the happens-before
+// order.
+package zipkin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/events"
+)
+
+// Span is a Zipkin v2 span.
+type Span struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind,omitempty"` // PRODUCER or CONSUMER, for tokens
+	Timestamp     int64             `json:"timestamp"`      // in microseconds since the Unix epoch
+	Duration      int64             `json:"duration"`       // in microseconds
+	LocalEndpoint Endpoint          `json:"localEndpoint"`
+	Annotations   []Annotation      `json:"annotations,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+// Endpoint is the service a span is from.
+type Endpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// Annotation is a timestamped event of a span.
+type Annotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// Pair is a pair of actions delimiting a span, by the suffixes of their
+// tags: a record tagged with a name and StartSuffix starts a span, which
+// the next record of the same tracer in the same trace tagged with the same
+// name and EndSuffix ends. Pairs nest.
+type Pair struct {
+	StartSuffix string
+	EndSuffix   string
+}
+
+// DefaultPairs are the pairs of actions delimiting spans if Options.Pairs
+// is nil.
+var DefaultPairs = []Pair{
+	{StartSuffix: "Start", EndSuffix: "End"},
+	{StartSuffix: "Begin", EndSuffix: "End"},
+	{StartSuffix: "Request", EndSuffix: "Response"},
+}
+
+// Options are the options of Convert.
+type Options struct {
+	Start time.Time // the start of the logical time axis
+	Pairs []Pair    // DefaultPairs if nil
+}
+
+// openSpan is a span started by a paired action, not ended yet.
+type openSpan struct {
+	index int    // in the spans converted
+	end   string // the tag ending the span
+}
+
+// Convert returns the spans inferred from records, in the order of the
+// records starting them.
+func Convert(records []tracing.TraceRecord, options Options) []Span {
+	pairs := options.Pairs
+	if pairs == nil {
+		pairs = DefaultPairs
+	}
+	start := options.Start.UnixNano() / int64(time.Microsecond)
+
+	type lane struct {
+		traceID uint64
+		tracer  string
+	}
+	open := make(map[lane][]openSpan) // stacks of the spans open in each lane
+	spans := []Span{}
+	for _, record := range records {
+		l := lane{traceID: record.TraceID, tracer: record.TracerIdentity}
+		timestamp := start + int64(events.LogicalTime(record.VectorClock))
+		stack := open[l]
+
+		// the end of the innermost open span of the lane it matches
+		ended := false
+		for i := len(stack) - 1; i >= 0; i-- {
+			if record.Tag == stack[i].end {
+				span := &spans[stack[i].index]
+				span.Duration = timestamp - span.Timestamp
+				span.Annotations = append(span.Annotations, Annotation{Timestamp: timestamp, Value: record.Tag})
+				addTags(span.Tags, record, "end.")
+				open[l] = stack[:i]
+				ended = true
+				break
+			}
+		}
+		if ended {
+			continue
+		}
+
+		span := Span{
+			TraceID:       fmt.Sprintf("%016x", record.TraceID),
+			ID:            spanID(events.RecordID(record)),
+			Name:          record.Tag,
+			Timestamp:     timestamp,
+			LocalEndpoint: Endpoint{ServiceName: record.TracerIdentity},
+			Tags:          make(map[string]string),
+		}
+		if len(stack) > 0 {
+			span.ParentID = spans[stack[len(stack)-1].index].ID
+		}
+		switch record.Tag {
+		case "GenerateTokenTrace":
+			span.Kind = "PRODUCER"
+		case "ReceiveTokenTrace":
+			span.Kind = "CONSUMER"
+			if source, ok := events.TokenSource(record); ok {
+				span.ParentID = spanID(source)
+			}
+		}
+		addTags(span.Tags, record, "")
+		spans = append(spans, span)
+
+		for _, pair := range pairs {
+			if name := strings.TrimSuffix(record.Tag, pair.StartSuffix); name != record.Tag && name != "" {
+				spans[len(spans)-1].Name = name
+				spans[len(spans)-1].Annotations = []Annotation{{Timestamp: timestamp, Value: record.Tag}}
+				open[l] = append(stack, openSpan{index: len(spans) - 1, end: name + pair.EndSuffix})
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// addTags adds the contents of record to tags, under keys prefixed with
+// prefix.
+func addTags(tags map[string]string, record tracing.TraceRecord, prefix string) {
+	clock, _ := json.Marshal(record.VectorClock)
+	tags[prefix+"tracing.tracer_identity"] = record.TracerIdentity
+	tags[prefix+"tracing.vector_clock"] = string(clock)
+	tags[prefix+"tracing.body"] = string(record.Body)
+}
+
+// spanID returns the span ID of the event id (see events.ID), in
+// hexadecimal.
+func spanID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
@@ -0,0 +1,50 @@
+package zipkin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DistributedClocks/tracing"
+	"github.com/DistributedClocks/tracing/internal/tracetest"
+)
+
+type PutStart struct {
+	Key string
+}
+
+type PutEnd struct {
+	Key string
+}
+
+type TestAction struct {
+	Foo string
+}
+
+func TestConvert(t *testing.T) {
+	records := tracetest.Run(t, func(client1, client2 *tracing.Tracer) {
+		trace := client1.CreateTrace()
+		trace.RecordAction(PutStart{Key: "k"})
+		trace.RecordAction(TestAction{Foo: "foo"})
+		client2.ReceiveToken(trace.GenerateToken())
+		trace.RecordAction(PutEnd{Key: "k"})
+	})
+
+	spans := Convert(records, Options{Start: time.Unix(1000, 0)})
+	if len(spans) != 5 {
+		t.Fatalf("expected 5 spans, got %v", spans)
+	}
+	create, put, action, generate, receive := spans[0], spans[1], spans[2], spans[3], spans[4]
+	if create.Name != "CreateTrace" || create.ParentID != "" || create.Timestamp != 1000000000+1 {
+		t.Fatalf("unexpected span %v", create)
+	}
+	// PutStart is at {client1: 2}, PutEnd at {client1: 5}
+	if put.Name != "Put" || put.Duration != 3 || len(put.Annotations) != 2 || put.Tags["end.tracing.body"] != `{"Key":"k"}` {
+		t.Fatalf("unexpected span %v", put)
+	}
+	if action.ParentID != put.ID || generate.ParentID != put.ID || generate.Kind != "PRODUCER" {
+		t.Fatalf("expected the spans within Put to be its children, got %v and %v", action, generate)
+	}
+	if receive.Kind != "CONSUMER" || receive.ParentID != generate.ID || receive.LocalEndpoint.ServiceName != "client2" {
+		t.Fatalf("expected the reception to be a child of the generation %s, got %v", generate.ID, receive)
+	}
+}